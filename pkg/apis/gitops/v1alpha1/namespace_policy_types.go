@@ -0,0 +1,120 @@
+package v1alpha1
+
+import (
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/matcher"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NamespacePoliciesFileName default name of the namespace policies file
+	NamespacePoliciesFileName = "namespace-policies.yaml"
+
+	// KindNamespacePolicies the kind
+	KindNamespacePolicies = "NamespacePolicies"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NamespacePolicies represents the default labels, annotations and resources applied to namespaces
+//
+// +k8s:openapi-gen=true
+type NamespacePolicies struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the desired state of the NamespacePolicies from the client
+	// +optional
+	Spec NamespacePoliciesSpec `json:"spec"`
+}
+
+// NamespacePoliciesList contains a list of NamespacePolicies
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type NamespacePoliciesList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacePolicies `json:"items"`
+}
+
+// NamespacePoliciesSpec defines the desired state of NamespacePolicies.
+type NamespacePoliciesSpec struct {
+	// Policies the policies applied to the namespaces which match them. If more than one policy matches a namespace
+	// they are merged in order with later policies overriding earlier ones
+	Policies []NamespacePolicy `json:"policies,omitempty"`
+
+	// RequirePolicy if enabled every namespace must match at least one policy
+	RequirePolicy bool `json:"requirePolicy,omitempty"`
+}
+
+// NamespacePolicy the defaults for the namespaces which match the namespace patterns
+type NamespacePolicy struct {
+	// Name the name of the policy
+	Name string `json:"name,omitempty"`
+
+	// Namespaces the regular expressions of the namespace names the policy applies to. If empty all namespaces match
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Excludes the regular expressions of the namespace names the policy does not apply to
+	Excludes []string `json:"excludes,omitempty"`
+
+	// Labels the labels added to the Namespace such as 'pod-security.kubernetes.io/enforce' or a team owner
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations the annotations added to the Namespace
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ResourceQuota the spec of the ResourceQuota created in the namespace
+	ResourceQuota *corev1.ResourceQuotaSpec `json:"resourceQuota,omitempty"`
+
+	// LimitRange the spec of the LimitRange created in the namespace
+	LimitRange *corev1.LimitRangeSpec `json:"limitRange,omitempty"`
+
+	// NetworkPolicy the default deny NetworkPolicy created in the namespace
+	NetworkPolicy *NamespaceNetworkPolicy `json:"networkPolicy,omitempty"`
+}
+
+// NamespaceNetworkPolicy the traffic denied by default to the pods in a namespace
+type NamespaceNetworkPolicy struct {
+	// DenyIngress denies all ingress traffic to the pods in the namespace unless allowed by other NetworkPolicies
+	DenyIngress bool `json:"denyIngress,omitempty"`
+
+	// DenyEgress denies all egress traffic from the pods in the namespace unless allowed by other NetworkPolicies
+	DenyEgress bool `json:"denyEgress,omitempty"`
+}
+
+// Matcher returns a matcher for the namespace names of the policy
+func (p *NamespacePolicy) Matcher() (func(ns string) bool, error) {
+	matcher := matcher.Matcher{}
+	var err error
+	matcher.Includes, err = matcher.ToRegexs(p.Namespaces)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create namespaces regex")
+	}
+	matcher.Excludes, err = matcher.ToRegexs(p.Excludes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create exclude regex")
+	}
+	return matcher.Matches, nil
+}
+
+// PoliciesFor returns the policies which match the namespace in order
+func (s *NamespacePoliciesSpec) PoliciesFor(ns string) ([]*NamespacePolicy, error) {
+	var answer []*NamespacePolicy
+	for i := range s.Policies {
+		p := &s.Policies[i]
+		m, err := p.Matcher()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid namespace policy %s", p.Name)
+		}
+		if m(ns) {
+			answer = append(answer, p)
+		}
+	}
+	return answer, nil
+}
@@ -52,4 +52,10 @@ type PipelineCatalogSource struct {
 	Label  string `json:"label,omitempty" protobuf:"bytes,2,opt,name=label"`
 	GitURL string `json:"gitUrl,omitempty" protobuf:"bytes,3,opt,name=gitUrl"`
 	GitRef string `json:"gitRef,omitempty" protobuf:"bytes,4,opt,name=gitRef"`
+
+	// GitTag the tag the GitRef commit SHA was resolved from by 'pipelinecatalog upgrade'
+	GitTag string `json:"gitTag,omitempty" protobuf:"bytes,5,opt,name=gitTag"`
+
+	// VersionConstraint an optional semver constraint such as '^1.2' used by 'pipelinecatalog upgrade' to choose the tag
+	VersionConstraint string `json:"versionConstraint,omitempty" protobuf:"bytes,6,opt,name=versionConstraint"`
 }
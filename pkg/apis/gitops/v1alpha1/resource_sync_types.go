@@ -0,0 +1,91 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ResourceSyncFileName default name of the resource sync file
+	ResourceSyncFileName = "resource-sync.yaml"
+
+	// KindResourceSync the kind
+	KindResourceSync = "ResourceSync"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResourceSync represents the resources to keep in sync across namespaces
+//
+// +k8s:openapi-gen=true
+type ResourceSync struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the desired state of the ResourceSync from the client
+	// +optional
+	Spec ResourceSyncSpec `json:"spec"`
+}
+
+// ResourceSyncList contains a list of ResourceSync
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ResourceSyncList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceSync `json:"items"`
+}
+
+// ResourceSyncSpec defines the desired state of ResourceSync.
+type ResourceSyncSpec struct {
+	// Rules the rules of which resources to copy to which namespaces
+	Rules []ResourceSyncRule `json:"rules,omitempty"`
+}
+
+// ResourceSyncRule copies the source resources to the target namespaces
+type ResourceSyncRule struct {
+	// Name the unique name of the rule which is used to track the copies it creates
+	Name string `json:"name"`
+
+	// Source the resources to copy
+	Source ResourceSyncSource `json:"source"`
+
+	// Targets the namespaces to copy the resources to
+	Targets ResourceSyncTargets `json:"targets"`
+}
+
+// ResourceSyncSource the resources to copy
+type ResourceSyncSource struct {
+	// Group the API group of the resources such as 'apps'
+	Group string `json:"group,omitempty"`
+
+	// Version the API version of the resources which defaults to 'v1'
+	Version string `json:"version,omitempty"`
+
+	// Resource the plural resource name such as 'secrets' which defaults to 'configmaps'
+	Resource string `json:"resource,omitempty"`
+
+	// Namespace the namespace of the resources which defaults to the current namespace
+	Namespace string `json:"namespace,omitempty"`
+
+	// Selector the label selector of the resources
+	Selector string `json:"selector,omitempty"`
+
+	// Name the name of the resource to copy instead of a selector
+	Name string `json:"name,omitempty"`
+}
+
+// ResourceSyncTargets the namespaces to copy to
+type ResourceSyncTargets struct {
+	// Namespaces the names of the namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Selector the label selector of the namespaces
+	Selector string `json:"selector,omitempty"`
+
+	// Previews if enabled copy to all the preview namespaces
+	Previews bool `json:"previews,omitempty"`
+}
@@ -0,0 +1,99 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ServiceAccountIdentitiesFileName default name of the service account identities file
+	ServiceAccountIdentitiesFileName = "service-account-identities.yaml"
+
+	// KindServiceAccountIdentities the kind
+	KindServiceAccountIdentities = "ServiceAccountIdentities"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceAccountIdentities represents the cloud IAM identities of ServiceAccounts
+//
+// +k8s:openapi-gen=true
+type ServiceAccountIdentities struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the desired state of the ServiceAccountIdentities from the client
+	// +optional
+	Spec ServiceAccountIdentitiesSpec `json:"spec"`
+}
+
+// ServiceAccountIdentitiesList contains a list of ServiceAccountIdentities
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ServiceAccountIdentitiesList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceAccountIdentities `json:"items"`
+}
+
+// ServiceAccountIdentitiesSpec defines the desired state of ServiceAccountIdentities.
+type ServiceAccountIdentitiesSpec struct {
+	// ServiceAccounts the ServiceAccounts and their cloud identities
+	ServiceAccounts []ServiceAccountIdentity `json:"serviceAccounts,omitempty"`
+}
+
+// ServiceAccountIdentity the cloud identities of a ServiceAccount
+type ServiceAccountIdentity struct {
+	// Name the name of the ServiceAccount
+	Name string `json:"name" validate:"nonzero"`
+
+	// Namespace the namespace of the ServiceAccount. If empty the ServiceAccount in any namespace matches
+	Namespace string `json:"namespace,omitempty"`
+
+	// Identity the name of the cloud identity for the provider of the cluster in the requirements. For gke it is the
+	// Google service account ID and for eks it is the IAM role name. It is ignored if the identity of the provider is specified
+	Identity string `json:"identity,omitempty"`
+
+	// GCP the Google service account used via GKE Workload Identity
+	GCP *GCPIdentity `json:"gcp,omitempty"`
+
+	// AWS the IAM role used via EKS IAM Roles for Service Accounts
+	AWS *AWSIdentity `json:"aws,omitempty"`
+
+	// Azure the managed identity or application used via Azure Workload Identity
+	Azure *AzureIdentity `json:"azure,omitempty"`
+}
+
+// GCPIdentity the Google service account of a ServiceAccount
+type GCPIdentity struct {
+	// ServiceAccount the email of the Google service account. If it does not contain '@' it is the account ID
+	// and the email is created using the project of the cluster in the requirements
+	ServiceAccount string `json:"serviceAccount"`
+}
+
+// AWSIdentity the IAM role of a ServiceAccount
+type AWSIdentity struct {
+	// RoleARN the ARN of the IAM role
+	RoleARN string `json:"roleArn,omitempty"`
+
+	// RoleName the name of the IAM role if no ARN is specified. The ARN is created using the
+	// account ID of the cluster which is stored as the project in the requirements
+	RoleName string `json:"roleName,omitempty"`
+}
+
+// AzureIdentity the Azure AD application or managed identity of a ServiceAccount
+type AzureIdentity struct {
+	// ClientID the client ID of the application or user assigned managed identity
+	ClientID string `json:"clientId"`
+
+	// TenantID the optional tenant ID if it differs from the tenant of the cluster
+	TenantID string `json:"tenantId,omitempty"`
+}
+
+// Matches returns true if the identity is for the ServiceAccount with the given name and namespace
+func (i *ServiceAccountIdentity) Matches(name, ns string) bool {
+	return i.Name == name && (i.Namespace == "" || i.Namespace == ns)
+}
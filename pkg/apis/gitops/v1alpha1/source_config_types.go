@@ -152,6 +152,23 @@ type RepositoryGroup struct {
 
 	// Name optional field to distinguish repository groups between for example different teams. Not used by jx gitops
 	Name string `json:"name,omitempty"`
+
+	// Preview optional template used to generate preview environments for pull requests on repositories in this group
+	Preview *PreviewTemplate `json:"preview,omitempty"`
+}
+
+// PreviewTemplate the template used to generate the helmfile of a preview environment for a pull request
+type PreviewTemplate struct {
+	// Helmfile the helmfile snippet rendered for each preview. It is a go template which can use
+	// .Owner, .Repository, .PullRequest and .Namespace
+	Helmfile string `json:"helmfile,omitempty"`
+
+	// Namespace the go template of the preview namespace name.
+	// Defaults to 'jx-{{ .Owner }}-{{ .Repository }}-pr-{{ .PullRequest }}'
+	Namespace string `json:"namespace,omitempty"`
+
+	// TTL the time to live of the preview such as '72h' after which it can be garbage collected
+	TTL string `json:"ttl,omitempty"`
 }
 
 // Repository the name of the repository to import and the optional scheduler
@@ -0,0 +1,284 @@
+package chartsigning
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+const (
+	// SignatureTagSuffix the suffix of the tag used to store the signature of an artifact
+	SignatureTagSuffix = ".sig"
+
+	// SimpleSigningMediaType the media type of the signed payload
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// SignatureAnnotation the annotation on the payload layer containing the base64 encoded signature
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// SignatureType the type of the signed payload
+	SignatureType = "cosign container image signature"
+
+	configMediaType = "application/vnd.oci.image.config.v1+json"
+)
+
+// RegistryOptions the options for connecting to an OCI registry
+type RegistryOptions struct {
+	// Username the optional user name to access the registry
+	Username string
+
+	// Password the optional password to access the registry
+	Password string
+
+	// RegistryConfigFile the optional docker config file containing the registry credentials
+	RegistryConfigFile string
+
+	// PlainHTTP use HTTP rather than HTTPS to access the registry
+	PlainHTTP bool
+}
+
+// Payload the cosign compatible simple signing payload
+type Payload struct {
+	Critical Critical          `json:"critical"`
+	Optional map[string]string `json:"optional"`
+}
+
+// Critical the critical section of the payload
+type Critical struct {
+	Identity Identity `json:"identity"`
+	Image    Image    `json:"image"`
+	Type     string   `json:"type"`
+}
+
+// Identity the identity of the signed artifact
+type Identity struct {
+	DockerReference string `json:"docker-reference"`
+}
+
+// Image the digest of the signed artifact
+type Image struct {
+	DockerManifestDigest string `json:"docker-manifest-digest"`
+}
+
+// NewRepository creates a remote repository for the given reference such as 'myregistry.com/charts/myapp'
+func NewRepository(reference string, o *RegistryOptions) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(strings.TrimPrefix(reference, "oci://"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse OCI repository %s", reference)
+	}
+	repo.PlainHTTP = o.PlainHTTP
+
+	client := &auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.NewCache(),
+	}
+	host := repo.Reference.Registry
+	if o.Username != "" && o.Password != "" {
+		client.Credential = auth.StaticCredential(host, auth.Credential{
+			Username: o.Username,
+			Password: o.Password,
+		})
+	} else if o.RegistryConfigFile != "" {
+		exists, err := files.FileExists(o.RegistryConfigFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check if file exists %s", o.RegistryConfigFile)
+		}
+		if exists {
+			store, err := credentials.NewFileStore(o.RegistryConfigFile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load registry config %s", o.RegistryConfigFile)
+			}
+			client.Credential = credentials.Credential(store)
+		}
+	}
+	repo.Client = client
+	return repo, nil
+}
+
+// OCITag returns the OCI tag of the given chart version. Helm replaces + with _ as + is not allowed in OCI tags
+func OCITag(version string) string {
+	return strings.ReplaceAll(version, "+", "_")
+}
+
+// SignatureTag returns the tag used to store the signature of the artifact with the given digest
+func SignatureTag(d digest.Digest) string {
+	return d.Algorithm().String() + "-" + d.Encoded() + SignatureTagSuffix
+}
+
+// Sign signs the artifact with the given tag storing the signature in the registry as a cosign
+// compatible signature manifest. Returns the digest of the signed artifact
+func Sign(ctx context.Context, repo *remote.Repository, tag string, key *ecdsa.PrivateKey) (digest.Digest, error) {
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s:%s", repo.Reference.String(), tag)
+	}
+
+	payload := Payload{
+		Critical: Critical{
+			Identity: Identity{DockerReference: repo.Reference.Registry + "/" + repo.Reference.Repository},
+			Image:    Image{DockerManifestDigest: desc.Digest.String()},
+			Type:     SignatureType,
+		},
+	}
+	payloadData, err := json.Marshal(&payload)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal signature payload")
+	}
+	hash := sha256.Sum256(payloadData)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign payload")
+	}
+
+	configDesc, err := oras.PushBytes(ctx, repo, configMediaType, []byte("{}"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to push signature config")
+	}
+	layerDesc, err := oras.PushBytes(ctx, repo, SimpleSigningMediaType, payloadData)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to push signature payload")
+	}
+	layerDesc.Annotations = map[string]string{
+		SignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	}
+	manifestData, err := json.Marshal(&manifest)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal signature manifest")
+	}
+	_, err = oras.TagBytes(ctx, repo, ocispec.MediaTypeImageManifest, manifestData, SignatureTag(desc.Digest))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to push signature for %s", desc.Digest.String())
+	}
+	return desc.Digest, nil
+}
+
+// Verify verifies the artifact with the given tag has a signature which matches the public key.
+// Returns the digest of the verified artifact
+func Verify(ctx context.Context, repo *remote.Repository, tag string, key *ecdsa.PublicKey) (digest.Digest, error) {
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s:%s", repo.Reference.String(), tag)
+	}
+	sigTag := SignatureTag(desc.Digest)
+	_, manifestData, err := oras.FetchBytes(ctx, repo, sigTag, oras.DefaultFetchBytesOptions)
+	if err != nil {
+		return "", errors.Wrapf(err, "no signature found for %s:%s", repo.Reference.String(), tag)
+	}
+	manifest := &ocispec.Manifest{}
+	err = json.Unmarshal(manifestData, manifest)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse signature manifest %s", sigTag)
+	}
+
+	for _, layer := range manifest.Layers {
+		sigText := layer.Annotations[SignatureAnnotation]
+		if layer.MediaType != SimpleSigningMediaType || sigText == "" {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(sigText)
+		if err != nil {
+			continue
+		}
+		_, payloadData, err := oras.FetchBytes(ctx, repo.Blobs(), layer.Digest.String(), oras.DefaultFetchBytesOptions)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to fetch signature payload %s", layer.Digest.String())
+		}
+		hash := sha256.Sum256(payloadData)
+		if !ecdsa.VerifyASN1(key, hash[:], sig) {
+			continue
+		}
+		payload := &Payload{}
+		err = json.Unmarshal(payloadData, payload)
+		if err != nil {
+			continue
+		}
+		if payload.Critical.Image.DockerManifestDigest == desc.Digest.String() {
+			return desc.Digest, nil
+		}
+	}
+	return "", errors.Errorf("no valid signature found for %s:%s with digest %s", repo.Reference.String(), tag, desc.Digest.String())
+}
+
+// LoadPrivateKey loads a PEM encoded ECDSA private key
+func LoadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	block, err := loadPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "EC PRIVATE KEY" {
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse EC private key %s", path)
+		}
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse private key %s", path)
+	}
+	key, ok := k.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("the private key %s is not an ECDSA key", path)
+	}
+	return key, nil
+}
+
+// LoadPublicKey loads a PEM encoded ECDSA public key or the public key of a private key file
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	block, err := loadPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		key, err := LoadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PublicKey, nil
+	}
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse public key %s", path)
+	}
+	key, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("the public key %s is not an ECDSA key", path)
+	}
+	return key, nil
+}
+
+func loadPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read key file %s", path)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("no PEM data found in key file %s", path)
+	}
+	return block, nil
+}
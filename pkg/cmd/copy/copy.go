@@ -25,6 +25,13 @@ import (
 var (
 	cmdLong = templates.LongDesc(`
 		Copies kubernetes resources (by default confimaps) from a namespace to the current namespace
+
+		If --config is specified then the resource sync file (usually '.jx/gitops/resource-sync.yaml') is reconciled
+		instead. Each rule copies the resources matching its source to the target namespaces which can be specified by
+		name, label selector or all the preview namespaces. Copies are labelled and annotated with the resource sync file
+		and rule that created them so that changed copies are updated and copies which are no longer required are deleted,
+		including the copies of rules since removed from the file. Only the copies labelled with the name of the resource
+		sync file are deleted so give each resource sync file in a cluster a unique metadata.name.
 `)
 
 	cmdExample = templates.Examples(`
@@ -36,6 +43,9 @@ var (
 
 		# copies resources matching a selector and kind
 		%[1]s copy --kind ingresses -l mylabel=something --to=foo
+
+		# reconciles the resources in the resource sync file
+		%[1]s copy --config .jx/gitops/resource-sync.yaml
 	`)
 )
 
@@ -49,8 +59,10 @@ type Options struct {
 	Selector        string
 	Name            string
 	Query           string
+	Config          string
 	CreateNamespace bool
 	Count           int
+	SyncResults     []*SyncResult
 	DynamicClient   dynamic.Interface
 	KubeClient      kubernetes.Interface
 }
@@ -76,12 +88,16 @@ func NewCmdCopy() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.Kind, "kind", "k", "configmaps", "the kind name")
 	cmd.Flags().StringVarP(&o.Selector, "selector", "l", "", "the label selector to find the resources to copy")
 	cmd.Flags().StringVarP(&o.Name, "name", "", "", "the name of the resource to copy instead of a selector")
+	cmd.Flags().StringVarP(&o.Config, "config", "", "", "the resource sync file to reconcile such as '.jx/gitops/resource-sync.yaml' instead of copying a single selector or name")
 	cmd.Flags().BoolVarP(&o.CreateNamespace, "create-namespace", "", false, "create the to Namespace if it does not already exist")
 	return cmd, o
 }
 
 // Run implements the command
 func (o *Options) Run() error {
+	if o.Config != "" {
+		return o.runSync()
+	}
 	if o.ToNamespace == "" {
 		return options.MissingOption("to")
 	}
@@ -157,3 +173,16 @@ func (o *Options) Run() error {
 	}
 	return nil
 }
+
+func (o *Options) runSync() error {
+	var err error
+	o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create kube client")
+	}
+	o.DynamicClient, err = kube.LazyCreateDynamicClient(o.DynamicClient)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dynamic client")
+	}
+	return o.Sync()
+}
@@ -1,13 +1,19 @@
 package copy_test
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/copy"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	dynfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -75,3 +81,105 @@ func TestCmdCopy(t *testing.T) {
 		t.Logf("query %s has copied %d resources\n", o.Query, o.Count)
 	}
 }
+
+func TestCmdCopySync(t *testing.T) {
+	ns := "jx"
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	newConfigMap := func(namespace, value, owner string, annotations map[string]string) *corev1.ConfigMap {
+		labels := map[string]string{"sync": "ca"}
+		if owner != "" {
+			labels[copy.SyncedLabel] = "true"
+			labels[copy.SyncedByLabel] = owner
+		}
+		return &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "ca-certs",
+				Namespace:   namespace,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Data: map[string]string{
+				"ca.crt": value,
+			},
+		}
+	}
+	synced := map[string]string{copy.SyncedFromAnnotation: "ca/jx/ca-certs"}
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	apps := map[string]string{"team": "apps"}
+
+	_, o := copy.NewCmdCopy()
+	o.Namespace = ns
+	o.Config = filepath.Join("testdata", "resource-sync.yaml")
+	o.KubeClient = fake.NewSimpleClientset(
+		newNamespace(ns, nil),
+		newNamespace("jx-staging", apps),
+		newNamespace("jx-production", apps),
+		newNamespace("jx-myorg-myrepo-pr-1", nil),
+		newNamespace("other", nil),
+		newNamespace("another", nil),
+	)
+	o.DynamicClient = dynfake.NewSimpleDynamicClient(scheme,
+		newConfigMap(ns, "new", "", nil),
+		newConfigMap("jx-staging", "old", "", synced),
+		newConfigMap("jx-production", "manual", "", nil),
+		newConfigMap("other", "old", "resource-sync", synced),
+		newConfigMap("another", "old", "another-sync", synced),
+		&corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "legacy",
+				Namespace:   "jx-staging",
+				Labels:      map[string]string{copy.SyncedLabel: "true", copy.SyncedByLabel: "resource-sync"},
+				Annotations: map[string]string{copy.SyncedFromAnnotation: "removed/jx/legacy"},
+			},
+		},
+	)
+
+	err := o.Run()
+	require.NoError(t, err, "failed to sync resources")
+	require.Len(t, o.SyncResults, 2)
+	assert.Equal(t, &copy.SyncResult{Rule: "ca", Created: 1, Updated: 1, Deleted: 1, Skipped: 1}, o.SyncResults[0])
+	assert.Equal(t, &copy.SyncResult{Rule: "removed", Deleted: 1}, o.SyncResults[1], "should delete the copies of a rule removed from the resource sync file")
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	ctx := context.TODO()
+	for _, targetNS := range []string{"jx-staging", "jx-myorg-myrepo-pr-1"} {
+		r, err := o.DynamicClient.Resource(gvr).Namespace(targetNS).Get(ctx, "ca-certs", metav1.GetOptions{})
+		require.NoError(t, err, "failed to find copy in namespace %s", targetNS)
+		value, _, _ := unstructured.NestedString(r.Object, "data", "ca.crt")
+		assert.Equal(t, "new", value, "copy in namespace %s", targetNS)
+		assert.Equal(t, "ca/jx/ca-certs", r.GetAnnotations()[copy.SyncedFromAnnotation], "copy in namespace %s", targetNS)
+		assert.Equal(t, "true", r.GetLabels()[copy.SyncedLabel], "copy in namespace %s", targetNS)
+		assert.Equal(t, "resource-sync", r.GetLabels()[copy.SyncedByLabel], "copy in namespace %s", targetNS)
+	}
+
+	_, err = o.DynamicClient.Resource(gvr).Namespace("jx-staging").Get(ctx, "legacy", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "should have deleted the copy of the removed rule")
+
+	r, err := o.DynamicClient.Resource(gvr).Namespace("jx-production").Get(ctx, "ca-certs", metav1.GetOptions{})
+	require.NoError(t, err, "failed to find ConfigMap in namespace jx-production")
+	value, _, _ := unstructured.NestedString(r.Object, "data", "ca.crt")
+	assert.Equal(t, "manual", value, "should not have modified a resource the rule did not create")
+
+	_, err = o.DynamicClient.Resource(gvr).Namespace("other").Get(ctx, "ca-certs", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "should have deleted the orphaned copy")
+
+	_, err = o.DynamicClient.Resource(gvr).Namespace("another").Get(ctx, "ca-certs", metav1.GetOptions{})
+	assert.NoError(t, err, "should not have deleted the copy owned by another resource sync file")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to sync resources again")
+	require.Len(t, o.SyncResults, 1)
+	assert.Equal(t, &copy.SyncResult{Rule: "ca", Unchanged: 2, Skipped: 1}, o.SyncResults[0])
+}
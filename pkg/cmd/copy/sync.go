@@ -0,0 +1,363 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/previews"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// SyncedFromAnnotation the annotation added to the copies created by a resource sync rule to track the rule
+	// and source resource so that they can be updated or removed
+	SyncedFromAnnotation = "gitops.jenkins-x.io/synced-from"
+
+	// SyncedLabel the label added to the copies created by a resource sync rule so that the copies of rules which
+	// have been removed from the resource sync file can be found and deleted
+	SyncedLabel = "gitops.jenkins-x.io/synced"
+
+	// SyncedByLabel the label added to the copies with the name of the resource sync file which created them so that
+	// only the copies owned by the current resource sync file are deleted
+	SyncedByLabel = "gitops.jenkins-x.io/synced-by"
+)
+
+var info = termcolor.ColorInfo
+
+// SyncResult the summary of reconciling a resource sync rule
+type SyncResult struct {
+	Rule      string
+	Created   int
+	Updated   int
+	Unchanged int
+	Deleted   int
+	Skipped   int
+}
+
+// LoadResourceSync loads the resource sync configuration file
+func LoadResourceSync(path string) (*v1alpha1.ResourceSync, error) {
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return nil, errors.Errorf("resource sync file %s does not exist", path)
+	}
+	config := &v1alpha1.ResourceSync{}
+	err = yamls.LoadFile(path, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	if config.Name == "" {
+		config.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	names := map[string]bool{}
+	for i := range config.Spec.Rules {
+		name := config.Spec.Rules[i].Name
+		if name == "" {
+			return nil, errors.Errorf("rule %d in %s has no name", i+1, path)
+		}
+		if strings.Contains(name, "/") {
+			return nil, errors.Errorf("rule name %s in %s must not contain '/'", name, path)
+		}
+		if names[name] {
+			return nil, errors.Errorf("duplicate rule name %s in %s", name, path)
+		}
+		names[name] = true
+	}
+	return config, nil
+}
+
+// Sync reconciles the resource sync configuration creating, updating and deleting copies
+func (o *Options) Sync() error {
+	config, err := LoadResourceSync(o.Config)
+	if err != nil {
+		return err
+	}
+
+	o.SyncResults = nil
+	results := map[string]*SyncResult{}
+	desired := map[string]bool{}
+	gvrs := map[schema.GroupVersionResource]bool{
+		{Version: "v1", Resource: "configmaps"}: true,
+		{Version: "v1", Resource: "secrets"}:    true,
+	}
+	for i := range config.Spec.Rules {
+		rule := &config.Spec.Rules[i]
+		result, err := o.syncRule(config.Name, rule, desired, gvrs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sync rule %s", rule.Name)
+		}
+		o.SyncResults = append(o.SyncResults, result)
+		results[rule.Name] = result
+	}
+
+	err = o.removeOrphans(config.Name, gvrs, desired, results)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove orphaned copies")
+	}
+
+	for _, r := range o.SyncResults {
+		log.Logger().Infof("rule %s: created %d, updated %d, unchanged %d, deleted %d, skipped %d",
+			info(r.Rule), r.Created, r.Updated, r.Unchanged, r.Deleted, r.Skipped)
+	}
+	return nil
+}
+
+func (o *Options) syncRule(owner string, rule *v1alpha1.ResourceSyncRule, desired map[string]bool, gvrs map[schema.GroupVersionResource]bool) (*SyncResult, error) {
+	result := &SyncResult{Rule: rule.Name}
+	src := &rule.Source
+	if src.Selector == "" && src.Name == "" {
+		return nil, errors.Errorf("the source of rule %s has no selector or name", rule.Name)
+	}
+	version := src.Version
+	if version == "" {
+		version = "v1"
+	}
+	resource := src.Resource
+	if resource == "" {
+		resource = "configmaps"
+	}
+	sourceNS := src.Namespace
+	if sourceNS == "" {
+		sourceNS = o.Namespace
+	}
+	gvr := schema.GroupVersionResource{Group: src.Group, Version: version, Resource: resource}
+	gvrs[gvr] = true
+	resourceName := gvrName(gvr)
+
+	ctx := context.Background()
+	listOptions := metav1.ListOptions{LabelSelector: src.Selector}
+	if src.Name != "" {
+		listOptions.FieldSelector = "metadata.name=" + src.Name
+	}
+	list, err := o.DynamicClient.Resource(gvr).Namespace(sourceNS).List(ctx, listOptions)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to find %s in namespace %s", resourceName, sourceNS)
+	}
+	var sources []unstructured.Unstructured
+	if list != nil {
+		for i := range list.Items {
+			// lets not copy copies
+			if list.Items[i].GetAnnotations()[SyncedFromAnnotation] == "" {
+				sources = append(sources, list.Items[i])
+			}
+		}
+	}
+
+	namespaces, err := o.targetNamespaces(&rule.Targets, sourceNS)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ns := range namespaces {
+		for i := range sources {
+			copied := toCopy(&sources[i], owner, rule.Name, ns)
+			desired[copyKey(gvr, ns, copied.GetName())] = true
+
+			client := o.DynamicClient.Resource(gvr).Namespace(ns)
+			existing, err := client.Get(ctx, copied.GetName(), metav1.GetOptions{})
+			if err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, errors.Wrapf(err, "failed to get %s %s in namespace %s", resourceName, copied.GetName(), ns)
+				}
+				_, err = client.Create(ctx, copied, metav1.CreateOptions{})
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to create %s %s in namespace %s", resourceName, copied.GetName(), ns)
+				}
+				log.Logger().Infof("created %s %s in namespace %s", resourceName, info(copied.GetName()), info(ns))
+				result.Created++
+				continue
+			}
+			existingOwner := existing.GetLabels()[SyncedByLabel]
+			if !strings.HasPrefix(existing.GetAnnotations()[SyncedFromAnnotation], rule.Name+"/") || (existingOwner != "" && existingOwner != owner) {
+				log.Logger().Warnf("not modifying %s %s in namespace %s as it was not created by rule %s of %s", resourceName, copied.GetName(), ns, rule.Name, owner)
+				result.Skipped++
+				continue
+			}
+			if equality.Semantic.DeepEqual(syncedContent(existing), syncedContent(copied)) {
+				result.Unchanged++
+				continue
+			}
+			copied.SetResourceVersion(existing.GetResourceVersion())
+			_, err = client.Update(ctx, copied, metav1.UpdateOptions{})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to update %s %s in namespace %s", resourceName, copied.GetName(), ns)
+			}
+			log.Logger().Infof("updated %s %s in namespace %s", resourceName, info(copied.GetName()), info(ns))
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// removeOrphans deletes the copies owned by the resource sync file which are not required by any rule including the
+// copies of rules which have been removed from the file. Copies are found in each namespace by the sync labels
+func (o *Options) removeOrphans(owner string, gvrs map[schema.GroupVersionResource]bool, desired map[string]bool, results map[string]*SyncResult) error {
+	ctx := context.Background()
+	var keys []schema.GroupVersionResource
+	for gvr := range gvrs {
+		keys = append(keys, gvr)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	namespaces, err := o.KubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list namespaces")
+	}
+	listOptions := metav1.ListOptions{LabelSelector: SyncedLabel + "=true," + SyncedByLabel + "=" + owner}
+	for _, gvr := range keys {
+		resourceName := gvrName(gvr)
+		for j := range namespaces.Items {
+			ns := namespaces.Items[j].Name
+			list, err := o.DynamicClient.Resource(gvr).Namespace(ns).List(ctx, listOptions)
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return errors.Wrapf(err, "failed to list %s in namespace %s", resourceName, ns)
+			}
+			for i := range list.Items {
+				r := &list.Items[i]
+				if desired[copyKey(gvr, ns, r.GetName())] {
+					continue
+				}
+				err = o.DynamicClient.Resource(gvr).Namespace(ns).Delete(ctx, r.GetName(), metav1.DeleteOptions{})
+				if err != nil && !apierrors.IsNotFound(err) {
+					return errors.Wrapf(err, "failed to delete %s %s in namespace %s", resourceName, r.GetName(), ns)
+				}
+				log.Logger().Infof("deleted orphaned %s %s in namespace %s", resourceName, info(r.GetName()), info(ns))
+
+				rule := strings.SplitN(r.GetAnnotations()[SyncedFromAnnotation], "/", 2)[0]
+				result := results[rule]
+				if result == nil {
+					result = &SyncResult{Rule: rule}
+					results[rule] = result
+					o.SyncResults = append(o.SyncResults, result)
+				}
+				result.Deleted++
+			}
+		}
+	}
+	return nil
+}
+
+func gvrName(gvr schema.GroupVersionResource) string {
+	return strings.TrimPrefix(gvr.Group+"/"+gvr.Version+"/"+gvr.Resource, "/")
+}
+
+func copyKey(gvr schema.GroupVersionResource, ns, name string) string {
+	return gvr.String() + "/" + ns + "/" + name
+}
+
+// targetNamespaces returns the sorted names of the namespaces to copy to excluding the source namespace
+func (o *Options) targetNamespaces(targets *v1alpha1.ResourceSyncTargets, sourceNS string) ([]string, error) {
+	ctx := context.Background()
+	var answer []string
+	for _, ns := range targets.Namespaces {
+		if o.CreateNamespace {
+			err := jxenv.EnsureNamespaceCreated(o.KubeClient, ns, nil, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to create namespace %s", ns)
+			}
+		} else {
+			_, err := o.KubeClient.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					log.Logger().Warnf("ignoring namespace %s as it does not exist", ns)
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to get namespace %s", ns)
+			}
+		}
+		answer = stringhelpers.EnsureStringArrayContains(answer, ns)
+	}
+
+	if targets.Selector != "" {
+		list, err := o.KubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: targets.Selector})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list namespaces with selector %s", targets.Selector)
+		}
+		for i := range list.Items {
+			answer = stringhelpers.EnsureStringArrayContains(answer, list.Items[i].Name)
+		}
+	}
+
+	if targets.Previews {
+		list, err := o.KubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list namespaces")
+		}
+		for i := range list.Items {
+			if previews.IsPreviewNamespace(&list.Items[i]) {
+				answer = stringhelpers.EnsureStringArrayContains(answer, list.Items[i].Name)
+			}
+		}
+	}
+
+	answer = stringhelpers.RemoveStringFromSlice(answer, sourceNS)
+	sort.Strings(answer)
+	return answer, nil
+}
+
+// toCopy returns a copy of the resource in the given namespace labelled with the owner and annotated with the rule and source
+func toCopy(r *unstructured.Unstructured, owner, rule, ns string) *unstructured.Unstructured {
+	answer := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for k, v := range r.Object {
+		if k != "metadata" && k != "status" {
+			answer.Object[k] = runtime.DeepCopyJSONValue(v)
+		}
+	}
+	answer.SetName(r.GetName())
+	answer.SetNamespace(ns)
+	labels := map[string]string{}
+	for k, v := range r.GetLabels() {
+		labels[k] = v
+	}
+	labels[SyncedLabel] = "true"
+	labels[SyncedByLabel] = owner
+	answer.SetLabels(labels)
+	annotations := map[string]string{}
+	for k, v := range r.GetAnnotations() {
+		if k != "kubectl.kubernetes.io/last-applied-configuration" {
+			annotations[k] = v
+		}
+	}
+	annotations[SyncedFromAnnotation] = fmt.Sprintf("%s/%s/%s", rule, r.GetNamespace(), r.GetName())
+	answer.SetAnnotations(annotations)
+	return answer
+}
+
+// syncedContent returns the content of the resource which is kept in sync
+func syncedContent(r *unstructured.Unstructured) map[string]interface{} {
+	answer := map[string]interface{}{}
+	for k, v := range r.Object {
+		if k != "metadata" && k != "status" {
+			answer[k] = v
+		}
+	}
+	labels := r.GetLabels()
+	if len(labels) > 0 {
+		answer["labels"] = labels
+	}
+	answer["annotations"] = r.GetAnnotations()
+	return answer
+}
@@ -0,0 +1,126 @@
+package get
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+)
+
+// checkoutFiles gets the matching files using a shallow sparse checkout of the repository for git providers which
+// cannot list directories via the contents API
+func (o *Options) checkoutFiles(base string, matcher pathMatcher) error {
+	gitURL := o.cloneURL()
+	tmpDir, err := os.MkdirTemp("", "jx-git-get-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pattern := "/" + strings.TrimSuffix(o.Path, "/")
+	if !IsGlob(pattern) {
+		pattern += "/"
+	}
+	ref := o.fetchRef()
+	g := cli.NewCLIClient("", o.CommandRunner)
+	dir := filepath.Join(tmpDir, "repo")
+	steps := [][]string{
+		{"clone", "--no-checkout", "--filter=blob:none", "--depth=1", gitURL, dir},
+		{"sparse-checkout", "set", "--no-cone", pattern},
+		{"fetch", "--depth=1", "origin", ref},
+		{"checkout", "FETCH_HEAD"},
+	}
+	env := o.gitAuthEnv(gitURL)
+	for _, args := range steps {
+		cmdDir := dir
+		if args[0] == "clone" {
+			cmdDir = tmpDir
+		}
+		_, err = o.CommandRunner(&cmdrunner.Command{
+			Name: "git",
+			Args: args,
+			Dir:  cmdDir,
+			Env:  env,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to run git %s", args[0])
+		}
+	}
+
+	sha, err := gitclient.GetLatestCommitSha(g, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the commit SHA of the checkout")
+	}
+	if o.VerifySHA && o.SHA != "" && sha != o.SHA {
+		return errors.Errorf("checked out commit %s rather than the expected commit %s", sha, o.SHA)
+	}
+	o.SHA = sha
+
+	return filepath.Walk(dir, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() {
+			if fileInfo.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find relative path of %s", path)
+		}
+		rel = filepath.ToSlash(rel)
+		if !matcher.Match(rel) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read file %s", path)
+		}
+		return o.writeFile(o.destination(base, rel), data)
+	})
+}
+
+// cloneURL returns the URL to clone the repository
+func (o *Options) cloneURL() string {
+	if o.isSourceURLOf(o.FromRepository) {
+		return o.SourceURL
+	}
+	return stringhelpers.UrlJoin(o.GitServerURL, o.FromRepository) + ".git"
+}
+
+// gitAuthEnv returns the environment variables which pass the git token to git as an HTTP header. The token is not
+// added to the URL or arguments as they are included in the logs and errors of failed commands
+func (o *Options) gitAuthEnv(gitURL string) map[string]string {
+	if o.GitToken == "" || !strings.HasPrefix(gitURL, "http") {
+		return nil
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte("oauth2:" + o.GitToken))
+	return map[string]string{
+		"GIT_CONFIG_COUNT":   "1",
+		"GIT_CONFIG_KEY_0":   "http.extraHeader",
+		"GIT_CONFIG_VALUE_0": "Authorization: Basic " + credentials,
+	}
+}
+
+// isSourceURLOf returns true if the source URL is the URL of the repository. The source URL may be discovered from the
+// current directory so it is only used to clone if it is the repository we are getting files from
+func (o *Options) isSourceURLOf(repository string) bool {
+	if o.SourceURL == "" {
+		return false
+	}
+	gitInfo, err := giturl.ParseGitURL(o.SourceURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(scm.Join(gitInfo.Organisation, gitInfo.Name), repository)
+}
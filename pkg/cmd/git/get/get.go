@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/gobwas/glob"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/go-scm/scm"
 	jxc "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
@@ -26,9 +28,10 @@ import (
 
 var (
 	cmdLong = templates.LongDesc(`
-		Gets a file from the specified git repository or the git repository described in specified JX Environment resource.
+		Gets a file, directory or glob of files from the specified git repository or the git repository described in specified JX Environment resource.
 
-		Copies a file specified by --file flag from a Git repository into local file system. The repository from which the
+		Copies a file, directory or glob pattern such as 'values/**/*.yaml' specified by --file flag from a Git repository
+		into local file system. The repository from which the
 		file is read is either the one specified via command line flags or the one defined as the target repository of the
 		JayeX Environment object, whose name too can be passed in via CLI flags.
 
@@ -40,11 +43,21 @@ var (
 		repository from which file is read needs to be configured in environment variable: JX_ENVIRONMENT_GIT_URL.
 
 		The file is either copied to the path specified by the command line flag --to or is written under the same path from
-		which it was read into the current working directory.
+		which it was read into the current working directory. When fetching a directory or glob the files are written under
+		the --to directory preserving their paths relative to the directory or the directory part of the glob.
+
+		Files are fetched using the git provider contents API falling back to a shallow sparse checkout if the contents API
+		cannot list the directory.
+
+		Use --verify-sha to resolve the --ref to a commit SHA before fetching so that all the files come from the same commit
+		and the SHA is recorded in the --sha-file.
 `)
 
 	cmdExample = templates.Examples(`
 		%s git get --file jx-values.yaml --dev dev 
+
+		# fetches all the YAML files under a directory of another environment repository
+		%[1]s git get --env staging --file 'values/**/*.yaml' --to values --verify-sha --sha-file values.sha
 	`)
 
 	info = termcolor.ColorInfo
@@ -59,8 +72,16 @@ type Options struct {
 	Path           string
 	To             string
 	Ref            string
+	VerifySHA      bool
+	SHAFile        string
 	Namespace      string
 	JXClient       jxc.Interface
+
+	// SHA the commit SHA the files were fetched from if it is known
+	SHA string
+
+	// Files the local files which were written
+	Files []string
 }
 
 // NewCmdGitGet creates a command object for the command
@@ -81,9 +102,11 @@ func NewCmdGitGet() (*cobra.Command, *Options) {
 
 	cmd.Flags().StringVarP(&o.FromRepository, "from", "", "", "the git repository of the form owner/name to find the file")
 	cmd.Flags().StringVarP(&o.Env, "env", "e", "", "the name of the Environment to find the git repository URL")
-	cmd.Flags().StringVarP(&o.Path, "file", "f", "", "the file in the git repository")
-	cmd.Flags().StringVarP(&o.To, "to", "", "", "the destination of the file. If not specified defaults to the path")
+	cmd.Flags().StringVarP(&o.Path, "file", "f", "", "the file, directory or glob pattern such as 'values/**/*.yaml' in the git repository")
+	cmd.Flags().StringVarP(&o.To, "to", "", "", "the destination of the file or the directory to write a directory or glob of files. If not specified defaults to the path")
 	cmd.Flags().StringVarP(&o.Ref, "ref", "", "master", "the git reference (branch, tag or SHA) to query the file")
+	cmd.Flags().BoolVarP(&o.VerifySHA, "verify-sha", "", false, "resolves the git reference to a commit SHA before fetching and verifies all the files are fetched from that commit")
+	cmd.Flags().StringVarP(&o.SHAFile, "sha-file", "", "", "the file to record the commit SHA the files were fetched from")
 	return cmd, o
 }
 
@@ -95,33 +118,237 @@ func (o *Options) Run() error {
 	}
 
 	ctx := context.Background()
-	sha := o.Ref
+	o.Files = nil
+	o.SHA = ""
+	if o.VerifySHA {
+		o.SHA, err = o.resolveSHA(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve the commit SHA of ref %s in repo %s", o.Ref, o.FromRepository)
+		}
+	}
+
+	if IsGlob(o.Path) {
+		err = o.getFiles(ctx, GlobBase(o.Path))
+	} else {
+		err = o.getFile(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.SHA != "" {
+		log.Logger().Infof("fetched %d files from repository %s commit %s", len(o.Files), info(o.FromRepository), info(o.SHA))
+		if o.SHAFile != "" {
+			err = saveFile(o.SHAFile, []byte(o.SHA+"\n"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getFile gets the file or directory of the path
+func (o *Options) getFile(ctx context.Context) error {
+	ref := o.fetchRef()
 	path := o.Path
 	repo := o.FromRepository
-	c, r, err := o.ScmClient.Contents.Find(ctx, repo, path, sha)
+	c, r, err := o.ScmClient.Contents.Find(ctx, repo, path, ref)
 	if err != nil {
-		if r != nil && r.Status == 404 {
-			return errors.Errorf("no file %s in repo %s for ref %s", path, repo, sha)
+		// some git providers such as GitLab return 404 for a directory and others return a listing which is not
+		// a file so lets check if the path is a directory
+		entries, lr, listErr := o.ScmClient.Contents.List(ctx, repo, path, ref, &scm.ListOptions{})
+		if listErr == nil && len(entries) > 0 {
+			log.Logger().Debugf("path %s in repo %s is a directory", path, repo)
+			return o.getFiles(ctx, path)
+		}
+		if !isNotFound(r) {
+			return errors.Wrapf(err, "failed to find file %s in repo %s for ref %s", path, repo, ref)
 		}
-		return errors.Wrapf(err, "failed to find file %s in repo %s with ref %s status %d", path, repo, sha, r.Status)
+		if listErr != nil && isNotFound(lr) {
+			return errors.Errorf("no file or directory %s in repo %s for ref %s", path, repo, ref)
+		}
+
+		// the git provider cannot list the directory so lets try a checkout
+		log.Logger().Debugf("could not find file %s in repo %s so trying it as a directory", path, repo)
+		return o.getFiles(ctx, path)
 	}
 	to := o.To
 	if to == "" {
 		to = filepath.Join(o.Dir, path)
 	}
+	err = o.writeFile(to, c.Data)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("saved file %s from repository %s ref %s", info(to), info(repo), info(ref))
+	return nil
+}
+
+// getFiles gets the files matching the path under the base directory
+func (o *Options) getFiles(ctx context.Context, base string) error {
+	matcher, err := o.matcher()
+	if err != nil {
+		return err
+	}
+	err = o.getDirectory(ctx, base, base, matcher)
+	if err != nil {
+		log.Logger().Infof("failed to list directory %s in repo %s so falling back to a sparse checkout: %s", base, o.FromRepository, err.Error())
+		o.Files = nil
+		err = o.checkoutFiles(base, matcher)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s from repo %s with ref %s", o.Path, o.FromRepository, o.Ref)
+		}
+	}
+	if len(o.Files) == 0 {
+		return errors.Errorf("no files matching %s in repo %s for ref %s", o.Path, o.FromRepository, o.Ref)
+	}
+	log.Logger().Infof("saved %d files matching %s from repository %s ref %s", len(o.Files), info(o.Path), info(o.FromRepository), info(o.fetchRef()))
+	return nil
+}
+
+// getDirectory recursively gets the files in the directory which match using the contents API
+func (o *Options) getDirectory(ctx context.Context, base, dir string, matcher pathMatcher) error {
+	repo := o.FromRepository
+	ref := o.fetchRef()
+	entries, _, err := o.ScmClient.Contents.List(ctx, repo, dir, ref, &scm.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list directory %s", dir)
+	}
+	for _, e := range entries {
+		path := strings.TrimPrefix(e.Path, "/")
+		switch e.Type {
+		case "dir":
+			err = o.getDirectory(ctx, base, path, matcher)
+			if err != nil {
+				return err
+			}
+		case "file":
+			if !matcher.Match(path) {
+				continue
+			}
+			c, _, err := o.ScmClient.Contents.Find(ctx, repo, path, ref)
+			if err != nil {
+				return errors.Wrapf(err, "failed to find file %s", path)
+			}
+			err = o.writeFile(o.destination(base, path), c.Data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// destination returns the local file for the path in the repository
+func (o *Options) destination(base, path string) string {
+	if o.To == "" {
+		return filepath.Join(o.Dir, path)
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(path, base), "/")
+	return filepath.Join(o.To, filepath.FromSlash(rel))
+}
+
+// writeFile saves a fetched file
+func (o *Options) writeFile(to string, data []byte) error {
+	err := saveFile(to, data)
+	if err != nil {
+		return err
+	}
+	o.Files = append(o.Files, to)
+	return nil
+}
+
+func saveFile(to string, data []byte) error {
 	dir := filepath.Dir(to)
-	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
 	if err != nil {
 		return errors.Wrapf(err, "failed to create dir %s", dir)
 	}
-	err = os.WriteFile(to, c.Data, files.DefaultFileWritePermissions)
+	err = os.WriteFile(to, data, files.DefaultFileWritePermissions)
 	if err != nil {
 		return errors.Wrapf(err, "failed to save file %s", to)
 	}
-	log.Logger().Infof("saved file %s from repository %s ref %s", info(to), info(repo), info(sha))
 	return nil
 }
 
+// pathMatcher matches a path if any of the globs match
+type pathMatcher []glob.Glob
+
+// Match returns true if the path matches
+func (m pathMatcher) Match(path string) bool {
+	for _, g := range m {
+		if g.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matcher returns the matcher of file paths to get
+func (o *Options) matcher() (pathMatcher, error) {
+	pattern := strings.TrimSuffix(o.Path, "/")
+	if !IsGlob(pattern) {
+		// lets match all the files in the directory
+		pattern += "/**"
+	}
+
+	// lets allow '**/' to match zero directories too
+	patterns := []string{pattern}
+	if strings.Contains(pattern, "**/") {
+		patterns = append(patterns, strings.ReplaceAll(pattern, "**/", ""))
+	}
+	var answer pathMatcher
+	for _, p := range patterns {
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse glob %s", o.Path)
+		}
+		answer = append(answer, g)
+	}
+	return answer, nil
+}
+
+func (o *Options) resolveSHA(ctx context.Context) (string, error) {
+	commit, _, err := o.ScmClient.Git.FindCommit(ctx, o.FromRepository, o.Ref)
+	if err != nil {
+		return "", err
+	}
+	if commit == nil || commit.Sha == "" {
+		return "", errors.Errorf("no commit found")
+	}
+	return commit.Sha, nil
+}
+
+func isNotFound(r *scm.Response) bool {
+	return r != nil && r.Status == 404
+}
+
+// fetchRef returns the commit SHA if it has been resolved otherwise the ref
+func (o *Options) fetchRef() string {
+	if o.VerifySHA && o.SHA != "" {
+		return o.SHA
+	}
+	return o.Ref
+}
+
+// IsGlob returns true if the path is a glob pattern
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[{")
+}
+
+// GlobBase returns the directory part of the glob before any pattern characters
+func GlobBase(pattern string) string {
+	var dirs []string
+	for _, p := range strings.Split(pattern, "/") {
+		if IsGlob(p) {
+			break
+		}
+		dirs = append(dirs, p)
+	}
+	return strings.Join(dirs, "/")
+}
+
 // Validate validates the inputs are valid
 func (o *Options) Validate() error {
 	if o.Options.CommandRunner == nil {
@@ -1,13 +1,21 @@
 package get_test
 
 import (
+	"context"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/git/get"
+	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/driver/fake"
 	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,3 +73,217 @@ func TestGitGetFromEnvironment(t *testing.T) {
 
 	t.Logf("generated file %s\n", generated)
 }
+
+func TestGitGetDirectoryAndGlob(t *testing.T) {
+	testCases := []struct {
+		path     string
+		to       string
+		expected []string
+	}{
+		{
+			path:     "values",
+			to:       "out",
+			expected: []string{"out/README.md", "out/a.yaml", "out/nested/b.yaml"},
+		},
+		{
+			path:     "values/**/*.yaml",
+			to:       "out",
+			expected: []string{"out/a.yaml", "out/nested/b.yaml"},
+		},
+		{
+			path:     "values/*.yaml",
+			expected: []string{"values/a.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tmpDir := t.TempDir()
+
+		scmClient, fakeData := fake.NewDefault()
+		fakeData.Commits["master"] = &scm.Commit{Sha: "abc1234"}
+
+		_, o := get.NewCmdGitGet()
+		o.SourceURL = "https://github.com/jenkins-x-plugins/jx-gitops"
+		o.Branch = "main"
+		o.ScmClient = scmClient
+		o.Dir = tmpDir
+		o.Path = tc.path
+		if tc.to != "" {
+			o.To = filepath.Join(tmpDir, tc.to)
+		}
+		o.FromRepository = "myorg/myrepo"
+		o.VerifySHA = true
+		o.SHAFile = filepath.Join(tmpDir, "sha.txt")
+
+		err := o.Run()
+		require.NoError(t, err, "failed to run command for %s", tc.path)
+
+		var actual []string
+		for _, f := range o.Files {
+			rel, err := filepath.Rel(tmpDir, f)
+			require.NoError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		assert.ElementsMatch(t, tc.expected, actual, "files for %s", tc.path)
+
+		assert.Equal(t, "abc1234", o.SHA, "SHA for %s", tc.path)
+		data, err := os.ReadFile(o.SHAFile)
+		require.NoError(t, err, "failed to read SHA file")
+		assert.Equal(t, "abc1234\n", string(data))
+	}
+}
+
+func TestGitGetSparseCheckoutFallback(t *testing.T) {
+	serverDir := t.TempDir()
+	repoDir := filepath.Join(serverDir, "myorg", "other.git")
+	runner := cmdrunner.QuietCommandRunner
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main", repoDir},
+		{"-C", repoDir, "config", "user.email", "test@example.com"},
+		{"-C", repoDir, "config", "user.name", "test"},
+	} {
+		_, err := runner(&cmdrunner.Command{Name: "git", Args: args})
+		require.NoError(t, err, "failed to run git %v", args)
+	}
+	err := files.CopyDirOverwrite(filepath.Join("testdata", "myorg", "myrepo"), repoDir)
+	require.NoError(t, err, "failed to copy files")
+	for _, args := range [][]string{
+		{"-C", repoDir, "add", "."},
+		{"-C", repoDir, "commit", "-q", "-m", "initial"},
+		{"-C", repoDir, "config", "uploadpack.allowFilter", "true"},
+	} {
+		_, err = runner(&cmdrunner.Command{Name: "git", Args: args})
+		require.NoError(t, err, "failed to run git %v", args)
+	}
+	sha, err := runner(&cmdrunner.Command{Name: "git", Args: []string{"-C", repoDir, "rev-parse", "HEAD"}})
+	require.NoError(t, err, "failed to get SHA")
+
+	tmpDir := t.TempDir()
+	scmClient, _ := fake.NewDefault()
+
+	_, o := get.NewCmdGitGet()
+	o.GitServerURL = "file://" + serverDir
+	o.SourceURL = "https://github.com/jenkins-x-plugins/jx-gitops"
+	o.GitKind = "fake"
+	o.ScmClient = scmClient
+	o.Dir = tmpDir
+	o.Path = "values/**/*.yaml"
+	o.To = filepath.Join(tmpDir, "out")
+	o.Ref = "main"
+
+	// the fake contents API cannot list this repository so we should fall back to a sparse checkout
+	o.FromRepository = "myorg/other"
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run command")
+
+	assert.Equal(t, strings.TrimSpace(sha), o.SHA, "SHA of the checkout")
+	assert.FileExists(t, filepath.Join(o.To, "a.yaml"))
+	assert.FileExists(t, filepath.Join(o.To, "nested", "b.yaml"))
+	assert.NoFileExists(t, filepath.Join(o.To, "README.md"))
+}
+
+func TestGitGetFindErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		expected    []string
+		expectError bool
+	}{
+		{
+			// GitLab returns 404 when finding a directory
+			name:     "directory not found as a file",
+			status:   404,
+			expected: []string{"out/README.md", "out/a.yaml", "out/nested/b.yaml"},
+		},
+		{
+			name:        "server error",
+			status:      500,
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tmpDir := t.TempDir()
+		scmClient, _ := fake.NewDefault()
+		contents := &failingContents{ContentService: scmClient.Contents, path: "values", status: tc.status}
+		if tc.status != 404 {
+			contents.path = "expected.txt"
+		}
+		scmClient.Contents = contents
+
+		_, o := get.NewCmdGitGet()
+		o.SourceURL = "https://github.com/jenkins-x-plugins/jx-gitops"
+		o.Branch = "main"
+		o.ScmClient = scmClient
+		o.Dir = tmpDir
+		o.Path = contents.path
+		o.To = filepath.Join(tmpDir, "out")
+		o.FromRepository = "myorg/myrepo"
+
+		err := o.Run()
+		if tc.expectError {
+			require.Error(t, err, "should fail for %s", tc.name)
+			assert.Contains(t, err.Error(), "failed to find file", "error for %s", tc.name)
+			assert.Empty(t, o.Files, "files for %s", tc.name)
+			continue
+		}
+		require.NoError(t, err, "failed to run command for %s", tc.name)
+
+		var actual []string
+		for _, f := range o.Files {
+			rel, err := filepath.Rel(tmpDir, f)
+			require.NoError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		assert.ElementsMatch(t, tc.expected, actual, "files for %s", tc.name)
+	}
+}
+
+// failingContents fails to find the path with the given status code
+type failingContents struct {
+	scm.ContentService
+	path   string
+	status int
+}
+
+func (c *failingContents) Find(ctx context.Context, repo, path, ref string) (*scm.Content, *scm.Response, error) {
+	if path == c.path {
+		return nil, &scm.Response{Status: c.status}, errors.Errorf("status %d finding %s", c.status, path)
+	}
+	return c.ContentService.Find(ctx, repo, path, ref)
+}
+
+func TestGitGetSparseCheckoutDoesNotLeakToken(t *testing.T) {
+	token := "mysecrettoken"
+	var commands []*cmdrunner.Command
+	runner := func(c *cmdrunner.Command) (string, error) {
+		commands = append(commands, c)
+		return "", errors.Errorf("failed to run '%s'", c.CLI())
+	}
+
+	tmpDir := t.TempDir()
+	scmClient, _ := fake.NewDefault()
+
+	_, o := get.NewCmdGitGet()
+	o.GitServerURL = "https://git.example.com"
+	o.SourceURL = "https://github.com/jenkins-x-plugins/jx-gitops"
+	o.GitKind = "fake"
+	o.GitToken = token
+	o.ScmClient = scmClient
+	o.CommandRunner = runner
+	o.Dir = tmpDir
+	o.Path = "values/**/*.yaml"
+	o.To = filepath.Join(tmpDir, "out")
+	o.Ref = "main"
+	o.FromRepository = "myorg/other"
+
+	err := o.Run()
+	require.Error(t, err, "the clone should fail")
+	assert.NotContains(t, err.Error(), token, "the error should not contain the token")
+
+	require.NotEmpty(t, commands, "should have run git")
+	clone := commands[0]
+	assert.Equal(t, "clone", clone.Args[0])
+	assert.NotContains(t, clone.CLI(), token, "the clone command line should not contain the token")
+	assert.Contains(t, clone.Env["GIT_CONFIG_VALUE_0"], "Authorization: Basic ", "the token should be passed as a header")
+}
@@ -0,0 +1,278 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// WorkloadKinds the kinds of workload annotated in auto mode if no kinds are specified
+var WorkloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob"}
+
+// configResource a ConfigMap, Secret or ExternalSecret which can be referenced by a workload
+type configResource struct {
+	// kind and name of the source resource
+	kind string
+	name string
+	// ref the 'Kind/name' a workload uses to reference the resource
+	ref  string
+	hash string
+}
+
+// configResources the source resources indexed by namespace and the 'Kind/name' they are referenced by
+type configResources map[string]map[string][]*configResource
+
+func (r configResources) add(namespace string, res *configResource) {
+	m := r[namespace]
+	if m == nil {
+		m = map[string][]*configResource{}
+		r[namespace] = m
+	}
+	m[res.ref] = append(m[res.ref], res)
+}
+
+// find returns the source resources for the reference sorted by kind and name
+func (r configResources) find(namespace, ref string) []*configResource {
+	answer := r[namespace][ref]
+	sort.Slice(answer, func(i, j int) bool {
+		if answer[i].kind != answer[j].kind {
+			return answer[i].kind < answer[j].kind
+		}
+		return answer[i].name < answer[j].name
+	})
+	return answer
+}
+
+// RunAuto annotates the pod template of each workload with a hash of the ConfigMaps and Secrets it references
+func (o *Options) RunAuto() error {
+	resources, err := findConfigResources(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find ConfigMaps and Secrets in dir %s", o.Dir)
+	}
+
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		podTemplatePath := []string{"spec", "template"}
+		if kyamls.GetKind(node, path) == "CronJob" {
+			podTemplatePath = []string{"spec", "jobTemplate", "spec", "template"}
+		}
+		podSpec, err := node.Pipe(yaml.Lookup(append(podTemplatePath, "spec")...))
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to find pod spec")
+		}
+		if podSpec == nil {
+			return false, nil
+		}
+
+		ns := resourceNamespace(node, path)
+		buff := strings.Builder{}
+		count := 0
+		for _, ref := range podSpecReferences(podSpec) {
+			sources := resources.find(ns, ref)
+			if len(sources) == 0 {
+				log.Logger().Debugf("could not find %s in namespace %s referenced by %s", ref, ns, path)
+				continue
+			}
+			for _, res := range sources {
+				buff.WriteString(ref + "=" + res.kind + "/" + res.name + ":" + res.hash + "\n")
+			}
+			count++
+		}
+		annotationsPath := append(podTemplatePath, "metadata", "annotations")
+		if count == 0 {
+			return o.removeStaleAnnotation(node, annotationsPath, path)
+		}
+		hashBytes := sha256.Sum256([]byte(buff.String()))
+		value := fmt.Sprintf("%x", hashBytes)
+
+		annotations, err := node.Pipe(yaml.PathGetter{Path: annotationsPath, Create: yaml.MappingNode})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get pod template annotations")
+		}
+		existing, err := annotations.Pipe(yaml.Get(o.Annotation))
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get annotation %s", o.Annotation)
+		}
+		if existing != nil && yaml.GetValue(existing) == value {
+			return false, nil
+		}
+		err = annotations.PipeE(yaml.SetField(o.Annotation, yaml.NewStringRNode(value)))
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to set annotation %s", o.Annotation)
+		}
+		log.Logger().Debugf("annotated %s with hash of %d ConfigMaps and Secrets", path, count)
+		return true, nil
+	}
+	return kyamls.ModifyFiles(o.Dir, modifyFn, o.Filter)
+}
+
+// removeStaleAnnotation removes the hash annotation from a workload which no longer references any ConfigMaps or Secrets
+func (o *Options) removeStaleAnnotation(node *yaml.RNode, annotationsPath []string, path string) (bool, error) {
+	annotations, err := node.Pipe(yaml.Lookup(annotationsPath...))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get pod template annotations")
+	}
+	if annotations == nil {
+		return false, nil
+	}
+	existing, err := annotations.Pipe(yaml.Clear(o.Annotation))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to remove annotation %s", o.Annotation)
+	}
+	if existing == nil {
+		return false, nil
+	}
+	log.Logger().Debugf("removed annotation %s from %s as it no longer references any ConfigMaps or Secrets", o.Annotation, path)
+	return true, nil
+}
+
+// findConfigResources finds the ConfigMaps, Secrets and ExternalSecrets in the directory
+func findConfigResources(dir string) (configResources, error) {
+	answer := configResources{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+			return nil
+		}
+		node, err := yaml.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load file %s", path)
+		}
+		kind := kyamls.GetKind(node, path)
+		name := kyamls.GetName(node, path)
+		ref := kind + "/" + name
+		var fields []string
+		switch kind {
+		case "ConfigMap":
+			fields = []string{"data", "binaryData"}
+		case "Secret":
+			fields = []string{"data", "stringData"}
+		case "ExternalSecret":
+			// the generated Secret is named after the ExternalSecret unless a target name is specified
+			fields = []string{"spec"}
+			ref = "Secret/" + name
+			targetName := kyamls.GetStringField(node, path, "spec", "target", "name")
+			if targetName != "" {
+				ref = "Secret/" + targetName
+			}
+		default:
+			return nil
+		}
+		h, err := hashFields(node, fields)
+		if err != nil {
+			return errors.Wrapf(err, "failed to hash file %s", path)
+		}
+		answer.add(resourceNamespace(node, path), &configResource{
+			kind: kind,
+			name: name,
+			ref:  ref,
+			hash: h,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+func hashFields(node *yaml.RNode, fields []string) (string, error) {
+	buff := strings.Builder{}
+	for _, f := range fields {
+		n, err := node.Pipe(yaml.Lookup(f))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get field %s", f)
+		}
+		if n == nil {
+			continue
+		}
+		text, err := n.String()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to marshal field %s", f)
+		}
+		buff.WriteString(f + ":\n" + text)
+	}
+	hashBytes := sha256.Sum256([]byte(buff.String()))
+	return fmt.Sprintf("%x", hashBytes), nil
+}
+
+// resourceNamespace returns the namespace of the resource or the namespace directory it is inside
+func resourceNamespace(node *yaml.RNode, path string) string {
+	ns := kyamls.GetNamespace(node, path)
+	if ns != "" {
+		return ns
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "namespaces" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// podSpecReferences returns the sorted ConfigMaps and Secrets referenced by the pod spec as 'Kind/name'
+func podSpecReferences(podSpec *yaml.RNode) []string {
+	refs := map[string]bool{}
+	add := func(kind string, n *yaml.RNode, fields ...string) {
+		if n == nil {
+			return
+		}
+		v, err := n.Pipe(yaml.Lookup(fields...))
+		if err != nil || v == nil {
+			return
+		}
+		name := yaml.GetValue(v)
+		if name != "" {
+			refs[kind+"/"+name] = true
+		}
+	}
+	elements := func(n *yaml.RNode, fields ...string) []*yaml.RNode {
+		list, err := n.Pipe(yaml.Lookup(fields...))
+		if err != nil || list == nil {
+			return nil
+		}
+		items, err := list.Elements()
+		if err != nil {
+			return nil
+		}
+		return items
+	}
+
+	for _, v := range elements(podSpec, "volumes") {
+		add("ConfigMap", v, "configMap", "name")
+		add("Secret", v, "secret", "secretName")
+		for _, s := range elements(v, "projected", "sources") {
+			add("ConfigMap", s, "configMap", "name")
+			add("Secret", s, "secret", "name")
+		}
+	}
+	for _, field := range []string{"initContainers", "containers"} {
+		for _, c := range elements(podSpec, field) {
+			for _, e := range elements(c, "envFrom") {
+				add("ConfigMap", e, "configMapRef", "name")
+				add("Secret", e, "secretRef", "name")
+			}
+			for _, e := range elements(c, "env") {
+				add("ConfigMap", e, "valueFrom", "configMapKeyRef", "name")
+				add("Secret", e, "valueFrom", "secretKeyRef", "name")
+			}
+		}
+	}
+
+	var answer []string
+	for k := range refs {
+		answer = append(answer, k)
+	}
+	sort.Strings(answer)
+	return answer
+}
@@ -23,11 +23,17 @@ const DefaultAnnotation = "jenkins-x.io/hash"
 var (
 	cmdLong = templates.LongDesc(`
 		Annotates the given files with a hash of the given source files for ConfigMaps/Secrets
+
+		With --auto the pod template of each workload is annotated with a hash of just the ConfigMaps, Secrets and
+		ExternalSecrets it references in the same namespace so that only the affected workloads roll when they change.
 `)
 
 	cmdExample = templates.Examples(`
 		# annotates the Deployments in a dir from some source ConfigMaps
 		%s hash -s foo/configmap.yaml -s another/configmap.yaml -d someDir
+
+		# annotates each workload with a hash of the ConfigMaps and Secrets it references
+		%[1]s hash --auto -d config-root
 	`)
 )
 
@@ -36,6 +42,7 @@ type Options struct {
 	tagging.Options
 	Annotation  string
 	SourceFiles []string
+	Auto        bool
 }
 
 // NewCmdHashAnnotate creates a command object for the command
@@ -56,9 +63,10 @@ func NewCmdHashAnnotate() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to recursively look for the *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.Annotation, "annotation", "a", DefaultAnnotation, "the annotation for the hash to add to the files")
 	cmd.Flags().BoolVarP(&o.PodSpec, "pod-spec", "p", false, "annotate the PodSpec in spec.templates.metadata.annotations rather than the top level annotations")
+	cmd.Flags().BoolVarP(&o.Auto, "auto", "", false, "annotates the pod template of each workload with a hash of the ConfigMaps and Secrets it references rather than using the --source files")
 
 	f := &o.Filter
-	cmd.Flags().StringArrayVarP(&f.Kinds, "kind", "k", []string{"Deployment"}, "adds Kubernetes resource kinds to filter on to annotate. For kind expressions see: https://github.com/jenkins-x-plugins/jx-gitops/tree/master/docs/kind_filters.md")
+	cmd.Flags().StringArrayVarP(&f.Kinds, "kind", "k", nil, "adds Kubernetes resource kinds to filter on to annotate. Defaults to Deployment or all workload kinds with --auto. For kind expressions see: https://github.com/jenkins-x-plugins/jx-gitops/tree/master/docs/kind_filters.md")
 	cmd.Flags().StringArrayVarP(&f.KindsIgnore, "kind-ignore", "", nil, "adds Kubernetes resource kinds to exclude. For kind expressions see: https://github.com/jenkins-x-plugins/jx-gitops/tree/master/docs/kind_filters.md")
 
 	return cmd, o
@@ -70,6 +78,15 @@ func (o *Options) Run() error {
 		return options.MissingOption("annotation")
 
 	}
+	if o.Auto {
+		if len(o.Filter.Kinds) == 0 {
+			o.Filter.Kinds = WorkloadKinds
+		}
+		return o.RunAuto()
+	}
+	if len(o.Filter.Kinds) == 0 {
+		o.Filter.Kinds = []string{"Deployment"}
+	}
 	if len(o.SourceFiles) == 0 {
 		return options.MissingOption("source")
 	}
@@ -1,6 +1,7 @@
 package hash_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func TestUpdateAnnotatesInYamlFiles(t *testing.T) {
@@ -80,3 +82,92 @@ func TestUpdatePodSpecAnnotatesInYamlFiles(t *testing.T) {
 
 	t.Logf("found annotation %s value: %s on file %s\n", hash.DefaultAnnotation, value, outFile)
 }
+
+func TestHashAuto(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join("testdata", "auto")
+	err := files.CopyDir(srcDir, tmpDir, true)
+	require.NoError(t, err, "failed to copy from %s to %s", srcDir, tmpDir)
+
+	run := func() {
+		_, ho := hash.NewCmdHashAnnotate()
+		ho.Dir = tmpDir
+		ho.Auto = true
+		err := ho.Run()
+		require.NoError(t, err, "failed to run auto hash")
+	}
+	nsDir := filepath.Join(tmpDir, "config-root", "namespaces")
+	podTemplateHash := func(path string, cronJob bool) string {
+		node, err := kyaml.ReadFile(filepath.Join(nsDir, path))
+		require.NoError(t, err, "failed to load %s", path)
+		fields := []string{"spec", "template", "metadata", "annotations", hash.DefaultAnnotation}
+		if cronJob {
+			fields = []string{"spec", "jobTemplate", "spec", "template", "metadata", "annotations", hash.DefaultAnnotation}
+		}
+		v, err := node.Pipe(kyaml.Lookup(fields...))
+		require.NoError(t, err, "failed to lookup annotation in %s", path)
+		if v == nil {
+			return ""
+		}
+		return kyaml.GetValue(v)
+	}
+
+	run()
+
+	app1 := podTemplateHash(filepath.Join("jx", "app1-deploy.yaml"), false)
+	app2 := podTemplateHash(filepath.Join("jx", "app2-deploy.yaml"), false)
+	otherApp2 := podTemplateHash(filepath.Join("other", "app2-deploy.yaml"), false)
+	cleanup := podTemplateHash(filepath.Join("jx", "cleanup-cronjob.yaml"), true)
+	assert.NotEmpty(t, app1, "app1 should be annotated")
+	assert.NotEmpty(t, app2, "app2 should be annotated")
+	assert.NotEmpty(t, otherApp2, "app2 in other namespace should be annotated")
+	assert.NotEmpty(t, cleanup, "cleanup CronJob should be annotated from the ExternalSecret")
+	assert.NotEqual(t, app1, app2, "workloads with different references should have different hashes")
+	assert.NotEqual(t, app2, otherApp2, "ConfigMaps should be resolved in the namespace of the workload")
+	assert.Empty(t, podTemplateHash(filepath.Join("jx", "norefs-deploy.yaml"), false), "norefs should not be annotated")
+
+	// lets modify a ConfigMap and check only the workloads referencing it change
+	cmFile := filepath.Join(nsDir, "jx", "shared-config-cm.yaml")
+	cm, err := kyaml.ReadFile(cmFile)
+	require.NoError(t, err, "failed to load %s", cmFile)
+	require.NoError(t, cm.PipeE(kyaml.SetField("data", kyaml.NewMapRNode(&map[string]string{"config.yaml": "foo: changed"}))))
+	require.NoError(t, kyaml.WriteFile(cm, cmFile))
+
+	run()
+
+	assert.Equal(t, app1, podTemplateHash(filepath.Join("jx", "app1-deploy.yaml"), false), "app1 hash should not change")
+	assert.NotEqual(t, app2, podTemplateHash(filepath.Join("jx", "app2-deploy.yaml"), false), "app2 hash should change")
+	assert.Equal(t, otherApp2, podTemplateHash(filepath.Join("other", "app2-deploy.yaml"), false), "app2 in other namespace should not change")
+	assert.Equal(t, cleanup, podTemplateHash(filepath.Join("jx", "cleanup-cronjob.yaml"), true), "cleanup hash should not change")
+
+	// the Secret and ExternalSecret of the same name should both contribute to the hash
+	esFile := filepath.Join(nsDir, "jx", "app1-token-externalsecret.yaml")
+	es, err := kyaml.ReadFile(esFile)
+	require.NoError(t, err, "failed to load %s", esFile)
+	require.NoError(t, es.PipeE(kyaml.Lookup("spec", "secretStoreRef"), kyaml.SetField("name", kyaml.NewStringRNode("another-store"))))
+	require.NoError(t, kyaml.WriteFile(es, esFile))
+
+	run()
+
+	cleanupWithChangedExternalSecret := podTemplateHash(filepath.Join("jx", "cleanup-cronjob.yaml"), true)
+	assert.NotEqual(t, cleanup, cleanupWithChangedExternalSecret, "cleanup hash should change when the ExternalSecret changes")
+
+	secretFile := filepath.Join(nsDir, "jx", "app1-token-secret.yaml")
+	secret, err := kyaml.ReadFile(secretFile)
+	require.NoError(t, err, "failed to load %s", secretFile)
+	require.NoError(t, secret.PipeE(kyaml.SetField("data", kyaml.NewMapRNode(&map[string]string{"token": "Y2hhbmdlZA=="}))))
+	require.NoError(t, kyaml.WriteFile(secret, secretFile))
+
+	run()
+
+	assert.NotEqual(t, cleanupWithChangedExternalSecret, podTemplateHash(filepath.Join("jx", "cleanup-cronjob.yaml"), true), "cleanup hash should change when the Secret changes")
+
+	// lets remove the sources of the Secret and check the stale annotation is removed
+	require.NoError(t, os.Remove(esFile))
+	require.NoError(t, os.Remove(secretFile))
+
+	run()
+
+	assert.Empty(t, podTemplateHash(filepath.Join("jx", "cleanup-cronjob.yaml"), true), "cleanup annotation should be removed")
+	assert.NotEmpty(t, podTemplateHash(filepath.Join("jx", "app1-deploy.yaml"), false), "app1 should still be annotated from its ConfigMap")
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/escape"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/mirror"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/release"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/verify"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/spf13/cobra"
@@ -27,5 +28,6 @@ func NewCmdHelm() *cobra.Command {
 	command.AddCommand(cobras.SplitCommand(escape.NewCmdEscape()))
 	command.AddCommand(cobras.SplitCommand(mirror.NewCmdMirror()))
 	command.AddCommand(cobras.SplitCommand(release.NewCmdHelmRelease()))
+	command.AddCommand(cobras.SplitCommand(verify.NewCmdHelmVerify()))
 	return command
 }
@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/ghpages"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
@@ -32,30 +35,49 @@ var (
 	info = termcolor.ColorInfo
 
 	cmdLong = templates.LongDesc(`
-		Mirrors a set of remote Helm repositories specified locally in charts/repositories.yml to a remote git repository
+		Mirrors a set of remote Helm repositories specified locally in charts/repositories.yml to a remote git repository,
+		an OCI registry or a local directory.
+
+		Only chart versions which are missing from the target are transferred.
 `)
 
 	cmdExample = templates.Examples(`
 	# Mirror all Helm repositories defined in charts/repositories.yml to a default github pages branch
-	%s mirror --url=https://github.com/example/charts.git --no-push=false
-	
+	%s helm mirror --url=https://github.com/example/charts.git --no-push=false
+
 	# Run the mirror command, ignoring unused repositories
-	%s mirror --url=https://github.com/example/charts.git --no-push=false --exclude=bitnami
+	%[1]s helm mirror --url=https://github.com/example/charts.git --no-push=false --exclude=bitnami
+
+	# Mirror the nginx charts with a version of at least 4.0.0 to an OCI registry
+	%[1]s helm mirror --kind oci --url ghcr.io/example/charts --chart 'nginx*' --version-range '>= 4.0.0'
+
+	# Mirror all Helm repositories to a local directory writing a report of the added charts
+	%[1]s helm mirror --kind dir --out-dir /tmp/charts --report mirror-report.yaml
 	`)
 )
 
 // Options the options for the command
 type Options struct {
 	scmhelpers.Factory
-	Dir              string
-	RepositoriesFile string
-	Branch           string
-	GitURL           string
-	CommitMessage    string
-	Excludes         []string
-	NoPush           bool
-	GitClient        gitclient.Interface
-	CommandRunner    cmdrunner.CommandRunner
+	Dir                string
+	RepositoriesFile   string
+	Branch             string
+	GitURL             string
+	CommitMessage      string
+	Kind               string
+	OutDir             string
+	HelmBinary         string
+	RegistryConfigFile string
+	VersionRange       string
+	ReportFile         string
+	Excludes           []string
+	Charts             []string
+	NoPush             bool
+	PlainHTTP          bool
+	GitClient          gitclient.Interface
+	CommandRunner      cmdrunner.CommandRunner
+	Report             Report
+	versionConstraint  *semver.Constraints
 }
 
 // NewCmdMirror creates a command object for the command
@@ -74,7 +96,15 @@ func NewCmdMirror() (*cobra.Command, *Options) {
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory which contains the charts/repositories.yml file")
 	cmd.Flags().StringVarP(&o.Branch, "branch", "b", "gh-pages", "the git branch to clone the repository")
-	cmd.Flags().StringVarP(&o.GitURL, "url", "u", "", "the git URL of the repository to mirror the charts into")
+	cmd.Flags().StringVarP(&o.Kind, "kind", "", TargetGit, fmt.Sprintf("the kind of mirror target. Possible values: %s", strings.Join(Targets, ", ")))
+	cmd.Flags().StringVarP(&o.GitURL, "url", "u", "", "the git URL of the repository or the OCI registry URL to mirror the charts into")
+	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "", "", "the directory to mirror the charts into if using the dir kind")
+	cmd.Flags().StringVarP(&o.HelmBinary, "helm-binary", "", "helm", "the helm binary used to push charts to an OCI registry")
+	cmd.Flags().StringVarP(&o.RegistryConfigFile, "registry-config", "", "", "the path to the registry config for the OCI registry")
+	cmd.Flags().BoolVarP(&o.PlainHTTP, "plain-http", "", false, "use insecure HTTP connections for the OCI registry")
+	cmd.Flags().StringArrayVarP(&o.Charts, "chart", "", nil, "the names of the charts to mirror. Supports wildcards such as 'nginx*'. If not specified all charts are mirrored")
+	cmd.Flags().StringVarP(&o.VersionRange, "version-range", "", "", "the semver range of chart versions to mirror such as '>= 1.2.0 < 2.0.0'. If not specified all versions are mirrored")
+	cmd.Flags().StringVarP(&o.ReportFile, "report", "", "", "the file to write the YAML report of the mirrored charts to")
 	cmd.Flags().StringVarP(&o.CommitMessage, "message", "m", "chore: upgrade mirrored charts", "the commit message")
 	cmd.Flags().BoolVarP(&o.NoPush, "no-push", "", true, "disables pushing changes back to the git repository")
 	cmd.Flags().StringArrayVarP(&o.Excludes, "exclude", "x", []string{"jenkins-x", "jx3"}, "the helm repositories to exclude from mirroring")
@@ -85,12 +115,44 @@ func NewCmdMirror() (*cobra.Command, *Options) {
 
 // Validate the arguments
 func (o *Options) Validate() error {
-	if o.GitURL == "" {
-		return options.MissingOption("url")
+	if o.Kind == "" {
+		o.Kind = TargetGit
+	}
+	if stringhelpers.StringArrayIndex(Targets, o.Kind) < 0 {
+		return options.InvalidOption("kind", o.Kind, Targets)
+	}
+	if o.VersionRange != "" {
+		c, err := semver.NewConstraint(o.VersionRange)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse version range %s", o.VersionRange)
+		}
+		o.versionConstraint = c
+	}
+	if o.HelmBinary == "" {
+		o.HelmBinary = "helm"
 	}
 	if o.CommandRunner == nil {
 		o.CommandRunner = cmdrunner.DefaultCommandRunner
 	}
+	o.Report.Target = o.Kind
+
+	switch o.Kind {
+	case TargetDir:
+		if o.OutDir == "" {
+			return options.MissingOption("out-dir")
+		}
+		return nil
+	case TargetOCI:
+		if o.GitURL == "" {
+			return options.MissingOption("url")
+		}
+		o.GitURL = strings.TrimSuffix(strings.TrimPrefix(o.GitURL, "oci://"), "/")
+		return nil
+	}
+
+	if o.GitURL == "" {
+		return options.MissingOption("url")
+	}
 	if o.GitClient == nil {
 		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
 	}
@@ -132,30 +194,52 @@ func (o *Options) Run() error {
 		return errors.Errorf("could not find charts/repositories.yml file in dir %s", o.Dir)
 	}
 
-	gitDir, err := ghpages.CloneGitHubPagesToDir(o.GitClient, o.GitURL, o.Branch, o.GitUsername, o.GitToken)
-	if err != nil {
-		return errors.Wrapf(err, "failed to clone the github pages repo %s branch %s", o.GitURL, o.Branch)
-	}
-	if gitDir == "" {
-		return errors.Errorf("no github pages clone dir")
+	var gitDir string
+	targetDir := o.OutDir
+	if o.Kind == TargetGit {
+		gitDir, err = ghpages.CloneGitHubPagesToDir(o.GitClient, o.GitURL, o.Branch, o.GitUsername, o.GitToken)
+		if err != nil {
+			return errors.Wrapf(err, "failed to clone the github pages repo %s branch %s", o.GitURL, o.Branch)
+		}
+		if gitDir == "" {
+			return errors.Errorf("no github pages clone dir")
+		}
+		log.Logger().Infof("cloned github pages repository to %s", info(gitDir))
+		targetDir = gitDir
 	}
-	log.Logger().Infof("cloned github pages repository to %s", info(gitDir))
 
 	for _, repo := range prefixes.Repositories {
 		name := repo.Prefix
 		if stringhelpers.StringArrayIndex(o.Excludes, name) >= 0 {
 			continue
 		}
-		outDir := filepath.Join(gitDir, name)
-		err = os.MkdirAll(outDir, files.DefaultDirWritePermissions)
-		if err != nil {
-			return errors.Wrapf(err, "failed to create dir %s", outDir)
+		target := o.GitURL + "/" + name
+		if o.Kind != TargetOCI {
+			target = filepath.Join(targetDir, name)
+			err = os.MkdirAll(target, files.DefaultDirWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create dir %s", target)
+			}
 		}
 
-		err = o.MirrorRepository(outDir, repo.URLs)
+		report := RepositoryReport{Name: name}
+		err = o.MirrorRepository(target, repo.URLs, &report)
 		if err != nil {
 			return errors.Wrapf(err, "failed to mirror repository %s", name)
 		}
+		o.Report.Repositories = append(o.Report.Repositories, report)
+	}
+
+	log.Logger().Infof("mirrored %s chart versions", info(strconv.Itoa(o.Report.TotalAdded())))
+	if o.ReportFile != "" {
+		err = WriteReport(&o.Report, o.ReportFile)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("saved mirror report to %s", info(o.ReportFile))
+	}
+	if o.Kind != TargetGit {
+		return nil
 	}
 
 	changes, err := gitclient.AddAndCommitFiles(o.GitClient, gitDir, o.CommitMessage)
@@ -184,10 +268,16 @@ func (o *Options) Run() error {
 	return nil
 }
 
-// MirrorRepository downloads the index yaml and all the referenced charts to the given directory
-func (o *Options) MirrorRepository(dir string, urls []string) error {
+// MirrorRepository downloads the index of the repository and syncs any missing chart versions to the target
+func (o *Options) MirrorRepository(target string, urls []string, report *RepositoryReport) error {
+	tmpDir, err := os.MkdirTemp("", "jx-helm-mirror-index-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
 	for _, u := range urls {
-		path := filepath.Join(dir, "index.yaml")
+		path := filepath.Join(tmpDir, "index.yaml")
 		indexURL := stringhelpers.UrlJoin(u, "index.yaml")
 		err := downloadURLToFile(indexURL, path)
 		if err != nil {
@@ -201,36 +291,14 @@ func (o *Options) MirrorRepository(dir string, urls []string) error {
 			return nil
 		}
 
-		log.Logger().Infof("downloaded %s", info(path))
-		err = o.DownloadIndex(idx, u, dir)
-		if err != nil {
-			return errors.Wrapf(err, "failed to download index for %s", dir)
+		log.Logger().Infof("downloaded %s", info(indexURL))
+		if o.Kind == TargetOCI {
+			err = o.SyncToOCI(idx, u, target, report)
+		} else {
+			err = o.SyncToDir(idx, u, target, report)
 		}
-	}
-	return nil
-}
-
-func (o *Options) DownloadIndex(idx *repo.IndexFile, u, dir string) error {
-	for _, v := range idx.Entries {
-		for _, cv := range v {
-			for _, name := range cv.URLs {
-				path := filepath.Join(dir, name)
-				exists, err := files.FileExists(path)
-				if err != nil {
-					return errors.Wrapf(err, "failed to check for path %s", path)
-				}
-				if exists {
-					continue
-				}
-
-				fileURL := stringhelpers.UrlJoin(u, name)
-				err = downloadURLToFile(fileURL, path)
-				if err != nil {
-					log.Logger().Warnf("failed to download %s", fileURL)
-					continue
-				}
-				log.Logger().Infof("downloaded %s", info(path))
-			}
+		if err != nil {
+			return errors.Wrapf(err, "failed to sync index %s", indexURL)
 		}
 	}
 	return nil
@@ -0,0 +1,287 @@
+package mirror
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/chartsigning"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/repo"
+	"oras.land/oras-go/v2/errdef"
+)
+
+const (
+	// TargetGit mirrors charts into a git repository branch such as GitHub Pages
+	TargetGit = "git"
+
+	// TargetOCI mirrors charts into an OCI registry
+	TargetOCI = "oci"
+
+	// TargetDir mirrors charts into a local directory
+	TargetDir = "dir"
+)
+
+// Targets the supported mirror targets
+var Targets = []string{TargetGit, TargetOCI, TargetDir}
+
+// Report the report of a mirror
+type Report struct {
+	Target       string             `json:"target"`
+	Repositories []RepositoryReport `json:"repositories,omitempty"`
+}
+
+// RepositoryReport the charts mirrored for a repository
+type RepositoryReport struct {
+	Name    string         `json:"name"`
+	Added   []ChartVersion `json:"added,omitempty"`
+	Skipped int            `json:"skipped,omitempty"`
+	Failed  []ChartVersion `json:"failed,omitempty"`
+}
+
+// ChartVersion a version of a chart
+type ChartVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// TotalAdded returns the total number of chart versions added
+func (r *Report) TotalAdded() int {
+	count := 0
+	for i := range r.Repositories {
+		count += len(r.Repositories[i].Added)
+	}
+	return count
+}
+
+// Matches returns true if the chart version matches the chart name and version range filters
+func (o *Options) Matches(cv *repo.ChartVersion) bool {
+	if cv.Metadata == nil {
+		return false
+	}
+	if len(o.Charts) > 0 {
+		matched := false
+		for _, pattern := range o.Charts {
+			m, err := filepath.Match(pattern, cv.Name)
+			if err == nil && m {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if o.versionConstraint != nil {
+		v, err := semver.NewVersion(cv.Version)
+		if err != nil || !o.versionConstraint.Check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// SyncToDir downloads the chart versions from the source index which are missing from the index in the directory
+// and then adds them to the index in the directory
+func (o *Options) SyncToDir(idx *repo.IndexFile, u, dir string, report *RepositoryReport) error {
+	indexPath := filepath.Join(dir, "index.yaml")
+	destIdx := repo.NewIndexFile()
+	exists, err := files.FileExists(indexPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for path %s", indexPath)
+	}
+	if exists {
+		destIdx, err = repo.LoadIndexFile(indexPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load index file %s", indexPath)
+		}
+	}
+
+	for _, versions := range idx.Entries {
+		for _, cv := range versions {
+			if !o.Matches(cv) {
+				continue
+			}
+			if hasChartVersion(destIdx, cv.Name, cv.Version) && chartFilesExist(cv, dir) {
+				report.Skipped++
+				continue
+			}
+
+			cv.URLs, err = downloadChartURLs(cv, u, dir)
+			if err != nil {
+				log.Logger().Warnf("failed to download %s %s: %s", cv.Name, cv.Version, err.Error())
+				report.Failed = append(report.Failed, ChartVersion{Name: cv.Name, Version: cv.Version})
+				continue
+			}
+			if !hasChartVersion(destIdx, cv.Name, cv.Version) {
+				destIdx.Entries[cv.Name] = append(destIdx.Entries[cv.Name], cv)
+			}
+			report.Added = append(report.Added, ChartVersion{Name: cv.Name, Version: cv.Version})
+			log.Logger().Infof("mirrored chart %s version %s", info(cv.Name), info(cv.Version))
+		}
+	}
+	if len(report.Added) == 0 && exists {
+		return nil
+	}
+	destIdx.SortEntries()
+	err = destIdx.WriteFile(indexPath, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save index file %s", indexPath)
+	}
+	return nil
+}
+
+// SyncToOCI pushes the chart versions from the source index which are missing from the OCI registry
+func (o *Options) SyncToOCI(idx *repo.IndexFile, u, registryURL string, report *RepositoryReport) error {
+	tmpDir, err := os.MkdirTemp("", "jx-helm-mirror-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for name, versions := range idx.Entries {
+		var tags []string
+		tagsLoaded := false
+		for _, cv := range versions {
+			if !o.Matches(cv) {
+				continue
+			}
+			if !tagsLoaded {
+				tags, err = o.registryTags(registryURL + "/" + name)
+				if err != nil {
+					return errors.Wrapf(err, "failed to list the versions of chart %s", name)
+				}
+				tagsLoaded = true
+			}
+			if stringhelpers.StringArrayIndex(tags, chartsigning.OCITag(cv.Version)) >= 0 {
+				report.Skipped++
+				continue
+			}
+
+			urls, err := downloadChartURLs(cv, u, tmpDir)
+			if err != nil || len(urls) == 0 {
+				log.Logger().Warnf("failed to download %s %s: %v", cv.Name, cv.Version, err)
+				report.Failed = append(report.Failed, ChartVersion{Name: cv.Name, Version: cv.Version})
+				continue
+			}
+			args := []string{"push", filepath.Join(tmpDir, urls[0]), "oci://" + registryURL}
+			if o.RegistryConfigFile != "" {
+				args = append(args, "--registry-config", o.RegistryConfigFile)
+			}
+			if o.PlainHTTP {
+				args = append(args, "--plain-http")
+			}
+			c := &cmdrunner.Command{
+				Name: o.HelmBinary,
+				Args: args,
+			}
+			_, err = o.CommandRunner(c)
+			if err != nil {
+				log.Logger().Warnf("failed to push %s %s: %s", cv.Name, cv.Version, err.Error())
+				report.Failed = append(report.Failed, ChartVersion{Name: cv.Name, Version: cv.Version})
+				continue
+			}
+			report.Added = append(report.Added, ChartVersion{Name: cv.Name, Version: cv.Version})
+			log.Logger().Infof("mirrored chart %s version %s to %s", info(cv.Name), info(cv.Version), info(registryURL))
+		}
+	}
+	return nil
+}
+
+// registryTags returns the tags of the OCI repository or an empty list if it does not exist yet
+func (o *Options) registryTags(reference string) ([]string, error) {
+	r, err := chartsigning.NewRepository(reference, &chartsigning.RegistryOptions{
+		RegistryConfigFile: o.RegistryConfigFile,
+		PlainHTTP:          o.PlainHTTP,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var answer []string
+	err = r.Tags(context.Background(), "", func(tags []string) error {
+		answer = append(answer, tags...)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) || strings.Contains(err.Error(), "NAME_UNKNOWN") {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to list tags of %s", reference)
+	}
+	return answer, nil
+}
+
+// WriteReport writes the mirror report to the given file
+func WriteReport(report *Report, path string) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	err = yamls.SaveFile(report, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save mirror report %s", path)
+	}
+	return nil
+}
+
+// downloadChartURLs downloads the chart files into the directory returning the URLs relative to the directory
+func downloadChartURLs(cv *repo.ChartVersion, u, dir string) ([]string, error) {
+	var answer []string
+	for _, name := range cv.URLs {
+		fileURL := name
+		if isAbsoluteURL(name) {
+			name = path.Base(name)
+		} else {
+			fileURL = stringhelpers.UrlJoin(u, name)
+		}
+		p := filepath.Join(dir, name)
+		exists, err := files.FileExists(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check for path %s", p)
+		}
+		if !exists {
+			err = downloadURLToFile(fileURL, p)
+			if err != nil {
+				return nil, err
+			}
+			log.Logger().Debugf("downloaded %s", p)
+		}
+		answer = append(answer, name)
+	}
+	return answer, nil
+}
+
+func chartFilesExist(cv *repo.ChartVersion, dir string) bool {
+	for _, name := range cv.URLs {
+		if isAbsoluteURL(name) {
+			name = path.Base(name)
+		}
+		exists, err := files.FileExists(filepath.Join(dir, name))
+		if err != nil || !exists {
+			return false
+		}
+	}
+	return true
+}
+
+func hasChartVersion(idx *repo.IndexFile, name, version string) bool {
+	for _, cv := range idx.Entries[name] {
+		if cv.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func isAbsoluteURL(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
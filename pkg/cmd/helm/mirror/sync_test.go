@@ -0,0 +1,114 @@
+package mirror_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/mirror"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/internal/fakeregistry"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestHelmMirrorToDir(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Join("testdata", "source"))))
+	defer server.Close()
+
+	versionStreamDir := createVersionStream(t, server.URL)
+	outDir := t.TempDir()
+	reportFile := filepath.Join(t.TempDir(), "report.yaml")
+
+	_, o := mirror.NewCmdMirror()
+	o.Dir = versionStreamDir
+	o.Kind = mirror.TargetDir
+	o.OutDir = outDir
+	o.Charts = []string{"my*"}
+	o.VersionRange = "< 2.0.0"
+	o.ReportFile = reportFile
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	assert.Equal(t, []mirror.ChartVersion{{Name: "myapp", Version: "1.1.0"}, {Name: "myapp", Version: "1.0.0"}}, o.Report.Repositories[0].Added)
+	assert.FileExists(t, filepath.Join(outDir, "myrepo", "myapp-1.0.0.tgz"))
+	assert.FileExists(t, filepath.Join(outDir, "myrepo", "myapp-1.1.0.tgz"))
+	assert.NoFileExists(t, filepath.Join(outDir, "myrepo", "myapp-2.0.0.tgz"))
+	assert.NoFileExists(t, filepath.Join(outDir, "myrepo", "other-0.1.0.tgz"))
+
+	idx, err := repo.LoadIndexFile(filepath.Join(outDir, "myrepo", "index.yaml"))
+	require.NoError(t, err, "failed to load mirrored index")
+	assert.Len(t, idx.Entries["myapp"], 2)
+	assert.Empty(t, idx.Entries["other"])
+
+	report := &mirror.Report{}
+	err = yamls.LoadFile(reportFile, report)
+	require.NoError(t, err, "failed to load report")
+	assert.Equal(t, mirror.TargetDir, report.Target)
+	assert.Equal(t, 2, report.TotalAdded())
+
+	// lets sync again without filters which should only transfer the missing versions
+	_, o = mirror.NewCmdMirror()
+	o.Dir = versionStreamDir
+	o.Kind = mirror.TargetDir
+	o.OutDir = outDir
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	r := o.Report.Repositories[0]
+	assert.ElementsMatch(t, []mirror.ChartVersion{{Name: "myapp", Version: "2.0.0"}, {Name: "other", Version: "0.1.0"}}, r.Added)
+	assert.Equal(t, 2, r.Skipped)
+
+	idx, err = repo.LoadIndexFile(filepath.Join(outDir, "myrepo", "index.yaml"))
+	require.NoError(t, err, "failed to load mirrored index")
+	assert.Len(t, idx.Entries["myapp"], 3)
+	assert.Len(t, idx.Entries["other"], 1)
+}
+
+func TestHelmMirrorToOCI(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Join("testdata", "source"))))
+	defer server.Close()
+
+	registry, _ := fakeregistry.NewServer()
+	defer registry.Close()
+	host := fakeregistry.Host(registry)
+
+	// lets pretend one version has already been mirrored
+	require.NoError(t, fakeregistry.PushChart(host+"/charts/myrepo/myapp", "1.0.0"))
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := mirror.NewCmdMirror()
+	o.Dir = createVersionStream(t, server.URL)
+	o.Kind = mirror.TargetOCI
+	o.GitURL = "oci://" + host + "/charts"
+	o.PlainHTTP = true
+	o.Charts = []string{"myapp"}
+	o.CommandRunner = runner.Run
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	r := o.Report.Repositories[0]
+	assert.ElementsMatch(t, []mirror.ChartVersion{{Name: "myapp", Version: "2.0.0"}, {Name: "myapp", Version: "1.1.0"}}, r.Added)
+	assert.Equal(t, 1, r.Skipped)
+
+	require.Len(t, runner.OrderedCommands, 2)
+	for _, c := range runner.OrderedCommands {
+		require.Len(t, c.Args, 4)
+		assert.Equal(t, "push", c.Args[0])
+		assert.Equal(t, "oci://"+host+"/charts/myrepo", c.Args[2])
+		assert.Equal(t, "--plain-http", c.Args[3])
+	}
+}
+
+func createVersionStream(t *testing.T, u string) string {
+	dir := t.TempDir()
+	chartsDir := filepath.Join(dir, "charts")
+	require.NoError(t, os.MkdirAll(chartsDir, 0o755))
+	text := "repositories:\n- prefix: myrepo\n  urls:\n  - " + u + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(chartsDir, "repositories.yml"), []byte(text), 0o600))
+	return dir
+}
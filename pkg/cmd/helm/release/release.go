@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/chart"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/chartsigning"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/ghpages"
@@ -50,6 +51,9 @@ var (
 
 		# Performs a release of a specific chart in the charts folder
 		%[1]s helm release myapp
+
+		# Performs a release generating a provenance file and signing the chart pushed to an OCI registry
+		%[1]s helm release --provenance-key "My Key" --keyring ~/.gnupg/secring.gpg --sign-key cosign.key
 	`)
 
 	defaultReadMe = `
@@ -82,6 +86,7 @@ type Options struct {
 	ChartPages           bool
 	NoOCILogin           bool
 	Artifactory          bool
+	PlainHTTP            bool
 	HelmBinary           string
 	Dir                  string
 	ChartsDir            string
@@ -98,6 +103,10 @@ type Options struct {
 	RegistryConfigFile   string
 	ContainerRegistryOrg string
 	GitHubPagesDir       string
+	ProvenanceKey        string
+	Keyring              string
+	PassphraseFile       string
+	SignKey              string
 	IgnoreChartNames     []string
 	KubeClient           kubernetes.Interface
 	JXClient             jxc.Interface
@@ -139,6 +148,11 @@ func NewCmdHelmRelease() (*cobra.Command, *Options) {
 	cmd.Flags().BoolVarP(&o.ChartOCI, "oci", "", false, "treat the repository as an OCI container registry. If not specified its defaulted from the cluster.chartOCI flag on the 'jx-requirements.yml' file")
 	cmd.Flags().BoolVarP(&o.Artifactory, "artifactory", "", false, "use artifactory mode for publishing the chart which involves using an artifactory header and -T for pushing the chart")
 	cmd.Flags().BoolVarP(&o.NoOCILogin, "no-oci-login", "", false, "disables using the 'helm registry login' command when using OCI")
+	cmd.Flags().BoolVarP(&o.PlainHTTP, "plain-http", "", false, "use insecure HTTP connections for the OCI registry")
+	cmd.Flags().StringVarP(&o.ProvenanceKey, "provenance-key", "", "", "the name of the PGP key in the keyring used to generate a .prov provenance file for each chart. If not specified no provenance file is generated")
+	cmd.Flags().StringVarP(&o.Keyring, "keyring", "", "", "the PGP keyring containing the provenance key. If not specified the helm default is used")
+	cmd.Flags().StringVarP(&o.PassphraseFile, "passphrase-file", "", "", "the file containing the passphrase of the provenance key")
+	cmd.Flags().StringVarP(&o.SignKey, "sign-key", "", "", "the PEM encoded ECDSA private key file used to sign the chart once its pushed to an OCI registry. If not specified charts are not signed")
 
 	cmd.Flags().BoolVarP(&o.NoRelease, "no-release", "", false, "disables publishing the release. Useful for a Pull Request pipeline")
 	cmd.Flags().BoolVarP(&o.UseHelmPlugin, "use-helm-plugin", "", false, "uses the jx binary plugin for helm rather than whatever helm is on the $PATH")
@@ -256,6 +270,9 @@ func (o *Options) Run(includeRepos ...string) error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to validate")
 	}
+	if o.SignKey != "" && (o.ChartPages || !o.ChartOCI) {
+		return errors.Errorf("cannot use --sign-key as charts can only be signed when they are released to an OCI registry")
+	}
 	dir := o.ChartsDir
 	exists, err := files.DirExists(dir)
 	if err != nil {
@@ -350,15 +367,50 @@ func (o *Options) oCIRegistry(repoURL, chartDir, name string) error {
 		return nil
 	}
 
+	args := []string{"push", chartPackageName, "oci://" + repoURL, "--registry-config", o.RegistryConfigFile}
+	if o.PlainHTTP {
+		args = append(args, "--plain-http")
+	}
 	c = &cmdrunner.Command{
 		Dir:  chartDir,
 		Name: o.HelmBinary,
-		Args: []string{"push", chartPackageName, "oci://" + repoURL, "--registry-config", o.RegistryConfigFile},
+		Args: args,
 	}
 	_, err = o.CommandRunner(c)
 	if err != nil {
 		return errors.Wrapf(err, "failed to push chart %s", qualifiedChartName)
 	}
+	if o.SignKey != "" {
+		err = o.signOCIChart(qualifiedChartName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign chart %s", qualifiedChartName)
+		}
+	}
+	return nil
+}
+
+// signOCIChart signs the chart version pushed to the OCI registry
+func (o *Options) signOCIChart(qualifiedChartName string) error {
+	key, err := chartsigning.LoadPrivateKey(o.SignKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load signing key")
+	}
+	repo, err := chartsigning.NewRepository(qualifiedChartName, &chartsigning.RegistryOptions{
+		Username:           o.RepositoryUsername,
+		Password:           o.RepositoryPassword,
+		RegistryConfigFile: o.RegistryConfigFile,
+		PlainHTTP:          o.PlainHTTP,
+	})
+	if err != nil {
+		return err
+	}
+
+	tag := chartsigning.OCITag(o.Version)
+	d, err := chartsigning.Sign(context.Background(), repo, tag, key)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("signed chart %s:%s with digest %s", info(qualifiedChartName), tag, info(d.String()))
 	return nil
 }
 
@@ -430,7 +482,7 @@ func (o *Options) ChartPageRegistry(repoURL, chartDir, name string) error {
 	}
 	for _, f := range fs {
 		fileName := f.Name()
-		if f.IsDir() || !strings.HasSuffix(fileName, ".tgz") {
+		if f.IsDir() || !(strings.HasSuffix(fileName, ".tgz") || strings.HasSuffix(fileName, ".tgz.prov")) {
 			continue
 		}
 		path := filepath.Join(chartDir, fileName)
@@ -518,13 +570,15 @@ func (o *Options) BasicRegistry(repoURL, chartDir, name string) error {
 		return nil
 	}
 
-	c, err = o.createPublishCommand(repoURL, name, chartDir, username, password)
+	commands, err := o.createPublishCommands(repoURL, name, chartDir, username, password)
 	if err != nil {
 		return errors.Wrapf(err, "failed to publish")
 	}
-	_, err = o.CommandRunner(c)
-	if err != nil {
-		return errors.Wrapf(err, "failed to publish")
+	for _, c := range commands {
+		_, err = o.CommandRunner(c)
+		if err != nil {
+			return errors.Wrapf(err, "failed to publish")
+		}
 	}
 	return nil
 }
@@ -581,10 +635,20 @@ func (o *Options) BuildAndPackage(chartDir string) error {
 		return errors.Wrapf(err, "failed to lint")
 	}
 
+	args := []string{"package", "."}
+	if o.ProvenanceKey != "" {
+		args = append(args, "--sign", "--key", o.ProvenanceKey)
+		if o.Keyring != "" {
+			args = append(args, "--keyring", o.Keyring)
+		}
+		if o.PassphraseFile != "" {
+			args = append(args, "--passphrase-file", o.PassphraseFile)
+		}
+	}
 	c = &cmdrunner.Command{
 		Dir:  chartDir,
 		Name: o.HelmBinary,
-		Args: []string{"package", "."},
+		Args: args,
 	}
 	_, err = o.CommandRunner(c)
 	if err != nil {
@@ -593,34 +657,51 @@ func (o *Options) BuildAndPackage(chartDir string) error {
 	return nil
 }
 
-func (o *Options) createPublishCommand(repoURL, name, chartDir, username, password string) (*cmdrunner.Command, error) {
+// createPublishCommands creates the commands to publish the chart along with its provenance file if one was generated
+func (o *Options) createPublishCommands(repoURL, name, chartDir, username, password string) ([]*cmdrunner.Command, error) {
 	tarFile := name + "-" + o.Version + ".tgz"
+	provFile := ""
+	if o.ProvenanceKey != "" {
+		provFile = tarFile + ".prov"
+	}
 
 	if strings.HasPrefix(repoURL, "gs:") {
 		// use gcs to push the chart
-		return &cmdrunner.Command{
-			Dir:  chartDir,
-			Name: o.HelmBinary,
-			Args: []string{"gcs", "push", tarFile, o.RepositoryName},
+		return []*cmdrunner.Command{
+			{
+				Dir:  chartDir,
+				Name: o.HelmBinary,
+				Args: []string{"gcs", "push", tarFile, o.RepositoryName},
+			},
 		}, nil
 	}
 
 	if strings.HasPrefix(repoURL, "s3:") {
 		// use s3 to push the chart
-		return &cmdrunner.Command{
-			Dir:  chartDir,
-			Name: o.HelmBinary,
-			Args: []string{"s3", "push", "--relative", tarFile, o.RepositoryName},
-		}, nil
+		commands := []*cmdrunner.Command{
+			{
+				Dir:  chartDir,
+				Name: o.HelmBinary,
+				Args: []string{"s3", "push", "--relative", tarFile, o.RepositoryName},
+			},
+		}
+		if provFile != "" {
+			// the s3 plugin only pushes the chart so lets copy the provenance file next to it
+			commands = append(commands, &cmdrunner.Command{
+				Dir:  chartDir,
+				Name: "aws",
+				Args: []string{"s3", "cp", provFile, stringhelpers.UrlJoin(repoURL, provFile)},
+			})
+		}
+		return commands, nil
 	}
 
 	if o.Artifactory {
 		// lets try detect the git repository name
-		url := stringhelpers.UrlJoin(repoURL, tarFile)
-
+		url := repoURL
 		repoName := os.Getenv("REPO_NAME")
 		if repoName != "" {
-			url = stringhelpers.UrlJoin(repoURL, repoName, tarFile)
+			url = stringhelpers.UrlJoin(repoURL, repoName)
 		}
 
 		if password == "" {
@@ -628,30 +709,47 @@ func (o *Options) createPublishCommand(repoURL, name, chartDir, username, passwo
 		}
 		apiKey := "X-JFrog-Art-Api:" + password
 
-		return &cmdrunner.Command{
-			Dir:  chartDir,
-			Name: "curl",
-			// lets hide progress bars (-s) and enable show errors (-S)
-			Args: []string{"--fail", "-sS", "-H", apiKey, "-T", tarFile, url},
-		}, nil
+		var commands []*cmdrunner.Command
+		for _, f := range []string{tarFile, provFile} {
+			if f == "" {
+				continue
+			}
+			commands = append(commands, &cmdrunner.Command{
+				Dir:  chartDir,
+				Name: "curl",
+				// lets hide progress bars (-s) and enable show errors (-S)
+				Args: []string{"--fail", "-sS", "-H", apiKey, "-T", f, stringhelpers.UrlJoin(url, f)},
+			})
+		}
+		return commands, nil
 	}
 	if username == "" || password == "" {
 		return nil, fmt.Errorf("user name or password missing for %s", repoURL)
 	}
 	userSecret := username + ":" + password
 
-	url := stringhelpers.UrlJoin(repoURL, "/api/charts")
-
+	apiURL := stringhelpers.UrlJoin(repoURL, "/api")
 	if o.RepositoryNested != "" {
-		url = stringhelpers.UrlJoin(repoURL, "/api/", o.RepositoryNested, "/charts")
+		apiURL = stringhelpers.UrlJoin(repoURL, "/api/", o.RepositoryNested)
 	}
 
-	return &cmdrunner.Command{
-		Dir:  chartDir,
-		Name: "curl",
-		// lets hide progress bars (-s) and enable show errors (-S)
-		Args: []string{"--fail", "-sS", "-u", userSecret, "--data-binary", "@" + tarFile, url},
-	}, nil
+	commands := []*cmdrunner.Command{
+		{
+			Dir:  chartDir,
+			Name: "curl",
+			// lets hide progress bars (-s) and enable show errors (-S)
+			Args: []string{"--fail", "-sS", "-u", userSecret, "--data-binary", "@" + tarFile, stringhelpers.UrlJoin(apiURL, "charts")},
+		},
+	}
+	if provFile != "" {
+		// chartmuseum accepts provenance files separately from the chart
+		commands = append(commands, &cmdrunner.Command{
+			Dir:  chartDir,
+			Name: "curl",
+			Args: []string{"--fail", "-sS", "-u", userSecret, "--data-binary", "@" + provFile, stringhelpers.UrlJoin(apiURL, "prov")},
+		})
+	}
+	return commands, nil
 }
 
 func (o *Options) findChartRepositoryUserPassword() (string, string, error) {
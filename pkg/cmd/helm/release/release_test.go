@@ -1,12 +1,21 @@
 package release_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/chartsigning"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/release"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/fakerunners"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/internal/fakeregistry"
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
 
@@ -458,3 +467,120 @@ func setupReleaseOCI(t *testing.T) (*fakerunner.FakeRunner, string, string, *rel
 
 	return runner, OCIRegistry, chartVersion, o, err
 }
+
+func TestStepHelmReleaseWithOCISigning(t *testing.T) {
+	server, _ := fakeregistry.NewServer()
+	defer server.Close()
+	host := fakeregistry.Host(server)
+
+	runner, _, chartVersion, o, err := setupReleaseOCI(t)
+	require.NoError(t, err, "failed to run the command")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	data, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "cosign.key")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: data}), 0o600)
+	require.NoError(t, err)
+
+	// the fake runner does not push the chart so lets push it to the registry first
+	require.NoError(t, fakeregistry.PushChart(host+"/myapp", chartVersion))
+
+	o.IgnoreChartNames = []string{"anotherchart", "preview"}
+	o.RepositoryURL = host
+	o.NoOCILogin = true
+	o.PlainHTTP = true
+	o.SignKey = keyFile
+	o.ProvenanceKey = "My Key"
+	o.Keyring = "secring.gpg"
+	err = o.Run()
+	require.NoError(t, err, "failed to run the command")
+	assert.Equal(t, o.ReleasedCharts, 1, "should have released 1 chart")
+
+	var clis []string
+	for _, c := range runner.OrderedCommands {
+		clis = append(clis, c.CLI())
+	}
+	assert.Contains(t, clis, helmPackage+" --sign --key My Key --keyring secring.gpg")
+	assert.Contains(t, clis, "helm push myapp-"+chartVersion+".tgz oci://"+host+" --registry-config "+o.RegistryConfigFile+" --plain-http")
+
+	repo, err := chartsigning.NewRepository(host+"/myapp", &chartsigning.RegistryOptions{PlainHTTP: true})
+	require.NoError(t, err)
+	_, err = chartsigning.Verify(context.Background(), repo, chartVersion, &key.PublicKey)
+	require.NoError(t, err, "should have signed the chart")
+}
+
+func TestStepHelmReleaseProvenance(t *testing.T) {
+	testCases := []struct {
+		name          string
+		repositoryURL string
+		artifactory   bool
+		expected      []string
+	}{
+		{
+			name:          "chartmuseum",
+			repositoryURL: "http://chartmuseum/",
+			expected: []string{
+				"curl --fail -sS -u myuser:mypwd --data-binary @myapp-1.2.3.tgz http://chartmuseum/api/charts",
+				"curl --fail -sS -u myuser:mypwd --data-binary @myapp-1.2.3.tgz.prov http://chartmuseum/api/prov",
+			},
+		},
+		{
+			name:          "artifactory",
+			repositoryURL: "https://artifactory/charts",
+			artifactory:   true,
+			expected: []string{
+				"curl --fail -sS -H X-JFrog-Art-Api:mypwd -T myapp-1.2.3.tgz https://artifactory/charts/myapp-1.2.3.tgz",
+				"curl --fail -sS -H X-JFrog-Art-Api:mypwd -T myapp-1.2.3.tgz.prov https://artifactory/charts/myapp-1.2.3.tgz.prov",
+			},
+		},
+		{
+			name:          "s3",
+			repositoryURL: "s3://mybucket/charts",
+			expected: []string{
+				"helm s3 push --relative myapp-1.2.3.tgz",
+				"aws s3 cp myapp-1.2.3.tgz.prov s3://mybucket/charts/myapp-1.2.3.tgz.prov",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		runner, _, _, o, err := setupReleaseOCI(t)
+		require.NoError(t, err, "failed to setup release for %s", tc.name)
+		o.ChartOCI = false
+		o.RepositoryURL = tc.repositoryURL
+		o.Artifactory = tc.artifactory
+		o.IgnoreChartNames = []string{"anotherchart", "preview"}
+		o.ProvenanceKey = "My Key"
+
+		err = o.Run()
+		require.NoError(t, err, "failed to run the command for %s", tc.name)
+
+		var clis []string
+		for _, c := range runner.OrderedCommands {
+			clis = append(clis, c.CLI())
+		}
+		for _, expected := range tc.expected {
+			found := false
+			for _, cli := range clis {
+				if strings.HasPrefix(cli, expected) {
+					found = true
+				}
+			}
+			assert.True(t, found, "should have run %s for %s but ran %v", expected, tc.name, clis)
+		}
+	}
+}
+
+func TestStepHelmReleaseSignKeyRequiresOCI(t *testing.T) {
+	_, _, _, o, err := setupReleaseOCI(t)
+	require.NoError(t, err, "failed to setup release")
+	o.ChartOCI = false
+	o.RepositoryURL = "http://chartmuseum/"
+	o.SignKey = "cosign.key"
+
+	err = o.Run()
+	require.Error(t, err, "should fail to sign a chart which is not released to an OCI registry")
+	assert.Contains(t, err.Error(), "--sign-key")
+}
@@ -0,0 +1,222 @@
+package verify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/chartsigning"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Verifies the signatures of helm charts before they are used
+
+		OCI charts are verified against the signature pushed by 'helm release --sign-key' using the given public key.
+		Chart packages are verified against their .prov provenance file using the given keyring.
+`)
+
+	cmdExample = templates.Examples(`
+		# verifies the signature of a chart in an OCI registry
+		%s helm verify --key cosign.pub --chart oci://ghcr.io/myorg/charts/myapp --version 1.2.3
+
+		# verifies the signatures of all the OCI charts in the helmfile
+		%[1]s helm verify --key cosign.pub --helmfile helmfile.yaml
+
+		# verifies the provenance of a chart package
+		%[1]s helm verify --keyring pubring.gpg myapp-1.2.3.tgz
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir                string
+	Helmfile           string
+	Key                string
+	Keyring            string
+	Charts             []string
+	Version            string
+	Username           string
+	Password           string
+	RegistryConfigFile string
+	PlainHTTP          bool
+	Packages           []string
+	Verified           []string
+}
+
+// NewCmdHelmVerify creates a command object for the command
+func NewCmdHelmVerify() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "verify [chart packages]",
+		Short:   "Verifies the signatures of helm charts before they are used",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, args []string) {
+			o.Packages = append(o.Packages, args...)
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "", ".", "the directory containing the helmfile")
+	cmd.Flags().StringVarP(&o.Helmfile, "helmfile", "", "", "the helmfile to verify the OCI chart releases of")
+	cmd.Flags().StringVarP(&o.Key, "key", "", "", "the PEM encoded ECDSA public key used to verify OCI chart signatures")
+	cmd.Flags().StringVarP(&o.Keyring, "keyring", "", "", "the PGP public keyring used to verify the provenance of chart packages")
+	cmd.Flags().StringArrayVarP(&o.Charts, "chart", "", nil, "the OCI charts to verify such as 'oci://myregistry.com/charts/myapp:1.2.3'")
+	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version of the charts to verify if not specified in the chart reference")
+	cmd.Flags().StringVarP(&o.Username, "username", "", "", "the username to access the OCI registry")
+	cmd.Flags().StringVarP(&o.Password, "password", "", "", "the password to access the OCI registry")
+	cmd.Flags().StringVarP(&o.RegistryConfigFile, "registry-config", "", "", "the path to the docker registry config containing the OCI registry credentials")
+	cmd.Flags().BoolVarP(&o.PlainHTTP, "plain-http", "", false, "use insecure HTTP connections for the OCI registry")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	charts := append([]string{}, o.Charts...)
+	if o.Helmfile != "" {
+		helmfileCharts, err := o.findHelmfileCharts()
+		if err != nil {
+			return err
+		}
+		charts = append(charts, helmfileCharts...)
+	}
+	if len(charts) == 0 && len(o.Packages) == 0 {
+		return errors.Errorf("no charts to verify. Please specify --chart, --helmfile or a chart package")
+	}
+
+	var failed []string
+	if len(charts) > 0 {
+		if o.Key == "" {
+			return options.MissingOption("key")
+		}
+		key, err := chartsigning.LoadPublicKey(o.Key)
+		if err != nil {
+			return err
+		}
+		for _, chart := range charts {
+			err = o.verifyOCIChart(chart, key)
+			if err != nil {
+				log.Logger().Warnf("failed to verify chart %s: %s", chart, err.Error())
+				failed = append(failed, chart)
+				continue
+			}
+			o.Verified = append(o.Verified, chart)
+		}
+	}
+
+	if len(o.Packages) > 0 {
+		if o.Keyring == "" {
+			return options.MissingOption("keyring")
+		}
+		sig, err := provenance.NewFromKeyring(o.Keyring, "")
+		if err != nil {
+			return errors.Wrapf(err, "failed to load keyring %s", o.Keyring)
+		}
+		for _, pkg := range o.Packages {
+			v, err := sig.Verify(pkg, pkg+".prov")
+			if err != nil {
+				log.Logger().Warnf("failed to verify chart package %s: %s", pkg, err.Error())
+				failed = append(failed, pkg)
+				continue
+			}
+			log.Logger().Infof("verified chart package %s with hash %s", info(pkg), info(v.FileHash))
+			o.Verified = append(o.Verified, pkg)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("failed to verify charts: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (o *Options) verifyOCIChart(chart string, key *ecdsa.PublicKey) error {
+	ref, tag := splitTag(strings.TrimPrefix(chart, "oci://"))
+	if tag == "" {
+		tag = o.Version
+	}
+	if tag == "" {
+		return errors.Errorf("no version specified")
+	}
+	tag = chartsigning.OCITag(tag)
+
+	repo, err := chartsigning.NewRepository(ref, &chartsigning.RegistryOptions{
+		Username:           o.Username,
+		Password:           o.Password,
+		RegistryConfigFile: o.RegistryConfigFile,
+		PlainHTTP:          o.PlainHTTP,
+	})
+	if err != nil {
+		return err
+	}
+	d, err := chartsigning.Verify(context.Background(), repo, tag, key)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("verified chart %s:%s with digest %s", info(ref), info(tag), info(d.String()))
+	return nil
+}
+
+// findHelmfileCharts returns the OCI chart references of the releases in the helmfile and any nested helmfiles
+func (o *Options) findHelmfileCharts() ([]string, error) {
+	helmfileList, err := helmfiles.GatherHelmfiles(o.Helmfile, o.Dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to gather helmfiles from %s", filepath.Join(o.Dir, o.Helmfile))
+	}
+
+	var answer []string
+	for _, hf := range helmfileList {
+		helmStates, err := helmfiles.LoadHelmfile(hf.Filepath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load helmfile %s", hf.Filepath)
+		}
+		for _, helmState := range helmStates {
+			ociRepositories := map[string]string{}
+			for i := range helmState.Repositories {
+				r := &helmState.Repositories[i]
+				if r.OCI {
+					ociRepositories[r.Name] = r.URL
+				}
+			}
+			for i := range helmState.Releases {
+				rel := &helmState.Releases[i]
+				prefix, name := helmfiles.SpitChartName(rel.Chart)
+				repoURL := ociRepositories[prefix]
+				if repoURL == "" {
+					continue
+				}
+				if rel.Version == "" {
+					log.Logger().Warnf("ignoring OCI chart %s in %s as it has no version", rel.Chart, hf.Filepath)
+					continue
+				}
+				answer = append(answer, strings.TrimSuffix(repoURL, "/")+"/"+name+":"+rel.Version)
+			}
+		}
+	}
+	return answer, nil
+}
+
+// splitTag splits the tag from the OCI reference if there is one
+func splitTag(ref string) (string, string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i+1:], "/") {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
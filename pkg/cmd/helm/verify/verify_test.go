@@ -0,0 +1,123 @@
+package verify_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/chartsigning"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helm/verify"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/internal/fakeregistry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelmVerifyOCI(t *testing.T) {
+	server, _ := fakeregistry.NewServer()
+	defer server.Close()
+	host := fakeregistry.Host(server)
+
+	tmpDir := t.TempDir()
+	key, pubKeyFile := writeKeys(t, tmpDir)
+
+	require.NoError(t, fakeregistry.PushChart(host+"/charts/signed", "1.0.0"))
+	require.NoError(t, fakeregistry.PushChart(host+"/charts/unsigned", "2.0.0"))
+
+	repo, err := chartsigning.NewRepository(host+"/charts/signed", &chartsigning.RegistryOptions{PlainHTTP: true})
+	require.NoError(t, err)
+	_, err = chartsigning.Sign(context.Background(), repo, "1.0.0", key)
+	require.NoError(t, err)
+
+	_, o := verify.NewCmdHelmVerify()
+	o.Key = pubKeyFile
+	o.PlainHTTP = true
+	o.Charts = []string{"oci://" + host + "/charts/signed:1.0.0"}
+	err = o.Run()
+	require.NoError(t, err, "should verify the signed chart")
+	assert.Equal(t, o.Charts, o.Verified)
+
+	_, o = verify.NewCmdHelmVerify()
+	o.Key = pubKeyFile
+	o.PlainHTTP = true
+	o.Charts = []string{"oci://" + host + "/charts/unsigned"}
+	o.Version = "2.0.0"
+	err = o.Run()
+	require.Error(t, err, "should fail to verify the unsigned chart")
+	assert.Empty(t, o.Verified)
+
+	// a different key should not verify the signature
+	_, otherPubKeyFile := writeKeys(t, t.TempDir())
+	_, o = verify.NewCmdHelmVerify()
+	o.Key = otherPubKeyFile
+	o.PlainHTTP = true
+	o.Charts = []string{"oci://" + host + "/charts/signed:1.0.0"}
+	err = o.Run()
+	require.Error(t, err, "should fail to verify with another key")
+}
+
+func TestHelmVerifyHelmfile(t *testing.T) {
+	server, _ := fakeregistry.NewServer()
+	defer server.Close()
+	host := fakeregistry.Host(server)
+
+	tmpDir := t.TempDir()
+	key, pubKeyFile := writeKeys(t, tmpDir)
+
+	require.NoError(t, fakeregistry.PushChart(host+"/charts/myapp", "1.2.3"))
+	repo, err := chartsigning.NewRepository(host+"/charts/myapp", &chartsigning.RegistryOptions{PlainHTTP: true})
+	require.NoError(t, err)
+	_, err = chartsigning.Sign(context.Background(), repo, "1.2.3", key)
+	require.NoError(t, err)
+
+	helmfile := `repositories:
+- name: myrepo
+  url: ` + host + `/charts
+  oci: true
+- name: stable
+  url: https://charts.helm.sh/stable
+releases:
+- chart: myrepo/myapp
+  version: 1.2.3
+  name: myapp
+- chart: stable/chartmuseum
+  version: 2.4.1
+  name: chartmuseum
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "helmfile.yaml"), []byte(helmfile), 0o600)
+	require.NoError(t, err)
+
+	_, o := verify.NewCmdHelmVerify()
+	o.Dir = tmpDir
+	o.Helmfile = "helmfile.yaml"
+	o.Key = pubKeyFile
+	o.PlainHTTP = true
+	err = o.Run()
+	require.NoError(t, err, "should verify the helmfile charts")
+	assert.Equal(t, []string{host + "/charts/myapp:1.2.3"}, o.Verified)
+}
+
+func TestHelmVerifyProvenance(t *testing.T) {
+	_, o := verify.NewCmdHelmVerify()
+	o.Keyring = filepath.Join("testdata", "charts", "helm-test-key.pub")
+	o.Packages = []string{filepath.Join("testdata", "charts", "hashtest-1.2.3.tgz")}
+	err := o.Run()
+	require.NoError(t, err, "should verify the chart provenance")
+	assert.Equal(t, o.Packages, o.Verified)
+}
+
+func writeKeys(t *testing.T, dir string) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	data, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pubKeyFile := filepath.Join(dir, "cosign.pub")
+	err = os.WriteFile(pubKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: data}), 0o600)
+	require.NoError(t, err)
+	return key, pubKeyFile
+}
@@ -6,7 +6,10 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/namespacepolicies"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/ownership"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
@@ -45,6 +48,15 @@ var (
 		If supplied with --dir-includes-release-name then by default we will annotate the resources with the annotations "app.kubernetes.io/instance" to preserve the helm release name.
 
 		The annotation "meta.helm.sh/release-namespace" will be added by default and contain the namespace specified in the release.
+
+		An index of the cluster scoped resources and CRDs and the releases which render them is written to
+		'.jx/gitops/ownership.yaml'. Resources whose files no longer exist, such as those of removed releases, are
+		dropped from the index. If more than one release renders the same cluster scoped resource then a
+		warning is logged or, if --fail-on-duplicates is specified, the command fails.
+
+		Any missing Namespace resources are created in 'config-root/cluster/namespaces'. The labels, annotations,
+		ResourceQuota, LimitRange and default deny NetworkPolicy of the policies in the namespace policies file
+		which match each namespace are applied to the Namespace and generated into 'config-root/namespaces/$ns/namespace-policy'.
 `)
 
 	namespaceExample = templates.Examples(`
@@ -67,8 +79,19 @@ type Options struct {
 	OverrideNamespace            bool
 	AnnotateReleaseNames         bool
 	AnnotateReleaseNameSpace     bool
+	FailOnDuplicates             bool
+	NamespacePolicies            string
+	OwnershipFile                string
 	NamespacedKind               map[string]bool
 	ResourcesToMove              []ResourceToMove
+
+	// Ownership the cluster scoped resources moved and their releases
+	Ownership []ownership.Resource
+
+	// Conflicts the cluster scoped resources rendered by more than one release
+	Conflicts []ownership.Conflict
+
+	namespacePolicies *v1alpha1.NamespacePolicies
 }
 
 // NewCmdHelmfileMove creates a command object for the command
@@ -92,6 +115,9 @@ func NewCmdHelmfileMove() (*cobra.Command, *Options) {
 	cmd.Flags().BoolVarP(&o.AnnotateReleaseNames, "annotate-release-name", "", true, "if using --dir-includes-release-name layout then lets add the 'meta.helm.sh/release-name' annotation to record the helm release name")
 	cmd.Flags().BoolVarP(&o.AnnotateReleaseNameSpace, "annotate-release-namespace", "", true, "add the 'meta.helm.sh/release-namespace' annotation to record the helm release namespace")
 	cmd.Flags().BoolVarP(&o.OverrideNamespace, "override-namespace", "", true, "applies the namespace specified in helmfile to all the generated resources")
+	cmd.Flags().StringVarP(&o.NamespacePolicies, "namespace-policies", "", namespacepolicies.DefaultPath, "the file of policies applied to the namespaces. Ignored if it does not exist")
+	cmd.Flags().StringVarP(&o.OwnershipFile, "ownership-file", "", ownership.DefaultPath, "the file containing the index of the cluster scoped resources and the releases which render them")
+	cmd.Flags().BoolVarP(&o.FailOnDuplicates, "fail-on-duplicates", "", false, "fails if more than one release renders the same cluster scoped resource rather than logging a warning")
 
 	o.Filter.AddFlags(cmd)
 	return cmd, o
@@ -123,6 +149,12 @@ func (o *Options) Run() error {
 		o.CustomResourceDefinitionsDir = filepath.Join(o.OutputDir, "customresourcedefinitions")
 	}
 
+	var err error
+	o.namespacePolicies, err = namespacepolicies.Load(o.NamespacePolicies)
+	if err != nil {
+		return err
+	}
+
 	globPattern := "*/*"
 	if o.DirIncludesReleaseName {
 		globPattern = "*/*/*"
@@ -134,6 +166,8 @@ func (o *Options) Run() error {
 	}
 
 	var namespaces []string
+	owners := map[string]bool{}
+	o.Ownership = nil
 	o.NamespacedKind = make(map[string]bool)
 	if !kube.IsNoKubernetes() {
 		client, err := kube.LazyCreateKubeClient(nil)
@@ -179,6 +213,7 @@ noKube:
 			ns, releaseName, chartName = parts[0], parts[1], parts[1]
 		}
 		namespaces = append(namespaces, ns)
+		owners[ns+"/"+releaseName] = true
 
 		err = o.moveFilesToClusterOrNamespacesFolder(dir, ns, releaseName, chartName)
 		if err != nil {
@@ -224,6 +259,7 @@ noKube:
 			if err != nil {
 				return errors.Wrapf(err, "failed to remove metadata.namespace for path %s", res.path)
 			}
+			o.addOwnership(res.node, res.path, res.namespace, res.release, filepath.Join(outDir, res.rel))
 		}
 		err = o.writeNodeToDir(outDir, res.rel, res.node)
 		if err != nil {
@@ -238,12 +274,75 @@ noKube:
 			return errors.Wrapf(err, "failed to lazily create namespace resource %s", ns)
 		}
 	}
+	return o.updateOwnership(owners)
+}
+
+// addOwnership records the release which owns a cluster scoped resource
+func (o *Options) addOwnership(node *yaml.RNode, path, ns, releaseName, outFile string) {
+	rel, err := filepath.Rel(o.OutputDir, outFile)
+	if err != nil {
+		rel = outFile
+	}
+	o.Ownership = append(o.Ownership, ownership.Resource{
+		APIVersion: kyamls.GetAPIVersion(node, path),
+		Kind:       kyamls.GetKind(node, path),
+		Name:       kyamls.GetName(node, path),
+		Release:    releaseName,
+		Namespace:  ns,
+		Path:       filepath.ToSlash(rel),
+	})
+}
+
+// updateOwnership updates the ownership index with the cluster scoped resources of the moved releases and
+// reports any resources rendered by more than one release
+func (o *Options) updateOwnership(owners map[string]bool) error {
+	path := o.OwnershipFile
+	if path == "" {
+		path = ownership.DefaultPath
+	}
+	index, err := ownership.LoadIndex(path)
+	if err != nil {
+		return err
+	}
+	index.ReplaceOwners(owners, o.Ownership)
+	err = index.RemoveMissing(o.OutputDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove the resources of removed releases from %s", path)
+	}
+	err = ownership.SaveIndex(index, path)
+	if err != nil {
+		return err
+	}
+
+	o.Conflicts = index.Conflicts()
+	if len(o.Conflicts) == 0 {
+		return nil
+	}
+	var messages []string
+	for i := range o.Conflicts {
+		messages = append(messages, o.Conflicts[i].String())
+	}
+	if o.FailOnDuplicates {
+		return errors.Errorf("duplicate cluster scoped resources:\n%s", strings.Join(messages, "\n"))
+	}
+	for _, m := range messages {
+		log.Logger().Warnf("duplicate cluster scoped resource: %s", m)
+	}
 	return nil
 }
 
 func (o *Options) lazyCreateNamespaceResource(ns string) error {
 	dir := filepath.Dir(o.ClusterNamespacesDir)
 
+	policy, err := namespacepolicies.Resolve(o.namespacePolicies, ns)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve the policies of namespace %s", ns)
+	}
+	err = namespacepolicies.SaveResources(filepath.Join(o.NamespacesDir, ns), ns, policy)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save the policy resources of namespace %s", ns)
+	}
+
 	found := false
 
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
@@ -252,6 +351,7 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 			name := kyamls.GetName(node, path)
 			if name == ns {
 				found = true
+				return namespacepolicies.ApplyToNode(node, policy)
 			}
 		}
 		return false, nil
@@ -260,7 +360,7 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 	filter := kyamls.Filter{
 		Kinds: []string{"Namespace"},
 	}
-	err := kyamls.ModifyFiles(dir, modifyFn, filter)
+	err = kyamls.ModifyFiles(dir, modifyFn, filter)
 	if err != nil {
 		return errors.Wrapf(err, "failed to walk namespaces in dir %s", dir)
 	}
@@ -282,6 +382,7 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 			},
 		},
 	}
+	namespacepolicies.ApplyToNamespace(namespace, policy)
 	err = yamls.SaveFile(namespace, fileName)
 	if err != nil {
 		return errors.Wrapf(err, "failed to save file %s", fileName)
@@ -392,7 +493,9 @@ func (o *Options) moveFilesToClusterOrNamespacesFolder(dir, ns, releaseName, cha
 			name := kyamls.GetStringField(node, path, "spec", "names", "kind")
 			log.Logger().Debugf("CRD %s: namespaced = %v", name, namespaced)
 			o.NamespacedKind[name] = namespaced
-			return o.writeNodeToDir(filepath.Join(o.CustomResourceDefinitionsDir, ns, pathName), rel, node)
+			outDir := filepath.Join(o.CustomResourceDefinitionsDir, ns, pathName)
+			o.addOwnership(node, path, ns, releaseName, filepath.Join(outDir, rel))
+			return o.writeNodeToDir(outDir, rel, node)
 		}
 		o.ResourcesToMove = append(o.ResourcesToMove, ResourceToMove{
 			kind:      kind,
@@ -401,6 +504,7 @@ func (o *Options) moveFilesToClusterOrNamespacesFolder(dir, ns, releaseName, cha
 			pathname:  pathName,
 			rel:       rel,
 			namespace: ns,
+			release:   releaseName,
 		})
 		return nil
 	})
@@ -417,6 +521,7 @@ type ResourceToMove struct {
 	node      *yaml.RNode
 	pathname  string
 	namespace string
+	release   string
 }
 
 func (o *Options) writeNodeToDir(outDir, rel string, node *yaml.RNode) error {
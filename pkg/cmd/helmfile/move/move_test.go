@@ -1,12 +1,16 @@
 package move_test
 
 import (
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helmfile/move"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/namespacepolicies"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/ownership"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -120,6 +124,7 @@ func testMove(t *testing.T, test *test, overrideNamespace bool) {
 	tmpDir := t.TempDir()
 	t.Logf("generating output to namespace %s, override namespace: %t\n", tmpDir, overrideNamespace)
 	o.OutputDir = tmpDir
+	o.OwnershipFile = filepath.Join(t.TempDir(), ownership.FileName)
 	o.OverrideNamespace = overrideNamespace
 
 	err := o.Run()
@@ -169,3 +174,151 @@ func testMove(t *testing.T, test *test, overrideNamespace bool) {
 		}
 	}
 }
+
+func TestHelmfileMoveDuplicateClusterResources(t *testing.T) {
+	for _, failOnDuplicates := range []bool{false, true} {
+		_, o := move.NewCmdHelmfileMove()
+		o.Dir = filepath.Join("testdata", "duplicates")
+		o.DirIncludesReleaseName = true
+		o.OutputDir = t.TempDir()
+		o.OwnershipFile = filepath.Join(t.TempDir(), ownership.FileName)
+		o.FailOnDuplicates = failOnDuplicates
+
+		err := o.Run()
+		if failOnDuplicates {
+			require.Error(t, err, "should fail with duplicate cluster resources")
+			continue
+		}
+		require.NoError(t, err, "failed to run helmfile move")
+
+		var conflicts []string
+		for i := range o.Conflicts {
+			conflicts = append(conflicts, o.Conflicts[i].String())
+		}
+		assert.Equal(t, []string{
+			"apiextensions.k8s.io/CustomResourceDefinition/widgets.example.com is rendered by releases jx/app-a, jx/app-b",
+			"rbac.authorization.k8s.io/ClusterRole/shared-role is rendered by releases jx/app-a, jx/app-b",
+		}, conflicts)
+
+		index, err := ownership.LoadIndex(o.OwnershipFile)
+		require.NoError(t, err, "failed to load ownership index")
+		require.Len(t, index.Resources, 5)
+		r := index.Resources[0]
+		assert.Equal(t, "apiextensions.k8s.io/CustomResourceDefinition/widgets.example.com", r.Key())
+		assert.Equal(t, "jx/app-a", r.Owner())
+		assert.Equal(t, "customresourcedefinitions/jx/app-a/widgets-crd.yaml", r.Path)
+		r = index.Resources[2]
+		assert.Equal(t, "rbac.authorization.k8s.io/ClusterRole/app-c", r.Key())
+		assert.Equal(t, "cluster/resources/jx/app-c/app-c-clusterrole.yaml", r.Path)
+	}
+}
+
+func TestHelmfileMoveOwnershipRemovedRelease(t *testing.T) {
+	srcDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("testdata", "duplicates"), srcDir)
+	require.NoError(t, err, "failed to copy testdata")
+
+	outputDir := t.TempDir()
+	ownershipFile := filepath.Join(t.TempDir(), ownership.FileName)
+
+	_, o := move.NewCmdHelmfileMove()
+	o.Dir = srcDir
+	o.DirIncludesReleaseName = true
+	o.OutputDir = outputDir
+	o.OwnershipFile = ownershipFile
+	err = o.Run()
+	require.NoError(t, err, "failed to run helmfile move")
+	require.Len(t, o.Conflicts, 2)
+
+	// lets remove release app-b along with its generated files
+	require.NoError(t, os.RemoveAll(filepath.Join(srcDir, "jx", "app-b")))
+	require.NoError(t, os.RemoveAll(filepath.Join(outputDir, "cluster", "resources", "jx", "app-b")))
+	require.NoError(t, os.RemoveAll(filepath.Join(outputDir, "customresourcedefinitions", "jx", "app-b")))
+
+	_, o = move.NewCmdHelmfileMove()
+	o.Dir = srcDir
+	o.DirIncludesReleaseName = true
+	o.OutputDir = outputDir
+	o.OwnershipFile = ownershipFile
+	err = o.Run()
+	require.NoError(t, err, "failed to run helmfile move")
+	assert.Empty(t, o.Conflicts, "should not report conflicts with a removed release")
+
+	index, err := ownership.LoadIndex(ownershipFile)
+	require.NoError(t, err, "failed to load ownership index")
+	for i := range index.Resources {
+		assert.NotEqual(t, "jx/app-b", index.Resources[i].Owner(), "should have removed resource %s of the removed release", index.Resources[i].Key())
+	}
+	assert.Len(t, index.Resources, 3)
+}
+
+func TestHelmfileMoveOwnershipWithoutReleaseNameDir(t *testing.T) {
+	_, o := move.NewCmdHelmfileMove()
+	o.Dir = filepath.Join("testdata", "output")
+	o.DirIncludesReleaseName = false
+	o.OutputDir = t.TempDir()
+	o.OwnershipFile = filepath.Join(t.TempDir(), ownership.FileName)
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run helmfile move")
+
+	index, err := ownership.LoadIndex(o.OwnershipFile)
+	require.NoError(t, err, "failed to load ownership index")
+
+	owners := map[string]string{}
+	for i := range index.Resources {
+		r := &index.Resources[i]
+		owners[r.Path] = r.Owner()
+	}
+	assert.Equal(t, "jx/lighthouse", owners["customresourcedefinitions/jx/lighthouse/lighthousejobs.lighthouse.jenkins.io-crd.yaml"], "owners %v", owners)
+	assert.Equal(t, "nginx/nginx-ingress", owners["cluster/resources/nginx/nginx-ingress/nginx-ingress-clusterrole.yaml"], "owners %v", owners)
+}
+
+func TestHelmfileMovePoliciesApplied(t *testing.T) {
+	policiesFile := filepath.Join(t.TempDir(), "namespace-policies.yaml")
+	err := os.WriteFile(policiesFile, []byte(`apiVersion: gitops.jenkins-x.io/v1alpha1
+kind: NamespacePolicies
+metadata:
+  name: namespace-policies
+spec:
+  requirePolicy: true
+  policies:
+  - name: all
+    namespaces:
+    - ".*"
+    labels:
+      team: platform
+    resourceQuota:
+      hard:
+        pods: "50"
+    networkPolicy:
+      denyIngress: true
+      denyEgress: true
+`), 0o600)
+	require.NoError(t, err, "failed to save %s", policiesFile)
+
+	_, o := move.NewCmdHelmfileMove()
+	o.Dir = filepath.Join("testdata", "output")
+	o.OutputDir = t.TempDir()
+	o.OwnershipFile = filepath.Join(t.TempDir(), ownership.FileName)
+	o.NamespacePolicies = policiesFile
+	err = o.Run()
+	require.NoError(t, err, "failed to run helmfile move")
+
+	namespace := &unstructured.Unstructured{}
+	path := filepath.Join(o.OutputDir, "cluster", "namespaces", "jx.yaml")
+	err = yamls.LoadFile(path, namespace)
+	require.NoError(t, err, "failed to load %s", path)
+	assert.Equal(t, "platform", namespace.GetLabels()["team"], "label on namespace %s", path)
+
+	for _, name := range []string{"resourcequota.yaml", "networkpolicy.yaml"} {
+		path = filepath.Join(o.OutputDir, "namespaces", "jx", namespacepolicies.ResourcesDirName, name)
+		assert.FileExists(t, path)
+	}
+
+	config, err := namespacepolicies.Load(policiesFile)
+	require.NoError(t, err, "failed to load %s", policiesFile)
+	messages, err := namespacepolicies.Verify(config, o.OutputDir)
+	require.NoError(t, err, "failed to verify the namespace policies")
+	assert.Empty(t, messages, "the generated namespaces should match their policies")
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/releasereport"
 )
 
-// ToMarkdown converts the charts to markdown
+// ToMarkdown converts the charts and kpt packages to markdown
 func ToMarkdown(charts []*releasereport.NamespaceReleases) (string, error) {
 	w := &strings.Builder{}
 
@@ -38,7 +38,17 @@ func ToMarkdown(charts []*releasereport.NamespaceReleases) (string, error) {
 	w.WriteString(`
   </tbody>
 </table>
+`)
+
+	var packages []*releasereport.PackageInfo
+	for _, ns := range charts {
+		packages = append(packages, ns.Packages...)
+	}
+	if len(packages) > 0 {
+		WritePackages(w, packages)
+	}
 
+	w.WriteString(`
 created by [JayeX](https://jayex.io/) - see the docs on [how to configure these releases](https://jayex.io/v3/develop/apps/)
 `)
 
@@ -99,3 +109,66 @@ func WriteChart(w io.StringWriter, ch *releasereport.ReleaseInfo) {
 		log.Logger().Warn(err)
 	}
 }
+
+// WritePackages writes the table of kpt packages and their upstream git repositories
+func WritePackages(w io.StringWriter, packages []*releasereport.PackageInfo) {
+	_, err := w.WriteString(`
+## Packages
+
+<table class="table">
+  <thead>
+    <tr>
+      <th scope="col">Package</th>
+      <th scope="col">Repository</th>
+      <th scope="col">Directory</th>
+      <th scope="col">Ref</th>
+      <th scope="col">Commit</th>
+      <th scope="col">Latest</th>
+    </tr>
+  </thead>
+  <tbody>
+`)
+	if err != nil {
+		log.Logger().Warn(err)
+	}
+	for _, p := range packages {
+		WritePackage(w, p)
+	}
+	_, err = w.WriteString(`
+  </tbody>
+</table>
+`)
+	if err != nil {
+		log.Logger().Warn(err)
+	}
+}
+
+func WritePackage(w io.StringWriter, p *releasereport.PackageInfo) {
+	repository := html.EscapeString(p.Repository)
+	if govalidator.IsRequestURL(p.Repository) {
+		repository = fmt.Sprintf("<a href='%s'>%s</a>", strings.TrimSuffix(p.Repository, ".git"), repository)
+	}
+	commit := p.Commit
+	if len(commit) > 7 {
+		commit = fmt.Sprintf("<span title='%s'>%s</span>", commit, commit[:7])
+	}
+	latest := p.LatestTag
+	if p.Behind {
+		latest = fmt.Sprintf("<strong>%s</strong> (behind)", latest)
+	} else if p.Branch && latest != "" {
+		latest = fmt.Sprintf("%s (tracking branch)", latest)
+	}
+
+	_, err := w.WriteString(fmt.Sprintf(`    <tr>
+	      <td>%s</td>
+	      <td>%s</td>
+	      <td>%s</td>
+	      <td>%s</td>
+	      <td>%s</td>
+	      <td>%s</td>
+	    </tr>
+`, html.EscapeString(p.Path), repository, html.EscapeString(p.Directory), html.EscapeString(p.Ref), commit, latest))
+	if err != nil {
+		log.Logger().Warn(err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,3 +49,109 @@ func TestHemlfileMarkdownReport(t *testing.T) {
 
 	testhelpers.AssertTextFilesEqual(t, expectedPath, generatedFile, "generated README.md")
 }
+
+func TestHemlfileMarkdownReportPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sourceDir := filepath.Join("testdata", "packages")
+	expectedPath := filepath.Join("testdata", "expected.packages.README.md")
+
+	packages, err := releasereport.FindPackages(sourceDir)
+	require.NoError(t, err, "failed to find packages in %s", sourceDir)
+	require.Len(t, packages, 3, "packages found in %s", sourceDir)
+
+	repositoryTags := map[string]map[string]string{
+		"https://github.com/jenkins-x/jx3-pipeline-catalog": {
+			"v1.2.0": "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678",
+			"v1.3.0": "2222222222222222222222222222222222222222",
+		},
+		"https://github.com/jenkins-x/jxr-kube-resources": {
+			"v0.2.0": "4444444444444444444444444444444444444444",
+			"v0.3.0": "c0ffee1234567890abcdef1234567890abcdef12",
+		},
+		"https://github.com/jenkins-x/jx3-versions": {
+			"v1.0.0": "5555555555555555555555555555555555555555",
+		},
+	}
+	behind := map[string]bool{}
+	for _, p := range packages {
+		err = p.SetLatestTag(repositoryTags[p.Repository])
+		require.NoError(t, err, "failed to set latest tag of %s", p.Path)
+		behind[p.Path] = p.Behind
+	}
+	assert.Equal(t, map[string]bool{
+		filepath.Join(".lighthouse", "jenkins-x"):                true,
+		filepath.Join("config-root", "namespaces", "jx", "app1"): false,
+		"versionStream": false,
+	}, behind, "packages behind their latest tag")
+
+	md, err := report.ToMarkdown([]*releasereport.NamespaceReleases{
+		{
+			Packages: packages,
+		},
+	})
+	require.NoError(t, err, "failed to generate markdown for dir %s", sourceDir)
+
+	generatedFile := filepath.Join(tmpDir, "README.md")
+	err = os.WriteFile(generatedFile, []byte(md), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save file %s", generatedFile)
+
+	if generateTestOutput {
+		err = os.WriteFile(expectedPath, []byte(md), 0o600)
+		require.NoError(t, err, "failed to save file %s", expectedPath)
+
+		t.Logf("saved file %s\n", expectedPath)
+		return
+	}
+
+	testhelpers.AssertTextFilesEqual(t, expectedPath, generatedFile, "generated README.md")
+}
+
+func TestPackageSetLatestTag(t *testing.T) {
+	tags := map[string]string{
+		"v1.0.0": "1111111111111111111111111111111111111111",
+		"v1.1.0": "2222222222222222222222222222222222222222",
+	}
+	testCases := []struct {
+		name   string
+		pkg    releasereport.PackageInfo
+		behind bool
+		branch bool
+	}{
+		{
+			name:   "older tag",
+			pkg:    releasereport.PackageInfo{Ref: "v1.0.0"},
+			behind: true,
+		},
+		{
+			name: "latest tag",
+			pkg:  releasereport.PackageInfo{Ref: "v1.1.0", Commit: "2222222222222222222222222222222222222222"},
+		},
+		{
+			name:   "commit of older tag",
+			pkg:    releasereport.PackageInfo{Ref: "1111111111111111111111111111111111111111"},
+			behind: true,
+		},
+		{
+			name: "untagged commit",
+			pkg:  releasereport.PackageInfo{Ref: "3333333333333333333333333333333333333333"},
+		},
+		{
+			name: "unknown version tag",
+			pkg:  releasereport.PackageInfo{Ref: "v0.9.0"},
+		},
+		{
+			name:   "branch at commit of older tag",
+			pkg:    releasereport.PackageInfo{Ref: "main", Commit: "1111111111111111111111111111111111111111"},
+			branch: true,
+		},
+	}
+	for _, tc := range testCases {
+		p := tc.pkg
+		err := p.SetLatestTag(tags)
+		require.NoError(t, err, "failed to set latest tag for %s", tc.name)
+		assert.Equal(t, "v1.1.0", p.LatestTag, "latest tag for %s", tc.name)
+		assert.Equal(t, tc.behind, p.Behind, "behind for %s", tc.name)
+		assert.Equal(t, tc.branch, p.Branch, "branch for %s", tc.name)
+	}
+}
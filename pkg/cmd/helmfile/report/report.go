@@ -11,6 +11,8 @@ import (
 	charter "github.com/jenkins-x-plugins/jx-charter/pkg/apis/chart/v1alpha1"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmhelpers"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/ingresses"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/releasereport"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
@@ -20,8 +22,9 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	gitcli "github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/services"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
@@ -31,11 +34,8 @@ import (
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/helmpath"
 	helmrepo "helm.sh/helm/v3/pkg/repo"
-	nv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-
-	"sigs.k8s.io/yaml"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 var (
@@ -43,6 +43,10 @@ var (
 
 	cmdLong = templates.LongDesc(`
 		Generates a markdown report of the helmfile based deployments in each namespace
+
+		The report also includes the kpt packages found in the directory with their upstream git repository, directory, ref
+		and commit which are saved in an entry without a namespace in releases.yaml. Use --upstream-check to query the
+		upstream git repositories for their tags to report whether each package is behind the latest tag.
 `)
 
 	cmdExample = templates.Examples(`
@@ -63,6 +67,7 @@ type Options struct {
 	Helmfiles               []helmfiles.Helmfile
 	HelmBinary              string
 	DoGitCommit             bool
+	UpstreamCheck           bool
 	Gitter                  gitclient.Interface
 	CommandRunner           cmdrunner.CommandRunner
 	HelmClient              helmer.Helmer
@@ -71,6 +76,7 @@ type Options struct {
 	PreviousNamespaceCharts map[string]map[string]*releasereport.ReleaseInfo
 	RepositoryInfo          map[string]*helmrepo.IndexFile
 	HelmSettings            *cli.EnvSettings
+	Gateways                *ingresses.Gateways
 }
 
 // NewCmdHelmfileReport creates a command object for the command
@@ -91,6 +97,7 @@ func NewCmdHelmfileReport() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory that contains the helmfile.yaml")
 	cmd.Flags().StringVarP(&o.OutDir, "out-dir", "o", "docs", "the output directory")
 	cmd.Flags().StringVarP(&o.ConfigRootPath, "config-root", "", "config-root", "the folder name containing the kubernetes resources")
+	cmd.Flags().BoolVarP(&o.UpstreamCheck, "upstream-check", "", false, "queries the upstream git repositories of the kpt packages for their latest tags to report which packages are behind")
 	o.AddFlags(cmd, "")
 	o.BaseOptions.AddBaseFlags(cmd)
 	return cmd, o
@@ -123,6 +130,9 @@ func (o *Options) Validate() error {
 	if o.CommandRunner == nil {
 		o.CommandRunner = cmdrunner.QuietCommandRunner
 	}
+	if o.Gitter == nil {
+		o.Gitter = gitcli.NewCLIClient("", o.CommandRunner)
+	}
 
 	if o.HelmBinary == "" {
 		o.HelmBinary, err = plugins.GetHelmBinary(plugins.HelmVersion)
@@ -143,6 +153,14 @@ func (o *Options) Validate() error {
 		return errors.Wrapf(err, "failed to load requirements in dir %s", o.Dir)
 	}
 
+	if o.Gateways == nil {
+		configRootDir := filepath.Join(o.Dir, o.ConfigRootPath)
+		o.Gateways, err = ingresses.FindGateways(configRootDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find gateways in dir %s", configRootDir)
+		}
+	}
+
 	o.HelmSettings = cli.New()
 	o.RepositoryInfo = make(map[string]*helmrepo.IndexFile)
 	return nil
@@ -201,6 +219,16 @@ func (o *Options) Run() error {
 		}
 	}
 
+	packages, err := o.gatherPackages()
+	if err != nil {
+		return errors.Wrapf(err, "failed to gather kpt packages")
+	}
+	if len(packages) > 0 {
+		o.NamespaceCharts = append(o.NamespaceCharts, &releasereport.NamespaceReleases{
+			Packages: packages,
+		})
+	}
+
 	err = yamls.SaveFile(o.NamespaceCharts, path)
 	if err != nil {
 		return errors.Wrapf(err, "failed to save %s", path)
@@ -221,6 +249,43 @@ func (o *Options) Run() error {
 	return o.generateChartCRDs()
 }
 
+// gatherPackages finds the kpt packages and checks whether they are behind the latest tag of their upstream git repository
+func (o *Options) gatherPackages() ([]*releasereport.PackageInfo, error) {
+	packages, err := releasereport.FindPackages(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if !o.UpstreamCheck {
+		return packages, nil
+	}
+	repositoryTags := map[string]map[string]string{}
+	for _, p := range packages {
+		if p.Repository == "" {
+			continue
+		}
+		tags, found := repositoryTags[p.Repository]
+		if !found {
+			tags, err = pipelinecatalogs.RemoteTags(o.Gitter, p.Repository)
+			if err != nil {
+				log.Logger().Warnf("failed to find the tags of the upstream repository of kpt package %s: %s", p.Path, err.Error())
+			}
+			repositoryTags[p.Repository] = tags
+		}
+		if tags == nil {
+			// we could not list the tags so we don't know if the package is behind
+			continue
+		}
+		err = p.SetLatestTag(tags)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check the latest tag of kpt package %s", p.Path)
+		}
+		if p.Behind {
+			log.Logger().Infof("kpt package %s is behind the latest tag %s of %s", info(p.Path), info(p.LatestTag), info(p.Repository))
+		}
+	}
+	return packages, nil
+}
+
 func (o *Options) processHelmfile(helmfile helmfiles.Helmfile) (*releasereport.NamespaceReleases, error) {
 	answer := &releasereport.NamespaceReleases{}
 	// ignore the root file
@@ -444,39 +509,32 @@ func (o *Options) discoverIngress(ci *releasereport.ReleaseInfo, rel *state.Rele
 			return errors.Wrapf(err, "failed to load file %s", path)
 		}
 
-		obj := &unstructured.Unstructured{}
-		err = yaml.Unmarshal(data, obj)
+		node, err := kyaml.Parse(string(data))
 		if err != nil {
 			log.Logger().Infof("could not parse YAML file %s", path)
 			continue
 		}
-		if obj.GetKind() != "Ingress" {
-			continue
-		}
-		apiVersion := obj.GetAPIVersion()
-		if apiVersion != "networking.k8s.io/v1beta1" && apiVersion != "extensions/v1beta1" && apiVersion != "networking.k8s.io/v1" {
-			log.Logger().Infof("ignoring Ingress in file %s with api version %s", path, apiVersion)
+		h := ingresses.FindHandler(ingresses.DefaultHandlers, node, path)
+		if h == nil {
 			continue
 		}
 
-		ing := &nv1.Ingress{}
-		err = yaml.Unmarshal(data, ing)
+		u, err := h.URL(data, path, o.Gateways)
 		if err != nil {
-			log.Logger().Warnf("failed to unmarshal YAML as Ingress in file %s: %s", path, err.Error())
+			log.Logger().Warnf("failed to find the URL of %s in file %s: %s", h.Name, path, err.Error())
 			continue
 		}
-
-		u := services.IngressURL(ing)
 		if u == "" {
 			continue
 		}
+		resourceName := kyamls.GetName(node, path)
 
 		ci.Ingresses = append(ci.Ingresses, releasereport.IngressInfo{
-			Name: ing.Name,
+			Name: resourceName,
 			URL:  u,
 		})
 
-		if ing.Name == ci.Name || ing.Name == ci.Name+"-"+rel.Name {
+		if resourceName == ci.Name || resourceName == ci.Name+"-"+rel.Name {
 			ci.ApplicationURL = u
 		}
 	}
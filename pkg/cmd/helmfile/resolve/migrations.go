@@ -0,0 +1,331 @@
+package resolve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/migrations"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	cdfRepositoryURL          = "https://cdfoundation.github.io/tekton-helm-chart"
+	jxghRepositoryURL         = "https://jenkins-x-charts.github.io/repo"
+	stableRepositoryURL       = "https://charts.helm.sh/stable"
+	ingressNginxRepositoryURL = "https://kubernetes.github.io/ingress-nginx"
+	externalSecretsURL        = "https://external-secrets.github.io/kubernetes-external-secrets"
+)
+
+// Migrations returns the migrations registered in Go which are applied along with any migrations in the version stream
+func (o *Options) Migrations() []*migrations.Migration {
+	answer := []*migrations.Migration{
+		{
+			ID:          "0001-quickstarts-yaml-extension",
+			Description: "use the .yaml extension for quickstart imports",
+			Repository: func(_ *migrations.Context) error {
+				return o.migrateQuickstartsFile()
+			},
+		},
+		{
+			ID:          "0002-remove-root-jx-values",
+			Description: "remove the top level jx-values.yaml when using nested helmfiles",
+			Helmfile:    o.removeRootJXValues,
+		},
+		{
+			ID:          "0003-repository-urls",
+			Description: "replace the URLs of moved chart repositories",
+			Steps: []migrations.Step{
+				{ReplaceRepositoryURL: &migrations.ReplaceRepositoryURL{From: "https://kubernetes-charts.storage.googleapis.com", To: stableRepositoryURL}},
+				{ReplaceRepositoryURL: &migrations.ReplaceRepositoryURL{From: "https://comcast.github.io/kuberhealthy/helm-repos", To: "https://kuberhealthy.github.io/kuberhealthy/helm-repos"}},
+				{ReplaceRepositoryURL: &migrations.ReplaceRepositoryURL{From: "https://godaddy.github.io/kubernetes-external-secrets", To: externalSecretsURL}},
+				{ReplaceRepositoryURL: &migrations.ReplaceRepositoryURL{From: "https://chrismellard.github.io/kubernetes-external-secrets", To: externalSecretsURL}},
+				{ReplaceRepositoryURL: &migrations.ReplaceRepositoryURL{Name: "external-secrets", From: "https://storage.googleapis.com/jenkinsxio/charts", To: externalSecretsURL}},
+				{ReplaceRepositoryURL: &migrations.ReplaceRepositoryURL{From: "http://chartmuseum.jenkins-x.io", To: "https://storage.googleapis.com/chartmuseum.jenkins-x.io"}},
+			},
+		},
+		{
+			ID:          "0004-tekton-pipeline-chart",
+			Description: "replace the jenkins-x/tekton chart with cdf/tekton-pipeline",
+			Steps: []migrations.Step{
+				{RenameChart: &migrations.RenameChart{From: "jenkins-x/tekton", To: "cdf/tekton-pipeline", RepositoryURL: cdfRepositoryURL, Namespace: "tekton-pipelines"}},
+			},
+		},
+		{
+			ID:          "0005-jx3-charts",
+			Description: "move the jx3 charts to the jxgh chart repository",
+			Helmfile:    migrateJX3Charts,
+		},
+		{
+			ID:          "0006-jenkins-x-charts",
+			Description: "move the jenkins-x charts to the jxgh chart repository",
+			Steps:       jenkinsXChartSteps("jxboot-helmfile-resources", "bucketrepo", "nexus", "lighthouse"),
+		},
+		{
+			ID:          "0008-stable-chartmuseum",
+			Description: "replace the jenkins-x/chartmuseum chart with stable/chartmuseum",
+			Helmfile:    o.migrateChartmuseum,
+		},
+		{
+			ID:          "0009-ingress-nginx",
+			Description: "replace the stable/nginx-ingress chart with ingress-nginx/ingress-nginx",
+			Helmfile:    o.migrateIngressNginx,
+		},
+		{
+			ID:          "0011-jx-labs-charts",
+			Description: "move the jx-labs charts to the jxgh chart repository",
+			Helmfile:    o.migrateJXLabsCharts,
+		},
+	}
+	return answer
+}
+
+// UpdateChecks performs the checks which run on every resolve in update mode. Unlike migrations these are never
+// recorded as their outcome depends on the current requirements and helmfile so they can change after any commit
+func (o *Options) UpdateChecks(helmState *state.HelmState) error {
+	o.warnTerraformVault(helmState)
+	o.addLocalExternalSecrets(helmState)
+	addBuildController(helmState)
+	if o.AddEnvironmentPipelines {
+		err := o.addEnvironmentPipelines()
+		if err != nil {
+			return errors.Wrapf(err, "failed to add the environment pipelines")
+		}
+	}
+	return nil
+}
+
+// PendingMigrations returns the migrations which have not yet been applied to the git repository
+func (o *Options) PendingMigrations() ([]*migrations.Migration, error) {
+	if o.Dir == "" {
+		o.Dir = "."
+	}
+	vsDir := o.VersionStreamDir
+	if vsDir == "" {
+		vsDir = filepath.Join(o.Dir, versionStreamDir)
+	}
+	runner, err := migrations.NewRunner(o.Dir, vsDir, o.Migrations())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load migrations")
+	}
+	return runner.Pending, nil
+}
+
+func helmfileVersionStreamPath(helmState *state.HelmState) string {
+	if helmState.OverrideNamespace == "" {
+		return versionStreamDir
+	}
+	return "../../" + versionStreamDir
+}
+
+func jenkinsXChartSteps(chartNames ...string) []migrations.Step {
+	var answer []migrations.Step
+	for _, chartName := range chartNames {
+		answer = append(answer,
+			migrations.Step{
+				RenameChart: &migrations.RenameChart{From: "jenkins-x/" + chartName, To: "jxgh/" + chartName, RepositoryURL: jxghRepositoryURL},
+			},
+			migrations.Step{
+				MoveValuesFile: &migrations.MoveValuesFile{
+					Chart: "jxgh/" + chartName,
+					From:  fmt.Sprintf("versionStream/charts/jenkins-x/%s/values.yaml.gotmpl", chartName),
+					To:    fmt.Sprintf("versionStream/charts/jxgh/%s/values.yaml.gotmpl", chartName),
+				},
+			})
+	}
+	return answer
+}
+
+func (o *Options) removeRootJXValues(ctx *migrations.Context, helmState *state.HelmState) error {
+	if helmState.OverrideNamespace == "" {
+		return nil
+	}
+	oldJXValues := filepath.Join(ctx.Dir, "jx-values.yaml")
+	exists, err := files.FileExists(oldJXValues)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", oldJXValues)
+	}
+	if exists {
+		err = os.Remove(oldJXValues)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove old file %s", oldJXValues)
+		}
+	}
+	return nil
+}
+
+func migrateJX3Charts(ctx *migrations.Context, helmState *state.HelmState) error {
+	versionStreamPath := ctx.VersionStreamPath
+	added := false
+	for i := range helmState.Releases {
+		release := &helmState.Releases[i]
+		names := strings.SplitN(release.Chart, "/", 2)
+		if len(names) != 2 || names[0] != "jx3" {
+			continue
+		}
+		chartName := names[1]
+		name := release.Name
+		release.Chart = "jxgh/" + chartName
+		added = true
+
+		for j := range release.Values {
+			s, ok := release.Values[j].(string)
+			if !ok {
+				continue
+			}
+			// lets switch invalid paths to the one inside a chart repo folder
+			if s == fmt.Sprintf("%s/charts/jx3/%s/values.yaml.gotmpl", versionStreamPath, chartName) {
+				release.Values[j] = fmt.Sprintf("%s/charts/jxgh/%s/values.yaml.gotmpl", versionStreamPath, chartName)
+				break
+			}
+			if s == fmt.Sprintf("%s/charts/jx3/%s/values.yaml.gotmpl", versionStreamPath, name) {
+				release.Values[j] = fmt.Sprintf("%s/charts/jxgh/%s/values.yaml.gotmpl", versionStreamPath, name)
+				break
+			}
+		}
+	}
+	if added {
+		migrations.EnsureRepository(helmState, "jxgh", jxghRepositoryURL)
+	}
+	return nil
+}
+
+func (o *Options) warnTerraformVault(helmState *state.HelmState) {
+	requirements := o.Options.Requirements
+	if requirements == nil || requirements.SecretStorage != jxcore.SecretStorageTypeVault || !requirements.TerraformVault {
+		return
+	}
+	for i := range helmState.Releases {
+		release := &helmState.Releases[i]
+		if release.Chart == "jxgh/vault-instance" || release.Chart == "banzaicloud-stable/vault-operator" {
+			log.Logger().Infof("Terraform installed detected and Vault chart %s still present in helmfile. Please migrate secrets as necessary and remove this chart from your helmfile", release.Chart)
+		}
+	}
+}
+
+func (o *Options) migrateChartmuseum(ctx *migrations.Context, helmState *state.HelmState) error {
+	release := migrations.FindRelease(helmState, "jenkins-x/chartmuseum")
+	if release == nil {
+		return nil
+	}
+	release.Chart = "stable/chartmuseum"
+	o.updateVersionFromVersionStream(release)
+	release.Values = []interface{}{fmt.Sprintf("%s/charts/stable/chartmuseum/values.yaml.gotmpl", ctx.VersionStreamPath)}
+	migrations.EnsureRepository(helmState, "stable", stableRepositoryURL)
+	return nil
+}
+
+func (o *Options) migrateIngressNginx(ctx *migrations.Context, helmState *state.HelmState) error {
+	versionStreamPath := ctx.VersionStreamPath
+	for i := range helmState.Releases {
+		release := &helmState.Releases[i]
+		switch release.Chart {
+		case "stable/nginx-ingress":
+			release.Chart = "ingress-nginx/ingress-nginx"
+			o.updateVersionFromVersionStream(release)
+			release.Values = []interface{}{fmt.Sprintf("%s/charts/ingress-nginx/ingress-nginx/values.yaml.gotmpl", versionStreamPath)}
+			migrations.EnsureRepository(helmState, "ingress-nginx", ingressNginxRepositoryURL)
+
+		case "ingress-nginx/ingress-nginx":
+			for j := range release.Values {
+				s, ok := release.Values[j].(string)
+				// lets switch invalid paths to the one inside a chart repo folder
+				if ok && s == fmt.Sprintf("%s/charts/ingress-nginx/values.yaml.gotmpl", versionStreamPath) {
+					release.Values[j] = fmt.Sprintf("%s/charts/ingress-nginx/ingress-nginx/values.yaml.gotmpl", versionStreamPath)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (o *Options) addLocalExternalSecrets(helmState *state.HelmState) {
+	requirements := o.Options.Requirements
+	if requirements == nil || requirements.SecretStorage != jxcore.SecretStorageTypeLocal || helmState.OverrideNamespace != jxcore.DefaultNamespace {
+		return
+	}
+	if migrations.FindRelease(helmState, "jxgh/local-external-secrets") != nil {
+		return
+	}
+	release := state.ReleaseSpec{
+		Chart: "jxgh/local-external-secrets",
+	}
+	o.updateVersionFromVersionStream(&release)
+	helmState.Releases = append(helmState.Releases, release)
+	migrations.EnsureRepository(helmState, "jxgh", jxghRepositoryURL)
+}
+
+func (o *Options) migrateJXLabsCharts(ctx *migrations.Context, helmState *state.HelmState) error {
+	for _, name := range []string{"jenkins-x-crds", "pusher-wave", "vault-instance"} {
+		release := migrations.FindRelease(helmState, "jx-labs/"+name)
+		if release == nil {
+			continue
+		}
+		release.Chart = "jxgh/" + name
+		if name == "jenkins-x-crds" {
+			release.Values = []interface{}{fmt.Sprintf("%s/charts/jxgh/jenkins-x-crds/values.yaml.gotmpl", ctx.VersionStreamPath)}
+		}
+		o.updateVersionFromVersionStream(release)
+		migrations.EnsureRepository(helmState, "jxgh", jxghRepositoryURL)
+	}
+	return nil
+}
+
+func addBuildController(helmState *state.HelmState) {
+	if helmState.OverrideNamespace != "jx" || !isDevCluster(helmState) {
+		return
+	}
+	if migrations.FindRelease(helmState, "jxgh/jx-build-controller") != nil {
+		return
+	}
+	helmState.Releases = append(helmState.Releases, state.ReleaseSpec{
+		Chart: "jxgh/jx-build-controller",
+	})
+	migrations.EnsureRepository(helmState, "jxgh", jxghRepositoryURL)
+}
+
+func (o *Options) addEnvironmentPipelines() error {
+	lighthouseTriggerFile := filepath.Join(o.Dir, ".lighthouse", "jenkins-x", "triggers.yaml")
+	exists, err := files.FileExists(lighthouseTriggerFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect file %s", lighthouseTriggerFile)
+	}
+	if exists {
+		return nil
+	}
+	bin := o.KptBinary
+	if bin == "" {
+		bin, err = plugins.GetKptBinary(plugins.KptVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	args := []string{"pkg", "get", "https://github.com/jenkins-x/jx3-pipeline-catalog.git/environment/.lighthouse", o.Dir}
+	c := &cmdrunner.Command{
+		Name: bin,
+		Args: args,
+		Dir:  o.Dir,
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get environment tekton pipeline via kpt in dir %s", o.Dir)
+	}
+
+	err = gitclient.Add(o.Git(), o.Dir, ".lighthouse")
+	if err != nil {
+		return errors.Wrapf(err, "failed to add .lighthouse dir to git")
+	}
+
+	log.Logger().Infof("got tekton pipeline for envirnment at %s", lighthouseTriggerFile)
+	return nil
+}
@@ -2,7 +2,6 @@ package resolve
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -10,6 +9,7 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/jxtmpl/reqvalues"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/migrations"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
@@ -27,6 +27,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helmfile/validate"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
@@ -47,11 +48,17 @@ var (
 
 	cmdLong = templates.LongDesc(`
 		Resolves the helmfile.yaml from the version stream to specify versions and helm values
+
+		With --validate-values the merged values of each release are then validated against the values.schema.json
+		of its chart in the same way as 'helmfile validate --values'
 `)
 
 	cmdExample = templates.Examples(`
 		# resolves the versions and values in the helmfile.yaml
 		%s helmfile resolve
+
+		# resolves the helmfile.yaml and then validates the values of each release
+		%[1]s helmfile resolve --validate-values
 	`)
 
 	valueFileNames = []string{"values.yaml.gotmpl", "values.yaml"}
@@ -69,10 +76,12 @@ type Options struct {
 	HelmBinary              string
 	BatchMode               bool
 	UpdateMode              bool
+	ValidateValues          bool
 	DoGitCommit             bool
 	TestOutOfCluster        bool
 	Gitter                  gitclient.Interface
 	prefixes                *versionstream.RepositoryPrefixes
+	migrations              *migrations.Runner
 	Results                 Results
 	AddEnvironmentPipelines bool
 }
@@ -98,6 +107,7 @@ func NewCmdHelmfileResolve() (*cobra.Command, *Options) {
 	o.BaseOptions.AddBaseFlags(cmd)
 
 	cmd.Flags().BoolVarP(&o.UpdateMode, "update", "", false, "updates versions from the version stream if they have changed")
+	cmd.Flags().BoolVarP(&o.ValidateValues, "validate-values", "", false, "validates the values of each release against the values.schema.json of its chart after resolving")
 	if useHelmfileRepos {
 		cmd.Flags().StringVarP(&o.HelmfileBinary, "helmfile-binary", "", "", "specifies the helmfile binary location to use. If not specified defaults to using the downloaded helmfile plugin")
 	}
@@ -183,6 +193,15 @@ func (o *Options) Run() error {
 	count := 0
 
 	if o.UpdateMode {
+		o.migrations, err = migrations.NewRunner(o.Dir, o.VersionStreamDir, o.Migrations())
+		if err != nil {
+			return errors.Wrapf(err, "failed to load migrations")
+		}
+		err = o.migrations.ApplyRepository()
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply migrations")
+		}
+
 		increment, err := o.upgradeHelmfileStructure(o.Dir)
 		count += increment
 		if err != nil {
@@ -227,6 +246,32 @@ func (o *Options) Run() error {
 		count += 0
 	}
 
+	if o.migrations != nil && len(o.migrations.Pending) > 0 {
+		// the migrations are only recorded once they have been applied to every helmfile
+		all, err := o.resolvedAllHelmfiles(includedHelmfiles)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if all helmfiles were resolved")
+		}
+		if all {
+			for _, m := range o.migrations.Pending {
+				log.Logger().Infof("applied migration %s: %s", info(m.ID), m.Description)
+			}
+			err = o.migrations.Complete()
+			if err != nil {
+				return errors.Wrapf(err, "failed to record applied migrations")
+			}
+		} else {
+			log.Logger().Infof("not recording the applied migrations as only the helmfiles included by %s were resolved", info(o.Helmfile))
+		}
+	}
+
+	if o.ValidateValues {
+		err = o.validateValues()
+		if err != nil {
+			return errors.Wrapf(err, "failed to validate the values of the releases")
+		}
+	}
+
 	if !o.DoGitCommit {
 		return nil
 	}
@@ -240,6 +285,43 @@ func (o *Options) Run() error {
 	return nil
 }
 
+// validateValues validates the resolved values of each release against the schema of its chart
+func (o *Options) validateValues() error {
+	_, vo := validate.NewCmdHelmfileValidate()
+	vo.Dir = o.Dir
+	vo.Helmfile = filepath.Join(o.Dir, o.Helmfile)
+	vo.Values = true
+	vo.HelmBinary = o.HelmBinary
+	vo.CommandRunner = o.CommandRunner
+	return vo.Run()
+}
+
+// resolvedAllHelmfiles returns true if the resolved helmfiles include every helmfile in the root helmfile.yaml
+func (o *Options) resolvedAllHelmfiles(resolved []helmfiles.Helmfile) (bool, error) {
+	root := filepath.Join(o.Dir, "helmfile.yaml")
+	exists, err := files.FileExists(root)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if file exists %s", root)
+	}
+	if !exists {
+		return true, nil
+	}
+	all, err := helmfiles.GatherHelmfiles("helmfile.yaml", o.Dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to gather helmfiles from %s", root)
+	}
+	paths := map[string]bool{}
+	for _, hf := range resolved {
+		paths[filepath.Clean(hf.Filepath)] = true
+	}
+	for _, hf := range all {
+		if !paths[filepath.Clean(hf.Filepath)] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // Handle WARNING: environments and releases cannot be defined within the same YAML part. Use --- to extract the environments into a dedicated part
 // Split automatically
 // Handle helmfile with multiple documents.
@@ -251,10 +333,16 @@ func (o *Options) processHelmfile(helmfile helmfiles.Helmfile) error {
 	}
 
 	for _, helmState := range helmStates {
-		if o.UpdateMode {
-			err = o.CustomUpgrades(helmState)
+		if o.migrations != nil && helmState.Releases != nil {
+			err = o.migrations.ApplyHelmfile(filepath.Dir(path), helmfileVersionStreamPath(helmState), helmState)
+			if err != nil {
+				return errors.Wrapf(err, "failed to apply migrations")
+			}
+		}
+		if o.UpdateMode && helmState.Releases != nil {
+			err = o.UpdateChecks(helmState)
 			if err != nil {
-				return errors.Wrapf(err, "failed to perform custom upgrades")
+				return errors.Wrapf(err, "failed to perform update checks")
 			}
 		}
 
@@ -723,346 +811,6 @@ func (o *Options) GitCommit(outDir, commitMessage string) error {
 	return nil
 }
 
-// CustomUpgrades performs custom upgrades outside of the version stream/kpt approach
-func (o *Options) CustomUpgrades(helmstate *state.HelmState) error {
-	if helmstate.Releases == nil {
-		return nil
-	}
-	err := o.migrateQuickstartsFile()
-	if err != nil {
-		return errors.Wrapf(err, "failed to migrate quickstarts file")
-	}
-
-	var versionStreamPath string
-	if helmstate.OverrideNamespace == "" {
-		versionStreamPath = "versionStream"
-	} else {
-		versionStreamPath = "../../versionStream"
-
-		// lets remove the old top level jx-values.yaml as we are using multi-level helmfiles
-		oldJXValues := filepath.Join(o.Dir, "jx-values.yaml")
-		exists, err := files.FileExists(oldJXValues)
-		if err != nil {
-			return errors.Wrapf(err, "failed to check if file exists %s", oldJXValues)
-		}
-		if exists {
-			err = os.Remove(oldJXValues)
-			if err != nil {
-				return errors.Wrapf(err, "failed to remove old file %s", oldJXValues)
-			}
-		}
-	}
-
-	requirementsResource, _, err := jxcore.LoadRequirementsConfig(o.Dir, false)
-	if err != nil {
-		return errors.Wrapf(err, "failed to load the requirements configuration")
-	}
-	requirements := &requirementsResource.Spec
-	// lets replace the old tekton repositories if they are being used
-	for i := range helmstate.Repositories {
-		repo := &helmstate.Repositories[i]
-		cleanURL := strings.TrimSuffix(repo.URL, "/")
-		switch cleanURL {
-		case "https://kubernetes-charts.storage.googleapis.com":
-			repo.URL = "https://charts.helm.sh/stable"
-		case "https://comcast.github.io/kuberhealthy/helm-repos":
-			repo.URL = "https://kuberhealthy.github.io/kuberhealthy/helm-repos"
-		case "https://godaddy.github.io/kubernetes-external-secrets":
-			repo.URL = "https://external-secrets.github.io/kubernetes-external-secrets"
-		case "https://chrismellard.github.io/kubernetes-external-secrets":
-			repo.URL = "https://external-secrets.github.io/kubernetes-external-secrets"
-		case "https://storage.googleapis.com/jenkinsxio/charts":
-			if repo.Name == "external-secrets" {
-				repo.URL = "https://external-secrets.github.io/kubernetes-external-secrets"
-			}
-		case "http://chartmuseum.jenkins-x.io":
-			repo.URL = "https://storage.googleapis.com/chartmuseum.jenkins-x.io"
-		}
-	}
-
-	// lets replace the old tekton chart if its being used
-	for i := range helmstate.Releases {
-		release := &helmstate.Releases[i]
-		if release.Chart == "jenkins-x/tekton" {
-			release.Chart = "cdf/tekton-pipeline"
-			release.Namespace = "tekton-pipelines"
-
-			// lets make sure we have a cdf repository
-			found := false
-			for k := range helmstate.Repositories {
-				repo := helmstate.Repositories[k]
-				if repo.Name == "cdf" {
-					found = true
-					break
-				}
-			}
-			if !found {
-				helmstate.Repositories = append(helmstate.Repositories, state.RepositorySpec{
-					Name: "cdf",
-					URL:  "https://cdfoundation.github.io/tekton-helm-chart",
-				})
-			}
-			break
-		}
-	}
-
-	addedJxgh := false
-
-	// lets replace the old jx3 charts
-	for i := range helmstate.Releases {
-		release := &helmstate.Releases[i]
-		names := strings.SplitN(release.Chart, "/", 2)
-		if len(names) == 2 && names[0] == "jx3" {
-			chartName := names[1]
-			name := release.Name
-			release.Chart = "jxgh/" + chartName
-			addedJxgh = true
-
-			for j := range release.Values {
-				v := release.Values[j]
-				s, ok := v.(string)
-				if !ok {
-					continue
-				}
-				// lets switch invalid paths to the one inside a chart repo folder
-				if s == fmt.Sprintf("%s/charts/jx3/%s/values.yaml.gotmpl", versionStreamPath, chartName) {
-					release.Values[j] = fmt.Sprintf("%s/charts/jxgh/%s/values.yaml.gotmpl", versionStreamPath, chartName)
-					break
-				}
-				if s == fmt.Sprintf("%s/charts/jx3/%s/values.yaml.gotmpl", versionStreamPath, name) {
-					release.Values[j] = fmt.Sprintf("%s/charts/jxgh/%s/values.yaml.gotmpl", versionStreamPath, name)
-					break
-				}
-			}
-		}
-	}
-
-	// lets replace the old jenkins-x charts
-	for _, chartName := range []string{"jxboot-helmfile-resources", "bucketrepo", "nexus"} {
-		for i := range helmstate.Releases {
-			release := &helmstate.Releases[i]
-			if release.Chart == "jenkins-x/"+chartName {
-				release.Chart = "jxgh/" + chartName
-				addedJxgh = true
-
-				for j := range release.Values {
-					v := release.Values[j]
-					s, ok := v.(string)
-					// lets switch invalid paths to the one inside a chart repo folder
-					if ok && s == fmt.Sprintf("%s/charts/jenkins-x/%s/values.yaml.gotmpl", versionStreamPath, chartName) {
-						release.Values[j] = fmt.Sprintf("%s/charts/jxgh/%s/values.yaml.gotmpl", versionStreamPath, chartName)
-						break
-					}
-				}
-			}
-		}
-	}
-
-	// lets replace the old lighthouse chart repository location if its being used
-	for i := range helmstate.Releases {
-		release := &helmstate.Releases[i]
-		if release.Chart == "jenkins-x/lighthouse" {
-			release.Chart = "jxgh/lighthouse"
-			addedJxgh = true
-
-			for i := range release.Values {
-				v := release.Values[i]
-				s, ok := v.(string)
-				// lets switch invalid paths to the one inside a chart repo folder
-				if ok && s == fmt.Sprintf("%s/charts/jenkins-x/lighthouse/values.yaml.gotmpl", versionStreamPath) {
-					release.Values[i] = fmt.Sprintf("%s/charts/jxgh/lighthouse/values.yaml.gotmpl", versionStreamPath)
-					break
-				}
-			}
-		}
-	}
-
-	// lets check for terraform installed vault
-	if requirements.SecretStorage == jxcore.SecretStorageTypeVault && requirements.TerraformVault {
-		for i := range helmstate.Releases {
-			release := &helmstate.Releases[i]
-			if release.Chart == "jxgh/vault-instance" || release.Chart == "banzaicloud-stable/vault-operator" {
-				log.Logger().Infof("Terraform installed detected and Vault chart %s still present in helmfile. Please migrate secrets as necessary and remove this chart from your helmfile", release.Chart)
-			}
-		}
-	}
-
-	// lets replace the old chartmuseum chart if its being used
-	for i := range helmstate.Releases {
-		release := &helmstate.Releases[i]
-		if release.Chart == "jenkins-x/chartmuseum" {
-			release.Chart = "stable/chartmuseum"
-			o.updateVersionFromVersionStream(release)
-			release.Values = []interface{}{fmt.Sprintf("%s/charts/stable/chartmuseum/values.yaml.gotmpl", versionStreamPath)}
-
-			// lets make sure we have a cdf repository
-			found := false
-			for k := range helmstate.Repositories {
-				repo := helmstate.Repositories[k]
-				if repo.Name == "stable" {
-					found = true
-					break
-				}
-			}
-			if !found {
-				helmstate.Repositories = append(helmstate.Repositories, state.RepositorySpec{
-					Name: "stable",
-					URL:  "https://charts.helm.sh/stable",
-				})
-			}
-			break
-		}
-	}
-
-	// Replace old nginx ingress chart
-	for i := range helmstate.Releases {
-		release := &helmstate.Releases[i]
-		switch release.Chart {
-		case "stable/nginx-ingress":
-			release.Chart = "ingress-nginx/ingress-nginx"
-			o.updateVersionFromVersionStream(release)
-			release.Values = []interface{}{fmt.Sprintf("%s/charts/ingress-nginx/ingress-nginx/values.yaml.gotmpl", versionStreamPath)}
-
-			// lets make sure we have the ingress-nginx repository
-			found := false
-			for k := range helmstate.Repositories {
-				repo := helmstate.Repositories[k]
-				if repo.Name == "ingress-nginx" {
-					found = true
-					break
-				}
-			}
-			if !found {
-				helmstate.Repositories = append(helmstate.Repositories, state.RepositorySpec{
-					Name: "ingress-nginx",
-					URL:  "https://kubernetes.github.io/ingress-nginx",
-				})
-			}
-		case "ingress-nginx/ingress-nginx":
-			for i := range release.Values {
-				v := release.Values[i]
-				s, ok := v.(string)
-				// lets switch invalid paths to the one inside a chart repo folder
-				if ok && s == fmt.Sprintf("%s/charts/ingress-nginx/values.yaml.gotmpl", versionStreamPath) {
-					release.Values[i] = fmt.Sprintf("%s/charts/ingress-nginx/ingress-nginx/values.yaml.gotmpl", versionStreamPath)
-					break
-				}
-			}
-		}
-	}
-
-	ns := jxcore.DefaultNamespace
-
-	if requirements.SecretStorage == jxcore.SecretStorageTypeLocal && helmstate.OverrideNamespace == ns {
-		// lets make sure the local external secrets chart is included
-		found := false
-		for i := range helmstate.Releases {
-			release := &helmstate.Releases[i]
-			if release.Chart == "jxgh/local-external-secrets" {
-				found = true
-				break
-			}
-		}
-		if !found {
-			release := state.ReleaseSpec{
-				Chart: "jxgh/local-external-secrets",
-			}
-			addedJxgh = true
-			o.updateVersionFromVersionStream(&release)
-			helmstate.Releases = append(helmstate.Releases, release)
-		}
-	}
-
-	// lets replace the old jx-labs/ charts...
-	for _, name := range []string{"jenkins-x-crds", "pusher-wave", "vault-instance"} {
-		chartName := "jx-labs/" + name
-		for i := range helmstate.Releases {
-			release := &helmstate.Releases[i]
-			if release.Chart == chartName {
-				release.Chart = "jxgh/" + name
-				addedJxgh = true
-				if name == "jenkins-x-crds" {
-					release.Values = []interface{}{fmt.Sprintf("%s/charts/jxgh/jenkins-x-crds/values.yaml.gotmpl", versionStreamPath)}
-				}
-				o.updateVersionFromVersionStream(release)
-				break
-			}
-		}
-	}
-
-	// lets ensure we have the jx-build-controller installed
-	if helmstate.OverrideNamespace == "jx" && isDevCluster(helmstate) {
-		found := false
-		for i := range helmstate.Releases {
-			release := &helmstate.Releases[i]
-			if release.Chart == "jxgh/jx-build-controller" {
-				found = true
-				break
-			}
-		}
-		if !found && helmstate.OverrideNamespace == "jx" {
-			helmstate.Releases = append(helmstate.Releases, state.ReleaseSpec{
-				Chart: "jxgh/jx-build-controller",
-			})
-			addedJxgh = true
-		}
-	}
-
-	if addedJxgh {
-		// lets make sure we have a jxgh repository
-		found := false
-		for k := range helmstate.Repositories {
-			repo := helmstate.Repositories[k]
-			if repo.Name == "jxgh" {
-				found = true
-				break
-			}
-		}
-		if !found {
-			helmstate.Repositories = append(helmstate.Repositories, state.RepositorySpec{
-				Name: "jxgh",
-				URL:  "https://jenkins-x-charts.github.io/repo",
-			})
-		}
-	}
-
-	if o.AddEnvironmentPipelines {
-		lighthouseTriggerFile := filepath.Join(o.Dir, ".lighthouse", "jenkins-x", "triggers.yaml")
-		exists, err := files.FileExists(lighthouseTriggerFile)
-		if err != nil {
-			return errors.Wrapf(err, "failed to detect file %s", lighthouseTriggerFile)
-		}
-		if !exists {
-			bin := o.KptBinary
-			if bin == "" {
-				bin, err = plugins.GetKptBinary(plugins.KptVersion)
-				if err != nil {
-					return err
-				}
-			}
-
-			args := []string{"pkg", "get", "https://github.com/jenkins-x/jx3-pipeline-catalog.git/environment/.lighthouse", o.Dir}
-			c := &cmdrunner.Command{
-				Name: bin,
-				Args: args,
-				Dir:  o.Dir,
-			}
-			_, err = o.CommandRunner(c)
-			if err != nil {
-				return errors.Wrapf(err, "failed to get environment tekton pipeline via kpt in dir %s", o.Dir)
-			}
-
-			err = gitclient.Add(o.Git(), o.Dir, ".lighthouse")
-			if err != nil {
-				return errors.Wrapf(err, "failed to add .lighthouse dir to git")
-			}
-
-			log.Logger().Infof("got tekton pipeline for envirnment at %s", lighthouseTriggerFile)
-		}
-	}
-	return nil
-}
-
 // removeRedundantRepositories removes any repositories from a state.HelmState that are not referenced by any releases
 func removeRedundantRepositories(helmstate *state.HelmState) {
 	requiredRepositories := make(map[string]bool)
@@ -1117,6 +865,10 @@ func (o *Options) upgradePipelineCatalog() error {
 	for i := range pc.Spec.Repositories {
 		repo := &pc.Spec.Repositories[i]
 		gitURL := repo.GitURL
+		if pipelinecatalogs.IsCommitSHA(repo.GitRef) && repo.GitTag != "" {
+			// pinned via 'pipelinecatalog upgrade' so lets not use the version stream
+			continue
+		}
 		if gitURL != "" {
 			version, err := o.Options.Resolver.ResolveGitVersion(gitURL)
 			if err != nil {
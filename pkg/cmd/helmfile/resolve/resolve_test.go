@@ -9,6 +9,7 @@ import (
 	"github.com/helmfile/helmfile/pkg/state"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helmfile/resolve"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/fakekpt"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/migrations"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
@@ -153,6 +154,8 @@ func TestStepHelmfileResolve(t *testing.T) {
 
 		t.Logf("generated files to %s\n", o.Dir)
 
+		assert.FileExists(t, filepath.Join(tmpDir, migrations.RecordFile), "should have recorded the applied migrations")
+
 		// lets assert that all the values files exist
 		helmState := &state.HelmState{}
 
@@ -292,3 +295,66 @@ func IsInCluster() bool {
 	_, err := rest.InClusterConfig()
 	return err == nil
 }
+
+func TestHelmfileResolveSubsetDoesNotRecordMigrations(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join("testdata", "helmfile_multi_subfolder")
+	err := files.CopyDirOverwrite(srcDir, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcDir, tmpDir)
+
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			return "", nil
+		},
+	}
+
+	_, o := resolve.NewCmdHelmfileResolve()
+	o.Dir = tmpDir
+	o.Helmfile = filepath.Join("helmfiles", "kuberhealthy", "helmfile.yaml")
+	o.HelmBinary = "helm"
+	o.HelmfileBinary = "helmfile"
+	o.TestOutOfCluster = true
+	o.CommandRunner = runner.Run
+	o.QuietCommandRunner = runner.Run
+	o.Gitter = cli.NewCLIClient("", runner.Run)
+	o.UpdateMode = true
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run the command")
+	assert.NoFileExists(t, filepath.Join(tmpDir, migrations.RecordFile), "should not record the migrations when only some helmfiles are resolved")
+}
+
+func TestHelmfileResolveValidateValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join("..", "validate", "testdata", "values")
+	err := files.CopyDirOverwrite(srcDir, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcDir, tmpDir)
+	err = files.CopyFile(filepath.Join("testdata", "no-versionstream", "jx-requirements.yml"), filepath.Join(tmpDir, "jx-requirements.yml"))
+	require.NoError(t, err, "failed to copy jx-requirements.yml")
+
+	// lets pull the local fixture charts of the validate command
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			if len(c.Args) < 2 || c.Args[0] != "pull" {
+				return "", nil
+			}
+			name := c.Args[1]
+			untarDir := c.Args[len(c.Args)-1]
+			return "", files.CopyDirOverwrite(filepath.Join("..", "validate", "testdata", "charts", name), filepath.Join(untarDir, name))
+		},
+	}
+
+	_, o := resolve.NewCmdHelmfileResolve()
+	o.Dir = tmpDir
+	o.HelmBinary = "helm"
+	o.HelmfileBinary = "helmfile"
+	o.TestOutOfCluster = true
+	o.CommandRunner = runner.Run
+	o.QuietCommandRunner = runner.Run
+	o.Gitter = cli.NewCLIClient("", runner.Run)
+	o.ValidateValues = true
+
+	err = o.Run()
+	require.Error(t, err, "should fail to validate the values")
+	assert.Contains(t, err.Error(), "replicaCount")
+}
@@ -7,10 +7,14 @@ import (
 	"strings"
 
 	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yaml2s"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -18,18 +22,37 @@ import (
 var (
 	cmdLong = templates.LongDesc(`
 		Parses a helmfile and any nested helmfiles and validates they conform to a canonical directory structure for jx based around namespace
+
+		With --values the merged values of each release are validated against the values.schema.json of the chart.
+		Any values.yaml.gotmpl files are rendered with the values of the default environment first. If the chart has
+		no schema then any top level values which are not in the values.yaml of the chart are reported as warnings.
 `)
 
 	cmdExample = templates.Examples(`
 		# Validates helmfile.yaml within the current directory
 		%s helmfile validate
+
+		# Validates the values of each release against the chart schemas too
+		%[1]s helmfile validate --values
 	`)
 )
 
 type Options struct {
-	Dir       string
-	Helmfile  string
-	OutputDir string
+	Dir           string
+	Helmfile      string
+	OutputDir     string
+	Values        bool
+	HelmBinary    string
+	ChartsDir     string
+	CommandRunner cmdrunner.CommandRunner
+
+	// Failures the values which do not match the chart schemas
+	Failures []string
+
+	// Warnings the unknown values of charts which have no schema
+	Warnings []string
+
+	tempChartsDir bool
 }
 
 func NewCmdHelmfileValidate() (*cobra.Command, *Options) {
@@ -48,6 +71,9 @@ func NewCmdHelmfileValidate() (*cobra.Command, *Options) {
 
 	cmd.Flags().StringVarP(&o.Helmfile, "helmfile", "", "", "the helmfile to template. Defaults to 'helmfile.yaml' in the directory")
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory that contains helmfile.yml")
+	cmd.Flags().BoolVarP(&o.Values, "values", "", false, "validates the values of each release against the values.schema.json of the chart")
+	cmd.Flags().StringVarP(&o.HelmBinary, "helm-binary", "", "", "specifies the helm binary location to use to pull charts. If not specified defaults to using the downloaded helm plugin")
+	cmd.Flags().StringVarP(&o.ChartsDir, "charts-dir", "", "", "the directory to pull charts into when validating values. Defaults to a temporary directory")
 
 	return cmd, o
 }
@@ -64,14 +90,35 @@ func (o *Options) Validate() error {
 			return errors.Wrapf(err, "failed to create temporary output directory")
 		}
 	}
+	if o.Values {
+		if o.CommandRunner == nil {
+			o.CommandRunner = cmdrunner.QuietCommandRunner
+		}
+		if o.HelmBinary == "" {
+			o.HelmBinary, err = plugins.GetHelmBinary(plugins.HelmVersion)
+			if err != nil {
+				return errors.Wrapf(err, "failed to download helm plugin")
+			}
+		}
+		if o.ChartsDir == "" {
+			o.ChartsDir, err = os.MkdirTemp("", "jx-charts-")
+			if err != nil {
+				return errors.Wrapf(err, "failed to create temporary charts directory")
+			}
+			o.tempChartsDir = true
+		}
+	}
 	return nil
 }
 
-func (o Options) Run() error {
+func (o *Options) Run() error {
 	err := o.Validate()
 	if err != nil {
 		return errors.Wrapf(err, "failed to validate")
 	}
+	if o.tempChartsDir {
+		defer os.RemoveAll(o.ChartsDir)
+	}
 
 	rootHelmState := state.HelmState{}
 
@@ -86,20 +133,37 @@ func (o Options) Run() error {
 		}
 	}
 
+	for _, w := range o.Warnings {
+		log.Logger().Warn(w)
+	}
+	if len(o.Failures) > 0 {
+		return errors.Errorf("values do not match the chart schemas:\n%s", strings.Join(o.Failures, "\n"))
+	}
 	return nil
 }
 
-func (o Options) validateSubHelmFile(path string) error {
+func (o *Options) validateSubHelmFile(path string) error {
 	targetNamespace, err := o.getSubHelmfileNamespace(path)
 	if err != nil {
 		return fmt.Errorf("failed to determine namespace from path %w", err)
 	}
 
-	helmState := state.HelmState{}
-	err = yaml2s.LoadFile(filepath.Join(o.Dir, path), &helmState)
+	// lets combine the documents of the helmfile as resolved helmfiles have the environments in a separate document
+	helmStates, err := helmfiles.LoadHelmfile(filepath.Join(o.Dir, path))
 	if err != nil {
 		return fmt.Errorf("failed to load helmfile - %w", err)
 	}
+	helmState := state.HelmState{}
+	for _, hs := range helmStates {
+		for name, env := range hs.Environments {
+			if helmState.Environments == nil {
+				helmState.Environments = map[string]state.EnvironmentSpec{}
+			}
+			helmState.Environments[name] = env
+		}
+		helmState.Repositories = append(helmState.Repositories, hs.Repositories...)
+		helmState.Releases = append(helmState.Releases, hs.Releases...)
+	}
 
 	for k := range helmState.Releases {
 		release := helmState.Releases[k]
@@ -113,6 +177,12 @@ func (o Options) validateSubHelmFile(path string) error {
 		if err := checkChartRepositoryExists(chartRepo, helmState.Repositories); err != nil {
 			return fmt.Errorf("error finding chart repo for %s", chartRepo)
 		}
+		if o.Values {
+			err = o.validateReleaseValues(filepath.Dir(filepath.Join(o.Dir, path)), &helmState, &release)
+			if err != nil {
+				return fmt.Errorf("failed to validate values of release %s: %w", release.Name, err)
+			}
+		}
 	}
 	return nil
 }
@@ -135,7 +205,7 @@ func getChartRepository(chart string) (string, error) {
 	return chartSplit[0], nil
 }
 
-func (o Options) getSubHelmfileNamespace(path string) (string, error) {
+func (o *Options) getSubHelmfileNamespace(path string) (string, error) {
 	subHelmRel := path
 
 	if filepath.IsAbs(path) {
@@ -5,7 +5,11 @@ import (
 	"testing"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/helmfile/validate"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -68,3 +72,64 @@ func TestStepHelmfileStructure(t *testing.T) {
 		}
 	}
 }
+
+func TestHelmfileValidateValues(t *testing.T) {
+	testCases := []struct {
+		testFolder       string
+		expectedFailures int
+		expectedWarnings []string
+	}{
+		{
+			testFolder: "values_valid",
+		},
+		{
+			testFolder:       "values",
+			expectedFailures: 1,
+			expectedWarnings: []string{
+				"release plainchart has unknown value ingres which is not in the values.yaml of chart example/plainchart",
+				"release plainchart has unknown value servcie which is not in the values.yaml of chart example/plainchart",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tmpDir := t.TempDir()
+
+		srcDir := filepath.Join("testdata", tc.testFolder)
+		err := files.CopyDirOverwrite(srcDir, tmpDir)
+		require.NoError(t, err, "failed to copy %s to %s", srcDir, tmpDir)
+
+		// lets pull the local fixture charts
+		runner := &fakerunner.FakeRunner{
+			CommandRunner: func(c *cmdrunner.Command) (string, error) {
+				if len(c.Args) < 2 || c.Args[0] != "pull" {
+					return "", errors.Errorf("unexpected command %s", c.CLI())
+				}
+				name := c.Args[1]
+				untarDir := c.Args[len(c.Args)-1]
+				return "", files.CopyDirOverwrite(filepath.Join("testdata", "charts", name), filepath.Join(untarDir, name))
+			},
+		}
+
+		_, o := validate.NewCmdHelmfileValidate()
+		o.Dir = tmpDir
+		o.Values = true
+		o.HelmBinary = "helm"
+		o.ChartsDir = t.TempDir()
+		o.CommandRunner = runner.Run
+
+		err = o.Run()
+		if tc.expectedFailures > 0 {
+			require.Error(t, err, "for %s", tc.testFolder)
+		} else {
+			require.NoError(t, err, "for %s", tc.testFolder)
+		}
+		require.Len(t, o.Failures, tc.expectedFailures, "failures for %s: %v", tc.testFolder, o.Failures)
+		assert.Equal(t, tc.expectedWarnings, o.Warnings, "warnings for %s", tc.testFolder)
+		if tc.expectedFailures > 0 {
+			assert.Contains(t, o.Failures[0], "replicaCount")
+		}
+		require.Len(t, runner.OrderedCommands, 2, "should have pulled the charts for %s", tc.testFolder)
+		assert.Equal(t, "helm pull schemachart --repo https://charts.example.com --version 1.0.0 --untar --untardir "+filepath.Join(o.ChartsDir, "example", "schemachart", "1.0.0"), runner.OrderedCommands[0].CLI())
+	}
+}
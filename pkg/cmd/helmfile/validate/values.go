@@ -0,0 +1,297 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/helmfile/helmfile/pkg/tmpl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"sigs.k8s.io/yaml"
+)
+
+// AlwaysAllowedValues top level values which are not reported as unknown for charts without a schema
+var AlwaysAllowedValues = []string{"global", "jxRequirements"}
+
+// validateReleaseValues validates the merged values of the release against the chart values.schema.json
+// or reports unknown top level values as warnings if the chart has no schema
+func (o *Options) validateReleaseValues(helmfileDir string, helmState *state.HelmState, release *state.ReleaseSpec) error {
+	chartDir, err := o.fetchChart(helmfileDir, helmState, release)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch chart %s", release.Chart)
+	}
+
+	values, err := loadValuesFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil {
+		return err
+	}
+	userValues := map[string]interface{}{}
+	for _, v := range release.Values {
+		var m map[string]interface{}
+		switch t := v.(type) {
+		case string:
+			path := t
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(helmfileDir, path)
+			}
+			if strings.HasSuffix(path, ".gotmpl") {
+				m, err = renderValuesTemplate(helmfileDir, helmState, release, path)
+			} else {
+				m, err = loadValuesFile(path)
+			}
+			if err != nil {
+				return err
+			}
+		case map[string]interface{}:
+			m = t
+		default:
+			// lets convert any other kind of map via YAML
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return errors.Wrapf(err, "failed to marshal values of release %s", release.Name)
+			}
+			err = yaml.Unmarshal(data, &m)
+			if err != nil {
+				return errors.Wrapf(err, "failed to unmarshal values of release %s", release.Name)
+			}
+		}
+		MergeValues(userValues, m)
+	}
+	MergeValues(values, userValues)
+
+	schemaFile := filepath.Join(chartDir, "values.schema.json")
+	exists, err := files.FileExists(schemaFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", schemaFile)
+	}
+	if !exists {
+		known, err := o.knownValues(chartDir)
+		if err != nil {
+			return err
+		}
+		var unknown []string
+		for k := range userValues {
+			if !known[k] {
+				unknown = append(unknown, k)
+			}
+		}
+		sort.Strings(unknown)
+		for _, k := range unknown {
+			o.Warnings = append(o.Warnings, fmt.Sprintf("release %s has unknown value %s which is not in the values.yaml of chart %s", release.Name, k, release.Chart))
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file %s", schemaFile)
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(data), gojsonschema.NewGoLoader(values))
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate values against schema %s", schemaFile)
+	}
+	for _, e := range result.Errors() {
+		o.Failures = append(o.Failures, fmt.Sprintf("release %s: %s", release.Name, e.String()))
+	}
+	return nil
+}
+
+// renderValuesTemplate renders the values template of the release like helmfile does using the values of the
+// default environment and the helmfile template functions such as readFile
+func renderValuesTemplate(helmfileDir string, helmState *state.HelmState, release *state.ReleaseSpec, path string) (map[string]interface{}, error) {
+	envValues := map[string]interface{}{}
+	env := helmState.Environments["default"]
+	for _, v := range env.Values {
+		text, ok := v.(string)
+		if !ok || strings.HasSuffix(text, ".gotmpl") {
+			log.Logger().Debugf("ignoring environment values %v when rendering %s", v, path)
+			continue
+		}
+		if !filepath.IsAbs(text) {
+			text = filepath.Join(helmfileDir, text)
+		}
+		m, err := loadValuesFile(text)
+		if err != nil {
+			return nil, err
+		}
+		MergeValues(envValues, m)
+	}
+	data := map[string]interface{}{
+		"Values": envValues,
+		"Environment": map[string]interface{}{
+			"Name":   "default",
+			"Values": envValues,
+		},
+		"Namespace": release.Namespace,
+		"Release": map[string]interface{}{
+			"Name":      release.Name,
+			"Namespace": release.Namespace,
+			"Chart":     release.Chart,
+		},
+	}
+	renderer := tmpl.NewFileRenderer(filesystem.DefaultFileSystem(), helmfileDir, data)
+	output, err := renderer.RenderToBytes(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to render values template %s of release %s", path, release.Name)
+	}
+	answer := map[string]interface{}{}
+	err = yaml.Unmarshal(output, &answer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the rendered values template %s", path)
+	}
+	if answer == nil {
+		answer = map[string]interface{}{}
+	}
+	return answer, nil
+}
+
+// knownValues returns the top level values defined in the chart values.yaml or by its dependencies
+func (o *Options) knownValues(chartDir string) (map[string]bool, error) {
+	answer := map[string]bool{}
+	for _, k := range AlwaysAllowedValues {
+		answer[k] = true
+	}
+	values, err := loadValuesFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	for k := range values {
+		answer[k] = true
+	}
+
+	chart := struct {
+		Dependencies []struct {
+			Name  string `json:"name"`
+			Alias string `json:"alias"`
+		} `json:"dependencies"`
+	}{}
+	path := filepath.Join(chartDir, "Chart.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	err = yaml.Unmarshal(data, &chart)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse file %s", path)
+	}
+	for _, d := range chart.Dependencies {
+		answer[d.Name] = true
+		if d.Alias != "" {
+			answer[d.Alias] = true
+		}
+	}
+	return answer, nil
+}
+
+// fetchChart returns the directory of the chart of the release pulling it if it is not local
+func (o *Options) fetchChart(helmfileDir string, helmState *state.HelmState, release *state.ReleaseSpec) (string, error) {
+	chart := release.Chart
+	if strings.HasPrefix(chart, ".") || filepath.IsAbs(chart) {
+		if !filepath.IsAbs(chart) {
+			chart = filepath.Join(helmfileDir, chart)
+		}
+		return chart, nil
+	}
+
+	prefix, name, err := splitChart(chart)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(o.ChartsDir, prefix, name, release.Version)
+	chartDir := filepath.Join(dir, name)
+	exists, err := files.DirExists(chartDir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if dir exists %s", chartDir)
+	}
+	if exists {
+		return chartDir, nil
+	}
+
+	var repo *state.RepositorySpec
+	for i := range helmState.Repositories {
+		if helmState.Repositories[i].Name == prefix {
+			repo = &helmState.Repositories[i]
+			break
+		}
+	}
+	if repo == nil {
+		return "", errors.Errorf("no repository %s for chart %s", prefix, chart)
+	}
+	args := []string{"pull"}
+	if repo.OCI {
+		args = append(args, "oci://"+strings.TrimSuffix(repo.URL, "/")+"/"+name)
+	} else {
+		args = append(args, name, "--repo", repo.URL)
+	}
+	if release.Version != "" {
+		args = append(args, "--version", release.Version)
+	}
+	args = append(args, "--untar", "--untardir", dir)
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	c := &cmdrunner.Command{
+		Name: o.HelmBinary,
+		Args: args,
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s", c.CLI())
+	}
+	return chartDir, nil
+}
+
+func splitChart(chart string) (string, string, error) {
+	parts := strings.Split(chart, "/")
+	if len(parts) != 2 {
+		return "", "", errors.Errorf("failed to determine chart name for %s", chart)
+	}
+	return parts[0], parts[1], nil
+}
+
+func loadValuesFile(path string) (map[string]interface{}, error) {
+	answer := map[string]interface{}{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return answer, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	err = yaml.Unmarshal(data, &answer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse values file %s", path)
+	}
+	if answer == nil {
+		answer = map[string]interface{}{}
+	}
+	return answer, nil
+}
+
+// MergeValues deep merges the values from the source into the destination like helm does
+func MergeValues(dest, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		if ok {
+			destMap, ok := dest[k].(map[string]interface{})
+			if ok {
+				MergeValues(destMap, srcMap)
+				continue
+			}
+		}
+		dest[k] = v
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/ingresses"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
@@ -15,14 +16,16 @@ import (
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	nv1 "k8s.io/api/networking/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"sigs.k8s.io/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 var (
 	ingressLong = templates.LongDesc(`
 		Updates Ingress resources with the current ingress domain
+
+		As well as Ingress resources the hosts of Gateway API HTTPRoute and Gateway, Istio VirtualService and Gateway
+		and OpenShift Route resources are updated. If TLS is disabled in the requirements then the TLS configuration
+		of any modified resource is removed.
 `)
 
 	ingressExample = templates.Examples(`
@@ -37,6 +40,7 @@ type Options struct {
 	ReplaceDomain        string
 	BatchMode            bool
 	FailOnYAMLParseError bool
+	Handlers             []ingresses.Handler
 }
 
 // NewCmdUpdate creates a command object for the command
@@ -75,53 +79,18 @@ func (o *Options) Run() error {
 
 	log.Logger().Infof("replacing ingress domain %s to %s with TLS: %v", termcolor.ColorInfo(o.ReplaceDomain), termcolor.ColorInfo(newDomain), tlsEnabled)
 
-	fn := func(ing *nv1.Ingress, path string) (bool, error) {
-		modified := false
-		s := &ing.Spec
-		for i, r := range s.Rules {
-			currentHost := r.Host
-			host := o.modifyHost(currentHost, newDomain)
-			if host != "" && host != currentHost {
-				modified = true
-				s.Rules[i].Host = host
-				log.Logger().Infof("ingress at %s updated to %s", termcolor.ColorInfo(path), termcolor.ColorInfo(host))
-			} else {
-				log.Logger().Infof("ingress at %s does not match domain as is %s", termcolor.ColorInfo(path), termcolor.ColorInfo(currentHost))
-			}
-		}
-		for i, tls := range s.TLS {
-			hosts := tls.Hosts
-			for j, currentHost := range hosts {
-				host := o.modifyHost(currentHost, newDomain)
-				if host != "" && host != currentHost {
-					modified = true
-					if !tlsEnabled {
-						log.Logger().Infof("ingress at %s disabling TLS", termcolor.ColorInfo(path))
-						s.TLS = nil
-						break
-					}
-					log.Logger().Infof("ingress at %s updated to %s", termcolor.ColorInfo(path), termcolor.ColorInfo(host))
-					hosts[j] = host
-					s.TLS[i].Hosts = hosts
-				} else {
-					log.Logger().Infof("ingress at %s does not match domain as is %s", termcolor.ColorInfo(path), termcolor.ColorInfo(currentHost))
-				}
-			}
-		}
-		return modified, nil
+	if len(o.Handlers) == 0 {
+		o.Handlers = ingresses.DefaultHandlers
 	}
-	return o.updateIngresses(o.Dir, fn)
-}
-
-// modifyHost modifies the host name if it matches the predicate otherwise return an empty string
-func (o *Options) modifyHost(host, newDomain string) string {
-	if strings.HasSuffix(host, o.ReplaceDomain) {
-		return strings.TrimSuffix(host, o.ReplaceDomain) + newDomain
+	r := &ingresses.Replacer{
+		ReplaceDomain: o.ReplaceDomain,
+		Domain:        newDomain,
+		TLS:           tlsEnabled,
 	}
-	return ""
+	return o.updateResources(o.Dir, r)
 }
 
-func (o *Options) updateIngresses(dir string, fn func(ing *nv1.Ingress, path string) (bool, error)) error {
+func (o *Options) updateResources(dir string, r *ingresses.Replacer) error {
 	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error { //nolint:staticcheck
 		if info == nil || info.IsDir() {
 			return nil
@@ -142,8 +111,7 @@ func (o *Options) updateIngresses(dir string, fn func(ing *nv1.Ingress, path str
 			return errors.Wrapf(err, "failed to load file %s", path)
 		}
 
-		obj := &unstructured.Unstructured{}
-		err = yaml.Unmarshal(data, obj)
+		node, err := yaml.Parse(string(data))
 		if err != nil {
 			if o.FailOnYAMLParseError {
 				return errors.Wrapf(err, "failed to unmarshal YAML in file %s", path)
@@ -151,31 +119,18 @@ func (o *Options) updateIngresses(dir string, fn func(ing *nv1.Ingress, path str
 			log.Logger().Infof("could not parse YAML file %s", path)
 			return nil
 		}
-		if obj.GetKind() != "Ingress" {
-			return nil
-		}
-		apiVersion := obj.GetAPIVersion()
-		if apiVersion != "networking.k8s.io/v1" && apiVersion != "networking.k8s.io/v1beta1" && apiVersion != "extensions/v1beta1" {
+		h := ingresses.FindHandler(o.Handlers, node, path)
+		if h == nil {
 			return nil
 		}
 
-		ing := &nv1.Ingress{}
-		err = yaml.Unmarshal(data, ing)
+		data, modified, err := h.Update(data, path, r)
 		if err != nil {
-			return errors.Wrapf(err, "failed to unmarshal YAML as Ingress in file %s", path)
-		}
-
-		modified, err := fn(ing, path)
-		if err != nil {
-			return errors.Wrapf(err, "failed to modify Ingress at %s", path)
+			return errors.Wrapf(err, "failed to modify %s at %s", h.Name, path)
 		}
 		if !modified {
 			return nil
 		}
-		data, err = yaml.Marshal(ing)
-		if err != nil {
-			return errors.Wrap(err, "failed to marshal ingress onject to yaml")
-		}
 		err = os.WriteFile(path, data, files.DefaultFileWritePermissions)
 		if err != nil {
 			return errors.Wrapf(err, "failed to save %s", path)
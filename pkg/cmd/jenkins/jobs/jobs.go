@@ -15,6 +15,8 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
@@ -34,6 +36,11 @@ var (
 		# generate the jenkins job files
 		%s jenkins jobs
 
+		# generate the JCasC jobs YAML as a structured YAML tree which the helm values include via helmfile
+		%[1]s jenkins jobs --format yaml
+
+		# generate a job DSL seed script for each jenkins server which JCasC runs on startup
+		%[1]s jenkins jobs --format seed
 	`)
 
 	jobValuesHeader = `# NOTE this file is autogenerated - DO NOT EDIT!
@@ -50,12 +57,27 @@ controller:
 	indent = "              "
 )
 
+const (
+	// FormatJCasC generates the job DSL scripts indented into the JCasC configScripts string
+	FormatJCasC = "jcasc"
+
+	// FormatYAML generates the JCasC jobs YAML as a structured YAML node tree
+	FormatYAML = "yaml"
+
+	// FormatSeed generates a job DSL seed script for each jenkins server
+	FormatSeed = "seed"
+)
+
+// Formats the supported output formats
+var Formats = []string{FormatJCasC, FormatYAML, FormatSeed}
+
 // LabelOptions the options for the command
 type Options struct {
 	Dir                    string
 	ConfigFile             string
 	OutDir                 string
 	DefaultTemplate        string
+	Format                 string
 	NoCreateHelmfile       bool
 	SourceConfig           v1alpha1.SourceConfig
 	JenkinsServerTemplates map[string][]*JenkinsTemplateConfig
@@ -65,6 +87,8 @@ type Options struct {
 type JenkinsTemplateConfig struct {
 	Server       string
 	Key          string
+	Group        string
+	Repository   string
 	TemplateFile string
 	TemplateText string
 	TemplateData map[string]interface{}
@@ -89,6 +113,7 @@ func NewCmdJenkinsJobs() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.OutDir, "out", "o", "", "the output directory for the generated config files. If not specified defaults to the jenkins dir in the current directory")
 	cmd.Flags().StringVarP(&o.ConfigFile, "config", "c", "", "the configuration file to load for the repository configurations. If not specified we look in ./.jx/gitops/source-config.yaml")
 	cmd.Flags().StringVarP(&o.DefaultTemplate, "default-template", "", "", "the default job template file if none is configured for a repository")
+	cmd.Flags().StringVarP(&o.Format, "format", "", FormatJCasC, fmt.Sprintf("the output format of the generated jobs. Possible values: %s", strings.Join(Formats, ", ")))
 	cmd.Flags().BoolVarP(&o.NoCreateHelmfile, "no-create-helmfile", "", false, "disables the creation of the helmfiles/jenkinsName/helmfile.yaml file if a jenkins server does not yet exist")
 	return cmd, o
 }
@@ -100,6 +125,12 @@ func (o *Options) Validate() error {
 	if o.OutDir == "" {
 		o.OutDir = filepath.Join(o.Dir, "helmfiles")
 	}
+	if o.Format == "" {
+		o.Format = FormatJCasC
+	}
+	if stringhelpers.StringArrayIndex(Formats, o.Format) < 0 {
+		return options.InvalidOption("format", o.Format, Formats)
+	}
 
 	exists, err := files.FileExists(o.ConfigFile)
 	if err != nil {
@@ -206,30 +237,55 @@ func (o *Options) Run() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to verify the jenkins helmfile exists for %s", server)
 		}
+		err = o.removeStaleJobFiles(dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove the job files of other formats for %s", server)
+		}
 
-		path := filepath.Join(dir, "job-values.yaml")
-		log.Logger().Infof("creating Jenkins values.yaml file %s", path)
+		switch o.Format {
+		case FormatYAML:
+			err = o.writeJobValuesYAML(dir, server, configs)
+		case FormatSeed:
+			err = o.writeSeedScript(dir, server, configs)
+		default:
+			err = o.writeJCasCJobValues(dir, server, configs)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate jobs for jenkins server %s", server)
+		}
+	}
+	if o.Format != FormatJCasC {
+		err = o.pruneRemovedServers()
+		if err != nil {
+			return errors.Wrapf(err, "failed to prune jobs for removed jenkins servers")
+		}
+	}
+	return nil
+}
 
-		funcMap := sprig.TxtFuncMap()
+func (o *Options) writeJCasCJobValues(dir, server string, configs []*JenkinsTemplateConfig) error {
+	path := filepath.Join(dir, "job-values.yaml")
+	log.Logger().Infof("creating Jenkins values.yaml file %s", path)
 
-		buf := strings.Builder{}
-		buf.WriteString(jobValuesHeader)
+	funcMap := sprig.TxtFuncMap()
 
-		for _, jcfg := range configs {
-			tmplpath := jcfg.TemplateFile
-			output, err := templater.Evaluate(funcMap, jcfg.TemplateData, jcfg.TemplateText, tmplpath, "Jenkins Server "+server)
-			if err != nil {
-				return errors.Wrapf(err, "failed to evaluate template %s", tmplpath)
-			}
-			buf.WriteString(indent + "// from template: " + tmplpath + "\n")
-			buf.WriteString(indentText(output, indent))
-			buf.WriteString(indent + "\n")
-		}
+	buf := strings.Builder{}
+	buf.WriteString(jobValuesHeader)
 
-		err = os.WriteFile(path, []byte(buf.String()), files.DefaultFileWritePermissions)
+	for _, jcfg := range configs {
+		tmplpath := jcfg.TemplateFile
+		output, err := templater.Evaluate(funcMap, jcfg.TemplateData, jcfg.TemplateText, tmplpath, "Jenkins Server "+server)
 		if err != nil {
-			return errors.Wrapf(err, "failed to save file %s", path)
+			return errors.Wrapf(err, "failed to evaluate template %s", tmplpath)
 		}
+		buf.WriteString(indent + "// from template: " + tmplpath + "\n")
+		buf.WriteString(indentText(output, indent))
+		buf.WriteString(indent + "\n")
+	}
+
+	err := os.WriteFile(path, []byte(buf.String()), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
 	}
 	return nil
 }
@@ -265,7 +321,7 @@ func (o *Options) processJenkinsJobConfigForGroup(group *v1alpha1.RepositoryGrou
 		"GitName":      group.ProviderName,
 		"Server":       server,
 	}
-	return o.processJenkinsServerJobTemplate(server, owner, jobTemplatePath, templateData)
+	return o.processJenkinsServerJobTemplate(server, owner, owner, "", jobTemplatePath, templateData)
 }
 
 func (o *Options) processJenkinsJobConfigForRepository(group *v1alpha1.RepositoryGroup, repo *v1alpha1.Repository, server, jobTemplatePath string) error {
@@ -290,10 +346,10 @@ func (o *Options) processJenkinsJobConfigForRepository(group *v1alpha1.Repositor
 		"CloneURL":     repo.HTTPCloneURL,
 		"Server":       server,
 	}
-	return o.processJenkinsServerJobTemplate(server, fullName, jobTemplatePath, templateData)
+	return o.processJenkinsServerJobTemplate(server, fullName, group.Owner, repo.Name, jobTemplatePath, templateData)
 }
 
-func (o *Options) processJenkinsServerJobTemplate(server, key, jobTemplatePath string, templateData map[string]interface{}) error {
+func (o *Options) processJenkinsServerJobTemplate(server, key, group, repository, jobTemplatePath string, templateData map[string]interface{}) error {
 	jobTemplate := filepath.Join(o.Dir, jobTemplatePath)
 	exists, err := files.FileExists(jobTemplate)
 	if err != nil {
@@ -310,6 +366,8 @@ func (o *Options) processJenkinsServerJobTemplate(server, key, jobTemplatePath s
 	o.JenkinsServerTemplates[server] = append(o.JenkinsServerTemplates[server], &JenkinsTemplateConfig{
 		Server:       server,
 		Key:          key,
+		Group:        group,
+		Repository:   repository,
 		TemplateFile: jobTemplate,
 		TemplateText: string(data),
 		TemplateData: templateData,
@@ -322,6 +380,9 @@ func (o *Options) verifyServerHelmfileExists(server string) error {
 	ao.Name = server
 	ao.Dir = o.Dir
 	ao.Values = []string{"job-values.yaml", "values.yaml"}
+	if o.Format != FormatJCasC {
+		ao.Values = []string{JobValuesTemplateFileName, "values.yaml"}
+	}
 
 	err := ao.Run()
 	if err != nil {
@@ -1,6 +1,7 @@
 package jobs_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -107,3 +108,166 @@ func TestNoJenkinsJobs(t *testing.T) {
 	err := o.Run()
 	require.NoError(t, err, "failed to run the command in dir %s", tmpDir)
 }
+
+func TestJenkinsJobsStructuredFormats(t *testing.T) {
+	for _, format := range []string{jobs.FormatYAML, jobs.FormatSeed} {
+		tmpDir := t.TempDir()
+
+		srcDir := filepath.Join("testdata", "hasjobs")
+		err := files.CopyDirOverwrite(srcDir, tmpDir)
+		require.NoError(t, err, "failed to copy from %s to %s", srcDir, tmpDir)
+
+		gitter := cli.NewCLIClient("", nil)
+		_, err = gitter.Command(tmpDir, "init")
+		require.NoError(t, err, "failed to git init dir %s", tmpDir)
+
+		_, o := jobs.NewCmdJenkinsJobs()
+		o.Dir = tmpDir
+		o.Format = format
+		err = o.Run()
+		require.NoError(t, err, "failed to run the generate jobs command for format %s", format)
+
+		jenkinsDir := filepath.Join(tmpDir, "helmfiles", jenkinsName)
+		manifestFile := filepath.Join(jenkinsDir, jobs.JobsManifestFileName)
+		manifest := &jobs.JobsManifest{}
+		err = yamls.LoadFile(manifestFile, manifest)
+		require.NoError(t, err, "failed to load %s", manifestFile)
+		require.Len(t, manifest.Jobs, 3, "jobs in %s", manifestFile)
+		assert.Equal(t, "myorg", manifest.Jobs[0].Name, "folder job name")
+		assert.Equal(t, "myorg/myapp", manifest.Jobs[1].Name, "repository job name")
+		assert.Equal(t, "myapp", manifest.Jobs[1].Repository, "repository job repository")
+		assert.Equal(t, filepath.Join("jenkins", "templates", "job.gotmpl"), manifest.Jobs[1].Template, "repository job template")
+
+		valuesTemplateFile := filepath.Join(jenkinsDir, jobs.JobValuesTemplateFileName)
+		require.FileExists(t, valuesTemplateFile)
+		assert.NoFileExists(t, filepath.Join(jenkinsDir, jobs.JobValuesFileName))
+
+		helmfile := filepath.Join(jenkinsDir, "helmfile.yaml")
+		helmState := state.HelmState{}
+		err = yaml2s.LoadFile(helmfile, &helmState)
+		require.NoError(t, err, "failed to load file %s", helmfile)
+		require.NotEmpty(t, helmState.Releases, "releases in %s", helmfile)
+		assert.Contains(t, helmState.Releases[0].Values, jobs.JobValuesTemplateFileName, "values of the jenkins release in %s", helmfile)
+
+		data, err := os.ReadFile(valuesTemplateFile)
+		require.NoError(t, err, "failed to load %s", valuesTemplateFile)
+		valuesTemplate := string(data)
+
+		switch format {
+		case jobs.FormatYAML:
+			assert.Contains(t, valuesTemplate, `readFile "`+jobs.JCasCJobsFileName+`"`, "values template %s", valuesTemplateFile)
+
+			jcascFile := filepath.Join(jenkinsDir, jobs.JCasCJobsFileName)
+			jcasc := map[string][]map[string]string{}
+			err = yamls.LoadFile(jcascFile, &jcasc)
+			require.NoError(t, err, "failed to parse JCasC file %s", jcascFile)
+			require.Len(t, jcasc["jobs"], 3, "jobs in JCasC file %s", jcascFile)
+			assert.Contains(t, jcasc["jobs"][1]["script"], "multibranchPipelineJob('myorg/myapp')")
+		case jobs.FormatSeed:
+			assert.Contains(t, valuesTemplate, `readFile "`+jobs.SeedFileName+`"`, "values template %s", valuesTemplateFile)
+			assert.FileExists(t, filepath.Join(jenkinsDir, jobs.SeedFileName))
+		}
+
+		// now lets remove the jenkins server and check we prune the generated files
+		sourceConfigFile := filepath.Join(tmpDir, ".jx", "gitops", "source-config.yaml")
+		err = os.WriteFile(sourceConfigFile, []byte("apiVersion: gitops.jenkins-x.io/v1alpha1\nkind: SourceConfig\nspec: {}\n"), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save %s", sourceConfigFile)
+
+		_, o = jobs.NewCmdJenkinsJobs()
+		o.Dir = tmpDir
+		o.Format = format
+		err = o.Run()
+		require.NoError(t, err, "failed to run the generate jobs command for format %s", format)
+
+		assert.NoFileExists(t, manifestFile, "should have pruned the manifest")
+		assert.NoFileExists(t, valuesTemplateFile, "should have pruned the job values template")
+		assert.NoFileExists(t, filepath.Join(jenkinsDir, jobs.JCasCJobsFileName), "should have pruned the JCasC jobs")
+		assert.NoFileExists(t, filepath.Join(jenkinsDir, jobs.SeedFileName), "should have pruned the seed")
+
+		helmState = state.HelmState{}
+		err = yaml2s.LoadFile(helmfile, &helmState)
+		require.NoError(t, err, "failed to load file %s", helmfile)
+		assert.NotContains(t, helmState.Releases[0].Values, jobs.JobValuesTemplateFileName, "should have removed the pruned values from %s", helmfile)
+	}
+}
+
+func TestJenkinsJobsSwitchFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	srcDir := filepath.Join("testdata", "hasjobs")
+	err := files.CopyDirOverwrite(srcDir, tmpDir)
+	require.NoError(t, err, "failed to copy from %s to %s", srcDir, tmpDir)
+
+	gitter := cli.NewCLIClient("", nil)
+	_, err = gitter.Command(tmpDir, "init")
+	require.NoError(t, err, "failed to git init dir %s", tmpDir)
+
+	jenkinsDir := filepath.Join(tmpDir, "helmfiles", jenkinsName)
+	helmfile := filepath.Join(jenkinsDir, "helmfile.yaml")
+	for _, format := range []string{jobs.FormatSeed, jobs.FormatJCasC} {
+		_, o := jobs.NewCmdJenkinsJobs()
+		o.Dir = tmpDir
+		o.Format = format
+		err = o.Run()
+		require.NoError(t, err, "failed to run the generate jobs command for format %s", format)
+	}
+
+	assert.FileExists(t, filepath.Join(jenkinsDir, jobs.JobValuesFileName))
+	assert.NoFileExists(t, filepath.Join(jenkinsDir, jobs.JobValuesTemplateFileName), "should have removed the seed values template")
+	assert.NoFileExists(t, filepath.Join(jenkinsDir, jobs.SeedFileName), "should have removed the seed")
+
+	helmState := state.HelmState{}
+	err = yaml2s.LoadFile(helmfile, &helmState)
+	require.NoError(t, err, "failed to load file %s", helmfile)
+	require.NotEmpty(t, helmState.Releases, "releases in %s", helmfile)
+	values := helmState.Releases[0].Values
+	assert.Contains(t, values, jobs.JobValuesFileName, "values of the jenkins release in %s", helmfile)
+	assert.NotContains(t, values, jobs.JobValuesTemplateFileName, "values of the jenkins release in %s", helmfile)
+}
+
+func TestJenkinsJobsInvalidGroovy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	srcDir := filepath.Join("testdata", "hasjobs")
+	err := files.CopyDirOverwrite(srcDir, tmpDir)
+	require.NoError(t, err, "failed to copy from %s to %s", srcDir, tmpDir)
+
+	gitter := cli.NewCLIClient("", nil)
+	_, err = gitter.Command(tmpDir, "init")
+	require.NoError(t, err, "failed to git init dir %s", tmpDir)
+
+	jobTemplate := filepath.Join(tmpDir, "jenkins", "templates", "job.gotmpl")
+	err = os.WriteFile(jobTemplate, []byte("multibranchPipelineJob('{{ .FullName }}') {\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", jobTemplate)
+
+	_, o := jobs.NewCmdJenkinsJobs()
+	o.Dir = tmpDir
+	o.Format = jobs.FormatYAML
+	err = o.Run()
+	require.Error(t, err, "should fail for an invalid job DSL script")
+	assert.Contains(t, err.Error(), "from group myorg repository myapp template "+filepath.Join("jenkins", "templates", "job.gotmpl"))
+}
+
+func TestValidateGroovy(t *testing.T) {
+	testCases := []struct {
+		script string
+		valid  bool
+	}{
+		{script: "folder('myorg')", valid: true},
+		{script: "job('a') {\n  // a comment with a (\n  steps { shell(\"echo '}'\") }\n}", valid: true},
+		{script: "job('a') {\n  description('''multi\nline { ''')\n}", valid: true},
+		{script: "/* comment */ folder('a')", valid: true},
+		{script: "job('a') {", valid: false},
+		{script: "job('a')) {}", valid: false},
+		{script: "job('a) {}", valid: false},
+		{script: "/* comment folder('a')", valid: false},
+	}
+	for _, tc := range testCases {
+		err := jobs.ValidateGroovy(tc.script)
+		if tc.valid {
+			assert.NoError(t, err, "for script %s", tc.script)
+		} else {
+			assert.Error(t, err, "for script %s", tc.script)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/maps"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// JobsManifestFileName the file generated in each jenkins server folder listing the generated jobs
+	JobsManifestFileName = "jobs.yaml"
+
+	// JobValuesFileName the helm values file containing the JCasC jobs
+	JobValuesFileName = "job-values.yaml"
+
+	// JobValuesTemplateFileName the helm values template which includes the generated jobs into the JCasC configuration
+	JobValuesTemplateFileName = "job-values.yaml.gotmpl"
+
+	// JCasCJobsFileName the JCasC jobs YAML file generated by the yaml format
+	JCasCJobsFileName = "jcasc-jobs.yaml"
+
+	// SeedFileName the job DSL seed script file name
+	SeedFileName = "seed.groovy"
+
+	generatedHeader = `# NOTE this file is autogenerated - DO NOT EDIT!
+#
+# This file is generated from the template files via the command:
+#    jx gitops jenkins jobs
+`
+
+	// jcascJobsValuesTemplate includes the JCasC jobs file into the helm values. The jenkins chart requires
+	// each configScripts entry to be a string so helmfile embeds the file when the values are rendered
+	jcascJobsValuesTemplate = generatedHeader + `controller:
+  JCasC:
+    configScripts:
+      jxsetup: |
+{{ readFile "` + JCasCJobsFileName + `" | indent 8 }}
+`
+
+	// seedValuesTemplate registers the seed script with the job DSL plugin via the JCasC jobs configuration
+	seedValuesTemplate = generatedHeader + `controller:
+  JCasC:
+    configScripts:
+      jxsetup: |
+        jobs:
+          - script: |
+{{ readFile "` + SeedFileName + `" | indent 14 }}
+`
+)
+
+// JobsManifest records the jobs generated for a jenkins server so that we can report
+// where each job came from and prune jobs which are no longer configured
+type JobsManifest struct {
+	// Jobs the generated jobs
+	Jobs []JobSource `json:"jobs,omitempty"`
+}
+
+// JobSource describes the source configuration that generated a job
+type JobSource struct {
+	// Name the name of the job
+	Name string `json:"name"`
+
+	// Group the owner of the RepositoryGroup
+	Group string `json:"group,omitempty"`
+
+	// Repository the name of the Repository or blank for a folder job
+	Repository string `json:"repository,omitempty"`
+
+	// Template the template file used to generate the job
+	Template string `json:"template,omitempty"`
+}
+
+// String returns a description of where the job came from
+func (j *JobSource) String() string {
+	text := "job " + j.Name + " from group " + j.Group
+	if j.Repository != "" {
+		text += " repository " + j.Repository
+	}
+	return text + " template " + j.Template
+}
+
+// renderedJob a job script rendered from its template
+type renderedJob struct {
+	Source JobSource
+	Script string
+}
+
+func (o *Options) renderJobs(server string, configs []*JenkinsTemplateConfig) ([]renderedJob, error) {
+	funcMap := sprig.TxtFuncMap()
+	var answer []renderedJob
+	for _, jcfg := range configs {
+		tmplpath := jcfg.TemplateFile
+		source := JobSource{
+			Name:       jcfg.Key,
+			Group:      jcfg.Group,
+			Repository: jcfg.Repository,
+			Template:   o.relativePath(tmplpath),
+		}
+		output, err := templater.Evaluate(funcMap, jcfg.TemplateData, jcfg.TemplateText, tmplpath, "Jenkins Server "+server)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate template for %s", source.String())
+		}
+		err = ValidateGroovy(output)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid job DSL for %s", source.String())
+		}
+		log.Logger().Debugf("generated %s", source.String())
+		answer = append(answer, renderedJob{
+			Source: source,
+			Script: strings.TrimSpace(output) + "\n",
+		})
+	}
+	return answer, nil
+}
+
+// writeJobValuesYAML generates the JCasC jobs configuration as a YAML node tree rather than indenting text
+// along with the helm values template which includes it
+func (o *Options) writeJobValuesYAML(dir, server string, configs []*JenkinsTemplateConfig) error {
+	jobs, err := o.renderJobs(server, configs)
+	if err != nil {
+		return err
+	}
+
+	jobsNode := yaml.NewListRNode()
+	for i := range jobs {
+		job := &jobs[i]
+		scriptNode := yaml.NewScalarRNode(job.Script)
+		scriptNode.YNode().Style = yaml.LiteralStyle
+		entry := yaml.NewMapRNode(nil)
+		err = entry.PipeE(yaml.SetField("script", scriptNode))
+		if err != nil {
+			return errors.Wrapf(err, "failed to set script for %s", job.Source.String())
+		}
+		entry.YNode().Content[0].HeadComment = "from " + job.Source.String()
+		err = jobsNode.PipeE(yaml.Append(entry.YNode()))
+		if err != nil {
+			return errors.Wrapf(err, "failed to append %s", job.Source.String())
+		}
+	}
+	jcasc := yaml.NewMapRNode(nil)
+	err = jcasc.PipeE(yaml.SetField("jobs", jobsNode))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the JCasC jobs")
+	}
+	jcascText, err := jcasc.String()
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the JCasC jobs")
+	}
+
+	path := filepath.Join(dir, JCasCJobsFileName)
+	log.Logger().Infof("creating Jenkins JCasC jobs file %s", path)
+	err = os.WriteFile(path, []byte(generatedHeader+jcascText), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	err = writeJobValuesTemplate(dir, jcascJobsValuesTemplate)
+	if err != nil {
+		return err
+	}
+	err = verifyJobValues(dir, jobs)
+	if err != nil {
+		return errors.Wrapf(err, "invalid job values for jenkins server %s", server)
+	}
+	return o.writeJobsManifest(dir, server, jobs)
+}
+
+// verifyJobValues renders the helm values template in the same way as helmfile and verifies that the resulting
+// JCasC configuration contains each job script unchanged
+func verifyJobValues(dir string, jobs []renderedJob) error {
+	path := filepath.Join(dir, JobValuesTemplateFileName)
+	funcMap := sprig.TxtFuncMap()
+	funcMap["readFile"] = func(name string) (string, error) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read file %s", name)
+		}
+		return string(data), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file %s", path)
+	}
+	t, err := template.New(JobValuesTemplateFileName).Funcs(funcMap).Parse(string(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse template %s", path)
+	}
+	buf := strings.Builder{}
+	err = t.Execute(&buf, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to render template %s", path)
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal([]byte(buf.String()), &values)
+	if err != nil {
+		return errors.Wrapf(err, "the rendered values of %s are not valid YAML", path)
+	}
+	setup := maps.GetMapValueAsStringViaPath(values, "controller.JCasC.configScripts.jxsetup")
+	jcasc := map[string][]map[string]string{}
+	err = yaml.Unmarshal([]byte(setup), &jcasc)
+	if err != nil {
+		return errors.Wrapf(err, "the rendered JCasC configuration of %s is not valid YAML", path)
+	}
+	if len(jcasc["jobs"]) != len(jobs) {
+		return errors.Errorf("the rendered JCasC configuration of %s has %d jobs but expected %d", path, len(jcasc["jobs"]), len(jobs))
+	}
+	for i := range jobs {
+		if jcasc["jobs"][i]["script"] != jobs[i].Script {
+			return errors.Errorf("the rendered JCasC configuration of %s does not contain the script of %s", path, jobs[i].Source.String())
+		}
+	}
+	return nil
+}
+
+// writeSeedScript generates a job DSL seed script containing all the jobs for the server
+// along with the helm values template which runs it when jenkins starts
+func (o *Options) writeSeedScript(dir, server string, configs []*JenkinsTemplateConfig) error {
+	jobs, err := o.renderJobs(server, configs)
+	if err != nil {
+		return err
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString(strings.ReplaceAll(generatedHeader, "#", "//"))
+	for i := range jobs {
+		job := &jobs[i]
+		buf.WriteString("\n// from " + job.Source.String() + "\n")
+		buf.WriteString(job.Script)
+	}
+
+	path := filepath.Join(dir, SeedFileName)
+	log.Logger().Infof("creating Jenkins job DSL seed file %s", path)
+	err = os.WriteFile(path, []byte(buf.String()), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	err = writeJobValuesTemplate(dir, seedValuesTemplate)
+	if err != nil {
+		return err
+	}
+	return o.writeJobsManifest(dir, server, jobs)
+}
+
+// writeJobValuesTemplate saves the helm values template referenced by the jenkins helmfile
+func writeJobValuesTemplate(dir, text string) error {
+	path := filepath.Join(dir, JobValuesTemplateFileName)
+	log.Logger().Infof("creating Jenkins values template file %s", path)
+	err := os.WriteFile(path, []byte(text), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// writeJobsManifest saves the manifest of generated jobs reporting any jobs removed since the last generation
+func (o *Options) writeJobsManifest(dir, server string, jobs []renderedJob) error {
+	path := filepath.Join(dir, JobsManifestFileName)
+	previous, err := loadJobsManifest(path)
+	if err != nil {
+		return err
+	}
+
+	manifest := &JobsManifest{}
+	names := map[string]bool{}
+	for i := range jobs {
+		manifest.Jobs = append(manifest.Jobs, jobs[i].Source)
+		names[jobs[i].Source.Name] = true
+	}
+	for i := range previous.Jobs {
+		old := &previous.Jobs[i]
+		if !names[old.Name] {
+			log.Logger().Infof("pruned %s on jenkins server %s as it is no longer in the source configuration", info(old.String()), info(server))
+		}
+	}
+
+	err = yamls.SaveFile(manifest, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// pruneRemovedServers removes the generated job files for any jenkins servers which no longer have any jobs
+func (o *Options) pruneRemovedServers() error {
+	if o.JenkinsServerTemplates == nil {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(o.OutDir, "*", JobsManifestFileName))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find jobs manifests in %s", o.OutDir)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		server := filepath.Base(dir)
+		if len(o.JenkinsServerTemplates[server]) > 0 {
+			continue
+		}
+		previous, err := loadJobsManifest(path)
+		if err != nil {
+			return err
+		}
+		for i := range previous.Jobs {
+			log.Logger().Infof("pruned %s on jenkins server %s as it is no longer in the source configuration", info(previous.Jobs[i].String()), info(server))
+		}
+		err = removeJobFiles(dir, JobValuesFileName, JobValuesTemplateFileName, JCasCJobsFileName, SeedFileName, JobsManifestFileName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleJobFiles removes the files generated by the other formats along with their values in the helmfile
+func (o *Options) removeStaleJobFiles(dir string) error {
+	var names []string
+	switch o.Format {
+	case FormatYAML:
+		names = []string{JobValuesFileName, SeedFileName}
+	case FormatSeed:
+		names = []string{JobValuesFileName, JCasCJobsFileName}
+	default:
+		names = []string{JobValuesTemplateFileName, JCasCJobsFileName, SeedFileName, JobsManifestFileName}
+	}
+	return removeJobFiles(dir, names...)
+}
+
+// removeJobFiles removes the generated files from the jenkins server dir and any references to them from the
+// values of the releases in its helmfile so that helmfile does not fail on missing values files
+func removeJobFiles(dir string, names ...string) error {
+	for _, name := range names {
+		f := filepath.Join(dir, name)
+		err := os.RemoveAll(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", f)
+		}
+	}
+
+	path := filepath.Join(dir, "helmfile.yaml")
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return nil
+	}
+	helmStates, err := helmfiles.LoadHelmfile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load helmfile %s", path)
+	}
+	modified := false
+	for _, helmState := range helmStates {
+		for i := range helmState.Releases {
+			release := &helmState.Releases[i]
+			var values []interface{}
+			for _, v := range release.Values {
+				if s, ok := v.(string); ok && stringhelpers.StringArrayIndex(names, s) >= 0 {
+					modified = true
+					continue
+				}
+				values = append(values, v)
+			}
+			release.Values = values
+		}
+	}
+	if !modified {
+		return nil
+	}
+	err = helmfiles.SaveHelmfile(path, helmStates)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save helmfile %s", path)
+	}
+	return nil
+}
+
+// ValidateGroovy performs a basic syntax check of the job DSL script verifying that strings
+// and comments are terminated and that brackets are balanced
+func ValidateGroovy(text string) error {
+	var stack []rune
+	line := 1
+	closers := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\n':
+			line++
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			line++
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			start := line
+			i += 2
+			for ; i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/'); i++ {
+				if runes[i] == '\n' {
+					line++
+				}
+			}
+			if i >= len(runes) {
+				return errors.Errorf("unterminated comment starting at line %d", start)
+			}
+			i++
+		case c == '\'' || c == '"':
+			start := line
+			delim := string(c)
+			if i+2 < len(runes) && runes[i+1] == c && runes[i+2] == c {
+				delim = strings.Repeat(delim, 3)
+			}
+			i += len(delim)
+			terminated := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '\\' {
+					i++
+					continue
+				}
+				if runes[i] == '\n' {
+					if len(delim) == 1 {
+						break
+					}
+					line++
+				}
+				if strings.HasPrefix(string(runes[i:]), delim) {
+					i += len(delim) - 1
+					terminated = true
+					break
+				}
+			}
+			if !terminated {
+				return errors.Errorf("unterminated string starting at line %d", start)
+			}
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, c)
+		case closers[c] != 0:
+			if len(stack) == 0 || stack[len(stack)-1] != closers[c] {
+				return errors.Errorf("unexpected '%c' at line %d", c, line)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		return errors.Errorf("unclosed '%c' at end of script", stack[len(stack)-1])
+	}
+	return nil
+}
+
+func loadJobsManifest(path string) (*JobsManifest, error) {
+	manifest := &JobsManifest{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return manifest, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return manifest, nil
+	}
+	err = yamls.LoadFile(path, manifest)
+	if err != nil {
+		return manifest, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	return manifest, nil
+}
+
+func (o *Options) relativePath(path string) string {
+	rel, err := filepath.Rel(o.Dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,413 @@
+package update
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge3"
+)
+
+// FileStatus the result of comparing a file in a kpt package with its upstream
+type FileStatus string
+
+const (
+	// FileStatusUpdated the file would be cleanly updated from the upstream
+	FileStatusUpdated FileStatus = "updated"
+
+	// FileStatusConflict the file has been changed locally and upstream in ways which conflict
+	FileStatusConflict FileStatus = "conflict"
+
+	// FileStatusLocalOnly the file only has local edits which would be preserved
+	FileStatusLocalOnly FileStatus = "local-only"
+
+	// FileStatusOverwritten the file has local edits which would be discarded by the strategy
+	FileStatusOverwritten FileStatus = "overwritten"
+)
+
+// mergeKeys the keys used to identify elements of sequences when comparing resources
+var mergeKeys = []string{"name", "containerPort", "mountPath", "devicePath", "ip", "topologyKey"}
+
+// PackageReport the dry run report for a kpt package
+type PackageReport struct {
+	// Dir the relative directory of the kpt package
+	Dir string
+
+	// Strategy the kpt update strategy
+	Strategy string
+
+	// Repository the upstream git repository
+	Repository string
+
+	// OldRef the git ref the package was last updated from
+	OldRef string
+
+	// NewRef the git ref the package would be updated to
+	NewRef string
+
+	// Files the status of each file which would be affected
+	Files map[string]FileStatus
+}
+
+// FilesWithStatus returns the sorted file names with the given status
+func (r *PackageReport) FilesWithStatus(status FileStatus) []string {
+	var answer []string
+	for name, s := range r.Files {
+		if s == status {
+			answer = append(answer, name)
+		}
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+// UpstreamFetcher fetches the directory of the upstream git repository at the given ref into the output directory
+type UpstreamFetcher func(repository, ref, directory, outDir string) error
+
+// dryRunPackage compares the local package with the old and new upstream versions
+// returning nil if the package has no upstream git repository to compare with
+func (o *Options) dryRunPackage(dir, rel, kptfile, strategy string) (*PackageReport, error) {
+	node, err := kyaml.ReadFile(kptfile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s", kptfile)
+	}
+	upstream := "upstream"
+	if kyamls.GetAPIVersion(node, kptfile) == "kpt.dev/v1" {
+		upstream = "upstreamLock"
+	}
+	repository := lookupString(node, upstream, "git", "repo")
+	directory := lookupString(node, upstream, "git", "directory")
+	oldRef := lookupString(node, upstream, "git", "commit")
+	if oldRef == "" {
+		oldRef = lookupString(node, upstream, "git", "ref")
+	}
+	if repository == "" || oldRef == "" {
+		log.Logger().Warnf("ignoring kpt package %s as the Kptfile does not have an upstream git repository and ref", termcolor.ColorInfo(rel))
+		return nil, nil
+	}
+	// kpt pkg update re-pulls the locked ref unless a version is specified
+	newRef := o.Version
+	if newRef == "" {
+		newRef = lookupString(node, upstream, "git", "ref")
+	}
+	if newRef == "" {
+		log.Logger().Warnf("ignoring kpt package %s as there is no new ref to compare with", termcolor.ColorInfo(rel))
+		return nil, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "jx-kpt-dry-run-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldDir := filepath.Join(tmpDir, "old")
+	newDir := filepath.Join(tmpDir, "new")
+	err = o.FetchUpstream(repository, oldRef, directory, oldDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s at %s", repository, oldRef)
+	}
+	err = o.FetchUpstream(repository, newRef, directory, newDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s at %s", repository, newRef)
+	}
+
+	report := &PackageReport{
+		Dir:        rel,
+		Strategy:   strategy,
+		Repository: repository,
+		OldRef:     oldRef,
+		NewRef:     newRef,
+		Files:      map[string]FileStatus{},
+	}
+	localDir := filepath.Join(dir, rel)
+	names := map[string]bool{}
+	for _, d := range []string{localDir, oldDir, newDir} {
+		err = addRelativeFileNames(d, names)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hasLocalEdits := false
+	for name := range names {
+		if name == "Kptfile" {
+			continue
+		}
+		local, err := readOptionalFile(filepath.Join(localDir, name))
+		if err != nil {
+			return nil, err
+		}
+		old, err := readOptionalFile(filepath.Join(oldDir, name))
+		if err != nil {
+			return nil, err
+		}
+		updated, err := readOptionalFile(filepath.Join(newDir, name))
+		if err != nil {
+			return nil, err
+		}
+		status := compareFile(name, local, old, updated)
+		if status == "" {
+			continue
+		}
+		if status == FileStatusLocalOnly {
+			hasLocalEdits = true
+			if strategy == "force-delete-replace" {
+				status = FileStatusOverwritten
+			}
+		}
+		if status == FileStatusConflict && strategy == "force-delete-replace" {
+			status = FileStatusOverwritten
+		}
+		report.Files[name] = status
+	}
+
+	// fast-forward fails if there are any local changes so any locally edited files would conflict
+	if strategy == "fast-forward" && hasLocalEdits && len(report.FilesWithStatus(FileStatusUpdated))+len(report.FilesWithStatus(FileStatusConflict)) > 0 {
+		for name, status := range report.Files {
+			if status == FileStatusLocalOnly {
+				report.Files[name] = FileStatusConflict
+			}
+		}
+	}
+	return report, nil
+}
+
+// compareFile performs a three way comparison of the local, old upstream and new upstream file
+// returning a blank status if there is nothing to report
+func compareFile(name string, local, old, updated []byte) FileStatus {
+	if bytes.Equal(old, updated) {
+		if bytes.Equal(local, old) {
+			return ""
+		}
+		return FileStatusLocalOnly
+	}
+	if bytes.Equal(local, old) || bytes.Equal(local, updated) {
+		return FileStatusUpdated
+	}
+	ext := filepath.Ext(name)
+	if (ext == ".yaml" || ext == ".yml") && local != nil && old != nil && updated != nil {
+		if !yamlConflicts(local, old, updated) {
+			return FileStatusUpdated
+		}
+	}
+	return FileStatusConflict
+}
+
+// yamlConflicts returns true if the local and upstream changes to the resources cannot be merged
+func yamlConflicts(local, old, updated []byte) bool {
+	localValues, err := flattenResources(local)
+	if err != nil {
+		return true
+	}
+	oldValues, err := flattenResources(old)
+	if err != nil {
+		return true
+	}
+	newValues, err := flattenResources(updated)
+	if err != nil {
+		return true
+	}
+	keys := map[string]bool{}
+	for _, m := range []map[string]string{localValues, oldValues, newValues} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for k := range keys {
+		l, o, n := localValues[k], oldValues[k], newValues[k]
+		if l != o && n != o && l != n {
+			return true
+		}
+	}
+
+	// lets check kyaml can merge the resources too
+	_, err = merge3.MergeStrings(string(local), string(old), string(updated), true)
+	return err != nil
+}
+
+// flattenResources converts the resources into a map of leaf paths to values
+func flattenResources(data []byte) (map[string]string, error) {
+	nodes, err := kio.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	answer := map[string]string{}
+	for i, node := range nodes {
+		prefix := strconv.Itoa(i)
+		meta, err := node.GetMeta()
+		if err == nil && meta.Kind != "" {
+			prefix = meta.Kind + "/" + meta.Name
+		}
+		flattenNode(node.YNode(), prefix, answer)
+	}
+	return answer, nil
+}
+
+func flattenNode(node *kyaml.Node, path string, values map[string]string) {
+	switch node.Kind {
+	case kyaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			flattenNode(node.Content[i+1], path+"."+node.Content[i].Value, values)
+		}
+	case kyaml.SequenceNode:
+		for i, child := range node.Content {
+			flattenNode(child, path+"["+sequenceElementKey(child, i)+"]", values)
+		}
+	case kyaml.DocumentNode:
+		for _, child := range node.Content {
+			flattenNode(child, path, values)
+		}
+	default:
+		values[path] = node.Value
+	}
+}
+
+func sequenceElementKey(node *kyaml.Node, idx int) string {
+	if node.Kind == kyaml.MappingNode {
+		for _, key := range mergeKeys {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					return key + "=" + node.Content[i+1].Value
+				}
+			}
+		}
+	}
+	return strconv.Itoa(idx)
+}
+
+// fetchUpstreamWithGit shallow fetches each ref of the repository into a git repository which is
+// cached for the duration of the command
+func (o *Options) fetchUpstreamWithGit(repository, ref, directory, outDir string) error {
+	cloneDir, err := o.upstreamCloneDir(repository)
+	if err != nil {
+		return err
+	}
+	_, err = o.GitClient.Command(cloneDir, "fetch", "--depth", "1", "origin", ref)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", ref)
+	}
+	_, err = o.GitClient.Command(cloneDir, "checkout", "--force", "FETCH_HEAD")
+	if err != nil {
+		return errors.Wrapf(err, "failed to checkout %s", ref)
+	}
+	srcDir := filepath.Join(cloneDir, directory)
+	exists, err := files.DirExists(srcDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", srcDir)
+	}
+	if !exists {
+		return os.MkdirAll(outDir, files.DefaultDirWritePermissions)
+	}
+	return files.CopyDirOverwrite(srcDir, outDir)
+}
+
+// upstreamCloneDir lazily creates an empty git repository with the upstream repository as its origin
+func (o *Options) upstreamCloneDir(repository string) (string, error) {
+	if o.clones == nil {
+		o.clones = map[string]string{}
+	}
+	cloneDir := o.clones[repository]
+	if cloneDir != "" {
+		return cloneDir, nil
+	}
+	cloneDir, err := os.MkdirTemp("", "jx-kpt-upstream-")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp dir")
+	}
+	o.clones[repository] = cloneDir
+	_, err = o.GitClient.Command(cloneDir, "init")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to initialise git repository in %s", cloneDir)
+	}
+	_, err = o.GitClient.Command(cloneDir, "remote", "add", "origin", repository)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to add remote %s", repository)
+	}
+	return cloneDir, nil
+}
+
+func (o *Options) removeClones() {
+	for _, dir := range o.clones {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			log.Logger().Warnf("failed to remove %s: %s", dir, err.Error())
+		}
+	}
+	o.clones = nil
+}
+
+// LogReport logs the dry run report for a package
+func (r *PackageReport) LogReport() {
+	log.Logger().Infof("kpt package %s would be updated from %s to %s with strategy %s", termcolor.ColorInfo(r.Dir), r.OldRef, termcolor.ColorInfo(r.NewRef), r.Strategy)
+	if len(r.Files) == 0 {
+		log.Logger().Infof("  no changes")
+		return
+	}
+	for _, status := range []FileStatus{FileStatusUpdated, FileStatusLocalOnly, FileStatusOverwritten, FileStatusConflict} {
+		for _, name := range r.FilesWithStatus(status) {
+			text := fmt.Sprintf("  %-12s %s", string(status), name)
+			switch status {
+			case FileStatusConflict:
+				log.Logger().Info(termcolor.ColorError(text))
+			case FileStatusOverwritten:
+				log.Logger().Info(termcolor.ColorWarning(text))
+			default:
+				log.Logger().Info(text)
+			}
+		}
+	}
+}
+
+func lookupString(node *kyaml.RNode, path ...string) string {
+	n, err := node.Pipe(kyaml.Lookup(path...))
+	if err != nil || n == nil {
+		return ""
+	}
+	return strings.TrimSpace(n.YNode().Value)
+}
+
+func addRelativeFileNames(dir string, names map[string]bool) error {
+	exists, err := files.DirExists(dir)
+	if err != nil || !exists {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names[rel] = true
+		return nil
+	})
+}
+
+func readOptionalFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	return data, nil
+}
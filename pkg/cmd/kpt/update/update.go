@@ -45,6 +45,9 @@ var (
 		# recurses the current dir looking for directories with Kptfile inside 
 		# and upgrades the kpt package found there to the latest version
 		%s kpt --dir .
+
+		# previews which files would be updated, conflict or have local only edits without changing anything
+		%[1]s kpt update --dry-run
 	`)
 
 	info = termcolor.ColorInfo
@@ -63,9 +66,13 @@ type Options struct {
 	KptBinary              string
 	Strategy               string
 	IgnoreYamlContentError bool
+	DryRun                 bool
 	ContainerTool          string
 	GitClient              gitclient.Interface
 	CommandRunner          cmdrunner.CommandRunner
+	FetchUpstream          UpstreamFetcher
+	Reports                []*PackageReport
+	clones                 map[string]string
 }
 
 // NewCmdKptUpdate creates a command object for the command
@@ -100,6 +107,7 @@ func (o *Options) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.ContainerTool, "container-tool", "c", defaultContainer, "the underlying container tool for kpt to use")
 
 	cmd.Flags().BoolVarP(&o.IgnoreYamlContentError, "ignore-yaml-error", "", false, "ignore kpt errors of the form: yaml: did not find expected node content")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "compares each package with its current upstream version and the --version or the ref in the Kptfile and reports which files would be updated, conflict or have local only edits without modifying anything")
 }
 
 // Run implements the command
@@ -124,6 +132,10 @@ func (o *Options) Run() error {
 		return errors.Wrap(err, "failed to load kpt merge override strategies")
 	}
 
+	if o.DryRun {
+		return o.dryRun(dir, strategies)
+	}
+
 	bin := o.KptBinary
 	if bin == "" {
 		bin, err = plugins.GetKptBinary(plugins.KptVersion)
@@ -249,6 +261,59 @@ func (o *Options) Run() error {
 	return nil
 }
 
+// dryRun reports the changes an update would make to each kpt package without modifying them
+func (o *Options) dryRun(dir string, strategies map[string]string) error {
+	if o.FetchUpstream == nil {
+		o.FetchUpstream = o.fetchUpstreamWithGit
+	}
+	defer o.removeClones()
+
+	o.Reports = nil
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != "Kptfile" {
+			return nil
+		}
+		flag, err := o.Matches(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if path matches %s", path)
+		}
+		if !flag {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return errors.Wrapf(err, "failed to calculate the relative directory of %s", path)
+		}
+		strategy := o.Strategy
+		if strategies[rel] != "" {
+			strategy = strategies[rel]
+		}
+		report, err := o.dryRunPackage(dir, rel, path, strategy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compare kpt package %s", rel)
+		}
+		if report == nil {
+			return nil
+		}
+		report.LogReport()
+		o.Reports = append(o.Reports, report)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to compare kpt packages in dir %s", dir)
+	}
+
+	conflicts := 0
+	for _, r := range o.Reports {
+		conflicts += len(r.FilesWithStatus(FileStatusConflict))
+	}
+	log.Logger().Infof("dry run compared %d kpt packages and found %d conflicting files", len(o.Reports), conflicts)
+	return nil
+}
+
 // handleKptfileConflictsAndContinue if there's only a single conflict for the Kptfile lets
 // handle it and continue
 func (o *Options) handleKptfileConflictsAndContinue(dir string, lines []string) (bool, error) {
@@ -10,12 +10,14 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/kpt/update"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestUpdateKptNoFilter(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 	absSourceDir, err := filepath.Abs(sourceDir)
 	require.NoError(t, err, "failed to find abs dir of %s", sourceDir)
 	require.DirExists(t, absSourceDir)
@@ -46,7 +48,7 @@ func TestUpdateKptNoFilter(t *testing.T) {
 }
 
 func TestUpdateKptFilterRepositoryURL(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 	absSourceDir, err := filepath.Abs(sourceDir)
 	require.NoError(t, err, "failed to find abs dir of %s", sourceDir)
 	require.DirExists(t, absSourceDir)
@@ -75,7 +77,7 @@ func TestUpdateKptFilterRepositoryURL(t *testing.T) {
 }
 
 func TestUpdateKptFilterRepositoryName(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 	absSourceDir, err := filepath.Abs(sourceDir)
 	require.NoError(t, err, "failed to find abs dir of %s", sourceDir)
 	require.DirExists(t, absSourceDir)
@@ -104,7 +106,7 @@ func TestUpdateKptFilterRepositoryName(t *testing.T) {
 }
 
 func TestUpdateKptFilterNotMatching(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 	absSourceDir, err := filepath.Abs(sourceDir)
 	require.NoError(t, err, "failed to find abs dir of %s", sourceDir)
 	require.DirExists(t, absSourceDir)
@@ -138,7 +140,7 @@ func failOnFnEval(c *cmdrunner.Command) (string, error) {
 }
 
 func TestUpdateKptUpgradesLegacyKptfileWithPinnedImage(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 
 	_, uk := update.NewCmdKptUpdate()
 	uk.KptBinary = "kpt"
@@ -167,7 +169,7 @@ func TestUpdateKptUpgradesLegacyKptfileWithPinnedImage(t *testing.T) {
 }
 
 func TestUpdateKptIgnoreYamlErrorSkipsPackage(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 
 	_, uk := update.NewCmdKptUpdate()
 	uk.KptBinary = "kpt"
@@ -183,7 +185,7 @@ func TestUpdateKptIgnoreYamlErrorSkipsPackage(t *testing.T) {
 }
 
 func TestUpdateKptYamlErrorWithoutFlagFails(t *testing.T) {
-	sourceDir := "testdata"
+	sourceDir := filepath.Join("testdata", "update")
 
 	_, uk := update.NewCmdKptUpdate()
 	uk.KptBinary = "kpt"
@@ -223,3 +225,98 @@ func TestOptions_loadOverrideStrategies(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateKptDryRun(t *testing.T) {
+	sourceDir := filepath.Join("testdata", "dryrun", "local")
+	upstreamDir := filepath.Join("testdata", "dryrun", "upstream")
+
+	testCases := []struct {
+		strategy string
+		expected map[string]update.FileStatus
+	}{
+		{
+			strategy: "resource-merge",
+			expected: map[string]update.FileStatus{
+				"configmap.yaml":      update.FileStatusLocalOnly,
+				"deployment.yaml":     update.FileStatusUpdated,
+				"service.yaml":        update.FileStatusConflict,
+				"serviceaccount.yaml": update.FileStatusUpdated,
+			},
+		},
+		{
+			strategy: "force-delete-replace",
+			expected: map[string]update.FileStatus{
+				"configmap.yaml":      update.FileStatusOverwritten,
+				"deployment.yaml":     update.FileStatusUpdated,
+				"service.yaml":        update.FileStatusOverwritten,
+				"serviceaccount.yaml": update.FileStatusUpdated,
+			},
+		},
+		{
+			strategy: "fast-forward",
+			expected: map[string]update.FileStatus{
+				"configmap.yaml":      update.FileStatusConflict,
+				"deployment.yaml":     update.FileStatusUpdated,
+				"service.yaml":        update.FileStatusConflict,
+				"serviceaccount.yaml": update.FileStatusUpdated,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		_, uk := update.NewCmdKptUpdate()
+		runner := &fakerunner.FakeRunner{}
+		uk.CommandRunner = runner.Run
+		uk.Dir = sourceDir
+		uk.DryRun = true
+		uk.Version = "v1.1.0"
+		uk.Strategy = tc.strategy
+		uk.FetchUpstream = func(repository, ref, directory, outDir string) error {
+			assert.Equal(t, "https://github.com/jenkins-x/jxr-kube-resources", repository, "repository")
+			assert.Equal(t, "/jenkins-x/app1", directory, "directory")
+			return files.CopyDirOverwrite(filepath.Join(upstreamDir, ref), outDir)
+		}
+
+		err := uk.Run()
+		require.NoError(t, err, "failed to run update kpt dry run for strategy %s", tc.strategy)
+		assert.Empty(t, runner.OrderedCommands, "should not run any commands for strategy %s", tc.strategy)
+
+		require.Len(t, uk.Reports, 1, "reports for strategy %s", tc.strategy)
+		report := uk.Reports[0]
+		assert.Equal(t, filepath.Join("config-root", "namespaces", "app1"), report.Dir, "report.Dir")
+		assert.Equal(t, "v1.0.0", report.OldRef, "report.OldRef")
+		assert.Equal(t, "v1.1.0", report.NewRef, "report.NewRef")
+		assert.Equal(t, tc.expected, report.Files, "report.Files for strategy %s", tc.strategy)
+	}
+}
+
+func TestUpdateKptDryRunLockedRef(t *testing.T) {
+	sourceDir := filepath.Join("testdata", "dryrun", "local")
+	upstreamDir := filepath.Join("testdata", "dryrun", "upstream")
+
+	_, uk := update.NewCmdKptUpdate()
+	runner := &fakerunner.FakeRunner{}
+	uk.CommandRunner = runner.Run
+	uk.Dir = sourceDir
+	uk.DryRun = true
+	uk.Strategy = "resource-merge"
+	var refs []string
+	uk.FetchUpstream = func(repository, ref, directory, outDir string) error {
+		refs = append(refs, ref)
+		return files.CopyDirOverwrite(filepath.Join(upstreamDir, ref), outDir)
+	}
+
+	err := uk.Run()
+	require.NoError(t, err, "failed to run update kpt dry run")
+
+	// without a version kpt pkg update re-pulls the ref in the upstreamLock so the newer tag is not used
+	assert.Equal(t, []string{"v1.0.0", "v1.0.0"}, refs, "fetched refs")
+	require.Len(t, uk.Reports, 1, "reports")
+	report := uk.Reports[0]
+	assert.Equal(t, "v1.0.0", report.NewRef, "report.NewRef")
+	assert.Equal(t, map[string]update.FileStatus{
+		"configmap.yaml":  update.FileStatusLocalOnly,
+		"deployment.yaml": update.FileStatusLocalOnly,
+		"service.yaml":    update.FileStatusLocalOnly,
+	}, report.Files, "report.Files")
+}
@@ -0,0 +1,159 @@
+package kustomize
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// jsonPatchOperation a JSON6902 patch operation
+type jsonPatchOperation struct {
+	Op    string
+	Path  string
+	Value *yaml.Node
+}
+
+// createJSONPatch creates the JSON6902 patch operations to convert the source into the target resource
+// or returns nil if there are no differences
+func createJSONPatch(srcNode, targetNode *yaml.RNode) (*yaml.RNode, error) {
+	var ops []jsonPatchOperation
+	addJSONPatchOperations(srcNode.YNode(), targetNode.YNode(), "", "", &ops)
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	answer := yaml.NewListRNode()
+	for _, op := range ops {
+		n := yaml.NewMapRNode(nil)
+		err := n.PipeE(yaml.SetField("op", yaml.NewScalarRNode(op.Op)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to set op of %s", op.Path)
+		}
+		err = n.PipeE(yaml.SetField("path", yaml.NewScalarRNode(op.Path)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to set path of %s", op.Path)
+		}
+		if op.Value != nil {
+			err = n.PipeE(yaml.SetField("value", yaml.NewRNode(op.Value)))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to set value of %s", op.Path)
+			}
+		}
+		err = answer.PipeE(yaml.Append(n.YNode()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to append operation %s", op.Path)
+		}
+	}
+	return answer, nil
+}
+
+func addJSONPatchOperations(src, target *yaml.Node, pointer, jsonPath string, ops *[]jsonPatchOperation) {
+	if src.Kind != target.Kind {
+		*ops = append(*ops, jsonPatchOperation{Op: "replace", Path: pointer, Value: target})
+		return
+	}
+	switch src.Kind {
+	case yaml.ScalarNode:
+		if src.Value != target.Value {
+			*ops = append(*ops, jsonPatchOperation{Op: "replace", Path: pointer, Value: target})
+		}
+
+	case yaml.MappingNode:
+		for i := 0; i < len(src.Content)-1; i += 2 {
+			key := src.Content[i]
+			childPath := joinPath(jsonPath, key.Value)
+			if stringhelpers.StringArrayIndex(mandatoryFields, childPath) >= 0 {
+				continue
+			}
+			childPointer := pointer + "/" + escapeJSONPointer(key.Value)
+			j := findMapEntry(key, target.Content)
+			if j < 0 {
+				*ops = append(*ops, jsonPatchOperation{Op: "remove", Path: childPointer})
+				continue
+			}
+			addJSONPatchOperations(src.Content[i+1], target.Content[j+1], childPointer, childPath, ops)
+		}
+		for i := 0; i < len(target.Content)-1; i += 2 {
+			key := target.Content[i]
+			childPath := joinPath(jsonPath, key.Value)
+			if stringhelpers.StringArrayIndex(mandatoryFields, childPath) >= 0 {
+				continue
+			}
+			if findMapEntry(key, src.Content) < 0 {
+				*ops = append(*ops, jsonPatchOperation{Op: "add", Path: pointer + "/" + escapeJSONPointer(key.Value), Value: target.Content[i+1]})
+			}
+		}
+
+	case yaml.SequenceNode:
+		key := sequenceMergeKey(lastPathElement(jsonPath), src.Content, target.Content)
+		if key == "" {
+			if !nodesEqual(src, target) {
+				*ops = append(*ops, jsonPatchOperation{Op: "replace", Path: pointer, Value: target})
+			}
+			return
+		}
+
+		// lets modify elements first, then remove in reverse order so indices stay valid and then append
+		var removeIdx []int
+		for i, s := range src.Content {
+			value := mergeKeyValue(s, key)
+			t := findSequenceElement(target.Content, key, value)
+			if t == nil {
+				removeIdx = append(removeIdx, i)
+				continue
+			}
+			childPath := jsonPath + "[" + key + "=" + value + "]"
+			addJSONPatchOperations(s, t, pointer+"/"+strconv.Itoa(i), childPath, ops)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(removeIdx)))
+		for _, i := range removeIdx {
+			*ops = append(*ops, jsonPatchOperation{Op: "remove", Path: pointer + "/" + strconv.Itoa(i)})
+		}
+		for _, t := range target.Content {
+			if findSequenceElement(src.Content, key, mergeKeyValue(t, key)) == nil {
+				*ops = append(*ops, jsonPatchOperation{Op: "add", Path: pointer + "/-", Value: t})
+			}
+		}
+
+	default:
+		if !nodesEqual(src, target) {
+			*ops = append(*ops, jsonPatchOperation{Op: "replace", Path: pointer, Value: target})
+		}
+	}
+}
+
+// patchTarget returns the selector of the resource for a JSON6902 patch
+func patchTarget(node *yaml.RNode) (*types.Selector, error) {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the resource metadata")
+	}
+	group, version, found := strings.Cut(meta.APIVersion, "/")
+	if !found {
+		group, version = "", meta.APIVersion
+	}
+	return &types.Selector{
+		ResId: resid.ResId{
+			Gvk:       resid.Gvk{Group: group, Version: version, Kind: meta.Kind},
+			Name:      meta.Name,
+			Namespace: meta.Namespace,
+		},
+	}, nil
+}
+
+func joinPath(jsonPath, name string) string {
+	if jsonPath == "" {
+		return name
+	}
+	return jsonPath + "." + name
+}
+
+func escapeJSONPointer(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "~", "~0"), "/", "~1")
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/kustomizes"
@@ -34,6 +33,9 @@ If you are using kpt to consume templates and you make lots of modifications and
 	splitExample = templates.Examples(`
 		# reverse engineer kustomize overlays by comparing the source to the current target
 		%s kustomize --source src/base --target config-root --output src/overlays/default
+
+		# reverse engineer JSON 6902 patches rather than strategic merge patches
+		%[1]s kustomize --source src/base --target config-root --output src/overlays/default --json-patch
 	`)
 
 	// mandatoryFields fields we should not remove when creating a diff
@@ -45,6 +47,7 @@ type Options struct {
 	SourceDir         string
 	TargetDir         string
 	OutputDir         string
+	JSONPatch         bool
 	Kustomization     *types.Kustomization
 	BaseKustomization *types.Kustomization
 }
@@ -66,6 +69,7 @@ func NewCmdKustomize() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.SourceDir, "source", "s", ".", "the directory to recursively look for the source *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.TargetDir, "target", "t", "", "the directory to recursively look for the target *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.OutputDir, "output", "o", "", "the output directory to store the overlays")
+	cmd.Flags().BoolVarP(&o.JSONPatch, "json-patch", "", false, "generates JSON 6902 patches instead of strategic merge patches")
 	return cmd, o
 }
 
@@ -138,9 +142,22 @@ func (o *Options) Run() error {
 			return errors.Wrapf(err, "failed to load file %s", targetFile)
 		}
 
-		overlayNode, err := o.createOverlay(srcNode, targetNode, path)
-		if err != nil {
-			return errors.Wrapf(err, "failed to create a delta node for %s", path)
+		patch := types.Patch{Path: rel}
+		var overlayNode *yaml.RNode
+		if o.JSONPatch {
+			overlayNode, err = createJSONPatch(srcNode, targetNode)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create a JSON patch for %s", path)
+			}
+			patch.Target, err = patchTarget(targetNode)
+			if err != nil {
+				return errors.Wrapf(err, "failed to find the patch target of %s", targetFile)
+			}
+		} else {
+			overlayNode, err = o.createOverlay(srcNode, targetNode, path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create a delta node for %s", path)
+			}
 		}
 		o.BaseKustomization.Resources = append(o.BaseKustomization.Resources, rel)
 
@@ -161,7 +178,7 @@ func (o *Options) Run() error {
 			return errors.Wrapf(err, "failed to save overlay to %s", overlayFile)
 		}
 
-		o.Kustomization.Patches = append(o.Kustomization.Patches, types.Patch{Path: rel})
+		o.Kustomization.Patches = append(o.Kustomization.Patches, patch)
 		return nil
 	})
 	if err != nil {
@@ -295,46 +312,43 @@ func (o *Options) removeEqualLeaves(src, target *yaml.Node, jsonPath string) (*y
 		}
 
 	case yaml.SequenceNode:
-		// lets remove this item all the contents are the same
-		eq := true
-		for i, s := range srcContent {
-			if len(targetContent) <= i {
-				eq = false
-				break
-			}
-			t := targetContent[i]
-			if !scalarsEqual(s, t) {
-				childPath := strconv.Itoa(i)
-				if jsonPath != "" {
-					childPath = jsonPath + "." + childPath
-				}
-				newTValue, err := o.removeEqualLeaves(s, t, childPath)
-				if err != nil {
-					return nil, errors.Wrapf(err, "failed to process node %s", childPath)
-				}
-				if newTValue == nil {
-					// lets remove this index
-					replaceTargetIdx = append(replaceTargetIdx, i)
-				} else {
-					eq = false
-					break
-				}
+		key := sequenceMergeKey(lastPathElement(jsonPath), srcContent, targetContent)
+		if key == "" {
+			// sequences without a merge key are replaced in full by a strategic merge patch
+			if nodesEqual(src, target) {
+				return nil, nil
 			}
+			return target, nil
 		}
-		if eq {
-			if len(srcContent) == 0 && len(targetContent) == 0 {
-				if src.Value == target.Value {
-					return nil, nil
-				}
-			} else {
-				return nil, nil
+
+		// lets only include the elements which have changed, identified by their merge key
+		var patched []*yaml.Node
+		for _, t := range targetContent {
+			value := mergeKeyValue(t, key)
+			s := findSequenceElement(srcContent, key, value)
+			if s == nil {
+				patched = append(patched, t)
+				continue
+			}
+			if nodesEqual(s, t) {
+				continue
+			}
+			keyNode := mergeKeyNode(t, key)
+			childPath := jsonPath + "[" + key + "=" + value + "]"
+			newTValue, err := o.removeEqualLeaves(s, t, childPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to process node %s", childPath)
+			}
+			if newTValue != nil {
+				patched = append(patched, withMergeKey(newTValue, key, keyNode))
 			}
 		}
-		// lets iterate in reverse order to preserve the indexes
-		for i := len(replaceTargetIdx) - 1; i >= 0; i-- {
-			idx := replaceTargetIdx[i]
-			targetContent = append(targetContent[0:idx], targetContent[idx+1:]...)
+		for _, s := range srcContent {
+			if findSequenceElement(targetContent, key, mergeKeyValue(s, key)) == nil {
+				patched = append(patched, deleteDirective(key, mergeKeyNode(s, key)))
+			}
 		}
+		targetContent = patched
 	}
 
 	if len(targetContent) == 0 {
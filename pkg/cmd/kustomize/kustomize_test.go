@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/kustomize"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/kustomizes"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,3 +41,49 @@ func TestKustomize(t *testing.T) {
 	testhelpers.AssertTextFilesEqual(t, filepath.Join(actual, "ingress.yaml"), filepath.Join(expected, "ingress.yaml"), "kusomize")
 	testhelpers.AssertTextFilesEqual(t, filepath.Join(actual, "deployment.yaml"), filepath.Join(expected, "deployment.yaml"), "kusomize")
 }
+
+func TestKustomizeLists(t *testing.T) {
+	testCases := []struct {
+		jsonPatch bool
+		expected  string
+	}{
+		{
+			expected: filepath.Join("testdata", "lists", "expected"),
+		},
+		{
+			jsonPatch: true,
+			expected:  filepath.Join("testdata", "lists", "expected-json"),
+		},
+	}
+	for _, tc := range testCases {
+		tmpDir := t.TempDir()
+		srcDir := filepath.Join("testdata", "lists")
+		err := files.CopyDirOverwrite(srcDir, tmpDir)
+		require.NoError(t, err, "failed to copy from %s to %s", srcDir, tmpDir)
+
+		_, ko := kustomize.NewCmdKustomize()
+		ko.SourceDir = filepath.Join(tmpDir, "source")
+		ko.TargetDir = filepath.Join(tmpDir, "target")
+		ko.OutputDir = filepath.Join(tmpDir, "overlay")
+		ko.JSONPatch = tc.jsonPatch
+
+		err = ko.Run()
+		require.NoError(t, err, "failed to run")
+
+		testhelpers.AssertTextFilesEqual(t, filepath.Join(tc.expected, "myapp", "deployment.yaml"), filepath.Join(ko.OutputDir, "myapp", "deployment.yaml"), "kustomize")
+
+		kustomization, err := kustomizes.LoadKustomization(ko.OutputDir)
+		require.NoError(t, err, "failed to load kustomization in %s", ko.OutputDir)
+		require.Len(t, kustomization.Patches, 1, "patches")
+		patch := kustomization.Patches[0]
+		assert.Equal(t, filepath.Join("myapp", "deployment.yaml"), patch.Path, "patch.Path")
+		if tc.jsonPatch {
+			require.NotNil(t, patch.Target, "patch.Target")
+			assert.Equal(t, "Deployment", patch.Target.Kind, "patch.Target.Kind")
+			assert.Equal(t, "myapp", patch.Target.Name, "patch.Target.Name")
+			assert.Equal(t, "myapps", patch.Target.Namespace, "patch.Target.Namespace")
+		} else {
+			assert.Nil(t, patch.Target, "patch.Target")
+		}
+	}
+}
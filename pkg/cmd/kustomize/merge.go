@@ -0,0 +1,151 @@
+package kustomize
+
+import (
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var (
+	// defaultMergeKeys the strategic merge patch keys used for sequences of objects
+	defaultMergeKeys = []string{"name"}
+
+	// fieldMergeKeys the strategic merge patch keys for sequences which are not merged by name
+	fieldMergeKeys = map[string][]string{
+		"ports":                     {"containerPort", "port"},
+		"volumeMounts":              {"mountPath"},
+		"volumeDevices":             {"devicePath"},
+		"hostAliases":               {"ip"},
+		"topologySpreadConstraints": {"topologyKey"},
+	}
+)
+
+// sequenceMergeKey returns the strategic merge key for the sequence field if every element of
+// both sequences is an object with the key or returns blank
+func sequenceMergeKey(field string, sequences ...[]*yaml.Node) string {
+	keys := fieldMergeKeys[field]
+	if len(keys) == 0 {
+		keys = defaultMergeKeys
+	}
+	for _, key := range keys {
+		found := true
+		empty := true
+		for _, content := range sequences {
+			for _, n := range content {
+				empty = false
+				if mergeKeyValue(n, key) == "" {
+					found = false
+				}
+			}
+		}
+		if found && !empty {
+			return key
+		}
+	}
+	return ""
+}
+
+// mergeKeyValue returns the scalar value of the given key if the node is a mapping
+func mergeKeyValue(node *yaml.Node, key string) string {
+	n := mergeKeyNode(node, key)
+	if n == nil {
+		return ""
+	}
+	return n.Value
+}
+
+// mergeKeyNode returns the scalar node of the given key if the node is a mapping
+func mergeKeyNode(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func findSequenceElement(content []*yaml.Node, key, value string) *yaml.Node {
+	for _, n := range content {
+		if mergeKeyValue(n, key) == value {
+			return n
+		}
+	}
+	return nil
+}
+
+// withMergeKey ensures the element of the patch contains its merge key so it can be matched
+func withMergeKey(node *yaml.Node, key string, value *yaml.Node) *yaml.Node {
+	if mergeKeyNode(node, key) != nil {
+		return node
+	}
+	content := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: key, Tag: yaml.NodeTagString},
+		value,
+	}
+	node.Content = append(content, node.Content...)
+	return node
+}
+
+// deleteDirective returns the strategic merge patch element to remove the element with the given key
+func deleteDirective(key string, value *yaml.Node) *yaml.Node {
+	return &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: key, Tag: yaml.NodeTagString},
+			value,
+			{Kind: yaml.ScalarNode, Value: "$patch", Tag: yaml.NodeTagString},
+			{Kind: yaml.ScalarNode, Value: "delete", Tag: yaml.NodeTagString},
+		},
+	}
+}
+
+// nodesEqual returns true if the nodes have the same values ignoring the order of mapping keys
+func nodesEqual(n1, n2 *yaml.Node) bool {
+	if n1.Kind != n2.Kind {
+		return false
+	}
+	switch n1.Kind {
+	case yaml.ScalarNode:
+		return n1.Value == n2.Value
+	case yaml.MappingNode:
+		if len(n1.Content) != len(n2.Content) {
+			return false
+		}
+		for i := 0; i < len(n1.Content)-1; i += 2 {
+			j := findMapEntry(n1.Content[i], n2.Content)
+			if j < 0 || !nodesEqual(n1.Content[i+1], n2.Content[j+1]) {
+				return false
+			}
+		}
+		return true
+	default:
+		if len(n1.Content) != len(n2.Content) {
+			return false
+		}
+		for i := range n1.Content {
+			if !nodesEqual(n1.Content[i], n2.Content[i]) {
+				return false
+			}
+		}
+		return n1.Value == n2.Value
+	}
+}
+
+// lastPathElement returns the last field name of the path expression ignoring any dots inside sequence element selectors
+func lastPathElement(jsonPath string) string {
+	depth := 0
+	for i := len(jsonPath) - 1; i >= 0; i-- {
+		switch jsonPath[i] {
+		case ']':
+			depth++
+		case '[':
+			depth--
+		case '.':
+			if depth == 0 {
+				return jsonPath[i+1:]
+			}
+		}
+	}
+	return jsonPath
+}
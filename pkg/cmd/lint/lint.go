@@ -3,9 +3,13 @@ package lint
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/helmfile/helmfile/pkg/state"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/namespacepolicies"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/ownership"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
@@ -62,10 +66,28 @@ func (o *Options) Validate() error {
 				return o.LintResource(path, test, &v1alpha1.SourceConfig{})
 			},
 		},
+		linter.Linter{
+			Path: filepath.Join(".jx", "gitops", v1alpha1.ResourceSyncFileName),
+			Linter: func(path string, test *linter.Test) error {
+				return o.LintResource(path, test, &v1alpha1.ResourceSync{})
+			},
+		},
+		linter.Linter{
+			Path: filepath.Join(".jx", "gitops", v1alpha1.ServiceAccountIdentitiesFileName),
+			Linter: func(path string, test *linter.Test) error {
+				return o.LintResource(path, test, &v1alpha1.ServiceAccountIdentities{})
+			},
+		},
+		linter.Linter{
+			Path: filepath.Join(".jx", "gitops", v1alpha1.NamespacePoliciesFileName),
+			Linter: func(path string, test *linter.Test) error {
+				return o.LintNamespacePolicies(path, test)
+			},
+		},
 		linter.Linter{
 			Path: filepath.Join("extensions", v1alpha1.PipelineCatalogFileName),
 			Linter: func(path string, test *linter.Test) error {
-				return o.LintResource(path, test, &v1alpha1.PipelineCatalog{})
+				return o.LintPipelineCatalog(path, test)
 			},
 		},
 		linter.Linter{
@@ -86,10 +108,80 @@ func (o *Options) Validate() error {
 				return o.LintYaml2Resource(path, test, &state.HelmState{})
 			},
 		},
+		linter.Linter{
+			Path: ownership.DefaultPath,
+			Linter: func(path string, test *linter.Test) error {
+				return o.LintOwnership(path, test)
+			},
+		},
 	)
 	return nil
 }
 
+// LintPipelineCatalog lints the pipeline catalog file and fails the test if any catalog is not pinned
+// to a commit SHA or version tag as a branch can change without the cluster git repository changing
+func (o *Options) LintPipelineCatalog(path string, test *linter.Test) error {
+	pc := &v1alpha1.PipelineCatalog{}
+	err := o.LintResource(path, test, pc)
+	if err != nil || test.Error != nil {
+		return err
+	}
+	var messages []string
+	for i := range pc.Spec.Repositories {
+		repo := &pc.Spec.Repositories[i]
+		if repo.GitURL == "" || pipelinecatalogs.IsPinned(repo) {
+			continue
+		}
+		if repo.GitRef == "" {
+			messages = append(messages, fmt.Sprintf("%s has no gitRef so uses the default branch", repo.GitURL))
+		} else {
+			messages = append(messages, fmt.Sprintf("%s is pinned to branch '%s'", repo.GitURL, repo.GitRef))
+		}
+	}
+	if len(messages) > 0 {
+		test.Error = errors.Errorf("pipeline catalogs should be pinned to a commit SHA or version tag via 'pipelinecatalog upgrade': %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// LintNamespacePolicies lints the namespace policies file and fails the test if any Namespace in the config root
+// directory is missing the labels, annotations or resources of the policies which match it
+func (o *Options) LintNamespacePolicies(path string, test *linter.Test) error {
+	config := &v1alpha1.NamespacePolicies{}
+	err := o.LintResource(path, test, config)
+	if err != nil || test.Error != nil {
+		return err
+	}
+	messages, err := namespacepolicies.Verify(config, filepath.Join(o.Dir, "config-root"))
+	if err != nil {
+		test.Error = err
+		return nil
+	}
+	if len(messages) > 0 {
+		test.Error = errors.Errorf("namespaces are missing required policy: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// LintOwnership fails the test if any cluster scoped resource is rendered by more than one release
+func (o *Options) LintOwnership(path string, test *linter.Test) error {
+	index, err := ownership.LoadIndex(path)
+	if err != nil {
+		test.Error = err
+		return nil
+	}
+	conflicts := index.Conflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+	var messages []string
+	for i := range conflicts {
+		messages = append(messages, conflicts[i].String())
+	}
+	test.Error = errors.Errorf("duplicate cluster resources: %s", strings.Join(messages, "; "))
+	return nil
+}
+
 // Run implements the command
 func (o *Options) Run() error {
 	err := o.Validate()
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/lint"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -13,4 +14,35 @@ func TestLint(t *testing.T) {
 
 	err := o.Run()
 	require.NoError(t, err, "failed to run")
+
+	lintedOwnership := false
+	lintedPipelineCatalog := false
+	lintedNamespacePolicies := false
+	for _, test := range o.Tests {
+		switch test.File {
+		case ".jx/gitops/ownership.yaml":
+			require.Error(t, test.Error, "should have found duplicate cluster resources")
+			assert.Contains(t, test.Error.Error(), "rbac.authorization.k8s.io/ClusterRole/shared-role is rendered by releases jx/app-a, jx/app-b")
+			lintedOwnership = true
+		case "extensions/pipeline-catalog.yaml":
+			require.Error(t, test.Error, "should have found a pipeline catalog pinned to a branch")
+			assert.Contains(t, test.Error.Error(), "https://github.com/jstrachan/jx3-pipeline-catalog is pinned to branch 'myref'")
+			assert.Contains(t, test.Error.Error(), "https://github.com/myorg/another-pipeline-catalog has no gitRef so uses the default branch")
+			lintedPipelineCatalog = true
+		case ".jx/gitops/namespace-policies.yaml":
+			require.Error(t, test.Error, "should have found namespaces missing required policy")
+			message := test.Error.Error()
+			assert.Contains(t, message, "namespace jx-production is missing label pod-security.kubernetes.io/enforce=baseline")
+			assert.Contains(t, message, "namespace jx-production is missing a ResourceQuota")
+			assert.Contains(t, message, "namespace jx-production is missing a default deny NetworkPolicy")
+			assert.Contains(t, message, "namespace tools does not match any policy")
+			assert.Contains(t, message, "namespace jx-preview has a ResourceQuota namespace-policy which does not match the policy")
+			assert.Contains(t, message, "namespace jx-preview is missing a default deny NetworkPolicy")
+			assert.NotContains(t, message, "namespace jx-staging", "jx-staging has all of its required policy")
+			lintedNamespacePolicies = true
+		}
+	}
+	assert.True(t, lintedOwnership, "did not lint the ownership index")
+	assert.True(t, lintedPipelineCatalog, "did not lint the pipeline catalog")
+	assert.True(t, lintedNamespacePolicies, "did not lint the namespace policies")
 }
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/namespacepolicies"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
@@ -25,6 +27,10 @@ import (
 var (
 	namespaceLong = templates.LongDesc(`
 		Updates all kubernetes resources in the given directory to the given namespace
+
+		In dir mode any missing Namespace resources are created in the cluster directory. The labels, annotations,
+		ResourceQuota, LimitRange and default deny NetworkPolicy of the policies in the namespace policies file
+		which match each namespace are applied to the Namespace and generated into the 'namespace-policy' directory of the namespace.
 `)
 
 	namespaceExample = templates.Examples(`
@@ -46,6 +52,11 @@ type Options struct {
 	ClusterDir string
 	Namespace  string
 	DirMode    bool
+
+	// NamespacePolicies the file of policies applied to the namespaces in dir mode
+	NamespacePolicies string
+
+	namespacePolicies *v1alpha1.NamespacePolicies
 }
 
 // NewCmdUpdate creates a command object for the command
@@ -67,6 +78,7 @@ func NewCmdUpdateNamespace() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.ClusterDir, "cluster-dir", "", "", "the directory to recursively look for the *.yaml or *.yml files")
 	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace to modify the resources to")
 	cmd.Flags().BoolVarP(&o.DirMode, "dir-mode", "", false, "assumes the first child directory is the name of the namespace to use")
+	cmd.Flags().StringVarP(&o.NamespacePolicies, "namespace-policies", "", namespacepolicies.DefaultPath, "the file of policies applied to the namespaces in dir mode. Ignored if it does not exist")
 	o.Filter.AddFlags(cmd)
 	return cmd, o
 }
@@ -96,6 +108,11 @@ func (o *Options) RunDirMode() error {
 	if o.Namespace != "" {
 		return errors.Errorf("should not specify the --namespace option if you are running dir mode as the namespace is taken from the first child directory names")
 	}
+	var err error
+	o.namespacePolicies, err = namespacepolicies.Load(o.NamespacePolicies)
+	if err != nil {
+		return err
+	}
 	flieList, err := os.ReadDir(o.Dir)
 	if err != nil {
 		return errors.Wrapf(err, "failed to read dir %s", o.Dir)
@@ -132,6 +149,15 @@ func (o *Options) RunDirMode() error {
 func (o *Options) lazyCreateNamespaceResource(ns string) error {
 	dir := filepath.Dir(o.ClusterDir)
 
+	policy, err := namespacepolicies.Resolve(o.namespacePolicies, ns)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve the policies of namespace %s", ns)
+	}
+	err = namespacepolicies.SaveResources(filepath.Join(o.Dir, ns), ns, policy)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save the policy resources of namespace %s", ns)
+	}
+
 	found := false
 
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
@@ -140,6 +166,7 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 			name := kyamls.GetName(node, path)
 			if name == ns {
 				found = true
+				return namespacepolicies.ApplyToNode(node, policy)
 			}
 		}
 		return false, nil
@@ -148,7 +175,7 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 	filter := kyamls.Filter{
 		Kinds: []string{"Namespace"},
 	}
-	err := kyamls.ModifyFiles(dir, modifyFn, filter)
+	err = kyamls.ModifyFiles(dir, modifyFn, filter)
 	if err != nil {
 		return errors.Wrapf(err, "failed to walk namespaces in dir %s", dir)
 	}
@@ -170,6 +197,7 @@ func (o *Options) lazyCreateNamespaceResource(ns string) error {
 			},
 		},
 	}
+	namespacepolicies.ApplyToNamespace(namespace, policy)
 	err = yamls.SaveFile(namespace, fileName)
 	if err != nil {
 		return errors.Wrapf(err, "failed to save file %s", fileName)
@@ -151,3 +151,73 @@ func TestNamespaceDirMode(t *testing.T) {
 	}
 	assert.Len(t, found, 2, "found namespaces")
 }
+
+func TestNamespaceDirModePolicies(t *testing.T) {
+	srcFile := filepath.Join("testdata", "dirmode")
+	require.DirExists(t, srcFile)
+
+	rootTmpDir := t.TempDir()
+
+	tmpDir := filepath.Join(rootTmpDir, "namespaces")
+	err := files.CopyDirOverwrite(srcFile, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcFile, tmpDir)
+
+	// lets check an existing Namespace resource gets the policy labels too
+	clusterNamespacesDir := filepath.Join(rootTmpDir, "cluster", "namespaces")
+	err = os.MkdirAll(clusterNamespacesDir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to make cluster namespaces dir")
+	existingFile := filepath.Join(clusterNamespacesDir, "existing.yaml")
+	err = os.WriteFile(existingFile, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: something\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", existingFile)
+
+	o := &namespace.Options{
+		Dir:               tmpDir,
+		DirMode:           true,
+		NamespacePolicies: filepath.Join("testdata", "policies", "namespace-policies.yaml"),
+	}
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run in dir %s", tmpDir)
+
+	assertNamespaceMetadata := func(path, ns string, expectedLabels, expectedAnnotations map[string]string) {
+		node, err := yaml.ReadFile(path)
+		require.NoError(t, err, "failed to load file %s", path)
+		assert.Equal(t, ns, node.GetName(), "name of Namespace in %s", path)
+
+		labels := node.GetLabels()
+		for k, v := range expectedLabels {
+			assert.Equal(t, v, labels[k], "label %s of Namespace %s", k, ns)
+		}
+		annotations := node.GetAnnotations()
+		for k, v := range expectedAnnotations {
+			assert.Equal(t, v, annotations[k], "annotation %s of Namespace %s", k, ns)
+		}
+	}
+	assertNamespaceMetadata(filepath.Join(clusterNamespacesDir, "jx.yaml"), "jx", map[string]string{
+		"name":                               "jx",
+		"pod-security.kubernetes.io/enforce": "restricted",
+		"team":                               "platform",
+	}, map[string]string{
+		"owner": "platform-team",
+	})
+	assertNamespaceMetadata(existingFile, "something", map[string]string{
+		"pod-security.kubernetes.io/enforce": "baseline",
+	}, nil)
+	assert.NoFileExists(t, filepath.Join(clusterNamespacesDir, "something.yaml"), "should not create a Namespace resource if one exists")
+
+	expectedResources := map[string][]string{
+		"jx":        {"LimitRange", "NetworkPolicy", "ResourceQuota"},
+		"something": {"NetworkPolicy"},
+	}
+	for ns, kinds := range expectedResources {
+		dir := filepath.Join(tmpDir, ns, "namespace-policy")
+		var actual []string
+		err = kyamls.ModifyFiles(dir, func(node *yaml.RNode, path string) (bool, error) {
+			actual = append(actual, kyamls.GetKind(node, path))
+			assert.Equal(t, ns, kyamls.GetNamespace(node, path), "namespace of %s", path)
+			return false, nil
+		}, kyamls.Filter{})
+		require.NoError(t, err, "failed to find policy resources in dir %s", dir)
+		assert.ElementsMatch(t, kinds, actual, "policy resources for namespace %s", ns)
+	}
+}
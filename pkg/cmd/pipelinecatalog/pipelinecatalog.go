@@ -0,0 +1,25 @@
+package pipelinecatalog
+
+import (
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/pipelinecatalog/upgrade"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdPipelineCatalog creates the new command
+func NewCmdPipelineCatalog() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "pipelinecatalog",
+		Short:   "Commands for working with pipeline catalogs",
+		Aliases: []string{"pipelinecatalogs", "pc"},
+		Run: func(command *cobra.Command, _ []string) {
+			err := command.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	command.AddCommand(cobras.SplitCommand(upgrade.NewCmdPipelineCatalogUpgrade()))
+	return command
+}
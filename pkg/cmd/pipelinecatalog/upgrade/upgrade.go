@@ -0,0 +1,166 @@
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Upgrades the pipeline catalogs in extensions/pipeline-catalog.yaml to their latest version tag
+
+		Each catalog is resolved to the latest semantic version tag of its git repository via 'git ls-remote' or the latest tag
+		matching its 'versionConstraint' such as '^1.2'. The catalog is then pinned to the full commit SHA of the tag with the tag
+		recorded in 'gitTag' and a changelog of the pipeline files which changed is printed.
+`)
+
+	cmdExample = templates.Examples(`
+		# upgrades the pipeline catalogs to the latest version tags
+		%s pipelinecatalog upgrade
+
+		# upgrades the pipeline catalogs to the latest 1.x version
+		%[1]s pipelinecatalog upgrade --constraint ^1
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+// Result the result of upgrading a pipeline catalog
+type Result struct {
+	GitURL  string
+	FromRef string
+	ToRef   string
+	Tag     string
+	Changes []pipelinecatalogs.Change
+}
+
+// Options the options for the command
+type Options struct {
+	Dir           string
+	Constraint    string
+	NoChangelog   bool
+	CommandRunner cmdrunner.CommandRunner
+	GitClient     gitclient.Interface
+	Out           io.Writer
+	Results       []*Result
+}
+
+// NewCmdPipelineCatalogUpgrade creates a command object for the command
+func NewCmdPipelineCatalogUpgrade() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "upgrade",
+		Short:   "Upgrades the pipeline catalogs to their latest version tag pinned to the commit SHA",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the extensions/pipeline-catalog.yaml file")
+	cmd.Flags().StringVarP(&o.Constraint, "constraint", "", "", "the semver constraint such as '^1.2' to use for all catalogs. Defaults to the 'versionConstraint' of each catalog")
+	cmd.Flags().BoolVarP(&o.NoChangelog, "no-changelog", "", false, "disables cloning the catalogs to print the changelog of pipeline files")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.GitClient == nil {
+		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	pc, path, err := pipelinecatalogs.LoadPipelineCatalogs(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load pipeline catalogs")
+	}
+
+	o.Results = nil
+	for i := range pc.Spec.Repositories {
+		repo := &pc.Spec.Repositories[i]
+		gitURL := repo.GitURL
+		if gitURL == "" {
+			continue
+		}
+		constraint := o.Constraint
+		if constraint == "" {
+			constraint = repo.VersionConstraint
+		}
+		tags, err := pipelinecatalogs.RemoteTags(o.GitClient, gitURL)
+		if err != nil {
+			return err
+		}
+		tag, err := pipelinecatalogs.LatestTag(tags, constraint)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the latest tag of pipeline catalog %s", gitURL)
+		}
+		if tag == "" {
+			log.Logger().Warnf("no version tags of pipeline catalog %s match constraint '%s'", gitURL, constraint)
+			continue
+		}
+		sha := tags[tag]
+		if repo.GitRef == sha && repo.GitTag == tag {
+			log.Logger().Infof("pipeline catalog %s is already at %s", info(gitURL), info(tag))
+			continue
+		}
+
+		r := &Result{
+			GitURL:  gitURL,
+			FromRef: repo.GitRef,
+			ToRef:   sha,
+			Tag:     tag,
+		}
+		o.Results = append(o.Results, r)
+		repo.GitRef = sha
+		repo.GitTag = tag
+		log.Logger().Infof("upgraded pipeline catalog %s to %s at %s", info(gitURL), info(tag), sha)
+
+		if o.NoChangelog || r.FromRef == "" || r.FromRef == sha {
+			continue
+		}
+		r.Changes, err = pipelinecatalogs.Changelog(o.GitClient, gitURL, r.FromRef, r.ToRef)
+		if err != nil {
+			log.Logger().Warnf("failed to create the changelog of pipeline catalog %s: %s", gitURL, err.Error())
+			continue
+		}
+		o.printChangelog(r)
+	}
+	if len(o.Results) == 0 {
+		return nil
+	}
+
+	err = yamls.SaveFile(pc, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	log.Logger().Infof("modified %s", info(path))
+	return nil
+}
+
+func (o *Options) printChangelog(r *Result) {
+	fmt.Fprintf(o.Out, "\nChanges to %s from %s to %s:\n", r.GitURL, r.FromRef, r.Tag)
+	if len(r.Changes) == 0 {
+		fmt.Fprintln(o.Out, "  no pipeline files changed")
+		return
+	}
+	for _, c := range r.Changes {
+		fmt.Fprintf(o.Out, "  %s %s\n", c.Status, c.Path)
+	}
+}
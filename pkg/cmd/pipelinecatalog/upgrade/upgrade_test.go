@@ -0,0 +1,107 @@
+package upgrade_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/pipelinecatalog/upgrade"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineCatalogUpgrade(t *testing.T) {
+	g := cli.NewCLIClient("", nil)
+
+	// lets create a pipeline catalog git repository with some version tags
+	catalogDir := t.TempDir()
+	err := gitclient.Init(g, catalogDir)
+	require.NoError(t, err, "failed to git init")
+	_, _, err = gitclient.EnsureUserAndEmailSetup(g, catalogDir, "", "")
+	require.NoError(t, err, "failed to ensure user and email are setup for git")
+
+	releasePath := filepath.Join("packs", "go", ".lighthouse", "jenkins-x", "release.yaml")
+	pullRequestPath := filepath.Join("packs", "go", ".lighthouse", "jenkins-x", "pullrequest.yaml")
+
+	requireWriteFile(t, catalogDir, releasePath, "v1")
+	requireCommitAndTag(t, g, catalogDir, "v1.0.0")
+
+	requireWriteFile(t, catalogDir, releasePath, "v1.1")
+	requireWriteFile(t, catalogDir, pullRequestPath, "v1.1")
+	requireWriteFile(t, catalogDir, "README.md", "v1.1")
+	v11SHA := requireCommitAndTag(t, g, catalogDir, "v1.1.0")
+
+	requireWriteFile(t, catalogDir, releasePath, "v2")
+	v2SHA := requireCommitAndTag(t, g, catalogDir, "v2.0.0")
+
+	tmpDir := t.TempDir()
+	err = files.CopyDirOverwrite("testdata", tmpDir)
+	require.NoError(t, err, "failed to copy testdata to %s", tmpDir)
+
+	path := filepath.Join(tmpDir, "extensions", "pipeline-catalog.yaml")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	err = os.WriteFile(path, []byte(strings.ReplaceAll(string(data), "GIT_URL", catalogDir)), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", path)
+
+	_, o := upgrade.NewCmdPipelineCatalogUpgrade()
+	o.Dir = tmpDir
+	o.Out = &bytes.Buffer{}
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	pc, _, err := pipelinecatalogs.LoadPipelineCatalogs(tmpDir)
+	require.NoError(t, err, "failed to load pipeline catalogs")
+	require.Len(t, pc.Spec.Repositories, 1, "pipeline catalogs")
+	repo := pc.Spec.Repositories[0]
+	assert.Equal(t, v11SHA, repo.GitRef, "should be pinned to the latest tag matching the constraint")
+	assert.Equal(t, "v1.1.0", repo.GitTag, "gitTag")
+
+	require.Len(t, o.Results, 1, "results")
+	assert.Equal(t, []pipelinecatalogs.Change{
+		{Status: "A", Path: pullRequestPath},
+		{Status: "M", Path: releasePath},
+	}, o.Results[0].Changes, "changelog")
+
+	// lets upgrade to the latest major version
+	o.Constraint = ">=1"
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	pc, _, err = pipelinecatalogs.LoadPipelineCatalogs(tmpDir)
+	require.NoError(t, err, "failed to load pipeline catalogs")
+	assert.Equal(t, v2SHA, pc.Spec.Repositories[0].GitRef, "should be pinned to the latest tag")
+	assert.Equal(t, "v2.0.0", pc.Spec.Repositories[0].GitTag, "gitTag")
+	assert.Contains(t, o.Out.(*bytes.Buffer).String(), "M "+releasePath, "changelog output")
+
+	// lets check we don't modify anything if we are on the latest version
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Empty(t, o.Results, "should not have upgraded anything")
+}
+
+func requireWriteFile(t *testing.T, dir, name, contents string) {
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir for %s", path)
+	err = os.WriteFile(path, []byte(contents), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to write file %s", path)
+}
+
+func requireCommitAndTag(t *testing.T, g gitclient.Interface, dir, tag string) string {
+	err := gitclient.Add(g, dir, ".")
+	require.NoError(t, err, "failed to git add in dir %s", dir)
+	_, err = g.Command(dir, "commit", "-m", "release "+tag, "--no-gpg-sign")
+	require.NoError(t, err, "failed to git commit")
+	_, err = g.Command(dir, "tag", "-a", tag, "-m", tag)
+	require.NoError(t, err, "failed to git tag")
+	sha, err := g.Command(dir, "rev-parse", "HEAD")
+	require.NoError(t, err, "failed to get the head SHA")
+	return strings.TrimSpace(sha)
+}
@@ -48,12 +48,15 @@ func NewCmdPluginGet() (*cobra.Command, *Options) {
 
 // Run implements this command
 func (o *Options) Run() error {
+	lock, err := plugins.DefaultLockFile()
+	if err != nil {
+		return err
+	}
 	out := os.Stdout
 	t := table.CreateTable(out)
 	t.AddRow("NAME", "VERSION")
 
-	for i := range plugins.Plugins {
-		p := &plugins.Plugins[i]
+	for _, p := range plugins.LockedPlugins(lock) {
 		t.AddRow(p.Name, p.Spec.Version)
 	}
 	t.Render()
@@ -3,6 +3,7 @@ package plugin
 import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/plugin/get"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/plugin/upgrade"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/plugin/verify"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/spf13/cobra"
@@ -22,5 +23,6 @@ func NewCmdPlugin() *cobra.Command {
 	}
 	command.AddCommand(cobras.SplitCommand(get.NewCmdPluginGet()))
 	command.AddCommand(cobras.SplitCommand(upgrade.NewCmdUpgradePlugins()))
+	command.AddCommand(cobras.SplitCommand(verify.NewCmdPluginVerify()))
 	return command
 }
@@ -12,7 +12,6 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
@@ -73,10 +72,14 @@ func (o *Options) Run() error {
 		}
 	}
 
-	for k := range plugins.Plugins {
-		p := plugins.Plugins[k]
+	lock, err := plugins.DefaultLockFile()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the plugin lock file")
+	}
+
+	for _, p := range plugins.LockedPlugins(lock) {
 		log.Logger().Infof("checking binary jx plugin %s version %s is installed", termcolor.ColorInfo(p.Name), termcolor.ColorInfo(p.Spec.Version))
-		fileName, err := extensions.EnsurePluginInstalled(p, pluginBinDir)
+		fileName, err := plugins.InstallPlugin(p, pluginBinDir, lock)
 		if err != nil {
 			log.Logger().Errorf("failed to ensure plugin is installed %s: %v", p.Name, err)
 			continue
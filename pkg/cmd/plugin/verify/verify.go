@@ -0,0 +1,198 @@
+package verify
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// StatusVerified the binary matches the checksum in the lock file
+	StatusVerified = "verified"
+
+	// StatusMismatch the binary does not match the checksum in the lock file
+	StatusMismatch = "mismatch"
+
+	// StatusUnlocked there is no checksum in the lock file for the binary
+	StatusUnlocked = "unlocked"
+
+	// StatusMissing the binary has not been downloaded
+	StatusMissing = "missing"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Verifies the SHA-256 checksums of the downloaded binary plugins against the plugin lock file
+
+		The lock file is specified via --lock-file, $JX_GITOPS_PLUGIN_LOCK or defaults to 'versionStream/plugins/plugins-lock.yaml'.
+		If there is no lock file the default plugin versions embedded in the binary are used.
+		The lock file also pins the versions of the plugins so that a version stream can use different versions.
+
+		Use --update to record the checksums of the downloaded binaries for the current platform in the lock file.
+		This trusts the binaries on first use: the checksums are of whatever was downloaded so only run --update
+		on binaries you have verified against the checksums published by each project.
+`)
+
+	cmdExample = templates.Examples(`
+		# verify the downloaded binary plugins
+		%s plugin verify
+
+		# record the checksums of the downloaded binary plugins in the lock file
+		%[1]s plugin verify --update --lock-file versionStream/plugins/plugins-lock.yaml
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+// Result the result of verifying a plugin
+type Result struct {
+	Name     string
+	Version  string
+	Path     string
+	Status   string
+	Checksum string
+}
+
+// Options the options for the command
+type Options struct {
+	Dir      string
+	LockFile string
+	Update   bool
+	Out      io.Writer
+	Results  []*Result
+}
+
+// NewCmdPluginVerify creates the command
+func NewCmdPluginVerify() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "verify",
+		Short:   "Verifies the checksums of the downloaded binary plugins against the plugin lock file",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", "", "the plugin bin directory containing the binaries. Defaults to the directory each plugin is installed into")
+	cmd.Flags().StringVarP(&o.LockFile, "lock-file", "", "", "the plugin lock file. Defaults to $JX_GITOPS_PLUGIN_LOCK or the version stream lock file")
+	cmd.Flags().BoolVarP(&o.Update, "update", "u", false, "records the checksums of the downloaded binaries in the lock file rather than verifying them. The binaries are trusted on first use")
+	return cmd, o
+}
+
+// Run implements this command
+func (o *Options) Run() error {
+	var err error
+	if o.LockFile == "" {
+		o.LockFile, err = plugins.LockFilePath()
+		if err != nil {
+			return err
+		}
+		if o.LockFile == "" && o.Update {
+			o.LockFile = plugins.VersionStreamLockFile
+		}
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	lock, err := o.loadLockFile()
+	if err != nil {
+		return err
+	}
+
+	o.Results = nil
+	platform := plugins.CurrentPlatform()
+	var failed []string
+	for _, p := range plugins.LockedPlugins(lock) {
+		dir := o.Dir
+		if dir == "" {
+			dir, err = plugins.PluginBinDirFor(p.Spec.Name)
+			if err != nil {
+				return errors.Wrapf(err, "failed to find the bin dir of plugin %s", p.Spec.Name)
+			}
+		}
+		r := &Result{
+			Name:    p.Spec.Name,
+			Version: p.Spec.Version,
+			Path:    plugins.PluginPath(&p, dir),
+		}
+		o.Results = append(o.Results, r)
+
+		exists, err := files.FileExists(r.Path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if file exists %s", r.Path)
+		}
+		if !exists {
+			r.Status = StatusMissing
+			continue
+		}
+		r.Checksum, err = plugins.FileChecksum(r.Path)
+		if err != nil {
+			return err
+		}
+		if o.Update {
+			lock.SetChecksum(r.Name, r.Version, platform, r.Checksum)
+			r.Status = StatusVerified
+			continue
+		}
+
+		verified, err := plugins.VerifyPlugin(&p, r.Path, lock)
+		switch {
+		case err != nil:
+			r.Status = StatusMismatch
+			failed = append(failed, r.Name)
+			log.Logger().Warn(err.Error())
+		case verified:
+			r.Status = StatusVerified
+		default:
+			r.Status = StatusUnlocked
+		}
+	}
+
+	if o.Update {
+		err = plugins.SaveLockFile(lock, o.LockFile)
+		if err != nil {
+			return err
+		}
+		log.Logger().Infof("saved plugin checksums for %s to %s", info(platform), info(o.LockFile))
+	}
+
+	t := table.CreateTable(o.Out)
+	t.AddRow("NAME", "VERSION", "STATUS")
+	for _, r := range o.Results {
+		t.AddRow(r.Name, r.Version, r.Status)
+	}
+	t.Render()
+
+	if len(failed) > 0 {
+		return errors.Errorf("plugin binaries do not match the checksums in %s: %v", o.LockFile, failed)
+	}
+	return nil
+}
+
+// loadLockFile loads the lock file or the embedded default lock file if there is no lock file yet
+func (o *Options) loadLockFile() (*plugins.LockFile, error) {
+	if o.LockFile != "" {
+		exists, err := files.FileExists(o.LockFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check if file exists %s", o.LockFile)
+		}
+		if exists {
+			return plugins.LoadLockFile(o.LockFile)
+		}
+	}
+	return plugins.EmbeddedLockFile()
+}
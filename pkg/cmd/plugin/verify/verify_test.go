@@ -0,0 +1,89 @@
+package verify_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/plugin/verify"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginVerify(t *testing.T) {
+	binDir := t.TempDir()
+	lockFile := filepath.Join(t.TempDir(), plugins.LockFileName)
+	helmBinary := filepath.Join(binDir, plugins.HelmPluginName+"-"+plugins.HelmVersion)
+	err := os.WriteFile(helmBinary, []byte("fake helm"), 0o600)
+	require.NoError(t, err, "failed to write fake binary")
+
+	runVerify := func(update bool) (*verify.Options, error) {
+		_, o := verify.NewCmdPluginVerify()
+		o.Dir = binDir
+		o.LockFile = lockFile
+		o.Update = update
+		o.Out = &bytes.Buffer{}
+		return o, o.Run()
+	}
+	statuses := func(o *verify.Options) map[string]string {
+		answer := map[string]string{}
+		for _, r := range o.Results {
+			answer[r.Name] = r.Status
+		}
+		return answer
+	}
+
+	o, err := runVerify(false)
+	require.NoError(t, err, "should not fail without a lock file")
+	assert.Equal(t, verify.StatusUnlocked, statuses(o)[plugins.HelmPluginName])
+	assert.Equal(t, verify.StatusMissing, statuses(o)[plugins.KptPluginName])
+
+	_, err = runVerify(true)
+	require.NoError(t, err, "failed to update the lock file")
+	require.FileExists(t, lockFile)
+
+	o, err = runVerify(false)
+	require.NoError(t, err, "failed to verify")
+	assert.Equal(t, verify.StatusVerified, statuses(o)[plugins.HelmPluginName])
+
+	err = os.WriteFile(helmBinary, []byte("tampered helm"), 0o600)
+	require.NoError(t, err, "failed to modify fake binary")
+
+	o, err = runVerify(false)
+	require.Error(t, err, "should fail when the binary does not match")
+	assert.Equal(t, verify.StatusMismatch, statuses(o)[plugins.HelmPluginName])
+}
+
+func TestPluginVerifyUsesPluginBinDirs(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("JX_GITOPS_HOME", "")
+	t.Setenv("JX_HOME", "")
+	t.Setenv("JX3_HOME", filepath.Join(homeDir, "jx3"))
+
+	helmDir, err := plugins.PluginBinDirFor(plugins.HelmPluginName)
+	require.NoError(t, err, "failed to find helm bin dir")
+	kptDir, err := plugins.PluginBinDirFor(plugins.KptPluginName)
+	require.NoError(t, err, "failed to find kpt bin dir")
+	require.NotEqual(t, helmDir, kptDir, "kpt should be installed in the gitops plugin dir")
+
+	err = os.WriteFile(filepath.Join(helmDir, plugins.HelmPluginName+"-"+plugins.HelmVersion), []byte("fake helm"), 0o600)
+	require.NoError(t, err, "failed to write fake helm binary")
+	err = os.WriteFile(filepath.Join(kptDir, plugins.KptPluginName+"-"+plugins.KptVersion), []byte("fake kpt"), 0o600)
+	require.NoError(t, err, "failed to write fake kpt binary")
+
+	_, o := verify.NewCmdPluginVerify()
+	o.LockFile = filepath.Join(t.TempDir(), plugins.LockFileName)
+	o.Out = &bytes.Buffer{}
+	err = o.Run()
+	require.NoError(t, err, "failed to verify")
+
+	for _, r := range o.Results {
+		switch r.Name {
+		case plugins.HelmPluginName, plugins.KptPluginName:
+			assert.Equal(t, verify.StatusUnlocked, r.Status, "status of %s at %s", r.Name, r.Path)
+		}
+	}
+}
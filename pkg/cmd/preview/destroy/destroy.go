@@ -0,0 +1,103 @@
+package destroy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/previews"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Destroys the helmfile of a preview environment for a pull request
+
+		Removes the preview helmfile generated by 'preview generate' and its entry in the root helmfile.
+		Only directories marked as the preview of the pull request by 'preview generate' are removed.
+`)
+
+	cmdExample = templates.Examples(`
+		# destroys the preview for the current pull request using $REPO_OWNER, $REPO_NAME and $PULL_NUMBER
+		%s preview destroy
+
+		# destroys the preview of a specific pull request
+		%[1]s preview destroy --owner myorg --repo myapp --pr 123
+`)
+)
+
+// Options the options for the command
+type Options struct {
+	previews.Options
+}
+
+// NewCmdPreviewDestroy creates a command object for the command
+func NewCmdPreviewDestroy() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "destroy",
+		Short:   "Destroys the helmfile of a preview environment for a pull request",
+		Aliases: []string{"delete", "remove"},
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	_, preview, err := o.FindPreview()
+	if err != nil {
+		return err
+	}
+
+	rootPath, rootStates, err := o.LoadRootHelmfile()
+	if err != nil {
+		return err
+	}
+	rel := preview.HelmfilePath()
+	dir := filepath.Join(filepath.Dir(rootPath), filepath.Dir(rel))
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if exists {
+		marked, err := preview.IsPreviewDir(dir)
+		if err != nil {
+			return err
+		}
+		if !marked {
+			return errors.Errorf("refusing to remove dir %s as it is not the preview of %s", dir, preview.PullRequestName())
+		}
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove preview dir %s", dir)
+		}
+		log.Logger().Infof("removed preview dir %s", info(dir))
+	}
+
+	if helmfiles.RemoveNestedHelmfile(rootStates[0], rel) {
+		err = helmfiles.SaveHelmfile(rootPath, rootStates)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save helmfile %s", rootPath)
+		}
+		log.Logger().Infof("removed preview helmfile from %s", info(rootPath))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package destroy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/preview/destroy"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/preview/generate"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewDestroy(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("..", "generate", "testdata"), tmpDir)
+	require.NoError(t, err, "failed to copy testdata")
+
+	_, g := generate.NewCmdPreviewGenerate()
+	g.Dir = tmpDir
+	g.Owner = "myorg"
+	g.Repository = "myapp"
+	g.PullRequest = 5
+	err = g.Run()
+	require.NoError(t, err, "failed to generate preview")
+
+	previewDir := filepath.Join(tmpDir, "helmfiles", g.Namespace)
+	require.DirExists(t, previewDir)
+
+	_, o := destroy.NewCmdPreviewDestroy()
+	o.Options = g.Options
+	err = o.Run()
+	require.NoError(t, err, "failed to destroy preview")
+
+	assert.NoDirExists(t, previewDir)
+	rootStates, err := helmfiles.LoadHelmfile(filepath.Join(tmpDir, "helmfile.yaml"))
+	require.NoError(t, err, "failed to load root helmfile")
+	require.Len(t, rootStates[0].Helmfiles, 1)
+	assert.Equal(t, "helmfiles/jx/helmfile.yaml", rootStates[0].Helmfiles[0].Path)
+}
+
+func TestPreviewDestroyUnmarkedDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("..", "generate", "testdata"), tmpDir)
+	require.NoError(t, err, "failed to copy testdata")
+
+	dir := filepath.Join(tmpDir, "helmfiles", "jx-myorg-myapp-pr-7")
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir %s", dir)
+	err = os.WriteFile(filepath.Join(dir, "helmfile.yaml"), []byte("releases: []\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to create helmfile in %s", dir)
+
+	_, o := destroy.NewCmdPreviewDestroy()
+	o.Dir = tmpDir
+	o.Helmfile = "helmfile.yaml"
+	o.Owner = "myorg"
+	o.Repository = "myapp"
+	o.PullRequest = 7
+	err = o.Run()
+	require.Error(t, err, "should not remove a dir without a preview marker")
+	assert.DirExists(t, dir)
+}
+
+func TestPreviewDestroyEnvironmentNamespace(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("..", "generate", "testdata"), tmpDir)
+	require.NoError(t, err, "failed to copy testdata")
+
+	_, o := destroy.NewCmdPreviewDestroy()
+	o.Dir = tmpDir
+	o.Helmfile = "helmfile.yaml"
+	o.Owner = "admin"
+	o.Repository = "environments"
+	o.PullRequest = 1
+	err = o.Run()
+	require.Error(t, err, "should not destroy the preview in an environment namespace")
+	assert.Contains(t, err.Error(), "namespace of an environment")
+	assert.FileExists(t, filepath.Join(tmpDir, "helmfiles", "jx", "helmfile.yaml"))
+}
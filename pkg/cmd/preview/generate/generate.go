@@ -0,0 +1,116 @@
+package generate
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/previews"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Generates the helmfile of a preview environment for a pull request
+
+		The preview template of the repository group in the .jx/gitops/source-config.yaml file is rendered into
+		helmfiles/<namespace>/helmfile.yaml which is added to the root helmfile.
+
+		The resources of the preview are annotated with the TTL of the template so they can be garbage collected.
+`)
+
+	cmdExample = templates.Examples(`
+		# generates the preview for the current pull request using $REPO_OWNER, $REPO_NAME and $PULL_NUMBER
+		%s preview generate
+
+		# generates the preview of a specific pull request
+		%[1]s preview generate --owner myorg --repo myapp --pr 123
+`)
+)
+
+// Options the options for the command
+type Options struct {
+	previews.Options
+
+	// Namespace the namespace of the generated preview
+	Namespace string
+}
+
+// NewCmdPreviewGenerate creates a command object for the command
+func NewCmdPreviewGenerate() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "generate",
+		Short:   "Generates the helmfile of a preview environment for a pull request",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.Options.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	tmpl, preview, err := o.FindPreview()
+	if err != nil {
+		return err
+	}
+	helmStates, err := preview.Render(tmpl)
+	if err != nil {
+		return errors.Wrapf(err, "failed to render preview for %s/%s pull request %d", o.Owner, o.Repository, o.PullRequest)
+	}
+
+	rootPath, rootStates, err := o.LoadRootHelmfile()
+	if err != nil {
+		return err
+	}
+	rel := preview.HelmfilePath()
+	path := filepath.Join(filepath.Dir(rootPath), rel)
+	dir := filepath.Dir(path)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if exists {
+		marked, err := preview.IsPreviewDir(dir)
+		if err != nil {
+			return err
+		}
+		if !marked {
+			return errors.Errorf("refusing to overwrite helmfile %s as it is not the preview of %s", path, preview.PullRequestName())
+		}
+	}
+	err = helmfiles.SaveHelmfile(path, helmStates)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save preview helmfile %s", path)
+	}
+	err = preview.WriteMarker(dir)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("saved preview helmfile %s", info(path))
+
+	if helmfiles.AddNestedHelmfile(rootStates[0], rel) {
+		err = helmfiles.SaveHelmfile(rootPath, rootStates)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save helmfile %s", rootPath)
+		}
+		log.Logger().Infof("added preview helmfile to %s", info(rootPath))
+	}
+	o.Namespace = preview.Namespace
+	return nil
+}
@@ -0,0 +1,75 @@
+package generate_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/preview/generate"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/previews"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewGenerate(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite("testdata", tmpDir)
+	require.NoError(t, err, "failed to copy testdata")
+
+	_, o := generate.NewCmdPreviewGenerate()
+	o.Dir = tmpDir
+	o.Owner = "myorg"
+	o.Repository = "myapp"
+	o.PullRequest = 123
+
+	err = o.Run()
+	require.NoError(t, err, "failed to generate preview")
+	assert.Equal(t, "jx-myorg-myapp-pr-123", o.Namespace)
+
+	// lets check regenerating is idempotent
+	err = o.Run()
+	require.NoError(t, err, "failed to regenerate preview")
+
+	path := filepath.Join(tmpDir, "helmfiles", o.Namespace, "helmfile.yaml")
+	helmStates, err := helmfiles.LoadHelmfile(path)
+	require.NoError(t, err, "failed to load %s", path)
+	require.Len(t, helmStates, 1)
+	hs := helmStates[0]
+	assert.Equal(t, o.Namespace, hs.OverrideNamespace)
+	require.Len(t, hs.Releases, 1)
+	release := hs.Releases[0]
+	assert.Equal(t, "myapp", release.Name)
+	assert.Equal(t, "dev/myapp", release.Chart)
+	assert.Equal(t, "0.0.0-PR-123", release.Version)
+	require.Len(t, release.Transformers, 1)
+	transformer, ok := release.Transformers[0].(map[string]interface{})
+	require.True(t, ok, "transformer should be a map but was %#v", release.Transformers[0])
+	annotations, ok := transformer["annotations"].(map[string]interface{})
+	require.True(t, ok, "annotations should be a map but was %#v", transformer["annotations"])
+	assert.Equal(t, "72h", annotations[previews.TTLAnnotation])
+	assert.Equal(t, "myorg/myapp#123", annotations[previews.PullRequestAnnotation])
+
+	rootStates, err := helmfiles.LoadHelmfile(filepath.Join(tmpDir, "helmfile.yaml"))
+	require.NoError(t, err, "failed to load root helmfile")
+	var paths []string
+	for _, h := range rootStates[0].Helmfiles {
+		paths = append(paths, h.Path)
+	}
+	assert.Equal(t, []string{"helmfiles/jx-myorg-myapp-pr-123/helmfile.yaml", "helmfiles/jx/helmfile.yaml"}, paths)
+}
+
+func TestPreviewGenerateNoTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite("testdata", tmpDir)
+	require.NoError(t, err, "failed to copy testdata")
+
+	_, o := generate.NewCmdPreviewGenerate()
+	o.Dir = tmpDir
+	o.Owner = "other"
+	o.Repository = "nopreview"
+	o.PullRequest = 1
+
+	err = o.Run()
+	require.Error(t, err, "should fail as there is no preview template")
+}
@@ -0,0 +1,27 @@
+package preview
+
+import (
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/preview/destroy"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/preview/generate"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdPreview creates the new command
+func NewCmdPreview() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "preview",
+		Short:   "Commands for generating and destroying the helmfiles of preview environments",
+		Aliases: []string{"previews"},
+		Run: func(command *cobra.Command, _ []string) {
+			err := command.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	command.AddCommand(cobras.SplitCommand(generate.NewCmdPreviewGenerate()))
+	command.AddCommand(cobras.SplitCommand(destroy.NewCmdPreviewDestroy()))
+	return command
+}
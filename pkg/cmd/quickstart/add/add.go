@@ -0,0 +1,120 @@
+package add
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Adds a custom quickstart or an import of shared quickstarts to extensions/quickstarts.yaml
+
+		An existing quickstart with the same ID is replaced and the ID is excluded from any import which matches it so
+		that the custom quickstart overrides the imported one. The includes of an import of a file which is already imported
+		with the same excludes are merged into the existing import. Imports with different excludes are kept separate
+		so that a quickstart is only excluded by the imports which exclude it.
+`)
+
+	cmdExample = templates.Examples(`
+		# adds a custom quickstart
+		%s quickstart add --owner myorg --name my-quickstart --language go
+
+		# imports the node quickstarts from the version stream
+		%[1]s quickstart add --import versionStream/quickstarts.yaml --include 'jenkins-x-quickstarts/node-.*'
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+// Options the options for the command
+type Options struct {
+	Dir        string
+	Quickstart v1alpha1.QuickstartSource
+	Import     v1alpha1.QuickstartImport
+}
+
+// NewCmdQuickstartAdd creates a command object for the command
+func NewCmdQuickstartAdd() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "add",
+		Short:   "Adds a custom quickstart or an import of shared quickstarts to extensions/quickstarts.yaml",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	q := &o.Quickstart
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the extensions/quickstarts.yaml file")
+	cmd.Flags().StringVarP(&q.Name, "name", "n", "", "the name of the quickstart repository")
+	cmd.Flags().StringVarP(&q.Owner, "owner", "o", "", "the owner of the quickstart repository. Defaults to the default owner of the quickstarts")
+	cmd.Flags().StringVarP(&q.ID, "id", "", "", "the ID of the quickstart. Defaults to 'owner/name'")
+	cmd.Flags().StringVarP(&q.Version, "version", "", "", "the version of the quickstart")
+	cmd.Flags().StringVarP(&q.Language, "language", "l", "", "the language of the quickstart")
+	cmd.Flags().StringVarP(&q.Framework, "framework", "f", "", "the framework of the quickstart")
+	cmd.Flags().StringArrayVarP(&q.Tags, "tag", "t", nil, "the tags of the quickstart")
+	cmd.Flags().StringVarP(&q.DownloadZipURL, "download-zip-url", "", "", "the URL to download the quickstart zip. Defaults to the GitHub codeload URL")
+	cmd.Flags().StringVarP(&q.GitServer, "git-server", "", "", "the git server of the quickstart repository")
+	cmd.Flags().StringVarP(&q.GitKind, "git-kind", "", "", "the kind of git server of the quickstart repository")
+	cmd.Flags().StringVarP(&o.Import.File, "import", "i", "", "the file relative to the directory to import quickstarts from such as 'versionStream/quickstarts.yaml'")
+	cmd.Flags().StringArrayVarP(&o.Import.Include, "include", "", nil, "the regular expressions of the quickstart IDs to include from the imported file")
+	cmd.Flags().StringArrayVarP(&o.Import.Excludes, "exclude", "", nil, "the regular expressions of the quickstart IDs to exclude from the imported file")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Quickstart.Name == "" && o.Import.File == "" {
+		return options.MissingOption("name")
+	}
+	qs, fileName, err := quickstarthelpers.LoadQuickstarts(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load quickstarts")
+	}
+
+	// lets add the import first so that the quickstart is excluded from it
+	if o.Import.File != "" {
+		_, err = o.Import.Matcher()
+		if err != nil {
+			return errors.Wrapf(err, "invalid import")
+		}
+		quickstarthelpers.AddImport(&qs.Spec, &o.Import)
+		log.Logger().Infof("added import of %s", info(o.Import.File))
+	}
+	if o.Quickstart.Name != "" {
+		id := quickstarthelpers.QuickstartID(&qs.Spec, &o.Quickstart)
+		replaced, imports, err := quickstarthelpers.AddQuickstart(&qs.Spec, &o.Quickstart, o.Dir)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			log.Logger().Infof("replaced quickstart %s", info(id))
+		} else {
+			log.Logger().Infof("added quickstart %s", info(id))
+		}
+		if len(imports) > 0 {
+			log.Logger().Infof("excluded quickstart %s from the imports of %s", info(id), info(strings.Join(imports, ", ")))
+		}
+	}
+
+	err = quickstarthelpers.SaveQuickstarts(qs, fileName)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified %s", info(fileName))
+	return nil
+}
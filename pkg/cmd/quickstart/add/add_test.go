@@ -0,0 +1,128 @@
+package add_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart/add"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuickstartAdd(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite("testdata", tmpDir)
+	require.NoError(t, err, "failed to copy testdata to %s", tmpDir)
+
+	testCases := []struct {
+		name string
+		init func(o *add.Options)
+	}{
+		{
+			name: "new-quickstart",
+			init: func(o *add.Options) {
+				o.Quickstart.Owner = "myorg"
+				o.Quickstart.Name = "my-quickstart"
+				o.Quickstart.Language = "go"
+			},
+		},
+		{
+			name: "replace-quickstart",
+			init: func(o *add.Options) {
+				o.Quickstart.Name = "node-http"
+				o.Quickstart.Language = "typescript"
+			},
+		},
+		{
+			name: "duplicate-import",
+			init: func(o *add.Options) {
+				o.Import.File = "versionStream/quickstarts.yaml"
+				o.Import.Include = []string{"jenkins-x-quickstarts/node-.*", "jenkins-x-quickstarts/golang-.*"}
+			},
+		},
+		{
+			name: "import-with-excludes",
+			init: func(o *add.Options) {
+				o.Import.File = "versionStream/quickstarts.yaml"
+				o.Import.Include = []string{"jenkins-x-quickstarts/python-.*"}
+				o.Import.Excludes = []string{"jenkins-x-quickstarts/python-flask"}
+			},
+		},
+		{
+			name: "new-import",
+			init: func(o *add.Options) {
+				o.Import.File = "extra/quickstarts.yaml"
+			},
+		},
+		{
+			name: "override-imported-quickstart",
+			init: func(o *add.Options) {
+				o.Quickstart.Name = "golang-http"
+				o.Quickstart.Language = "go"
+				o.Quickstart.Version = "1.2.3"
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		_, o := add.NewCmdQuickstartAdd()
+		o.Dir = tmpDir
+		tc.init(o)
+
+		err = o.Run()
+		require.NoError(t, err, "failed to run %s", tc.name)
+	}
+
+	_, o := add.NewCmdQuickstartAdd()
+	o.Dir = tmpDir
+	err = o.Run()
+	require.Error(t, err, "should fail with no quickstart or import")
+
+	qs, _, err := quickstarthelpers.LoadQuickstarts(tmpDir)
+	require.NoError(t, err, "failed to load quickstarts")
+
+	assert.Equal(t, []v1alpha1.QuickstartSource{
+		{
+			Name:     "node-http",
+			Language: "typescript",
+		},
+		{
+			Owner:    "myorg",
+			Name:     "my-quickstart",
+			Language: "go",
+		},
+		{
+			Name:     "golang-http",
+			Language: "go",
+			Version:  "1.2.3",
+		},
+	}, qs.Spec.Quickstarts, "quickstarts")
+
+	assert.Equal(t, []v1alpha1.QuickstartImport{
+		{
+			File:     "versionStream/quickstarts.yaml",
+			Include:  []string{"jenkins-x-quickstarts/golang-.*", "jenkins-x-quickstarts/node-.*"},
+			Excludes: []string{"^jenkins-x-quickstarts/golang-http$"},
+		},
+		{
+			File:     "versionStream/quickstarts.yaml",
+			Include:  []string{"jenkins-x-quickstarts/python-.*"},
+			Excludes: []string{"jenkins-x-quickstarts/python-flask"},
+		},
+		{
+			File: "extra/quickstarts.yaml",
+		},
+	}, qs.Spec.Imports, "imports")
+
+	quickstarts, err := quickstarthelpers.ResolveQuickstarts(qs, tmpDir)
+	require.NoError(t, err, "failed to resolve quickstarts")
+	var versions []string
+	for i := range quickstarts {
+		if quickstarts[i].ID == "jenkins-x-quickstarts/golang-http" {
+			versions = append(versions, quickstarts[i].Version)
+		}
+	}
+	assert.Equal(t, []string{"1.2.3"}, versions, "should only resolve the custom golang-http quickstart")
+}
@@ -0,0 +1,29 @@
+package quickstart
+
+import (
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart/add"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart/remove"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart/verify"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdQuickstart creates the new command
+func NewCmdQuickstart() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "quickstart",
+		Short:   "Commands for working with the quickstarts in extensions/quickstarts.yaml",
+		Aliases: []string{"quickstarts", "qs"},
+		Run: func(command *cobra.Command, _ []string) {
+			err := command.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	command.AddCommand(cobras.SplitCommand(add.NewCmdQuickstartAdd()))
+	command.AddCommand(cobras.SplitCommand(remove.NewCmdQuickstartRemove()))
+	command.AddCommand(cobras.SplitCommand(verify.NewCmdQuickstartVerify()))
+	return command
+}
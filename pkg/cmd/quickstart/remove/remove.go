@@ -0,0 +1,107 @@
+package remove
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Removes quickstarts or imports of shared quickstarts from extensions/quickstarts.yaml
+
+		A custom quickstart is removed. A quickstart which is imported is excluded from the imports which include it.
+`)
+
+	cmdExample = templates.Examples(`
+		# removes a quickstart
+		%s quickstart remove --id jenkins-x-quickstarts/node-http
+
+		# removes the import of the version stream quickstarts
+		%[1]s quickstart remove --import versionStream/quickstarts.yaml
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+// Options the options for the command
+type Options struct {
+	Dir     string
+	IDs     []string
+	Imports []string
+}
+
+// NewCmdQuickstartRemove creates a command object for the command
+func NewCmdQuickstartRemove() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "remove",
+		Short:   "Removes quickstarts or imports of shared quickstarts from extensions/quickstarts.yaml",
+		Aliases: []string{"rm", "delete"},
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the extensions/quickstarts.yaml file")
+	cmd.Flags().StringArrayVarP(&o.IDs, "id", "", nil, "the IDs of the quickstarts to remove")
+	cmd.Flags().StringArrayVarP(&o.Imports, "import", "i", nil, "the imported files to remove")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if len(o.IDs) == 0 && len(o.Imports) == 0 {
+		return options.MissingOption("id")
+	}
+	qs, fileName, err := quickstarthelpers.LoadQuickstarts(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load quickstarts")
+	}
+
+	var missing []string
+	for _, file := range o.Imports {
+		if !quickstarthelpers.RemoveImport(&qs.Spec, file) {
+			missing = append(missing, file)
+			continue
+		}
+		log.Logger().Infof("removed import of %s", info(file))
+	}
+	for _, id := range o.IDs {
+		if quickstarthelpers.RemoveQuickstart(&qs.Spec, id) {
+			log.Logger().Infof("removed quickstart %s", info(id))
+			continue
+		}
+		imports, err := quickstarthelpers.ExcludeFromImports(&qs.Spec, id, o.Dir)
+		if err != nil {
+			return err
+		}
+		if len(imports) == 0 {
+			missing = append(missing, id)
+			continue
+		}
+		log.Logger().Infof("excluded quickstart %s from the imports of %s", info(id), info(strings.Join(imports, ", ")))
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("could not find %s in %s", strings.Join(missing, ", "), fileName)
+	}
+
+	err = quickstarthelpers.SaveQuickstarts(qs, fileName)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("modified %s", info(fileName))
+	return nil
+}
@@ -0,0 +1,50 @@
+package remove_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart/remove"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuickstartRemove(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite("testdata", tmpDir)
+	require.NoError(t, err, "failed to copy testdata to %s", tmpDir)
+
+	_, o := remove.NewCmdQuickstartRemove()
+	o.Dir = tmpDir
+	o.IDs = []string{"jenkins-x-quickstarts/node-http", "jenkins-x-quickstarts/golang-cli"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	qs, _, err := quickstarthelpers.LoadQuickstarts(tmpDir)
+	require.NoError(t, err, "failed to load quickstarts")
+	assert.Empty(t, qs.Spec.Quickstarts, "should have removed the custom quickstart")
+	require.Len(t, qs.Spec.Imports, 1, "imports")
+	assert.Equal(t, []string{"^jenkins-x-quickstarts/golang-cli$"}, qs.Spec.Imports[0].Excludes, "should have excluded the imported quickstart")
+
+	quickstarts, err := quickstarthelpers.ResolveQuickstarts(qs, tmpDir)
+	require.NoError(t, err, "failed to resolve quickstarts")
+	require.Len(t, quickstarts, 1, "quickstarts")
+	assert.Equal(t, "jenkins-x-quickstarts/golang-http", quickstarts[0].ID, "remaining quickstart")
+
+	_, o = remove.NewCmdQuickstartRemove()
+	o.Dir = tmpDir
+	o.IDs = []string{"jenkins-x-quickstarts/does-not-exist"}
+	err = o.Run()
+	require.Error(t, err, "should fail to remove an unknown quickstart")
+
+	_, o = remove.NewCmdQuickstartRemove()
+	o.Dir = tmpDir
+	o.Imports = []string{"versionStream/quickstarts.yaml"}
+	err = o.Run()
+	require.NoError(t, err, "failed to remove import")
+
+	qs, _, err = quickstarthelpers.LoadQuickstarts(tmpDir)
+	require.NoError(t, err, "failed to load quickstarts")
+	assert.Empty(t, qs.Spec.Imports, "should have removed the import")
+}
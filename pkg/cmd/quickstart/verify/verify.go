@@ -0,0 +1,283 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/quickstarthelpers"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// StatusOK the quickstart resolves
+	StatusOK = "ok"
+
+	// StatusFailed the quickstart does not resolve
+	StatusFailed = "failed"
+
+	// DefaultGitServer the git server used if a quickstart does not specify one
+	DefaultGitServer = "https://github.com"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Verifies the quickstarts in extensions/quickstarts.yaml resolve
+
+		Each custom and imported quickstart is checked that its git repository exists, its download zip URL is reachable
+		and that the repository contains a Dockerfile, charts and pipeline files.
+
+		Use --fake-dir to verify against a directory of 'owner/name' repository folders rather than a git server.
+`)
+
+	cmdExample = templates.Examples(`
+		# verify the quickstarts resolve
+		%s quickstart verify
+	`)
+
+	info = termcolor.ColorInfo
+)
+
+// Result the result of verifying a quickstart
+type Result struct {
+	ID       string
+	Status   string
+	Problems []string
+}
+
+// Options the options for the command
+type Options struct {
+	Dir              string
+	FakeDir          string
+	NoDownloadCheck  bool
+	ScmClientFactory scmhelpers.Factory
+	ScmClient        *scm.Client
+	HTTPClient       *http.Client
+	Out              io.Writer
+	Results          []*Result
+	scmClients       map[string]*scm.Client
+}
+
+// NewCmdQuickstartVerify creates a command object for the command
+func NewCmdQuickstartVerify() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "verify",
+		Short:   "Verifies the quickstarts in extensions/quickstarts.yaml resolve",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the extensions/quickstarts.yaml file")
+	cmd.Flags().StringVarP(&o.FakeDir, "fake-dir", "", "", "verifies against a directory of 'owner/name' repository folders using a fake git server")
+	cmd.Flags().BoolVarP(&o.NoDownloadCheck, "no-download-check", "", false, "disables checking the download zip URLs are reachable")
+	cmd.Flags().StringVarP(&o.ScmClientFactory.GitToken, "git-token", "", "", "the git token used to access the git servers. If not specified it's loaded from the git credentials file")
+	cmd.Flags().StringVarP(&o.ScmClientFactory.GitUsername, "git-username", "", "", "the git username used to access the git servers. If not specified it's loaded from the git credentials file")
+	return cmd, o
+}
+
+// Validate verifies settings
+func (o *Options) Validate() error {
+	if o.FakeDir != "" && o.ScmClient == nil {
+		var err error
+		o.ScmClient, err = NewFakeScmClient(o.FakeDir)
+		if err != nil {
+			return err
+		}
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = httphelpers.GetClient()
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+	qs, fileName, err := quickstarthelpers.LoadQuickstarts(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load quickstarts")
+	}
+	quickstarts, err := quickstarthelpers.ResolveQuickstarts(qs, o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve quickstarts in %s", fileName)
+	}
+
+	o.Results = nil
+	var failed []string
+	for i := range quickstarts {
+		r := o.verifyQuickstart(&quickstarts[i])
+		o.Results = append(o.Results, r)
+		if r.Status != StatusOK {
+			failed = append(failed, r.ID)
+		}
+	}
+
+	t := table.CreateTable(o.Out)
+	t.AddRow("ID", "STATUS", "PROBLEMS")
+	for _, r := range o.Results {
+		t.AddRow(r.ID, r.Status, strings.Join(r.Problems, ", "))
+	}
+	t.Render()
+
+	if len(failed) > 0 {
+		return errors.Errorf("the following quickstarts in %s do not resolve: %s", fileName, strings.Join(failed, ", "))
+	}
+	log.Logger().Infof("verified %d quickstarts in %s", len(o.Results), info(fileName))
+	return nil
+}
+
+func (o *Options) verifyQuickstart(q *v1alpha1.QuickstartSource) *Result {
+	r := &Result{ID: q.ID}
+	r.Problems = append(r.Problems, o.verifyRepository(q)...)
+	if !o.NoDownloadCheck {
+		err := o.verifyDownload(q.DownloadZipURL)
+		if err != nil {
+			r.Problems = append(r.Problems, err.Error())
+		}
+	}
+	r.Status = StatusOK
+	if len(r.Problems) > 0 {
+		r.Status = StatusFailed
+	}
+	return r
+}
+
+// verifyRepository checks the repository exists and contains a Dockerfile, charts and pipeline files
+func (o *Options) verifyRepository(q *v1alpha1.QuickstartSource) []string {
+	client, err := o.getScmClient(q)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	ctx := context.Background()
+	fullName := scm.Join(q.Owner, q.Name)
+	repo, _, err := client.Repositories.Find(ctx, fullName)
+	if err != nil {
+		return []string{fmt.Sprintf("repository %s not found", fullName)}
+	}
+	entries, _, err := client.Contents.List(ctx, fullName, "", repo.Branch, &scm.ListOptions{})
+	if err != nil {
+		return []string{fmt.Sprintf("failed to list the files in repository %s: %s", fullName, err.Error())}
+	}
+	names := map[string]bool{}
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+
+	var problems []string
+	if !names["Dockerfile"] {
+		problems = append(problems, "no Dockerfile")
+	}
+	if !names["charts"] {
+		problems = append(problems, "no charts")
+	}
+	if !names[".lighthouse"] && !names["jenkins-x.yml"] {
+		problems = append(problems, "no pipeline files")
+	}
+	return problems
+}
+
+// verifyDownload checks the download zip URL is reachable
+func (o *Options) verifyDownload(u string) error {
+	if u == "" {
+		return errors.Errorf("no download zip URL")
+	}
+	resp, err := o.HTTPClient.Head(u)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = o.HTTPClient.Get(u)
+	}
+	if err != nil {
+		return errors.Errorf("download zip URL %s is not reachable", u)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return errors.Errorf("download zip URL %s returned %s", u, resp.Status)
+	}
+	return nil
+}
+
+func (o *Options) getScmClient(q *v1alpha1.QuickstartSource) (*scm.Client, error) {
+	if o.ScmClient != nil {
+		return o.ScmClient, nil
+	}
+	server := q.GitServer
+	if server == "" {
+		server = DefaultGitServer
+	}
+	client := o.scmClients[server]
+	if client != nil {
+		return client, nil
+	}
+	f := o.ScmClientFactory
+	f.GitServerURL = server
+	f.GitKind = q.GitKind
+	f.IgnoreMissingToken = true
+	f.NoWriteGitCredentialsFile = true
+	client, err := f.Create()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create git client for %s", server)
+	}
+	if o.scmClients == nil {
+		o.scmClients = map[string]*scm.Client{}
+	}
+	o.scmClients[server] = client
+	return client, nil
+}
+
+// NewFakeScmClient creates a fake git client for the repositories in the 'owner/name' folders of the directory
+func NewFakeScmClient(dir string) (*scm.Client, error) {
+	client, data := fake.NewDefault()
+	data.ContentDir = dir
+
+	owners, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read dir %s", dir)
+	}
+	for _, owner := range owners {
+		if !owner.IsDir() {
+			continue
+		}
+		repos, err := os.ReadDir(filepath.Join(dir, owner.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read dir %s", filepath.Join(dir, owner.Name()))
+		}
+		for _, repo := range repos {
+			if repo.IsDir() {
+				data.Repositories = append(data.Repositories, &scm.Repository{
+					Namespace: owner.Name(),
+					Name:      repo.Name(),
+					FullName:  scm.Join(owner.Name(), repo.Name()),
+					Branch:    "master",
+				})
+			}
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,67 @@
+package verify_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart/verify"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuickstartVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.zip" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite("testdata", tmpDir)
+	require.NoError(t, err, "failed to copy testdata to %s", tmpDir)
+
+	for _, name := range []string{filepath.Join("extensions", "quickstarts.yaml"), filepath.Join("versionStream", "quickstarts.yaml")} {
+		path := filepath.Join(tmpDir, name)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err, "failed to load %s", path)
+		err = os.WriteFile(path, []byte(strings.ReplaceAll(string(data), "DOWNLOAD_URL", server.URL)), files.DefaultFileWritePermissions)
+		require.NoError(t, err, "failed to save %s", path)
+	}
+
+	_, o := verify.NewCmdQuickstartVerify()
+	o.Dir = tmpDir
+	o.FakeDir = filepath.Join(tmpDir, "fake-scm")
+	o.Out = &bytes.Buffer{}
+
+	err = o.Run()
+	require.Error(t, err, "should have failed to verify")
+	assert.Contains(t, err.Error(), "jenkins-x-quickstarts/golang-http, jenkins-x-quickstarts/missing")
+
+	results := map[string]*verify.Result{}
+	for _, r := range o.Results {
+		results[r.ID] = r
+	}
+	require.Len(t, results, 3, "should have verified the custom and included quickstarts")
+
+	r := results["jenkins-x-quickstarts/node-http"]
+	require.NotNil(t, r, "no result for node-http")
+	assert.Equal(t, verify.StatusOK, r.Status, "node-http status")
+	assert.Empty(t, r.Problems, "node-http problems")
+
+	r = results["jenkins-x-quickstarts/golang-http"]
+	require.NotNil(t, r, "no result for golang-http")
+	assert.Equal(t, verify.StatusFailed, r.Status, "golang-http status")
+	assert.Equal(t, []string{"no charts", "no pipeline files"}, r.Problems, "golang-http problems")
+
+	r = results["jenkins-x-quickstarts/missing"]
+	require.NotNil(t, r, "no result for missing")
+	assert.Equal(t, verify.StatusFailed, r.Status, "missing status")
+	assert.Equal(t, []string{"repository jenkins-x-quickstarts/missing not found", "download zip URL " + server.URL + "/missing.zip returned 404 Not Found"}, r.Problems, "missing problems")
+}
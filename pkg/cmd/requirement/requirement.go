@@ -5,6 +5,7 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/merge"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/publish"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/resolve"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/verify"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -32,5 +33,6 @@ func NewCmdRequirement() *cobra.Command {
 	command.AddCommand(helper.RetryOnErrorCommand(cobras.SplitCommand(merge.NewCmdRequirementsMerge()), helper.RegexRetryFunction(requirementRetriableErrors)))
 	command.AddCommand(helper.RetryOnErrorCommand(cobras.SplitCommand(resolve.NewCmdRequirementsResolve()), helper.RegexRetryFunction(requirementRetriableErrors)))
 	command.AddCommand(cobras.SplitCommand(publish.NewCmdRequirementsPublish()))
+	command.AddCommand(cobras.SplitCommand(verify.NewCmdRequirementsVerify()))
 	return command
 }
@@ -0,0 +1,128 @@
+package resolve
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AKSMetadataEndpoint default Azure instance metadata service endpoint.
+	// See https://learn.microsoft.com/en-us/azure/virtual-machines/instance-metadata-service
+	AKSMetadataEndpoint = "http://169.254.169.254/metadata/"
+
+	// AKSAPIVersion the version of the Azure instance metadata service API
+	AKSAPIVersion = "2021-02-01"
+
+	// paths in the REST API...
+
+	// AKSPathSubscriptionID metadata endpoint path to the subscription ID
+	AKSPathSubscriptionID = "instance/compute/subscriptionId"
+
+	// AKSPathLocation metadata endpoint path to the location
+	AKSPathLocation = "instance/compute/location"
+
+	// AKSPathTags metadata endpoint path to the tags of the node formatted as 'key:value;key2:value2'
+	AKSPathTags = "instance/compute/tags"
+
+	// AKSTagClusterName the tag AKS adds to nodes for the name of the cluster
+	AKSTagClusterName = "aks-managed-cluster-name"
+)
+
+// AKSConfig the AKS specific configuration
+type AKSConfig struct {
+	MetadataEndpoint string
+}
+
+// ResolveAKS resolves any missing AKS metadata.
+// The subscription ID is stored as the project and the location as the region
+func (o *Options) ResolveAKS() error {
+	cluster := &o.requirements.Spec.Cluster
+	if cluster.ProjectID != "" && cluster.ClusterName != "" && cluster.Region != "" {
+		o.logAKSMetadata()
+		return nil
+	}
+
+	if !o.NoInClusterCheck && !IsInCluster() {
+		log.Logger().Warnf("cannot default AKS metadata as this command is not running inside the cluster")
+		return nil
+	}
+
+	c := &AKSMetadataClient{Endpoint: o.AKSConfig.MetadataEndpoint}
+	log.Logger().Infof("resolving missing AKS subscription and cluster metadata from endpoint %s", c.getEndpoint())
+	var err error
+	if cluster.ProjectID == "" {
+		cluster.ProjectID, err = c.Get(AKSPathSubscriptionID)
+		if err != nil {
+			return err
+		}
+	}
+	if cluster.Region == "" {
+		cluster.Region, err = c.Get(AKSPathLocation)
+		if err != nil {
+			return err
+		}
+	}
+	text, err := c.Get(AKSPathTags)
+	if err != nil {
+		return err
+	}
+	tags := ParseAKSTags(text)
+	if cluster.ClusterName == "" {
+		cluster.ClusterName = tags[AKSTagClusterName]
+		if cluster.ClusterName == "" {
+			log.Logger().Warnf("could not find the cluster name as the node has no %s tag", AKSTagClusterName)
+		}
+	}
+
+	o.logAKSMetadata()
+	return o.saveResolvedMetadata("chore: default AKS subscription, cluster and location metadata")
+}
+
+func (o *Options) logAKSMetadata() {
+	cluster := &o.requirements.Spec.Cluster
+	info := termcolor.ColorInfo
+
+	log.Logger().Infof("subscription: %s", info(cluster.ProjectID))
+	log.Logger().Infof("cluster name: %s", info(cluster.ClusterName))
+	log.Logger().Infof("location: %s", info(cluster.Region))
+}
+
+// ParseAKSTags parses the tags of the node in the format 'key:value;key2:value2'
+func ParseAKSTags(text string) map[string]string {
+	answer := map[string]string{}
+	for _, tag := range strings.Split(text, ";") {
+		k, v, ok := strings.Cut(tag, ":")
+		if ok {
+			answer[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return answer
+}
+
+// AKSMetadataClient looks up values from the Azure instance metadata service
+type AKSMetadataClient struct {
+	Endpoint string
+}
+
+// Get returns the value at the given path in the Azure instance metadata service
+func (c *AKSMetadataClient) Get(path string) (string, error) {
+	u := stringhelpers.UrlJoin(c.getEndpoint(), path) + "?api-version=" + AKSAPIVersion + "&format=text"
+	req, err := http.NewRequest("GET", u, http.NoBody)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create http request for %s", u)
+	}
+	req.Header.Add("Metadata", "true")
+	return doMetadataRequest(req)
+}
+
+func (c *AKSMetadataClient) getEndpoint() string {
+	if c.Endpoint == "" {
+		return AKSMetadataEndpoint
+	}
+	return c.Endpoint
+}
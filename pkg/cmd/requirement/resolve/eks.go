@@ -0,0 +1,176 @@
+package resolve
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EKSMetadataEndpoint default EC2 instance metadata service endpoint.
+	// See https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/instancedata-data-retrieval.html
+	EKSMetadataEndpoint = "http://169.254.169.254/"
+
+	// paths in the REST API...
+
+	// EKSPathToken the path to create an IMDSv2 session token
+	EKSPathToken = "latest/api/token"
+
+	// EKSPathRegion metadata endpoint path to the region
+	EKSPathRegion = "latest/meta-data/placement/region"
+
+	// EKSPathIdentityDocument metadata endpoint path to the instance identity document containing the account ID
+	EKSPathIdentityDocument = "latest/dynamic/instance-identity/document"
+
+	// EKSPathClusterName metadata endpoint path to the cluster name tag of the node.
+	// Requires access to tags in the instance metadata to be enabled
+	EKSPathClusterName = "latest/meta-data/tags/instance/eks:cluster-name"
+
+	eksTokenHeader    = "X-aws-ec2-metadata-token"
+	eksTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+)
+
+// EKSConfig the EKS specific configuration
+type EKSConfig struct {
+	MetadataEndpoint string
+}
+
+// ResolveEKS resolves any missing EKS metadata.
+// The AWS account ID is stored as the project
+func (o *Options) ResolveEKS() error {
+	cluster := &o.requirements.Spec.Cluster
+	if cluster.ProjectID != "" && cluster.ClusterName != "" && cluster.Region != "" {
+		o.logEKSMetadata()
+		return nil
+	}
+
+	if !o.NoInClusterCheck && !IsInCluster() {
+		log.Logger().Warnf("cannot default EKS metadata as this command is not running inside the cluster")
+		return nil
+	}
+
+	c := &EKSMetadataClient{Endpoint: o.EKSConfig.MetadataEndpoint}
+	log.Logger().Infof("resolving missing EKS account and cluster metadata from endpoint %s", c.getEndpoint())
+	modified := false
+	if cluster.Region == "" {
+		cluster.Region = getEKSMetadata(c, EKSPathRegion, "region")
+		modified = modified || cluster.Region != ""
+	}
+	if cluster.ProjectID == "" {
+		text := getEKSMetadata(c, EKSPathIdentityDocument, "account ID")
+		if text != "" {
+			accountID, err := EKSAccountID(text)
+			if err != nil {
+				log.Logger().Warnf("%s", err.Error())
+			}
+			cluster.ProjectID = accountID
+			modified = modified || cluster.ProjectID != ""
+		}
+	}
+	if cluster.ClusterName == "" {
+		cluster.ClusterName = getEKSMetadata(c, EKSPathClusterName, "cluster name. Make sure access to tags in the instance metadata is enabled and the hop limit allows access from pods")
+		modified = modified || cluster.ClusterName != ""
+	}
+
+	o.logEKSMetadata()
+	if !modified {
+		return nil
+	}
+	return o.saveResolvedMetadata("chore: default EKS account, cluster and region metadata")
+}
+
+// getEKSMetadata returns the value at the given path in the instance metadata service or logs a warning and
+// returns an empty string if it is not available as instance tags and pod access are often disabled
+func getEKSMetadata(c *EKSMetadataClient, path, description string) string {
+	value, err := c.Get(path)
+	if err != nil {
+		log.Logger().Warnf("could not find the %s: %s", description, err.Error())
+		return ""
+	}
+	return value
+}
+
+func (o *Options) logEKSMetadata() {
+	cluster := &o.requirements.Spec.Cluster
+	info := termcolor.ColorInfo
+
+	log.Logger().Infof("AWS account: %s", info(cluster.ProjectID))
+	log.Logger().Infof("cluster name: %s", info(cluster.ClusterName))
+	log.Logger().Infof("region: %s", info(cluster.Region))
+}
+
+// EKSAccountID returns the AWS account ID from the text of the instance identity document
+func EKSAccountID(text string) (string, error) {
+	doc := &eksIdentityDocument{}
+	err := json.Unmarshal([]byte(text), doc)
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse the instance identity document")
+	}
+	return doc.AccountID, nil
+}
+
+// eksIdentityDocument the fields used from the EC2 instance identity document
+type eksIdentityDocument struct {
+	AccountID string `json:"accountId"`
+	Region    string `json:"region"`
+}
+
+// EKSMetadataClient looks up values from the EC2 instance metadata service using IMDSv2 session tokens
+type EKSMetadataClient struct {
+	Endpoint string
+	token    string
+}
+
+// Get returns the value at the given path in the EC2 instance metadata service
+func (c *EKSMetadataClient) Get(path string) (string, error) {
+	if c.token == "" {
+		req, err := http.NewRequest("PUT", stringhelpers.UrlJoin(c.getEndpoint(), EKSPathToken), http.NoBody)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create http request for the IMDSv2 token")
+		}
+		req.Header.Add(eksTokenTTLHeader, "300")
+		c.token, err = doMetadataRequest(req)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create IMDSv2 token")
+		}
+	}
+	u := stringhelpers.UrlJoin(c.getEndpoint(), path)
+	req, err := http.NewRequest("GET", u, http.NoBody)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create http request for %s", u)
+	}
+	req.Header.Add(eksTokenHeader, c.token)
+	return doMetadataRequest(req)
+}
+
+func (c *EKSMetadataClient) getEndpoint() string {
+	if c.Endpoint == "" {
+		return EKSMetadataEndpoint
+	}
+	return c.Endpoint
+}
+
+// doMetadataRequest performs the request to a metadata service returning the trimmed body
+func doMetadataRequest(req *http.Request) (string, error) {
+	u := req.URL.String()
+	resp, err := httphelpers.GetClient().Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to %s endpoint %s", req.Method, u)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read response from %s", u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to %s endpoint %s with status %s", req.Method, u, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
@@ -95,31 +94,32 @@ func (o *Options) ResolveGKE() error {
 		cluster.Zone = location
 	}
 
-	err = o.requirements.SaveConfig(o.requirementsFileName)
-	if err != nil {
-		return errors.Wrapf(err, "failed to save modified requirements file: %s", o.requirementsFileName)
-	}
-
-	log.Logger().Infof("resolved GKE project and cluster metadata and modified file %s", termcolor.ColorInfo(o.requirementsFileName))
 	o.logGKEMetadata()
-
-	if o.NoCommit {
-		return nil
-	}
-	gitter := o.GitClient()
-	_, err = gitter.Command(o.Dir, "add", "*")
-	if err != nil {
-		return errors.Wrapf(err, "failed to add to git")
-	}
-	err = gitclient.CommitIfChanges(gitter, o.Dir, "chore: default GKE project, cluster and location metadata")
-	if err != nil {
-		return errors.Wrapf(err, "failed to git commit the changes to the GKE project, cluster and location")
-	}
-	return nil
+	return o.saveResolvedMetadata("chore: default GKE project, cluster and location metadata")
 }
 
 func (o *Options) getGKEMetadata(path string) (string, error) {
-	ep := o.getGKEMetadataEndpoint()
+	c := &GKEMetadataClient{Endpoint: o.getGKEMetadataEndpoint()}
+	return c.Get(path)
+}
+
+// MetadataClient looks up values from the metadata service of a cloud provider
+type MetadataClient interface {
+	// Get returns the value at the given path in the metadata service
+	Get(path string) (string, error)
+}
+
+// GKEMetadataClient looks up values from the Google metadata endpoint
+type GKEMetadataClient struct {
+	Endpoint string
+}
+
+// Get returns the value at the given path in the Google metadata endpoint
+func (c *GKEMetadataClient) Get(path string) (string, error) {
+	ep := c.Endpoint
+	if ep == "" {
+		ep = GKEMetadataEndpoint
+	}
 	u := stringhelpers.UrlJoin(ep, path)
 
 	client := httphelpers.GetClient()
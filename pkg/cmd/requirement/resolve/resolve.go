@@ -28,6 +28,8 @@ var (
 		Resolves any missing values in the jx-requirements.yml which can be detected.
 
 For example if the provider is GKE then this step will automatically default the project, cluster name and location values if they are not in the 'jx-requirements.yml' file.
+
+For EKS the region, account ID and cluster name are defaulted from the EC2 instance metadata service and for AKS the subscription, cluster name and location are defaulted from the Azure instance metadata service.
 `)
 
 	cmdExample = templates.Examples(`
@@ -44,6 +46,8 @@ type Options struct {
 	Namespace            string
 	SecretName           string
 	GKEConfig            GKEConfig
+	EKSConfig            EKSConfig
+	AKSConfig            AKSConfig
 	KubeClient           kubernetes.Interface
 	gitClient            gitclient.Interface
 	requirements         *jxcore.Requirements
@@ -103,6 +107,10 @@ func (o *Options) Run() error {
 	switch provider {
 	case "gke":
 		return o.ResolveGKE()
+	case "eks":
+		return o.ResolveEKS()
+	case "aks":
+		return o.ResolveAKS()
 	default:
 		log.Logger().Infof("no resolve logic for kubernetes provider %s", termcolor.ColorInfo(provider))
 		return nil
@@ -213,6 +221,29 @@ func (o *Options) resolveEnvironmentGitOwner() error {
 	return nil
 }
 
+// saveResolvedMetadata saves the requirements modified by a cloud provider resolver and commits the changes
+func (o *Options) saveResolvedMetadata(commitMessage string) error {
+	err := o.requirements.SaveConfig(o.requirementsFileName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save modified requirements file: %s", o.requirementsFileName)
+	}
+	log.Logger().Infof("resolved cloud metadata and modified file %s", termcolor.ColorInfo(o.requirementsFileName))
+
+	if o.NoCommit {
+		return nil
+	}
+	gitter := o.GitClient()
+	_, err = gitter.Command(o.Dir, "add", "*")
+	if err != nil {
+		return errors.Wrapf(err, "failed to add to git")
+	}
+	err = gitclient.CommitIfChanges(gitter, o.Dir, commitMessage)
+	if err != nil {
+		return errors.Wrapf(err, "failed to git commit the changes to the cloud metadata")
+	}
+	return nil
+}
+
 // IsInCluster tells if we are running incluster
 func IsInCluster() bool {
 	_, err := rest.InClusterConfig()
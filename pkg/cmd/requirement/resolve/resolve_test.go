@@ -1,6 +1,8 @@
 package resolve_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"testing"
 
@@ -22,8 +24,46 @@ const (
 	expectedProjectNumber = "12345"
 	expectedClusterName   = "cluster-something"
 	expectedLocation      = "europe-west1-e"
+
+	expectedAccountID     = "123456789012"
+	expectedEKSCluster    = "my-eks-cluster"
+	expectedEKSRegion     = "us-east-1"
+	expectedEKSToken      = "my-token"
+	expectedSubscription  = "00000000-1111-2222-3333-444444444444"
+	expectedAKSCluster    = "my-aks-cluster"
+	expectedAKSLocation   = "westeurope"
+	expectedResourceGroup = "my-rg"
 )
 
+// newEKSMetadataServer creates a stub of the EC2 instance metadata service which requires IMDSv2 tokens.
+// The instance tags are only available if tags is true
+func newEKSMetadataServer(t *testing.T, tags bool) *httptest.Server {
+	responses := map[string]string{
+		"/" + resolve.EKSPathRegion:           expectedEKSRegion,
+		"/" + resolve.EKSPathIdentityDocument: `{"accountId": "` + expectedAccountID + `", "region": "` + expectedEKSRegion + `"}`,
+	}
+	if tags {
+		responses["/"+resolve.EKSPathClusterName] = expectedEKSCluster
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/"+resolve.EKSPathToken {
+			assert.NotEmpty(t, r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"), "token TTL header")
+			_, _ = w.Write([]byte(expectedEKSToken))
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != expectedEKSToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
 func TestRequirementsResolveGKE(t *testing.T) {
 	// lets mock the http requests...
 	client := httphelpers.GetClient()
@@ -107,10 +147,14 @@ func TestRequirementsResolvePipelineUser(t *testing.T) {
 	err := files.CopyDirOverwrite(srcFile, tmpDir)
 	require.NoError(t, err, "failed to copy %s to %s", srcFile, tmpDir)
 
+	server := newEKSMetadataServer(t, true)
+	defer server.Close()
+
 	// now lets run the command
 	_, o := resolve.NewCmdRequirementsResolve()
 	o.Dir = tmpDir
 	o.NoInClusterCheck = true
+	o.EKSConfig.MetadataEndpoint = server.URL
 
 	runner := &fakerunner.FakeRunner{}
 	o.CommandRunner = runner.Run
@@ -149,3 +193,110 @@ func TestRequirementsResolvePipelineUser(t *testing.T) {
 	t.Logf("have chart repository %s\n", requirements.Spec.Cluster.ChartRepository)
 	assert.Equal(t, "http://jenkins-x-chartmuseum.jx.svc.cluster.local:8080", requirements.Spec.Cluster.ChartRepository, "requirements.Cluster.ChartRepository for file %s", fileName)
 }
+
+func TestRequirementsResolveEKS(t *testing.T) {
+	server := newEKSMetadataServer(t, true)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+
+	srcFile := filepath.Join("testdata", "eks")
+	err := files.CopyDirOverwrite(srcFile, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcFile, tmpDir)
+
+	_, o := resolve.NewCmdRequirementsResolve()
+	o.Dir = tmpDir
+	o.NoInClusterCheck = true
+	o.EKSConfig.MetadataEndpoint = server.URL
+
+	runner := &fakerunner.FakeRunner{}
+	o.CommandRunner = runner.Run
+	o.KubeClient = fake.NewSimpleClientset()
+	o.Namespace = "jx"
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run resolve")
+
+	requirements, fileName, err := jxcore.LoadRequirementsConfig(tmpDir, false)
+	require.NoError(t, err, "failed to load requirements from %s", tmpDir)
+
+	assert.Equal(t, expectedAccountID, requirements.Spec.Cluster.ProjectID, "requirements.Cluster.ProjectID for file %s", fileName)
+	assert.Equal(t, expectedEKSCluster, requirements.Spec.Cluster.ClusterName, "requirements.Cluster.ClusterName for file %s", fileName)
+	assert.Equal(t, expectedEKSRegion, requirements.Spec.Cluster.Region, "requirements.Cluster.Region for file %s", fileName)
+}
+
+func TestRequirementsResolveEKSWithoutInstanceTags(t *testing.T) {
+	server := newEKSMetadataServer(t, false)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+
+	srcFile := filepath.Join("testdata", "eks")
+	err := files.CopyDirOverwrite(srcFile, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcFile, tmpDir)
+
+	_, o := resolve.NewCmdRequirementsResolve()
+	o.Dir = tmpDir
+	o.NoInClusterCheck = true
+	o.EKSConfig.MetadataEndpoint = server.URL
+
+	runner := &fakerunner.FakeRunner{}
+	o.CommandRunner = runner.Run
+	o.KubeClient = fake.NewSimpleClientset()
+	o.Namespace = "jx"
+
+	err = o.Run()
+	require.NoError(t, err, "should not fail when the instance tags are not available")
+
+	requirements, fileName, err := jxcore.LoadRequirementsConfig(tmpDir, false)
+	require.NoError(t, err, "failed to load requirements from %s", tmpDir)
+
+	assert.Equal(t, expectedAccountID, requirements.Spec.Cluster.ProjectID, "requirements.Cluster.ProjectID for file %s", fileName)
+	assert.Empty(t, requirements.Spec.Cluster.ClusterName, "requirements.Cluster.ClusterName for file %s", fileName)
+	assert.Equal(t, expectedEKSRegion, requirements.Spec.Cluster.Region, "requirements.Cluster.Region for file %s", fileName)
+}
+
+func TestRequirementsResolveAKS(t *testing.T) {
+	responses := map[string]string{
+		"/" + resolve.AKSPathSubscriptionID: expectedSubscription,
+		"/" + resolve.AKSPathLocation:       expectedAKSLocation,
+		"/" + resolve.AKSPathTags:           "aks-managed-cluster-name:" + expectedAKSCluster + ";aks-managed-cluster-rg:" + expectedResourceGroup,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("Metadata"), "Metadata header for %s", r.URL.Path)
+		assert.Equal(t, resolve.AKSAPIVersion, r.URL.Query().Get("api-version"), "api-version for %s", r.URL.Path)
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+
+	srcFile := filepath.Join("testdata", "aks")
+	err := files.CopyDirOverwrite(srcFile, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", srcFile, tmpDir)
+
+	_, o := resolve.NewCmdRequirementsResolve()
+	o.Dir = tmpDir
+	o.NoInClusterCheck = true
+	o.AKSConfig.MetadataEndpoint = server.URL
+
+	runner := &fakerunner.FakeRunner{}
+	o.CommandRunner = runner.Run
+	o.KubeClient = fake.NewSimpleClientset()
+	o.Namespace = "jx"
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run resolve")
+
+	requirements, fileName, err := jxcore.LoadRequirementsConfig(tmpDir, false)
+	require.NoError(t, err, "failed to load requirements from %s", tmpDir)
+
+	assert.Equal(t, expectedSubscription, requirements.Spec.Cluster.ProjectID, "requirements.Cluster.ProjectID for file %s", fileName)
+	assert.Equal(t, expectedAKSCluster, requirements.Spec.Cluster.ClusterName, "requirements.Cluster.ClusterName for file %s", fileName)
+	assert.Equal(t, expectedAKSLocation, requirements.Spec.Cluster.Region, "requirements.Cluster.Region for file %s", fileName)
+}
@@ -0,0 +1,197 @@
+package verify
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/resolve"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-api/v4/pkg/cloud"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// verifyPublishedRequirements compares the requirements with those published to the dev Environment
+func (o *Options) verifyPublishedRequirements() error {
+	env, err := jxenv.GetDevEnvironment(o.JXClient, o.Namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to find the dev Environment in namespace %s", o.Namespace)
+	}
+	if env == nil {
+		o.addFinding(SeverityWarning, "teamSettings", "", "", "no dev Environment in namespace "+o.Namespace)
+		return nil
+	}
+	text := env.Spec.TeamSettings.BootRequirements
+	if text == "" {
+		o.addFinding(SeverityWarning, "teamSettings.bootRequirements", "", "", "the requirements have not been published to the dev Environment")
+		return nil
+	}
+	published := &jxcore.RequirementsConfig{}
+	err = yaml.Unmarshal([]byte(text), published)
+	if err != nil {
+		o.addFinding(SeverityError, "teamSettings.bootRequirements", "", "", "failed to parse the published requirements: "+err.Error())
+		return nil
+	}
+
+	r := o.requirements
+	o.compare("ingress.domain", r.Ingress.Domain, published.Ingress.Domain)
+	o.compare("ingress.tls.enabled", strconv.FormatBool(r.Ingress.TLS != nil && r.Ingress.TLS.Enabled), strconv.FormatBool(published.Ingress.TLS != nil && published.Ingress.TLS.Enabled))
+	o.compare("cluster.registry", r.Cluster.Registry, published.Cluster.Registry)
+	o.compare("cluster.project", r.Cluster.ProjectID, published.Cluster.ProjectID)
+	o.compare("cluster.clusterName", r.Cluster.ClusterName, published.Cluster.ClusterName)
+	return nil
+}
+
+func (o *Options) compare(field, local, live string) {
+	if local != live {
+		o.addFinding(SeverityError, field, local, live, "differs from the requirements published to the dev Environment")
+	}
+}
+
+// verifyIngress checks the domain resolves to the IP address of the ingress controller LoadBalancer
+func (o *Options) verifyIngress() error {
+	domain := o.requirements.Ingress.Domain
+	if domain == "" {
+		o.addFinding(SeverityWarning, "ingress.domain", "", "", "no ingress domain in the requirements")
+		return nil
+	}
+	svc, err := o.KubeClient.CoreV1().Services(o.IngressNamespace).Get(context.TODO(), o.IngressService, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			o.addFinding(SeverityWarning, "ingress.domain", domain, "", "no ingress controller Service "+o.IngressService+" in namespace "+o.IngressNamespace)
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get Service %s in namespace %s", o.IngressService, o.IngressNamespace)
+	}
+	var addresses []string
+	for _, ing := range svc.Status.LoadBalancer.Ingress {
+		if ing.IP != "" {
+			addresses = append(addresses, ing.IP)
+		}
+		if ing.Hostname != "" {
+			ips, err := o.LookupHost(ing.Hostname)
+			if err == nil {
+				addresses = append(addresses, ips...)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		o.addFinding(SeverityWarning, "ingress.domain", domain, "", "the ingress controller LoadBalancer has no address")
+		return nil
+	}
+	live := strings.Join(addresses, ",")
+
+	host := "hook" + o.requirements.Ingress.NamespaceSubDomain + domain
+	ips, err := o.LookupHost(host)
+	if err != nil {
+		o.addFinding(SeverityWarning, "ingress.domain", domain, live, "failed to resolve "+host+": "+err.Error())
+		return nil
+	}
+	for _, ip := range ips {
+		for _, a := range addresses {
+			if ip == a {
+				return nil
+			}
+		}
+	}
+	o.addFinding(SeverityError, "ingress.domain", domain, live, host+" resolves to "+strings.Join(ips, ",")+" rather than the ingress controller LoadBalancer")
+	return nil
+}
+
+// verifyRegistry checks the container registry is reachable
+func (o *Options) verifyRegistry() {
+	registry := o.requirements.Cluster.Registry
+	if registry == "" {
+		return
+	}
+	u := registry
+	if !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	u = strings.TrimSuffix(u, "/") + "/v2/"
+	resp, err := o.HTTPClient.Get(u)
+	if err != nil {
+		o.addFinding(SeverityError, "cluster.registry", registry, "", "the registry is not reachable: "+err.Error())
+		return
+	}
+	resp.Body.Close()
+	// an unauthenticated registry API request returns 401 if the registry is reachable
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		o.addFinding(SeverityWarning, "cluster.registry", registry, resp.Status, "unexpected status from the registry API "+u)
+	}
+}
+
+// metadataCheck compares a field of the requirements with a value from the cloud metadata service
+type metadataCheck struct {
+	field string
+	path  string
+	local string
+	// parse optionally extracts the value from the text returned by the metadata service
+	parse func(text string) (string, error)
+}
+
+// verifyCloudMetadata compares the project, cluster name and location with the cloud metadata service
+func (o *Options) verifyCloudMetadata() {
+	if o.MetadataClient == nil {
+		return
+	}
+	if !o.NoInClusterCheck && !resolve.IsInCluster() {
+		o.addFinding(SeverityInfo, "cluster", "", "", "not verifying the cloud metadata as not running inside the cluster")
+		return
+	}
+	for _, c := range o.metadataChecks() {
+		text, err := o.MetadataClient.Get(c.path)
+		if err != nil {
+			o.addFinding(SeverityWarning, c.field, c.local, "", "failed to query the metadata service: "+err.Error())
+			continue
+		}
+		live := text
+		if c.parse != nil {
+			live, err = c.parse(text)
+			if err != nil {
+				o.addFinding(SeverityWarning, c.field, c.local, "", err.Error())
+				continue
+			}
+		}
+		if live != c.local {
+			o.addFinding(SeverityError, c.field, c.local, live, "differs from the cloud metadata")
+		}
+	}
+}
+
+// metadataChecks returns the fields to compare with the metadata service of the cluster provider.
+// The AWS account ID and Azure subscription ID are stored as the project
+func (o *Options) metadataChecks() []metadataCheck {
+	cluster := &o.requirements.Cluster
+	switch cluster.Provider {
+	case cloud.EKS:
+		return []metadataCheck{
+			{field: "cluster.project", path: resolve.EKSPathIdentityDocument, local: cluster.ProjectID, parse: resolve.EKSAccountID},
+			{field: "cluster.clusterName", path: resolve.EKSPathClusterName, local: cluster.ClusterName},
+			{field: "cluster.region", path: resolve.EKSPathRegion, local: cluster.Region},
+		}
+	case cloud.AKS:
+		return []metadataCheck{
+			{field: "cluster.project", path: resolve.AKSPathSubscriptionID, local: cluster.ProjectID},
+			{field: "cluster.clusterName", path: resolve.AKSPathTags, local: cluster.ClusterName, parse: func(text string) (string, error) {
+				return resolve.ParseAKSTags(text)[resolve.AKSTagClusterName], nil
+			}},
+			{field: "cluster.region", path: resolve.AKSPathLocation, local: cluster.Region},
+		}
+	default:
+		location := cluster.Region
+		if location == "" {
+			location = cluster.Zone
+		}
+		return []metadataCheck{
+			{field: "cluster.project", path: resolve.GKEPathProjectID, local: cluster.ProjectID},
+			{field: "cluster.clusterName", path: resolve.GKEPathClusterName, local: cluster.ClusterName},
+			{field: "cluster.zone", path: resolve.GKEPathClusterLocation, local: location},
+		}
+	}
+}
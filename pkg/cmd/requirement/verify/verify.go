@@ -0,0 +1,219 @@
+package verify
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/resolve"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/jx-api/v4/pkg/cloud"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// SeverityError the live cluster does not match the requirements
+	SeverityError = "error"
+
+	// SeverityWarning the live cluster could not be checked or may not match the requirements
+	SeverityWarning = "warning"
+
+	// SeverityInfo informational findings
+	SeverityInfo = "info"
+
+	// FailOnNone never fail
+	FailOnNone = "none"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Verifies the jx-requirements.yml matches the live cluster
+
+		The requirements are compared with the requirements published to the dev Environment via 'requirement publish',
+		the IP address of the ingress controller LoadBalancer with the DNS of the domain, the container registry is
+		checked to be reachable and, for GKE, EKS and AKS, the project, cluster name and location are compared with the
+		instance metadata service of the cloud provider.
+
+		Any mismatches are reported with a severity. The command fails if there are any findings of the --fail-on severity or higher.
+`)
+
+	cmdExample = templates.Examples(`
+		# verify the requirements match the live cluster
+		%s requirement verify
+
+		# only report the mismatches
+		%[1]s requirement verify --fail-on none
+	`)
+
+	info = termcolor.ColorInfo
+
+	severities = map[string]int{
+		SeverityInfo:    1,
+		SeverityWarning: 2,
+		SeverityError:   3,
+	}
+)
+
+// Finding a difference between the requirements and the live cluster
+type Finding struct {
+	Severity string
+	Field    string
+	Local    string
+	Live     string
+	Message  string
+}
+
+// Options the options for the command
+type Options struct {
+	Dir                  string
+	Namespace            string
+	IngressNamespace     string
+	IngressService       string
+	FailOn               string
+	NoInClusterCheck     bool
+	GKEConfig            resolve.GKEConfig
+	EKSConfig            resolve.EKSConfig
+	AKSConfig            resolve.AKSConfig
+	MetadataClient       resolve.MetadataClient
+	KubeClient           kubernetes.Interface
+	JXClient             versioned.Interface
+	HTTPClient           *http.Client
+	LookupHost           func(host string) ([]string, error)
+	Out                  io.Writer
+	Findings             []Finding
+	requirements         *jxcore.RequirementsConfig
+	requirementsFileName string
+}
+
+// NewCmdRequirementsVerify creates a command object for the command
+func NewCmdRequirementsVerify() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "verify",
+		Short:   "Verifies the jx-requirements.yml matches the live cluster",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the jx-requirements.yml")
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace of the dev Environment. Defaults to the current namespace")
+	cmd.Flags().StringVarP(&o.IngressNamespace, "ingress-namespace", "", "nginx", "the namespace of the ingress controller Service")
+	cmd.Flags().StringVarP(&o.IngressService, "ingress-service", "", "ingress-nginx-controller", "the name of the ingress controller LoadBalancer Service")
+	cmd.Flags().StringVarP(&o.FailOn, "fail-on", "", SeverityError, "the severity of findings which fail the command. One of: error, warning, info, none")
+	cmd.Flags().BoolVarP(&o.NoInClusterCheck, "no-in-cluster-check", "", false, "query the cloud metadata service even if not running inside the cluster")
+	cmd.Flags().StringVarP(&o.GKEConfig.MetadataEndpoint, "gke-metadata-endpoint", "", resolve.GKEMetadataEndpoint, "the GKE metadata endpoint")
+	cmd.Flags().StringVarP(&o.EKSConfig.MetadataEndpoint, "eks-metadata-endpoint", "", resolve.EKSMetadataEndpoint, "the EC2 instance metadata endpoint used on EKS")
+	cmd.Flags().StringVarP(&o.AKSConfig.MetadataEndpoint, "aks-metadata-endpoint", "", resolve.AKSMetadataEndpoint, "the Azure instance metadata endpoint used on AKS")
+	return cmd, o
+}
+
+// Validate verifies settings
+func (o *Options) Validate() error {
+	if o.FailOn != FailOnNone && severities[o.FailOn] == 0 {
+		return errors.Errorf("invalid --fail-on value %s. Must be one of: error, warning, info, none", o.FailOn)
+	}
+	requirementsResource, fileName, err := jxcore.LoadRequirementsConfig(o.Dir, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load requirements in dir %s", o.Dir)
+	}
+	o.requirements = &requirementsResource.Spec
+	o.requirementsFileName = fileName
+
+	o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create kube client")
+	}
+	o.JXClient, err = jxclient.LazyCreateJXClient(o.JXClient)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create jx client")
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if o.LookupHost == nil {
+		o.LookupHost = net.LookupHost
+	}
+	if o.MetadataClient == nil {
+		switch o.requirements.Cluster.Provider {
+		case cloud.GKE:
+			o.MetadataClient = &resolve.GKEMetadataClient{Endpoint: o.GKEConfig.MetadataEndpoint}
+		case cloud.EKS:
+			o.MetadataClient = &resolve.EKSMetadataClient{Endpoint: o.EKSConfig.MetadataEndpoint}
+		case cloud.AKS:
+			o.MetadataClient = &resolve.AKSMetadataClient{Endpoint: o.AKSConfig.MetadataEndpoint}
+		}
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+
+	o.Findings = nil
+	err = o.verifyPublishedRequirements()
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify the published requirements")
+	}
+	err = o.verifyIngress()
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify the ingress")
+	}
+	o.verifyRegistry()
+	o.verifyCloudMetadata()
+
+	if len(o.Findings) == 0 {
+		log.Logger().Infof("the requirements in %s match the live cluster", info(o.requirementsFileName))
+		return nil
+	}
+
+	t := table.CreateTable(o.Out)
+	t.AddRow("SEVERITY", "FIELD", "LOCAL", "LIVE", "MESSAGE")
+	failed := 0
+	for _, f := range o.Findings {
+		t.AddRow(f.Severity, f.Field, f.Local, f.Live, f.Message)
+		if o.FailOn != FailOnNone && severities[f.Severity] >= severities[o.FailOn] {
+			failed++
+		}
+	}
+	t.Render()
+
+	if failed > 0 {
+		return errors.Errorf("found %d differences between %s and the live cluster", failed, o.requirementsFileName)
+	}
+	return nil
+}
+
+func (o *Options) addFinding(severity, field, local, live, message string) {
+	o.Findings = append(o.Findings, Finding{
+		Severity: severity,
+		Field:    field,
+		Local:    local,
+		Live:     live,
+		Message:  message,
+	})
+}
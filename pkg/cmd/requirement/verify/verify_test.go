@@ -0,0 +1,227 @@
+package verify_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/resolve"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement/verify"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/jx-api/v4/pkg/cloud"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+type fakeMetadataClient map[string]string
+
+func (c fakeMetadataClient) Get(path string) (string, error) {
+	value, ok := c[path]
+	if !ok {
+		return "", errors.Errorf("no metadata for %s", path)
+	}
+	return value, nil
+}
+
+func TestRequirementsVerify(t *testing.T) {
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/", r.URL.Path, "registry API path")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+	registryHost := strings.TrimPrefix(registry.URL, "https://")
+
+	tmpDir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "jx-requirements.yml"))
+	require.NoError(t, err, "failed to load requirements")
+	err = os.WriteFile(filepath.Join(tmpDir, jxcore.RequirementsConfigFileName), []byte(strings.ReplaceAll(string(data), "REGISTRY", registryHost)), 0o600)
+	require.NoError(t, err, "failed to save requirements")
+
+	lbService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ingress-nginx-controller",
+			Namespace: "nginx",
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+			},
+		},
+	}
+	metadata := fakeMetadataClient{
+		resolve.GKEPathProjectID:       "myproject",
+		resolve.GKEPathClusterName:     "mycluster",
+		resolve.GKEPathClusterLocation: "europe-west1-b",
+	}
+
+	testCases := []struct {
+		name      string
+		local     func(r *jxcore.RequirementsConfig)
+		published func(r *jxcore.RequirementsConfig)
+		metadata  fakeMetadataClient
+		dns       string
+		failOn    string
+		expected  []string
+		fail      bool
+	}{
+		{
+			name: "matches",
+		},
+		{
+			name: "published-domain",
+			published: func(r *jxcore.RequirementsConfig) {
+				r.Ingress.Domain = "5.6.7.8.nip.io"
+			},
+			expected: []string{"error ingress.domain"},
+			fail:     true,
+		},
+		{
+			name:     "dns",
+			dns:      "5.6.7.8",
+			expected: []string{"error ingress.domain"},
+			fail:     true,
+		},
+		{
+			name: "metadata",
+			metadata: fakeMetadataClient{
+				resolve.GKEPathProjectID:       "myproject",
+				resolve.GKEPathClusterName:     "another",
+				resolve.GKEPathClusterLocation: "europe-west1-b",
+			},
+			failOn:   verify.FailOnNone,
+			expected: []string{"error cluster.clusterName"},
+		},
+		{
+			name:     "missing-metadata",
+			metadata: fakeMetadataClient{},
+			expected: []string{"warning cluster.project", "warning cluster.clusterName", "warning cluster.zone"},
+		},
+		{
+			name: "eks",
+			local: func(r *jxcore.RequirementsConfig) {
+				r.Cluster.Provider = cloud.EKS
+				r.Cluster.ProjectID = "123456789012"
+				r.Cluster.Region = "us-east-1"
+				r.Cluster.Zone = ""
+			},
+			metadata: fakeMetadataClient{
+				resolve.EKSPathIdentityDocument: `{"accountId": "123456789012", "region": "us-east-1"}`,
+				resolve.EKSPathClusterName:      "another",
+				resolve.EKSPathRegion:           "us-east-1",
+			},
+			failOn:   verify.FailOnNone,
+			expected: []string{"error cluster.clusterName"},
+		},
+		{
+			name: "eks-without-instance-tags",
+			local: func(r *jxcore.RequirementsConfig) {
+				r.Cluster.Provider = cloud.EKS
+				r.Cluster.ProjectID = "123456789012"
+				r.Cluster.Region = "us-east-1"
+				r.Cluster.Zone = ""
+			},
+			metadata: fakeMetadataClient{
+				resolve.EKSPathIdentityDocument: `{"accountId": "123456789012", "region": "us-east-1"}`,
+				resolve.EKSPathRegion:           "us-east-1",
+			},
+			expected: []string{"warning cluster.clusterName"},
+		},
+		{
+			name: "aks",
+			local: func(r *jxcore.RequirementsConfig) {
+				r.Cluster.Provider = cloud.AKS
+				r.Cluster.ProjectID = "mysubscription"
+				r.Cluster.Region = "westeurope"
+				r.Cluster.Zone = ""
+			},
+			metadata: fakeMetadataClient{
+				resolve.AKSPathSubscriptionID: "mysubscription",
+				resolve.AKSPathTags:           "aks-managed-cluster-name:mycluster;aks-managed-poolName:nodepool1",
+				resolve.AKSPathLocation:       "northeurope",
+			},
+			failOn:   verify.FailOnNone,
+			expected: []string{"error cluster.region"},
+		},
+	}
+
+	for _, tc := range testCases {
+		dir := tmpDir
+		if tc.local != nil {
+			local, _, err := jxcore.LoadRequirementsConfig(tmpDir, false)
+			require.NoError(t, err, "failed to load requirements for %s", tc.name)
+			tc.local(&local.Spec)
+			dir = t.TempDir()
+			err = local.SaveConfig(filepath.Join(dir, jxcore.RequirementsConfigFileName))
+			require.NoError(t, err, "failed to save requirements for %s", tc.name)
+		}
+		localRequirements, _, err := jxcore.LoadRequirementsConfig(dir, false)
+		require.NoError(t, err, "failed to load requirements for %s", tc.name)
+		published := localRequirements.Spec
+		if tc.published != nil {
+			tc.published(&published)
+		}
+		publishedYAML, err := yaml.Marshal(&published)
+		require.NoError(t, err, "failed to marshal requirements for %s", tc.name)
+
+		devEnv := &v1.Environment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dev",
+				Namespace: "jx",
+			},
+			Spec: v1.EnvironmentSpec{
+				TeamSettings: v1.TeamSettings{
+					BootRequirements: string(publishedYAML),
+				},
+			},
+		}
+		dns := tc.dns
+		if dns == "" {
+			dns = "1.2.3.4"
+		}
+		md := tc.metadata
+		if md == nil {
+			md = metadata
+		}
+
+		_, o := verify.NewCmdRequirementsVerify()
+		o.Dir = dir
+		o.Namespace = "jx"
+		o.NoInClusterCheck = true
+		if tc.failOn != "" {
+			o.FailOn = tc.failOn
+		}
+		o.KubeClient = fake.NewSimpleClientset(lbService)
+		o.JXClient = jxfake.NewSimpleClientset(devEnv)
+		o.HTTPClient = registry.Client()
+		o.MetadataClient = md
+		o.LookupHost = func(host string) ([]string, error) {
+			assert.Equal(t, "hook-jx.1.2.3.4.nip.io", host, "host for %s", tc.name)
+			return []string{dns}, nil
+		}
+		o.Out = &bytes.Buffer{}
+
+		err = o.Run()
+		if tc.fail {
+			require.Error(t, err, "should have failed for %s", tc.name)
+		} else {
+			require.NoError(t, err, "failed to run for %s", tc.name)
+		}
+
+		var actual []string
+		for _, f := range o.Findings {
+			actual = append(actual, f.Severity+" "+f.Field)
+		}
+		assert.Equal(t, tc.expected, actual, "findings for %s", tc.name)
+	}
+}
@@ -19,9 +19,12 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/lint"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/namespace"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/patch"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/pipelinecatalog"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/plugin"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/postprocess"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/preview"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/quickstart"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/rename"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/repository"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/requirement"
@@ -61,8 +64,11 @@ func Main() *cobra.Command {
 	cmd.AddCommand(git.NewCmdGit())
 	cmd.AddCommand(jenkins.NewCmdJenkins())
 	cmd.AddCommand(kpt.NewCmdKpt())
+	cmd.AddCommand(pipelinecatalog.NewCmdPipelineCatalog())
 	cmd.AddCommand(plugin.NewCmdPlugin())
 	cmd.AddCommand(pr.NewCmdPR())
+	cmd.AddCommand(preview.NewCmdPreview())
+	cmd.AddCommand(quickstart.NewCmdQuickstart())
 	cmd.AddCommand(requirement.NewCmdRequirement())
 	cmd.AddCommand(repository.NewCmdRepository())
 	cmd.AddCommand(sa.NewCmdServiceAccount())
@@ -0,0 +1,318 @@
+package identity
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-api/v4/pkg/cloud"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// GKEServiceAccountAnnotation the annotation of the Google service account used via GKE Workload Identity
+	GKEServiceAccountAnnotation = "iam.gke.io/gcp-service-account"
+
+	// EKSRoleARNAnnotation the annotation of the IAM role used via EKS IAM Roles for Service Accounts
+	EKSRoleARNAnnotation = "eks.amazonaws.com/role-arn"
+
+	// AzureClientIDAnnotation the annotation of the client ID used via Azure Workload Identity
+	AzureClientIDAnnotation = "azure.workload.identity/client-id"
+
+	// AzureTenantIDAnnotation the annotation of the tenant ID used via Azure Workload Identity
+	AzureTenantIDAnnotation = "azure.workload.identity/tenant-id"
+
+	// AzureUseLabel the pod label which enables Azure Workload Identity for the pods of a ServiceAccount
+	AzureUseLabel = "azure.workload.identity/use"
+
+	defaultServiceAccount = "default"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Annotates the ServiceAccounts in the config root directory with their cloud IAM identities
+
+		The identities are read from the .jx/gitops/service-account-identities.yaml file. Each ServiceAccount is annotated for
+		GKE Workload Identity, EKS IAM Roles for Service Accounts or Azure Workload Identity. For Azure the pods
+		which use the ServiceAccount are also labelled to enable the identity.
+
+		Google service account IDs and IAM role names are expanded using the project of the cluster in the jx-requirements.yml file.
+		An identity can also be given by name in which case the provider of the cluster in the jx-requirements.yml file decides
+		whether it is a Google service account ID or an IAM role name.
+
+		This lets the identities be added to the generated resources rather than patching the ServiceAccounts after they are applied.
+`)
+
+	cmdExample = templates.Examples(`
+		# annotates the ServiceAccounts in config-root with their cloud identities
+		%s sa identity
+	`)
+
+	info = termcolor.ColorInfo
+
+	// workloadPodTemplatePaths the paths of the pod templates of the workload kinds
+	workloadPodTemplatePaths = map[string][]string{
+		"Pod":         nil,
+		"Deployment":  {"spec", "template"},
+		"StatefulSet": {"spec", "template"},
+		"DaemonSet":   {"spec", "template"},
+		"ReplicaSet":  {"spec", "template"},
+		"Job":         {"spec", "template"},
+		"CronJob":     {"spec", "jobTemplate", "spec", "template"},
+	}
+)
+
+// Options the options for the command
+type Options struct {
+	Dir           string
+	ConfigRootDir string
+	File          string
+	Requirements  *jxcore.RequirementsConfig
+	Identities    *v1alpha1.ServiceAccountIdentities
+
+	// Annotated the paths of the ServiceAccount files which were annotated
+	Annotated []string
+
+	// Labelled the paths of the workload files whose pods were labelled
+	Labelled []string
+}
+
+// NewCmdServiceAccountIdentity creates a command object for the command
+func NewCmdServiceAccountIdentity() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "identity",
+		Short:   "Annotates the ServiceAccounts in the config root directory with their cloud IAM identities",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the .jx/gitops/service-account-identities.yaml and jx-requirements.yml files")
+	cmd.Flags().StringVarP(&o.ConfigRootDir, "config-root", "", "", "the directory containing the kubernetes resources. Defaults to 'config-root' in the dir")
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "the file of ServiceAccount identities. Defaults to '.jx/gitops/service-account-identities.yaml' in the dir")
+	return cmd, o
+}
+
+// Validate verifies settings
+func (o *Options) Validate() error {
+	if o.ConfigRootDir == "" {
+		o.ConfigRootDir = filepath.Join(o.Dir, "config-root")
+	}
+	if o.File == "" {
+		o.File = filepath.Join(o.Dir, ".jx", "gitops", v1alpha1.ServiceAccountIdentitiesFileName)
+	}
+	if o.Identities == nil {
+		exists, err := files.FileExists(o.File)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if file exists %s", o.File)
+		}
+		o.Identities = &v1alpha1.ServiceAccountIdentities{}
+		if exists {
+			err = yamls.LoadFile(o.File, o.Identities)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load ServiceAccountIdentities file %s", o.File)
+			}
+		}
+	}
+	if o.Requirements == nil {
+		requirements, _, err := jxcore.LoadRequirementsConfig(o.Dir, false)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load requirements in dir %s", o.Dir)
+		}
+		o.Requirements = &requirements.Spec
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+	identities := o.Identities.Spec.ServiceAccounts
+	if len(identities) == 0 {
+		log.Logger().Infof("no ServiceAccount identities in %s", info(o.File))
+		return nil
+	}
+
+	annotations := make([]map[string]string, len(identities))
+	for i := range identities {
+		annotations[i], err = o.identityAnnotations(&identities[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	matched := make([]bool, len(identities))
+	o.Annotated = nil
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		name := kyamls.GetName(node, path)
+		ns := kyamls.GetNamespace(node, path)
+		modified := false
+		for i := range identities {
+			if !identities[i].Matches(name, ns) {
+				continue
+			}
+			matched[i] = true
+			for _, k := range slices.Sorted(maps.Keys(annotations[i])) {
+				v := annotations[i][k]
+				if node.GetAnnotations()[k] == v {
+					continue
+				}
+				err := node.PipeE(yaml.SetAnnotation(k, v))
+				if err != nil {
+					return false, errors.Wrapf(err, "failed to set annotation %s on %s", k, path)
+				}
+				modified = true
+			}
+		}
+		if modified {
+			o.Annotated = append(o.Annotated, path)
+			log.Logger().Infof("annotated ServiceAccount %s in namespace %s with its cloud identity", info(name), info(ns))
+		}
+		return modified, nil
+	}
+	err = kyamls.ModifyFiles(o.ConfigRootDir, modifyFn, kyamls.Filter{Kinds: []string{"ServiceAccount"}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to annotate ServiceAccounts in dir %s", o.ConfigRootDir)
+	}
+	for i := range identities {
+		// the default ServiceAccount is created by kubernetes so is not usually in the config root
+		if !matched[i] && identities[i].Name != defaultServiceAccount {
+			log.Logger().Warnf("no ServiceAccount %s found in dir %s", identities[i].Name, o.ConfigRootDir)
+		}
+	}
+
+	return o.labelAzurePods(identities)
+}
+
+// identityAnnotations returns the annotations of the cloud identities of the ServiceAccount
+func (o *Options) identityAnnotations(i *v1alpha1.ServiceAccountIdentity) (map[string]string, error) {
+	answer := map[string]string{}
+	project := o.Requirements.Cluster.ProjectID
+	provider := o.Requirements.Cluster.Provider
+	gcp, aws := i.GCP, i.AWS
+	if i.Identity != "" {
+		switch provider {
+		case cloud.GKE:
+			if gcp == nil {
+				gcp = &v1alpha1.GCPIdentity{ServiceAccount: i.Identity}
+			}
+		case cloud.EKS:
+			if aws == nil {
+				aws = &v1alpha1.AWSIdentity{RoleName: i.Identity}
+			}
+		case cloud.AKS:
+			if i.Azure == nil {
+				return nil, errors.Errorf("cannot use identity %s for ServiceAccount %s as Azure Workload Identity requires a client ID", i.Identity, i.Name)
+			}
+		default:
+			return nil, errors.Errorf("cannot use identity %s for ServiceAccount %s as the cluster provider %s in the requirements does not support workload identity", i.Identity, i.Name, provider)
+		}
+	}
+	if gcp != nil {
+		email := gcp.ServiceAccount
+		if email == "" {
+			return nil, errors.Errorf("missing GCP service account for ServiceAccount %s", i.Name)
+		}
+		if !strings.Contains(email, "@") {
+			if project == "" {
+				return nil, errors.Errorf("cannot create the GCP service account email for ServiceAccount %s as there is no project in the requirements", i.Name)
+			}
+			email = fmt.Sprintf("%s@%s.iam.gserviceaccount.com", email, project)
+		}
+		answer[GKEServiceAccountAnnotation] = email
+	}
+	if aws != nil {
+		arn := aws.RoleARN
+		if arn == "" {
+			if aws.RoleName == "" {
+				return nil, errors.Errorf("missing AWS role ARN or name for ServiceAccount %s", i.Name)
+			}
+			if project == "" {
+				return nil, errors.Errorf("cannot create the AWS role ARN for ServiceAccount %s as there is no account ID project in the requirements", i.Name)
+			}
+			arn = fmt.Sprintf("arn:aws:iam::%s:role/%s", project, aws.RoleName)
+		}
+		answer[EKSRoleARNAnnotation] = arn
+	}
+	if i.Azure != nil {
+		if i.Azure.ClientID == "" {
+			return nil, errors.Errorf("missing Azure client ID for ServiceAccount %s", i.Name)
+		}
+		answer[AzureClientIDAnnotation] = i.Azure.ClientID
+		if i.Azure.TenantID != "" {
+			answer[AzureTenantIDAnnotation] = i.Azure.TenantID
+		}
+	}
+	return answer, nil
+}
+
+// labelAzurePods labels the pod templates of the workloads which use a ServiceAccount with an Azure identity
+func (o *Options) labelAzurePods(identities []v1alpha1.ServiceAccountIdentity) error {
+	var azureIdentities []*v1alpha1.ServiceAccountIdentity
+	for i := range identities {
+		if identities[i].Azure != nil {
+			azureIdentities = append(azureIdentities, &identities[i])
+		}
+	}
+	if len(azureIdentities) == 0 {
+		return nil
+	}
+
+	var kinds []string
+	for k := range workloadPodTemplatePaths {
+		kinds = append(kinds, k)
+	}
+	o.Labelled = nil
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		templatePath := workloadPodTemplatePaths[kyamls.GetKind(node, path)]
+		sa := kyamls.GetStringField(node, path, append(append([]string{}, templatePath...), "spec", "serviceAccountName")...)
+		if sa == "" {
+			// pods without a ServiceAccount use the default ServiceAccount of their namespace
+			sa = defaultServiceAccount
+		}
+		ns := kyamls.GetNamespace(node, path)
+		for _, i := range azureIdentities {
+			if !i.Matches(sa, ns) {
+				continue
+			}
+			labelsPath := append(append([]string{}, templatePath...), "metadata", "labels")
+			if kyamls.GetStringField(node, path, append(labelsPath, AzureUseLabel)...) == "true" {
+				return false, nil
+			}
+			err := node.PipeE(yaml.LookupCreate(yaml.MappingNode, labelsPath...), yaml.SetField(AzureUseLabel, yaml.NewStringRNode("true")))
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to set label %s on %s", AzureUseLabel, path)
+			}
+			o.Labelled = append(o.Labelled, path)
+			log.Logger().Infof("labelled the pods of %s %s in namespace %s to use Azure Workload Identity", kyamls.GetKind(node, path), info(kyamls.GetName(node, path)), info(ns))
+			return true, nil
+		}
+		return false, nil
+	}
+	err := kyamls.ModifyFiles(o.ConfigRootDir, modifyFn, kyamls.Filter{Kinds: kinds})
+	if err != nil {
+		return errors.Wrapf(err, "failed to label the pods using Azure Workload Identity in dir %s", o.ConfigRootDir)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package identity_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/sa/identity"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestServiceAccountIdentity(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sourceData := "testdata"
+
+	err := files.CopyDirOverwrite(sourceData, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", sourceData, tmpDir)
+
+	_, o := identity.NewCmdServiceAccountIdentity()
+	o.Dir = tmpDir
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	nsDir := filepath.Join(tmpDir, "config-root", "namespaces")
+	testCases := []struct {
+		path        string
+		annotations map[string]string
+	}{
+		{
+			path: filepath.Join(nsDir, "jx", "tekton", "tekton-bot-sa.yaml"),
+			annotations: map[string]string{
+				identity.GKEServiceAccountAnnotation: "mycluster-tekton@myproject.iam.gserviceaccount.com",
+				"meta.helm.sh/release-name":          "tekton",
+			},
+		},
+		{
+			path: filepath.Join(nsDir, "external-dns", "external-dns", "external-dns-sa.yaml"),
+			annotations: map[string]string{
+				identity.EKSRoleARNAnnotation: "arn:aws:iam::123456789012:role/mycluster-external-dns",
+			},
+		},
+		{
+			path: filepath.Join(nsDir, "jx", "lighthouse", "lighthouse-bot-sa.yaml"),
+			annotations: map[string]string{
+				identity.AzureClientIDAnnotation: "00000000-1111-2222-3333-444444444444",
+			},
+		},
+		{
+			path: filepath.Join(nsDir, "jx", "bucketrepo", "bucketrepo-sa.yaml"),
+			annotations: map[string]string{
+				identity.GKEServiceAccountAnnotation: "mycluster-bucketrepo@myproject.iam.gserviceaccount.com",
+			},
+		},
+		{
+			path: filepath.Join(nsDir, "jx", "tekton", "other-sa.yaml"),
+		},
+	}
+	for _, tc := range testCases {
+		sa := &corev1.ServiceAccount{}
+		err = yamls.LoadFile(tc.path, sa)
+		require.NoError(t, err, "failed to load ServiceAccount %s", tc.path)
+		assert.Equal(t, tc.annotations, sa.Annotations, "annotations of ServiceAccount %s", tc.path)
+	}
+	assert.Len(t, o.Annotated, 4, "annotated ServiceAccounts")
+
+	deployPath := filepath.Join(nsDir, "jx", "lighthouse", "lighthouse-webhooks-deploy.yaml")
+	deploy := &appsv1.Deployment{}
+	err = yamls.LoadFile(deployPath, deploy)
+	require.NoError(t, err, "failed to load Deployment %s", deployPath)
+	assert.Equal(t, map[string]string{
+		"app":                  "lighthouse-webhooks",
+		identity.AzureUseLabel: "true",
+	}, deploy.Spec.Template.Labels, "pod labels of Deployment %s", deployPath)
+
+	cronJobPath := filepath.Join(nsDir, "jx", "lighthouse", "lighthouse-gc-cronjob.yaml")
+	cronJob := &batchv1.CronJob{}
+	err = yamls.LoadFile(cronJobPath, cronJob)
+	require.NoError(t, err, "failed to load CronJob %s", cronJobPath)
+	assert.Equal(t, map[string]string{
+		identity.AzureUseLabel: "true",
+	}, cronJob.Spec.JobTemplate.Spec.Template.Labels, "pod labels of CronJob %s", cronJobPath)
+
+	defaultPath := filepath.Join(nsDir, "jx", "bucketrepo", "bucketrepo-deploy.yaml")
+	defaultDeploy := &appsv1.Deployment{}
+	err = yamls.LoadFile(defaultPath, defaultDeploy)
+	require.NoError(t, err, "failed to load Deployment %s", defaultPath)
+	assert.Equal(t, "true", defaultDeploy.Spec.Template.Labels[identity.AzureUseLabel], "should label pods using the default ServiceAccount of Deployment %s", defaultPath)
+
+	tektonPath := filepath.Join(nsDir, "jx", "tekton", "tekton-deploy.yaml")
+	tekton := &appsv1.Deployment{}
+	err = yamls.LoadFile(tektonPath, tekton)
+	require.NoError(t, err, "failed to load Deployment %s", tektonPath)
+	assert.NotContains(t, tekton.Spec.Template.Labels, identity.AzureUseLabel, "should not label pods which do not use Azure Workload Identity")
+	assert.Len(t, o.Labelled, 3, "labelled workloads")
+
+	// running again should not modify anything
+	err = o.Run()
+	require.NoError(t, err, "failed to run again")
+	assert.Empty(t, o.Annotated, "annotated ServiceAccounts on second run")
+	assert.Empty(t, o.Labelled, "labelled workloads on second run")
+}
+
+func TestServiceAccountIdentityUnsupportedProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sourceData := "testdata"
+
+	err := files.CopyDirOverwrite(sourceData, tmpDir)
+	require.NoError(t, err, "failed to copy %s to %s", sourceData, tmpDir)
+
+	_, o := identity.NewCmdServiceAccountIdentity()
+	o.Dir = tmpDir
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.Provider = "kind"
+	o.Requirements.Cluster.ProjectID = "myproject"
+
+	err = o.Run()
+	require.Error(t, err, "should fail to resolve a named identity for provider kind")
+	assert.Contains(t, err.Error(), "bucketrepo")
+}
@@ -1,6 +1,7 @@
 package sa
 
 import (
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/sa/identity"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/sa/secret"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -20,6 +21,7 @@ func NewCmdServiceAccount() *cobra.Command {
 			}
 		},
 	}
+	command.AddCommand(cobras.SplitCommand(identity.NewCmdServiceAccountIdentity()))
 	command.AddCommand(cobras.SplitCommand(secret.NewCmdServiceAccountSecrets()))
 	return command
 }
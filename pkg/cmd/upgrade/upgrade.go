@@ -25,6 +25,7 @@ type Options struct {
 	HelmfileResolve  resolve.Options
 	TerraformUpgrade tfupgrade.Options
 	ReleaseNotesFile string
+	ListMigrations   bool
 }
 
 // NewCmdUpgrade creates a command object
@@ -41,6 +42,7 @@ func NewCmdUpgrade() (*cobra.Command, *Options) {
 		},
 	}
 	cmd.Flags().StringVarP(&o.ReleaseNotesFile, "release-notes-file", "", "", "the file to save any release notes in. By default any release notes will be rendered in the console")
+	cmd.Flags().BoolVarP(&o.ListMigrations, "list-migrations", "", false, "lists the pending migrations which have not been applied to the git repository without performing the upgrade")
 	o.Options.AddFlags(cmd)
 	o.HelmfileResolve.AddFlags(cmd, "")
 	return cmd, o
@@ -48,6 +50,9 @@ func NewCmdUpgrade() (*cobra.Command, *Options) {
 
 // Run implements the command
 func (o *Options) Run() error {
+	if o.ListMigrations {
+		return o.listMigrations()
+	}
 	log.Logger().Infof("upgrading local source code from the version stream using kpt...\n\n")
 
 	err := o.Options.Run()
@@ -93,6 +98,25 @@ func (o *Options) doHelmfileUpgrade() error {
 	return nil
 }
 
+func (o *Options) listMigrations() error {
+	if o.Options.Dir != "" {
+		o.HelmfileResolve.Dir = o.Options.Dir
+	}
+	pending, err := o.HelmfileResolve.PendingMigrations()
+	if err != nil {
+		return errors.Wrapf(err, "failed to find pending migrations")
+	}
+	if len(pending) == 0 {
+		log.Logger().Infof("there are no pending migrations")
+		return nil
+	}
+	log.Logger().Infof("pending migrations:")
+	for _, m := range pending {
+		log.Logger().Infof("  %s: %s", termcolor.ColorInfo(m.ID), m.Description)
+	}
+	return nil
+}
+
 func (o *Options) doTerraformUpgrade() error {
 	if o.Options.Dir != "" {
 		o.TerraformUpgrade.Dir = o.Options.Dir
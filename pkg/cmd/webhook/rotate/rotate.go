@@ -0,0 +1,433 @@
+package rotate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// HMACKey the key in the secret of the HMAC token used by lighthouse
+	HMACKey = "hmac"
+
+	// NextHMACKey the key in the secret of the new HMAC token while it is being rotated
+	NextHMACKey = "hmac-next"
+
+	// RotationAnnotation the annotation on the secret and SourceRepository resources recording the progress of a rotation
+	// as '<rotation id>/<phase>' so that an interrupted rotation can be resumed
+	RotationAnnotation = "webhook.jenkins-x.io/hmac-rotation"
+
+	// RotationQueryParameter the query parameter added to the webhook URL of the temporary webhooks using the new token
+	RotationQueryParameter = "hmac-rotation"
+
+	// PhaseHooks the phase where temporary webhooks using the new token are added to each repository
+	PhaseHooks = "hooks"
+
+	// PhaseCleanup the phase after the secret is updated where the old webhooks are replaced
+	PhaseCleanup = "cleanup"
+
+	// RepositoryAdded the temporary webhook has been added to the repository
+	RepositoryAdded = "added"
+
+	// RepositoryDone the webhooks of the repository have been rotated
+	RepositoryDone = "done"
+
+	// ExternalSecretManagedLabel the label added by the external secrets operator to the secrets it manages
+	ExternalSecretManagedLabel = "reconcile.external-secrets.io/managed"
+
+	// ExternalSecretDataHashAnnotation the annotation added by the external secrets operator to the secrets it populates
+	ExternalSecretDataHashAnnotation = "reconcile.external-secrets.io/data-hash"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Rotates the HMAC token used by the webhooks of all the source repositories
+
+		The rotation keeps events flowing by:
+
+		* generating a new token which is stored in the secret as 'hmac-next'
+		* adding a temporary webhook using the new token to each repository alongside the existing webhook
+		* updating the 'hmac' token in the secret and restarting lighthouse so that it uses the new token
+		* removing the old webhooks of each repository and changing the temporary webhook to use the webhook URL
+
+		Changing the temporary webhook in place means there is never more than one webhook using the new token. If the git
+		provider does not support editing webhooks a new webhook is created before the temporary webhook is removed so any
+		events in between may be delivered twice.
+
+		If the secret is populated from an ExternalSecret the command fails as the token must be rotated in the backing
+		secret store instead.
+
+		The progress is recorded in annotations on the secret and SourceRepository resources so that if the command
+		is interrupted or any repositories fail running it again resumes the rotation. The secret is not updated until
+		every repository has a webhook using the new token.
+`)
+
+	cmdExample = templates.Examples(`
+		# rotates the HMAC token of all the webhooks
+		%s webhook rotate-hmac
+
+		# rotates the HMAC token using a custom webhook endpoint
+		%[1]s webhook rotate-hmac --endpoint http://mything.com
+`)
+)
+
+// Options the options for rotating the HMAC token
+type Options struct {
+	update.Options
+
+	Deployment     string
+	RolloutTimeout time.Duration
+}
+
+// NewCmdWebHookRotateHMAC creates the command
+func NewCmdWebHookRotateHMAC() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "rotate-hmac",
+		Short:   "Rotates the HMAC token used by the webhooks of all the source repositories",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Org, "owner", "o", "", "The name of the git organisation or user to filter on")
+	cmd.Flags().StringVarP(&o.Repo, "repo", "r", "", "The name of the repository to filter on")
+	cmd.Flags().BoolVarP(&o.ExactHookMatch, "exact-hook-url-match", "", true, "Whether to exactly match the hook based on the URL")
+	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Don't use the endpoint from the cluster, use the provided endpoint")
+	cmd.Flags().IntVarP(&o.Workers, "workers", "", 8, "The number of repositories to update in parallel")
+	cmd.Flags().Float64VarP(&o.RateLimit, "rate-limit", "", 0, "The maximum number of requests per second to each git server. If not specified a default for the kind of git provider is used")
+	cmd.Flags().IntVarP(&o.MaxRetries, "max-retries", "", 5, "The maximum number of times to retry a request if the git provider rate limits it")
+	cmd.Flags().StringVarP(&o.Deployment, "deployment", "", "lighthouse-webhooks", "The name of the lighthouse Deployment to restart after the secret is updated")
+	cmd.Flags().DurationVarP(&o.RolloutTimeout, "rollout-timeout", "", 5*time.Minute, "The maximum time to wait for the lighthouse Deployment to restart")
+
+	o.ScmClientFactory.AddFlags(cmd)
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+// Run rotates the HMAC token
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+	ctx := context.TODO()
+
+	secret, err := o.KubeClient.CoreV1().Secrets(o.Namespace).Get(ctx, update.LighthouseHMACToken, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find secret %s in namespace %s", update.LighthouseHMACToken, o.Namespace)
+	}
+	owner := ExternalSecretOwner(secret)
+	if owner != "" {
+		return errors.Errorf("secret %s in namespace %s is populated by ExternalSecret %s so any changes would be overwritten. Please rotate the HMAC token in the backing secret store instead", update.LighthouseHMACToken, o.Namespace, owner)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	token := string(secret.Data[NextHMACKey])
+	id, phase := splitRotation(secret.Annotations[RotationAnnotation])
+	if token == "" || id == "" {
+		token, err = GenerateToken()
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate HMAC token")
+		}
+		id = time.Now().UTC().Format("20060102150405")
+		phase = PhaseHooks
+		secret.Data[NextHMACKey] = []byte(token)
+		secret.Annotations[RotationAnnotation] = id + "/" + phase
+		secret, err = o.KubeClient.CoreV1().Secrets(o.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to save new HMAC token in secret %s", update.LighthouseHMACToken)
+		}
+		log.Logger().Infof("starting HMAC token rotation %s", info(id))
+	} else {
+		log.Logger().Infof("resuming HMAC token rotation %s from phase %s", info(id), info(phase))
+	}
+
+	repositories, err := o.findRepositories()
+	if err != nil {
+		return err
+	}
+	o.LoadSkipVerify()
+
+	if phase == PhaseHooks {
+		o.Results = o.ForEachRepository(repositories, func(sr *v1.SourceRepository) (string, error) {
+			return o.addRotationHook(sr, id, token)
+		})
+		err = o.ReportResults(o.Results)
+		if err != nil {
+			return errors.Wrapf(err, "the secret has not been updated so the existing webhooks still work. Please fix the failures and run the command again to resume")
+		}
+
+		secret.Data[HMACKey] = []byte(token)
+		secret.Annotations[RotationAnnotation] = id + "/" + PhaseCleanup
+		secret, err = o.KubeClient.CoreV1().Secrets(o.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update the HMAC token in secret %s", update.LighthouseHMACToken)
+		}
+		log.Logger().Infof("updated the HMAC token in secret %s", info(update.LighthouseHMACToken))
+
+		err = o.restartDeployment(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to restart Deployment %s. Please check lighthouse uses the new token then run the command again to resume", o.Deployment)
+		}
+	}
+
+	o.Results = o.ForEachRepository(repositories, func(sr *v1.SourceRepository) (string, error) {
+		return o.replaceHooks(sr, id, token)
+	})
+	err = o.ReportResults(o.Results)
+	if err != nil {
+		return errors.Wrapf(err, "please fix the failures and run the command again to resume")
+	}
+
+	delete(secret.Data, NextHMACKey)
+	delete(secret.Annotations, RotationAnnotation)
+	_, err = o.KubeClient.CoreV1().Secrets(o.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to complete rotation in secret %s", update.LighthouseHMACToken)
+	}
+	log.Logger().Infof("completed HMAC token rotation %s", info(id))
+	return nil
+}
+
+// GenerateToken generates a new random HMAC token
+func GenerateToken() (string, error) {
+	data := make([]byte, 20)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// ExternalSecretOwner returns the name of the ExternalSecret which populates the secret or blank if it is not
+// managed by an ExternalSecret
+func ExternalSecretOwner(secret *corev1.Secret) string {
+	for _, ref := range secret.OwnerReferences {
+		if ref.Kind == "ExternalSecret" {
+			return ref.Name
+		}
+	}
+	if secret.Labels[ExternalSecretManagedLabel] == "true" || secret.Annotations[ExternalSecretDataHashAnnotation] != "" {
+		return secret.Name
+	}
+	return ""
+}
+
+// RotationURL returns the URL of the temporary webhook used during a rotation
+func RotationURL(endpoint, id string) string {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + RotationQueryParameter + "=" + id
+}
+
+// findRepositories returns the repositories matching the filters which support HMAC tokens
+func (o *Options) findRepositories() ([]*v1.SourceRepository, error) {
+	srList, err := o.JXClient.JenkinsV1().SourceRepositories(o.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find any SourceRepositories in namespace %s", o.Namespace)
+	}
+	var answer []*v1.SourceRepository
+	for i := range srList.Items {
+		sr := &srList.Items[i]
+		// hmac isn't supported on bitbucketcloud
+		if sr.Spec.ProviderKind == "bitbucketcloud" || !o.MatchesRepository(sr) {
+			continue
+		}
+		answer = append(answer, sr)
+	}
+	return answer, nil
+}
+
+// addRotationHook adds a temporary webhook using the new token to the repository
+func (o *Options) addRotationHook(sr *v1.SourceRepository, id, token string) (string, error) {
+	state := sr.Annotations[RotationAnnotation]
+	if state == id+"/"+RepositoryAdded || state == id+"/"+RepositoryDone {
+		return update.StatusUnchanged, nil
+	}
+	server, err := o.GitServerFor(&sr.Spec)
+	if err != nil {
+		return update.StatusFailed, err
+	}
+	ctx := context.Background()
+	fullName := scm.Join(sr.Spec.Org, sr.Spec.Repo)
+	hooks, err := server.ListHooks(ctx, fullName)
+	if err != nil {
+		return update.StatusFailed, errors.Wrapf(err, "failed to list webhooks of repository %s", fullName)
+	}
+
+	rotationURL := RotationURL(o.Endpoint, id)
+	found := false
+	for _, hook := range hooks {
+		if hook.Target == rotationURL {
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = server.CreateHook(ctx, fullName, o.HookInput(rotationURL, token))
+		if err != nil {
+			return update.StatusFailed, err
+		}
+	}
+	o.annotate(sr, id+"/"+RepositoryAdded)
+	return update.StatusCreated, nil
+}
+
+// replaceHooks removes the old webhooks and changes the temporary webhook to use the webhook URL
+func (o *Options) replaceHooks(sr *v1.SourceRepository, id, token string) (string, error) {
+	if sr.Annotations[RotationAnnotation] == id+"/"+RepositoryDone {
+		return update.StatusUnchanged, nil
+	}
+	server, err := o.GitServerFor(&sr.Spec)
+	if err != nil {
+		return update.StatusFailed, err
+	}
+	ctx := context.Background()
+	fullName := scm.Join(sr.Spec.Org, sr.Spec.Repo)
+	hooks, err := server.ListHooks(ctx, fullName)
+	if err != nil {
+		return update.StatusFailed, errors.Wrapf(err, "failed to list webhooks of repository %s", fullName)
+	}
+
+	// the old webhooks use the old token which lighthouse no longer accepts so they can be removed first
+	rotationURL := RotationURL(o.Endpoint, id)
+	var rotationHooks []*scm.Hook
+	for _, hook := range hooks {
+		if hook.Target == rotationURL {
+			rotationHooks = append(rotationHooks, hook)
+			continue
+		}
+		if o.MatchesWebhookURL(hook, o.Endpoint, server.Kind) {
+			err = server.DeleteHook(ctx, fullName, hook)
+			if err != nil {
+				return update.StatusFailed, err
+			}
+		}
+	}
+
+	// lets change the temporary webhook in place so that only one webhook uses the new token at any time
+	input := o.HookInput(o.Endpoint, token)
+	updated := false
+	if len(rotationHooks) > 0 {
+		err = server.UpdateHook(ctx, fullName, rotationHooks[0], input)
+		switch {
+		case err == nil:
+			updated = true
+			rotationHooks = rotationHooks[1:]
+		case errors.Is(err, scm.ErrNotSupported):
+			log.Logger().Warnf("git provider %s cannot edit webhooks so events for repository %s may be delivered twice until the temporary webhook is removed", server.Kind, fullName)
+		default:
+			return update.StatusFailed, err
+		}
+	}
+	if !updated {
+		err = server.CreateHook(ctx, fullName, input)
+		if err != nil {
+			return update.StatusFailed, err
+		}
+	}
+	for _, hook := range rotationHooks {
+		err = server.DeleteHook(ctx, fullName, hook)
+		if err != nil {
+			return update.StatusFailed, err
+		}
+	}
+	if sr.Annotations == nil {
+		sr.Annotations = map[string]string{}
+	}
+	sr.Annotations[update.WebHookAnnotation] = "true"
+	sr.Annotations[update.WebHookConfigAnnotation] = update.HookFingerprint(input)
+	o.annotate(sr, id+"/"+RepositoryDone)
+	return update.StatusUpdated, nil
+}
+
+// annotate records the rotation state on the repository
+func (o *Options) annotate(sr *v1.SourceRepository, state string) {
+	if sr.Annotations == nil {
+		sr.Annotations = map[string]string{}
+	}
+	sr.Annotations[RotationAnnotation] = state
+	updated, err := o.JXClient.JenkinsV1().SourceRepositories(o.Namespace).Update(context.TODO(), sr, metav1.UpdateOptions{})
+	if err != nil {
+		log.Logger().Warnf("failed to annotate SourceRepository %s with the HMAC rotation state: %s", sr.Name, err.Error())
+		return
+	}
+	*sr = *updated
+}
+
+// restartDeployment restarts the lighthouse Deployment so that it uses the new token and waits for the rollout
+func (o *Options) restartDeployment(ctx context.Context) error {
+	if o.Deployment == "" {
+		return nil
+	}
+	deployments := o.KubeClient.AppsV1().Deployments(o.Namespace)
+	d, err := deployments.Get(ctx, o.Deployment, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Logger().Warnf("could not find Deployment %s in namespace %s so please make sure lighthouse uses the new HMAC token", o.Deployment, o.Namespace)
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get Deployment %s", o.Deployment)
+	}
+	if d.Spec.Template.Annotations == nil {
+		d.Spec.Template.Annotations = map[string]string{}
+	}
+	d.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().UTC().Format(time.RFC3339)
+	d, err = deployments.Update(ctx, d, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to restart Deployment %s", o.Deployment)
+	}
+	log.Logger().Infof("restarted Deployment %s so waiting for the rollout", info(o.Deployment))
+
+	generation := d.Generation
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, o.RolloutTimeout, true, func(ctx context.Context) (bool, error) {
+		d, err := deployments.Get(ctx, o.Deployment, metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get Deployment %s", o.Deployment)
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		s := d.Status
+		return s.ObservedGeneration >= generation && s.UpdatedReplicas == replicas && s.AvailableReplicas == replicas && s.UnavailableReplicas == 0, nil
+	})
+}
+
+func splitRotation(text string) (string, string) {
+	parts := strings.SplitN(text, "/", 2)
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
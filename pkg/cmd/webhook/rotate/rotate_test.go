@@ -0,0 +1,171 @@
+package rotate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/rotate"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/internal/fakescm"
+	"github.com/jenkins-x/go-scm/scm"
+	fakejx "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers/testjx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRotateHMAC(t *testing.T) {
+	ns := "jx"
+	owner := "myorg"
+	endpoint := "https://hook-jx.jx.example.com/hook"
+	oldToken := "oldtoken"
+	repoNames := []string{"repo1", "repo2", "repo3"}
+
+	kubeClient := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: update.LighthouseHMACToken, Namespace: ns},
+			Data:       map[string][]byte{rotate.HMACKey: []byte(oldToken)},
+		},
+	)
+	devEnv := jxenv.CreateDefaultDevEnvironment(ns)
+	devEnv.Namespace = ns
+	objects := []runtime.Object{devEnv}
+	for _, r := range repoNames {
+		objects = append(objects, testjx.CreateSourceRepository(ns, owner, r, "fake", "https://fake.git"))
+	}
+
+	// lets add a repository which fails as it has no git server
+	broken := testjx.CreateSourceRepository(ns, owner, "broken", "fake", "")
+	objects = append(objects, broken)
+	jxClient := fakejx.NewSimpleClientset(objects...)
+
+	_, o := rotate.NewCmdWebHookRotateHMAC()
+	o.Namespace = ns
+	o.KubeClient = kubeClient
+	o.JXClient = jxClient
+	o.Endpoint = endpoint
+	o.ScmClientFactory.GitToken = "dummytoken"
+	o.Workers = 2
+	o.RateLimit = 1000
+
+	// the fake driver is not safe for concurrent use so lets serialize the requests of the workers
+	sr := testjx.CreateSourceRepository(ns, owner, repoNames[0], "fake", "https://fake.git")
+	scmClient, _ := fakescm.NewSerialClient()
+	o.GitServers = map[string]*update.GitServer{
+		update.GitServerKey(&sr.Spec): {
+			Kind:    "fake",
+			Client:  scmClient,
+			Limiter: update.NewRateLimiter("fake", o.RateLimit, o.MaxRetries),
+		},
+	}
+
+	// lets create the existing webhooks using the old token
+	server, err := o.GitServerFor(&sr.Spec)
+	require.NoError(t, err, "failed to create git server")
+	ctx := context.Background()
+	for _, r := range repoNames {
+		err = server.CreateHook(ctx, scm.Join(owner, r), o.HookInput(endpoint, oldToken))
+		require.NoError(t, err, "failed to create hook")
+	}
+
+	err = o.Run()
+	require.Error(t, err, "should fail for the broken repository")
+
+	secret := getSecret(t, kubeClient, ns)
+	assert.Equal(t, oldToken, string(secret.Data[rotate.HMACKey]), "should not have updated the token")
+	newToken := string(secret.Data[rotate.NextHMACKey])
+	require.NotEmpty(t, newToken, "should have generated a new token")
+	rotation := secret.Annotations[rotate.RotationAnnotation]
+	require.True(t, strings.HasSuffix(rotation, "/"+rotate.PhaseHooks), "rotation annotation %s", rotation)
+	id := strings.TrimSuffix(rotation, "/"+rotate.PhaseHooks)
+
+	for _, r := range repoNames {
+		hooks := listHooks(t, server, owner, r)
+		assert.ElementsMatch(t, []string{endpoint, rotate.RotationURL(endpoint, id)}, hooks, "hooks for %s should include the old and temporary hooks", r)
+	}
+
+	// lets fix the broken repository and resume
+	err = jxClient.JenkinsV1().SourceRepositories(ns).Delete(ctx, broken.Name, metav1.DeleteOptions{})
+	require.NoError(t, err, "failed to delete broken SourceRepository")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to resume rotation")
+
+	secret = getSecret(t, kubeClient, ns)
+	assert.Equal(t, newToken, string(secret.Data[rotate.HMACKey]), "should have updated the token")
+	assert.Empty(t, secret.Data[rotate.NextHMACKey], "should have removed the next token")
+	assert.Empty(t, secret.Annotations[rotate.RotationAnnotation], "should have removed the rotation annotation")
+
+	for _, r := range repoNames {
+		hooks := listHooks(t, server, owner, r)
+		assert.Equal(t, []string{endpoint}, hooks, "hooks for %s", r)
+	}
+	for _, r := range o.Results {
+		assert.Equal(t, update.StatusUpdated, r.Status, "status for repository %s", r.Repository)
+	}
+
+	srList, err := jxClient.JenkinsV1().SourceRepositories(ns).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err, "failed to list SourceRepositories")
+	for i := range srList.Items {
+		item := &srList.Items[i]
+		assert.Equal(t, id+"/"+rotate.RepositoryDone, item.Annotations[rotate.RotationAnnotation], "annotation on %s", item.Name)
+	}
+}
+
+func TestRotateHMACExternalSecret(t *testing.T) {
+	ns := "jx"
+	oldToken := "oldtoken"
+	kubeClient := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      update.LighthouseHMACToken,
+				Namespace: ns,
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "external-secrets.io/v1beta1", Kind: "ExternalSecret", Name: update.LighthouseHMACToken},
+				},
+			},
+			Data: map[string][]byte{rotate.HMACKey: []byte(oldToken)},
+		},
+	)
+	devEnv := jxenv.CreateDefaultDevEnvironment(ns)
+	devEnv.Namespace = ns
+
+	_, o := rotate.NewCmdWebHookRotateHMAC()
+	o.Namespace = ns
+	o.KubeClient = kubeClient
+	o.JXClient = fakejx.NewSimpleClientset(devEnv)
+	o.Endpoint = "https://hook-jx.jx.example.com/hook"
+	o.ScmClientFactory.GitToken = "dummytoken"
+
+	err := o.Run()
+	require.Error(t, err, "should refuse to rotate a secret populated by an ExternalSecret")
+	assert.Contains(t, err.Error(), "backing secret store")
+
+	secret := getSecret(t, kubeClient, ns)
+	assert.Equal(t, oldToken, string(secret.Data[rotate.HMACKey]), "should not have updated the token")
+	assert.Empty(t, secret.Data[rotate.NextHMACKey], "should not have generated a new token")
+}
+
+func getSecret(t *testing.T, kubeClient *fake.Clientset, ns string) *corev1.Secret {
+	secret, err := kubeClient.CoreV1().Secrets(ns).Get(context.TODO(), update.LighthouseHMACToken, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get secret")
+	return secret
+}
+
+func listHooks(t *testing.T, server *update.GitServer, owner, repo string) []string {
+	hooks, err := server.ListHooks(context.Background(), scm.Join(owner, repo))
+	require.NoError(t, err, "failed to list hooks")
+	var answer []string
+	for _, h := range hooks {
+		answer = append(answer, h.Target)
+	}
+	return answer
+}
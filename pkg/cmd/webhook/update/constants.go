@@ -9,4 +9,8 @@ const (
 
 	// WebHookErrorAnnotation indicates an error to create a webhook
 	WebHookErrorAnnotation = "webhook.jenkins-x.io/error"
+
+	// WebHookConfigAnnotation the fingerprint of the events, TLS verification and HMAC token of the webhook which was
+	// created as git providers do not return the secret and report the events in their own format
+	WebHookConfigAnnotation = "webhook.jenkins-x.io/config"
 )
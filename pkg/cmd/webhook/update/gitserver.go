@@ -0,0 +1,119 @@
+package update
+
+import (
+	"context"
+	"io"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/pkg/errors"
+)
+
+// GitServer the client and rate limiter for a git server shared by the workers
+type GitServer struct {
+	Kind    string
+	Client  *scm.Client
+	Limiter *RateLimiter
+}
+
+// GitServerKey returns the key of the git server of the repository in the GitServers of the options
+func GitServerKey(spec *v1.SourceRepositorySpec) string {
+	return spec.ProviderKind + " " + spec.Provider
+}
+
+// GitServerFor returns the lazily created client and rate limiter for the git server of the repository
+func (o *Options) GitServerFor(spec *v1.SourceRepositorySpec) (*GitServer, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	key := GitServerKey(spec)
+	server := o.GitServers[key]
+	if server != nil {
+		return server, nil
+	}
+
+	o.ScmClientFactory.GitServerURL = spec.Provider
+	o.ScmClientFactory.GitKind = spec.ProviderKind
+	scmClient, err := o.ScmClientFactory.Create()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Scm client for %s", spec.URL)
+	}
+	kind := o.ScmClientFactory.GitKind
+	server = &GitServer{
+		Kind:    kind,
+		Client:  scmClient,
+		Limiter: NewRateLimiter(kind, o.RateLimit, o.MaxRetries),
+	}
+	if o.GitServers == nil {
+		o.GitServers = map[string]*GitServer{}
+	}
+	o.GitServers[key] = server
+	return server, nil
+}
+
+// Do invokes the request to the git server
+func (s *GitServer) Do(ctx context.Context, fn func() (*scm.Response, error)) error {
+	return s.Limiter.Do(ctx, fn)
+}
+
+// ListHooks lists the webhooks of the repository
+func (s *GitServer) ListHooks(ctx context.Context, fullName string) ([]*scm.Hook, error) {
+	var hooks []*scm.Hook
+	err := s.Do(ctx, func() (*scm.Response, error) {
+		var resp *scm.Response
+		var err error
+		hooks, resp, err = s.Client.Repositories.ListHooks(ctx, fullName, &scm.ListOptions{})
+		return resp, err
+	})
+	return hooks, err
+}
+
+// CreateHook creates a webhook on the repository
+func (s *GitServer) CreateHook(ctx context.Context, fullName string, input *scm.HookInput) error {
+	var resp *scm.Response
+	err := s.Do(ctx, func() (*scm.Response, error) {
+		var err error
+		_, resp, err = s.Client.Repositories.CreateHook(ctx, fullName, input)
+		return resp, err
+	})
+	if err != nil {
+		message := ""
+		if resp != nil && resp.Body != nil {
+			body, err := io.ReadAll(resp.Body)
+			if err == nil && body != nil {
+				message = " " + string(body)
+			}
+		}
+		return errors.Wrapf(err, "failed to create webhook %q on repository '%s'%s", input.Target, fullName, message)
+	}
+	return nil
+}
+
+// UpdateHook changes the target, secret and events of an existing webhook on the repository.
+// Returns scm.ErrNotSupported if the git provider cannot edit webhooks
+func (s *GitServer) UpdateHook(ctx context.Context, fullName string, hook *scm.Hook, input *scm.HookInput) error {
+	in := *input
+	in.Name = hook.ID
+	err := s.Do(ctx, func() (*scm.Response, error) {
+		_, resp, err := s.Client.Repositories.UpdateHook(ctx, fullName, &in)
+		return resp, err
+	})
+	if err != nil {
+		if errors.Is(err, scm.ErrNotSupported) {
+			return err
+		}
+		return errors.Wrapf(err, "failed to update webhook %s with target %s", hook.ID, hook.Target)
+	}
+	return nil
+}
+
+// DeleteHook deletes the webhook from the repository
+func (s *GitServer) DeleteHook(ctx context.Context, fullName string, hook *scm.Hook) error {
+	err := s.Do(ctx, func() (*scm.Response, error) {
+		return s.Client.Repositories.DeleteHook(ctx, fullName, hook.ID)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete webhook %s with target %s", hook.ID, hook.Target)
+	}
+	return nil
+}
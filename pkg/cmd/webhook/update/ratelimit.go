@@ -0,0 +1,116 @@
+package update
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// DefaultRequestsPerSecond the default maximum requests per second for each kind of git provider
+	DefaultRequestsPerSecond = map[string]float64{
+		"github":          5,
+		"gitlab":          10,
+		"gitea":           10,
+		"bitbucketserver": 5,
+		"bitbucketcloud":  2,
+	}
+
+	// FallbackRequestsPerSecond the maximum requests per second for git providers not in DefaultRequestsPerSecond
+	FallbackRequestsPerSecond = 5.0
+
+	// MaxBackoff the maximum time to wait before retrying a rate limited request
+	MaxBackoff = 5 * time.Minute
+)
+
+// RateLimiter limits the requests to a git provider and retries requests with a backoff if the provider rate limits them
+type RateLimiter struct {
+	Limiter    *rate.Limiter
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// NewRateLimiter creates a rate limiter for the kind of git provider. If requestsPerSecond is zero the default
+// for the kind of provider is used
+func NewRateLimiter(gitKind string, requestsPerSecond float64, maxRetries int) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = DefaultRequestsPerSecond[gitKind]
+		if requestsPerSecond <= 0 {
+			requestsPerSecond = FallbackRequestsPerSecond
+		}
+	}
+	burst := int(math.Ceil(requestsPerSecond))
+	return &RateLimiter{
+		Limiter:    rate.NewLimiter(rate.Limit(requestsPerSecond), burst),
+		MaxRetries: maxRetries,
+		Backoff:    time.Second,
+	}
+}
+
+// Do invokes the request when the rate limit allows, retrying with a backoff if the provider rate limits it
+func (r *RateLimiter) Do(ctx context.Context, fn func() (*scm.Response, error)) error {
+	for attempt := 0; ; attempt++ {
+		if r.Limiter != nil {
+			err := r.Limiter.Wait(ctx)
+			if err != nil {
+				return errors.Wrapf(err, "failed to wait for rate limiter")
+			}
+		}
+		resp, err := fn()
+		if err == nil || !IsRateLimited(resp, err) || attempt >= r.MaxRetries {
+			return err
+		}
+
+		delay := r.retryDelay(resp, attempt)
+		log.Logger().Warnf("git provider rate limited the request so retrying in %s: %s", delay.String(), err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// IsRateLimited returns true if the response or error indicates the git provider rate limited the request
+func IsRateLimited(resp *scm.Response, err error) bool {
+	if resp != nil {
+		switch resp.Status {
+		case http.StatusTooManyRequests:
+			return true
+		case http.StatusForbidden:
+			// 403 is also used for permission errors so only treat it as rate limiting if the provider says so
+			if resp.Header.Get("Retry-After") != "" || (resp.Rate.Limit > 0 && resp.Rate.Remaining == 0) {
+				return true
+			}
+		}
+	}
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "rate limit")
+}
+
+// retryDelay returns how long to wait before retrying using the Retry-After header or rate limit reset time
+// if the provider returns them, otherwise an exponential backoff
+func (r *RateLimiter) retryDelay(resp *scm.Response, attempt int) time.Duration {
+	var delay time.Duration
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			delay = time.Duration(seconds) * time.Second
+		} else if resp.Rate.Reset > 0 {
+			delay = time.Until(time.Unix(resp.Rate.Reset, 0))
+		}
+	}
+	if delay <= 0 {
+		delay = r.Backoff * time.Duration(1<<uint(attempt))
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+	return delay
+}
@@ -2,9 +2,13 @@ package update
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/go-scm/scm"
@@ -19,6 +23,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
@@ -46,6 +51,40 @@ type Options struct {
 	KubeClient       kubernetes.Interface
 	JXClient         jxc.Interface
 	Fast             bool
+	Force            bool
+	Workers          int
+	RateLimit        float64
+	MaxRetries       int
+	Results          []*Result
+	Out              io.Writer
+
+	// GitServers the lazily created clients and rate limiters for each git server keyed by GitServerKey
+	GitServers map[string]*GitServer
+
+	lock       sync.Mutex
+	skipVerify bool
+}
+
+const (
+	// StatusCreated a webhook was created as the repository had no matching webhook
+	StatusCreated = "created"
+
+	// StatusUpdated any matching webhooks were replaced with a new webhook
+	StatusUpdated = "updated"
+
+	// StatusUnchanged the repository already has the webhook or was skipped as it is already annotated as having a
+	// webhook when using --fast
+	StatusUnchanged = "unchanged"
+
+	// StatusFailed the webhook could not be updated
+	StatusFailed = "failed"
+)
+
+// Result the result of reconciling the webhook of a repository
+type Result struct {
+	Repository string
+	Status     string
+	Error      string
 }
 
 var (
@@ -54,6 +93,13 @@ var (
 	cmdLong = templates.LongDesc(`
 		Updates the webhooks for all the source repositories optionally filtering by owner and/or repository
 
+		The repositories are reconciled in parallel by a pool of workers. Requests to each git server are rate limited
+		and are retried with a backoff if the git provider rate limits them. A summary of the repositories
+		created, updated, unchanged and failed is displayed at the end.
+
+		A repository is unchanged if it has a single webhook with the URL and the events, TLS verification and HMAC
+		token recorded on the SourceRepository match. Use --force to recreate the webhooks anyway.
+
 `)
 
 	cmdExample = templates.Examples(`
@@ -66,6 +112,9 @@ var (
 		# use a custom hook webhook endpoint (e.g. if you are on premise using node ports or something)
 		%[1]s update --endpoint http://mything.com
 
+		# use more workers with a lower rate limit of requests per second to each git server
+		%[1]s update --workers 20 --rate-limit 2
+
 `)
 )
 
@@ -90,6 +139,10 @@ func NewCmdWebHookVerify() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Don't use the endpoint from the cluster, use the provided endpoint")
 	cmd.Flags().BoolVarP(&o.WarnOnFail, "warn-on-fail", "", false, "If enabled lets just log a warning that we could not update the webhook")
 	cmd.Flags().BoolVarP(&o.Fast, "fast", "", false, "If annotation webhook.jenkins-x.io is true on SourceConfig don't check with git provider")
+	cmd.Flags().BoolVarP(&o.Force, "force", "", false, "Recreates the webhooks even if they are unchanged")
+	cmd.Flags().IntVarP(&o.Workers, "workers", "", 8, "The number of repositories to update in parallel")
+	cmd.Flags().Float64VarP(&o.RateLimit, "rate-limit", "", 0, "The maximum number of requests per second to each git server. If not specified a default for the kind of git provider is used")
+	cmd.Flags().IntVarP(&o.MaxRetries, "max-retries", "", 5, "The maximum number of times to retry a request if the git provider rate limits it")
 
 	o.ScmClientFactory.AddFlags(cmd)
 	o.BaseOptions.AddBaseFlags(cmd)
@@ -140,9 +193,15 @@ func (o *Options) Run() error {
 		return errors.Wrapf(err, "failed to find any SourceRepositories in namespace %s", ns)
 	}
 
+	o.Results = nil
+	var repositories []*v1.SourceRepository
 	for k := range srList.Items {
-		sourceRepo := srList.Items[k]
+		sourceRepo := &srList.Items[k]
+		if !o.MatchesRepository(sourceRepo) {
+			continue
+		}
 		if o.Fast && sourceRepo.Annotations != nil && sourceRepo.Annotations[WebHookAnnotation] == "true" {
+			o.Results = append(o.Results, &Result{Repository: scm.Join(sourceRepo.Spec.Org, sourceRepo.Spec.Repo), Status: StatusUnchanged})
 			continue
 		}
 
@@ -153,11 +212,90 @@ func (o *Options) Run() error {
 				return errors.Wrapf(err, "failed to find hmac token from secret")
 			}
 		}
+		repositories = append(repositories, sourceRepo)
+	}
 
-		_, err2 := o.UpdateWebhookForSourceRepository(&sourceRepo, o.Endpoint, o.HMAC)
-		if err2 != nil {
-			return err2
+	o.LoadSkipVerify()
+
+	o.Results = append(o.Results, o.ForEachRepository(repositories, func(sr *v1.SourceRepository) (string, error) {
+		return o.ensureWebHookCreated(sr, o.Endpoint, o.HMAC)
+	})...)
+	return o.ReportResults(o.Results)
+}
+
+// LoadSkipVerify loads the requirements from the current directory to determine if webhooks should skip TLS verification
+func (o *Options) LoadSkipVerify() {
+	o.skipVerify = false
+	requirements, _, err := jxcore.LoadRequirementsConfig("", false)
+	if err != nil {
+		log.Logger().Warnf("unable to load requirements from the local directory so defaulting skipVerify option on the webhook to false")
+	}
+	if requirements != nil && requirements.Spec.Ingress.TLS != nil {
+		o.skipVerify = !requirements.Spec.Ingress.TLS.Production
+	}
+}
+
+// ForEachRepository invokes the function on each repository using a pool of workers returning the results
+func (o *Options) ForEachRepository(repositories []*v1.SourceRepository, fn func(sr *v1.SourceRepository) (string, error)) []*Result {
+	results := make([]*Result, len(repositories))
+	RunWorkers(o.Workers, len(repositories), func(i int) {
+		sr := repositories[i]
+		result := &Result{Repository: scm.Join(sr.Spec.Org, sr.Spec.Repo)}
+		status, err := fn(sr)
+		if err != nil {
+			log.Logger().Warn(err.Error())
+			status = StatusFailed
+			result.Error = err.Error()
 		}
+		result.Status = status
+		results[i] = result
+	})
+	return results
+}
+
+// RunWorkers invokes the function for each index from 0 to count using the given number of workers in parallel
+func RunWorkers(workers, count int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// ReportResults displays a summary of the results and returns an error if any repositories failed
+func (o *Options) ReportResults(results []*Result) error {
+	counts := map[string]int{}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	t := table.CreateTable(o.Out)
+	t.AddRow("REPOSITORY", "STATUS", "ERROR")
+	for _, r := range results {
+		counts[r.Status]++
+		t.AddRow(r.Repository, r.Status, r.Error)
+	}
+	t.Render()
+
+	log.Logger().Infof("webhooks created: %s updated: %s unchanged: %s failed: %s",
+		info(counts[StatusCreated]), info(counts[StatusUpdated]), info(counts[StatusUnchanged]), info(counts[StatusFailed]))
+
+	failed := counts[StatusFailed]
+	if failed > 0 && !o.WarnOnFail {
+		return errors.Errorf("failed to update the webhooks of %d of %d repositories", failed, len(results))
 	}
 	return nil
 }
@@ -198,10 +336,10 @@ func (o *Options) GetHMACTokenFromSecret() (string, error) {
 
 // UpdateWebhookForSourceRepository updates the webhook for the given source repository
 func (o *Options) UpdateWebhookForSourceRepository(sr *v1.SourceRepository, webhookURL, hmacToken string) (bool, error) {
-	if !o.matchesRepository(sr) {
+	if !o.MatchesRepository(sr) {
 		return false, nil
 	}
-	err := o.ensureWebHookCreated(sr, webhookURL, hmacToken)
+	_, err := o.ensureWebHookCreated(sr, webhookURL, hmacToken)
 	if err != nil {
 		if !o.WarnOnFail {
 			return false, err
@@ -211,18 +349,15 @@ func (o *Options) UpdateWebhookForSourceRepository(sr *v1.SourceRepository, webh
 	return true, nil
 }
 
-func (o *Options) ensureWebHookCreated(repository *v1.SourceRepository, webhookURL, hmacToken string) error {
+func (o *Options) ensureWebHookCreated(repository *v1.SourceRepository, webhookURL, hmacToken string) (string, error) {
 	spec := repository.Spec
 	gitServerURL := spec.Provider
 	owner := spec.Org
 	repo := spec.Repo
 
-	o.ScmClientFactory.GitServerURL = gitServerURL
-	o.ScmClientFactory.GitKind = spec.ProviderKind
-
-	scmClient, err := o.ScmClientFactory.Create()
+	server, err := o.GitServerFor(&spec)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create Scm client for %s", spec.URL)
+		return StatusFailed, err
 	}
 
 	srInterface := o.JXClient.JenkinsV1().SourceRepositories(o.Namespace)
@@ -240,47 +375,87 @@ func (o *Options) ensureWebHookCreated(repository *v1.SourceRepository, webhookU
 		}
 	}
 
+	previousConfig := repository.Annotations[WebHookConfigAnnotation]
 	repository.Annotations[WebHookAnnotation] = "creating"
 	annotate()
 
-	err = o.updateRepositoryWebhook(scmClient, owner, repo, webhookURL, hmacToken)
+	input := o.HookInput(webhookURL, hmacToken)
+	config := HookFingerprint(input)
+	// a repository without a fingerprint has a webhook created before fingerprints were recorded so lets adopt
+	// any matching webhook and record its fingerprint rather than replacing it
+	configMatches := previousConfig == "" || previousConfig == config
+	status, err := o.updateRepositoryWebhook(server, owner, repo, input, configMatches)
 	if err != nil {
 		repository.Annotations[WebHookAnnotation] = "failed"
 		repository.Annotations[WebHookErrorAnnotation] = err.Error()
 		annotate()
-		return errors.Wrapf(err, "failed to update webhooks for Owner: %s and Repository: %s in git server: %s", owner, repo, gitServerURL)
+		return StatusFailed, errors.Wrapf(err, "failed to update webhooks for Owner: %s and Repository: %s in git server: %s", owner, repo, gitServerURL)
 	}
 
 	repository.Annotations[WebHookAnnotation] = "true"
+	repository.Annotations[WebHookConfigAnnotation] = config
+	delete(repository.Annotations, WebHookErrorAnnotation)
 	annotate()
-	return err
+	return status, nil
 }
 
-func (o *Options) updateRepositoryWebhook(scmClient *scm.Client, owner, repoName, webhookURL, hmacToken string) error {
+// updateRepositoryWebhook replaces any matching webhooks with a new webhook unless the repository already has the
+// webhook. The configMatches flag indicates the events, TLS verification and HMAC token are the same as when the
+// webhook was last created
+func (o *Options) updateRepositoryWebhook(server *GitServer, owner, repoName string, input *scm.HookInput, configMatches bool) (string, error) {
 	fullName := scm.Join(owner, repoName)
 
 	log.Logger().Debugf("Checking hooks for repository %s", info(fullName))
 
 	ctx := context.Background()
-	hooks, _, err := scmClient.Repositories.ListHooks(ctx, fullName, &scm.ListOptions{})
+	hooks, err := server.ListHooks(ctx, fullName)
 	if err != nil {
 		if !scmhelpers.IsScmNotFound(err) {
 			log.Logger().Warnf("failed to find hooks for repository %s: %s", info(fullName), err.Error())
 		}
 	}
 
-	skipVerify := false
-	requirements, _, err := jxcore.LoadRequirementsConfig("", false)
-	if err != nil {
-		log.Logger().Warnf("unable to load requirements from the local directory so defaulting skipVerify option on the webhook to false")
+	var matching []*scm.Hook
+	for _, hook := range hooks {
+		if o.MatchesWebhookURL(hook, input.Target, server.Kind) {
+			matching = append(matching, hook)
+		}
 	}
-	if requirements != nil {
-		if requirements.Spec.Ingress.TLS != nil {
-			skipVerify = !requirements.Spec.Ingress.TLS.Production
+	if !o.Force && configMatches && len(matching) == 1 && matching[0].Target == input.Target && matching[0].Active {
+		log.Logger().Debugf("repository %s already has the webhook %s", info(fullName), info(input.Target))
+		return StatusUnchanged, nil
+	}
+
+	// lets remove any previous matching hooks
+	status := StatusCreated
+	for _, hook := range matching {
+		log.Logger().Infof("repository %s has hook for url %s", info(fullName), info(hook.Target))
+		err = server.DeleteHook(ctx, fullName, hook)
+		if err != nil {
+			return StatusFailed, err
 		}
+		status = StatusUpdated
 	}
 
-	webHookArgs := &scm.HookInput{
+	// lets create a new webhook...
+	err = server.CreateHook(ctx, fullName, input)
+	if err != nil {
+		return StatusFailed, err
+	}
+	return status, nil
+}
+
+// HookFingerprint returns a fingerprint of the events, TLS verification and HMAC token of the webhook input so
+// that we can detect when a webhook needs to be recreated without storing the token
+func HookFingerprint(input *scm.HookInput) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%+v\n%v\n%t\n%s", input.Events, input.NativeEvents, input.SkipVerify, input.Secret)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// HookInput creates the input to create a webhook for the URL and HMAC token
+func (o *Options) HookInput(webhookURL, hmacToken string) *scm.HookInput {
+	return &scm.HookInput{
 		Name:   "",
 		Target: webhookURL,
 		Secret: hmacToken,
@@ -298,45 +473,17 @@ func (o *Options) updateRepositoryWebhook(scmClient *scm.Client, owner, repoName
 			ReviewComment:      true,
 			Tag:                true,
 		},
-		SkipVerify:   skipVerify,
+		SkipVerify:   o.skipVerify,
 		NativeEvents: nil,
 	}
-
-	// now lets remove any old ones
-	if len(hooks) > 0 {
-		// lets remove any previous matching hooks
-		for _, hook := range hooks {
-			if o.matchesWebhookURL(hook, webhookURL) {
-				// lets remove any old ones
-				log.Logger().Infof("repository %s has hook for url %s", info(fullName), info(hook.Target))
-				_, err = scmClient.Repositories.DeleteHook(ctx, fullName, hook.ID)
-				if err != nil {
-					return errors.Wrapf(err, "failed to delete webhook %s with target %s", hook.ID, hook.Target)
-				}
-			}
-		}
-	}
-
-	// lets create a new webhook...
-	_, resp, err := scmClient.Repositories.CreateHook(ctx, fullName, webHookArgs)
-	if err != nil {
-		status := ""
-		if resp != nil && resp.Body != nil {
-			body, err := io.ReadAll(resp.Body)
-			if err == nil && body != nil {
-				status = " " + string(body)
-			}
-		}
-		return errors.Wrapf(err, "failed to create webhook %q on repository '%s'%s", webhookURL, fullName, status)
-	}
-	return nil
 }
 
-func (o *Options) matchesWebhookURL(webHookArgs *scm.Hook, webhookURL string) bool {
+// MatchesWebhookURL returns true if the hook matches the webhook URL and so should be replaced
+func (o *Options) MatchesWebhookURL(webHookArgs *scm.Hook, webhookURL, gitKind string) bool {
 	if o.PreviousHookURL != "" {
 		return o.PreviousHookURL == webHookArgs.Target
 	}
-	if o.ScmClientFactory.GitKind == "gitlab" || o.ScmClientFactory.GitKind == "gitea" {
+	if gitKind == "gitlab" || gitKind == "gitea" {
 		return strings.HasPrefix(webHookArgs.Target, webhookURL)
 	}
 	if o.ExactHookMatch {
@@ -345,8 +492,8 @@ func (o *Options) matchesWebhookURL(webHookArgs *scm.Hook, webhookURL string) bo
 	return strings.Contains(webHookArgs.Target, "hook")
 }
 
-// matchesRepository returns true if the given source repository matchesWebhookURL the current filters
-func (o *Options) matchesRepository(repository *v1.SourceRepository) bool {
+// MatchesRepository returns true if the given source repository matches the current filters
+func (o *Options) MatchesRepository(repository *v1.SourceRepository) bool {
 	if o.Org != "" && o.Org != repository.Spec.Org {
 		return false
 	}
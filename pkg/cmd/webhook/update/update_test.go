@@ -1,22 +1,31 @@
 package update_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/internal/fakescm"
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 
 	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
 	fakejx "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/boot"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers/testjx"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
 	"sigs.k8s.io/yaml"
 )
@@ -27,7 +36,196 @@ func TestWebhookVerify(t *testing.T) {
 	repo := "myrepo"
 	fullName := scm.Join(owner, repo)
 
-	kubeClient := fake.NewSimpleClientset(
+	kubeClient := newFakeKubeClient(ns)
+	devEnv := newDevEnvironment(t, ns)
+	sr := testjx.CreateSourceRepository(ns, owner, repo, "fake", "https://fake.git")
+
+	jxClient := fakejx.NewSimpleClientset(devEnv, sr)
+
+	_, o := update.NewCmdWebHookVerify()
+	o.Namespace = ns
+	o.KubeClient = kubeClient
+	o.JXClient = jxClient
+	o.ScmClientFactory.GitToken = "dummytoken"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	hooks, _, err := o.ScmClientFactory.ScmClient.Repositories.ListHooks(context.Background(), fullName, &scm.ListOptions{})
+	require.NoError(t, err, "failed listing webhooks for repo %s", fullName)
+	require.NotEmpty(t, hooks, "should have created a webbook for repository %s", fullName)
+
+	for _, h := range hooks {
+		t.Logf("found hook %s for %s with events %#v\n", h.ID, h.Target, h.Events)
+	}
+
+	sr, err = jxClient.JenkinsV1().SourceRepositories(ns).Get(context.TODO(), sr.Name, metav1.GetOptions{})
+	require.NoError(t, err, "failed to lookup SourceRepository %s", sr.Name)
+	testhelpers.AssertAnnotation(t, update.WebHookAnnotation, "true", sr.ObjectMeta, "for SourceRepository: "+sr.Name)
+	t.Logf("SourceRepository %s has annotation %s = %s\n", sr.Name, update.WebHookAnnotation, sr.Annotations[update.WebHookAnnotation])
+}
+
+func TestWebhookUpdateParallel(t *testing.T) {
+	ns := "jx"
+	owner := "myorg"
+	repoCount := 10
+
+	objects := []runtime.Object{newDevEnvironment(t, ns)}
+	for i := 0; i < repoCount; i++ {
+		objects = append(objects, testjx.CreateSourceRepository(ns, owner, fmt.Sprintf("repo%d", i), "fake", "https://fake.git"))
+	}
+	jxClient := fakejx.NewSimpleClientset(objects...)
+
+	// the fake driver is not safe for concurrent use so lets serialize the requests of the workers
+	scmClient, _ := fakescm.NewSerialClient()
+
+	_, o := update.NewCmdWebHookVerify()
+	o.Namespace = ns
+	o.KubeClient = newFakeKubeClient(ns)
+	o.JXClient = jxClient
+	o.ScmClientFactory.GitToken = "dummytoken"
+	o.GitServers = map[string]*update.GitServer{
+		update.GitServerKey(&objects[1].(*v1.SourceRepository).Spec): {
+			Kind:    "fake",
+			Client:  scmClient,
+			Limiter: update.NewRateLimiter("fake", 1000, 3),
+		},
+	}
+	o.Workers = 4
+	o.RateLimit = 1000
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assertResults(t, o.Results, repoCount, update.StatusCreated)
+	assert.Contains(t, out.String(), scm.Join(owner, "repo0"), "should have written the results table")
+
+	// lets run again which should leave the identical hooks alone
+	err = o.Run()
+	require.NoError(t, err, "failed to run again")
+	assertResults(t, o.Results, repoCount, update.StatusUnchanged)
+
+	// changing the HMAC token should replace the existing hooks
+	o.HMAC = "newtoken"
+	err = o.Run()
+	require.NoError(t, err, "failed to run with a new token")
+	assertResults(t, o.Results, repoCount, update.StatusUpdated)
+
+	hooks, _, err := scmClient.Repositories.ListHooks(context.Background(), scm.Join(owner, "repo0"), &scm.ListOptions{})
+	require.NoError(t, err, "failed listing webhooks")
+	assert.Len(t, hooks, 1, "should have replaced the webhook")
+
+	// a repository without a fingerprint should adopt the existing webhook and record its fingerprint
+	sr, err := jxClient.JenkinsV1().SourceRepositories(ns).Get(context.TODO(), objects[1].(*v1.SourceRepository).Name, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get SourceRepository")
+	fingerprint := sr.Annotations[update.WebHookConfigAnnotation]
+	require.NotEmpty(t, fingerprint, "should have recorded the fingerprint")
+	delete(sr.Annotations, update.WebHookConfigAnnotation)
+	_, err = jxClient.JenkinsV1().SourceRepositories(ns).Update(context.TODO(), sr, metav1.UpdateOptions{})
+	require.NoError(t, err, "failed to update SourceRepository")
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run without a fingerprint")
+	assertResults(t, o.Results, repoCount, update.StatusUnchanged)
+	sr, err = jxClient.JenkinsV1().SourceRepositories(ns).Get(context.TODO(), sr.Name, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get SourceRepository")
+	testhelpers.AssertAnnotation(t, update.WebHookConfigAnnotation, fingerprint, sr.ObjectMeta, "for SourceRepository: "+sr.Name)
+
+	// with --force the hooks should be replaced even though they are unchanged
+	o.Force = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run with force")
+	assertResults(t, o.Results, repoCount, update.StatusUpdated)
+	o.Force = false
+
+	// with --fast the annotated repositories should be skipped
+	o.Fast = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run with fast")
+	assertResults(t, o.Results, repoCount, update.StatusUnchanged)
+
+	// lets add a repository which fails as it has no git server
+	broken := testjx.CreateSourceRepository(ns, owner, "broken", "fake", "")
+	_, err = jxClient.JenkinsV1().SourceRepositories(ns).Create(context.TODO(), broken, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create SourceRepository")
+
+	err = o.Run()
+	require.Error(t, err, "should have failed for the broken repository")
+	require.Len(t, o.Results, repoCount+1)
+	failed := 0
+	for _, r := range o.Results {
+		if r.Status == update.StatusFailed {
+			failed++
+			assert.Equal(t, scm.Join(owner, "broken"), r.Repository)
+			assert.NotEmpty(t, r.Error, "should have an error message")
+		}
+	}
+	assert.Equal(t, 1, failed, "failed repositories")
+
+	o.WarnOnFail = true
+	err = o.Run()
+	require.NoError(t, err, "should not fail with --warn-on-fail")
+}
+
+func TestRateLimiterRetries(t *testing.T) {
+	limiter := update.NewRateLimiter("github", 1000, 3)
+	limiter.Backoff = time.Millisecond
+
+	calls := 0
+	err := limiter.Do(context.Background(), func() (*scm.Response, error) {
+		calls++
+		if calls < 3 {
+			return &scm.Response{Status: http.StatusTooManyRequests}, errors.New("too many requests")
+		}
+		return &scm.Response{Status: http.StatusOK}, nil
+	})
+	require.NoError(t, err, "should have succeeded after retrying")
+	assert.Equal(t, 3, calls)
+
+	// a permission error should not be retried
+	calls = 0
+	err = limiter.Do(context.Background(), func() (*scm.Response, error) {
+		calls++
+		return &scm.Response{Status: http.StatusForbidden}, errors.New("forbidden")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// a 403 with no remaining rate should be retried until the max retries
+	calls = 0
+	err = limiter.Do(context.Background(), func() (*scm.Response, error) {
+		calls++
+		resp := &scm.Response{Status: http.StatusForbidden}
+		resp.Rate.Limit = 5000
+		return resp, errors.New("API rate limit exceeded")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func assertResults(t *testing.T, results []*update.Result, expectedCount int, expectedStatus string) {
+	require.Len(t, results, expectedCount)
+	for _, r := range results {
+		assert.Equal(t, expectedStatus, r.Status, "status for repository %s", r.Repository)
+	}
+}
+
+func newDevEnvironment(t *testing.T, ns string) *v1.Environment {
+	requirements := jxcore.NewRequirementsConfig()
+	requirements.Spec.Cluster.ChartRepository = "http://bucketrepo/bucketrepo/charts/"
+	data, err := yaml.Marshal(requirements)
+	require.NoError(t, err, "failed to marshal requirements")
+
+	devEnv := jxenv.CreateDefaultDevEnvironment(ns)
+	devEnv.Namespace = ns
+	devEnv.Spec.Source.URL = "https://github.com/myorg/myrepo.git"
+	devEnv.Spec.TeamSettings.BootRequirements = string(data)
+	return devEnv
+}
+
+func newFakeKubeClient(ns string) *fake.Clientset {
+	return fake.NewSimpleClientset(
 		&corev1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: ns,
@@ -65,40 +263,4 @@ func TestWebhookVerify(t *testing.T) {
 			},
 		},
 	)
-
-	requirements := jxcore.NewRequirementsConfig()
-	requirements.Spec.Cluster.ChartRepository = "http://bucketrepo/bucketrepo/charts/"
-	data, err := yaml.Marshal(requirements)
-	require.NoError(t, err, "failed to marshal requirements")
-
-	devEnv := jxenv.CreateDefaultDevEnvironment(ns)
-	devEnv.Namespace = ns
-	devEnv.Spec.Source.URL = "https://github.com/myorg/myrepo.git"
-	devEnv.Spec.TeamSettings.BootRequirements = string(data)
-
-	sr := testjx.CreateSourceRepository(ns, owner, repo, "fake", "https://fake.git")
-
-	jxClient := fakejx.NewSimpleClientset(devEnv, sr)
-
-	_, o := update.NewCmdWebHookVerify()
-	o.Namespace = ns
-	o.KubeClient = kubeClient
-	o.JXClient = jxClient
-	o.ScmClientFactory.GitToken = "dummytoken"
-
-	err = o.Run()
-	require.NoError(t, err, "failed to run")
-
-	hooks, _, err := o.ScmClientFactory.ScmClient.Repositories.ListHooks(context.Background(), fullName, &scm.ListOptions{})
-	require.NoError(t, err, "failed listing webhooks for repo %s", fullName)
-	require.NotEmpty(t, hooks, "should have created a webbook for repository %s", fullName)
-
-	for _, h := range hooks {
-		t.Logf("found hook %s for %s with events %#v\n", h.ID, h.Target, h.Events)
-	}
-
-	sr, err = jxClient.JenkinsV1().SourceRepositories(ns).Get(context.TODO(), sr.Name, metav1.GetOptions{})
-	require.NoError(t, err, "failed to lookup SourceRepository %s", sr.Name)
-	testhelpers.AssertAnnotation(t, update.WebHookAnnotation, "true", sr.ObjectMeta, "for SourceRepository: "+sr.Name)
-	t.Logf("SourceRepository %s has annotation %s = %s\n", sr.Name, update.WebHookAnnotation, sr.Annotations[update.WebHookAnnotation])
 }
@@ -0,0 +1,174 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+// RequiredEvents the events each kind of git provider must send to lighthouse as reported by the go-scm hook
+var RequiredEvents = map[string][]string{
+	"github": {"push", "pull_request", "issue_comment"},
+	"gitlab": {"push", "merge", "comment"},
+}
+
+// Delivery a recent delivery of a webhook
+type Delivery struct {
+	ID          string    `json:"id"`
+	Event       string    `json:"event,omitempty"`
+	StatusCode  int       `json:"statusCode"`
+	DeliveredAt time.Time `json:"deliveredAt"`
+}
+
+// Failed returns true if the delivery failed
+func (d *Delivery) Failed() bool {
+	return d.StatusCode == 0 || d.StatusCode >= 400
+}
+
+// DeliveryProvider accesses the recent deliveries of webhooks on a kind of git provider
+type DeliveryProvider interface {
+	// ContentType returns the content type of the hook or blank if it is not known
+	ContentType(ctx context.Context, fullName string, hook *scm.Hook) (string, error)
+
+	// Deliveries returns the recent deliveries of the hook with the most recent first
+	Deliveries(ctx context.Context, fullName string, hook *scm.Hook, count int) ([]*Delivery, error)
+
+	// Redeliver redelivers the delivery
+	Redeliver(ctx context.Context, fullName string, hook *scm.Hook, delivery *Delivery) error
+
+	// Ping triggers a test delivery of the hook
+	Ping(ctx context.Context, fullName string, hook *scm.Hook) error
+}
+
+// NewDeliveryProvider returns the provider of deliveries for the git server or nil if the kind of git provider does
+// not expose the deliveries of webhooks
+func NewDeliveryProvider(server *update.GitServer) DeliveryProvider {
+	switch server.Kind {
+	case "github":
+		return &githubDeliveries{server: server}
+	case "gitlab":
+		return &gitlabDeliveries{server: server}
+	default:
+		return nil
+	}
+}
+
+type githubDeliveries struct {
+	server *update.GitServer
+}
+
+func (p *githubDeliveries) ContentType(ctx context.Context, fullName string, hook *scm.Hook) (string, error) {
+	result := struct {
+		Config struct {
+			ContentType string `json:"content_type"`
+		} `json:"config"`
+	}{}
+	err := do(ctx, p.server, http.MethodGet, fmt.Sprintf("repos/%s/hooks/%s", fullName, hook.ID), &result)
+	return result.Config.ContentType, err
+}
+
+func (p *githubDeliveries) Deliveries(ctx context.Context, fullName string, hook *scm.Hook, count int) ([]*Delivery, error) {
+	var results []struct {
+		ID          int64     `json:"id"`
+		Event       string    `json:"event"`
+		StatusCode  int       `json:"status_code"`
+		DeliveredAt time.Time `json:"delivered_at"`
+	}
+	err := do(ctx, p.server, http.MethodGet, fmt.Sprintf("repos/%s/hooks/%s/deliveries?per_page=%d", fullName, hook.ID, count), &results)
+	if err != nil {
+		return nil, err
+	}
+	var answer []*Delivery
+	for _, r := range results {
+		answer = append(answer, &Delivery{
+			ID:          strconv.FormatInt(r.ID, 10),
+			Event:       r.Event,
+			StatusCode:  r.StatusCode,
+			DeliveredAt: r.DeliveredAt,
+		})
+	}
+	return answer, nil
+}
+
+func (p *githubDeliveries) Redeliver(ctx context.Context, fullName string, hook *scm.Hook, delivery *Delivery) error {
+	return do(ctx, p.server, http.MethodPost, fmt.Sprintf("repos/%s/hooks/%s/deliveries/%s/attempts", fullName, hook.ID, delivery.ID), nil)
+}
+
+func (p *githubDeliveries) Ping(ctx context.Context, fullName string, hook *scm.Hook) error {
+	return do(ctx, p.server, http.MethodPost, fmt.Sprintf("repos/%s/hooks/%s/pings", fullName, hook.ID), nil)
+}
+
+type gitlabDeliveries struct {
+	server *update.GitServer
+}
+
+func (p *gitlabDeliveries) ContentType(_ context.Context, _ string, _ *scm.Hook) (string, error) {
+	// gitlab always delivers JSON
+	return "json", nil
+}
+
+func (p *gitlabDeliveries) Deliveries(ctx context.Context, fullName string, hook *scm.Hook, count int) ([]*Delivery, error) {
+	var results []struct {
+		ID             int64       `json:"id"`
+		Trigger        string      `json:"trigger"`
+		ResponseStatus interface{} `json:"response_status"`
+		CreatedAt      time.Time   `json:"created_at"`
+	}
+	err := do(ctx, p.server, http.MethodGet, fmt.Sprintf("api/v4/projects/%s/hooks/%s/events?per_page=%d", gitlabProject(fullName), hook.ID, count), &results)
+	if err != nil {
+		return nil, err
+	}
+	var answer []*Delivery
+	for _, r := range results {
+		// the response status is a number or a string such as 'internal error'
+		code, _ := strconv.Atoi(fmt.Sprintf("%v", r.ResponseStatus))
+		answer = append(answer, &Delivery{
+			ID:          strconv.FormatInt(r.ID, 10),
+			Event:       r.Trigger,
+			StatusCode:  code,
+			DeliveredAt: r.CreatedAt,
+		})
+	}
+	return answer, nil
+}
+
+func (p *gitlabDeliveries) Redeliver(ctx context.Context, fullName string, hook *scm.Hook, delivery *Delivery) error {
+	return do(ctx, p.server, http.MethodPost, fmt.Sprintf("api/v4/projects/%s/hooks/%s/events/%s/resend", gitlabProject(fullName), hook.ID, delivery.ID), nil)
+}
+
+func (p *gitlabDeliveries) Ping(ctx context.Context, fullName string, hook *scm.Hook) error {
+	return do(ctx, p.server, http.MethodPost, fmt.Sprintf("api/v4/projects/%s/hooks/%s/test/push_events", gitlabProject(fullName), hook.ID), nil)
+}
+
+func gitlabProject(fullName string) string {
+	return strings.ReplaceAll(fullName, "/", "%2F")
+}
+
+// do invokes a REST API of the git provider decoding any JSON response into the result
+func do(ctx context.Context, server *update.GitServer, method, path string, result interface{}) error {
+	return server.Do(ctx, func() (*scm.Response, error) {
+		resp, err := server.Client.Do(ctx, &scm.Request{Method: method, Path: path})
+		if err != nil {
+			return resp, errors.Wrapf(err, "failed to invoke %s %s", method, path)
+		}
+		defer resp.Body.Close()
+		if resp.Status >= 300 {
+			return resp, errors.Errorf("%s %s returned status %d", method, path, resp.Status)
+		}
+		if result != nil {
+			err = json.NewDecoder(resp.Body).Decode(result)
+			if err != nil {
+				return resp, errors.Wrapf(err, "failed to parse response of %s %s", method, path)
+			}
+		}
+		return resp, nil
+	})
+}
@@ -0,0 +1,333 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// FormatTable displays the results as a table
+	FormatTable = "table"
+
+	// FormatJSON displays the results as JSON
+	FormatJSON = "json"
+
+	// StatusHealthy the webhook is registered and delivering events
+	StatusHealthy = "healthy"
+
+	// StatusUnhealthy the webhook is missing, misconfigured or failing deliveries
+	StatusUnhealthy = "unhealthy"
+
+	// StatusError the webhook could not be verified
+	StatusError = "error"
+)
+
+// Formats the supported output formats
+var Formats = []string{FormatTable, FormatJSON}
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Verifies the webhooks of the source repositories are registered and healthy
+
+		The webhook of each SourceRepository is checked for missing event subscriptions and, for git providers which
+		expose them such as GitHub and GitLab, the content type and recent deliveries so that webhooks whose latest
+		delivery failed or which have no recent deliveries are reported. Older failed deliveries are only counted.
+
+		The webhook annotation on each SourceRepository is updated with the result unless the webhook or its
+		deliveries could not be queried.
+`)
+
+	cmdExample = templates.Examples(`
+		# verifies the webhooks of all the source repositories
+		%s webhook verify
+
+		# verifies the webhooks of an owner outputting JSON
+		%[1]s webhook verify --owner mycorp --format json
+
+		# redelivers the last failed delivery and pings webhooks with no recent deliveries
+		%[1]s webhook verify --redeliver --ping
+`)
+)
+
+// Options the options for verifying webhooks
+type Options struct {
+	update.Options
+
+	Days       int
+	Deliveries int
+	Redeliver  bool
+	Ping       bool
+	Format     string
+	Out        io.Writer
+	Verified   []*Result
+}
+
+// Result the result of verifying the webhook of a repository
+type Result struct {
+	Repository       string     `json:"repository"`
+	Status           string     `json:"status"`
+	HookID           string     `json:"hookId,omitempty"`
+	Deliveries       int        `json:"deliveries"`
+	FailedDeliveries int        `json:"failedDeliveries"`
+	LastDelivery     *time.Time `json:"lastDelivery,omitempty"`
+	LastStatusCode   int        `json:"lastStatusCode,omitempty"`
+	Problems         []string   `json:"problems,omitempty"`
+	Redelivered      bool       `json:"redelivered,omitempty"`
+	Pinged           bool       `json:"pinged,omitempty"`
+}
+
+// NewCmdWebHookVerify creates the command
+func NewCmdWebHookVerify() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "verify",
+		Short:   "Verifies the webhooks of the source repositories are registered and healthy",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Org, "owner", "o", "", "The name of the git organisation or user to filter on")
+	cmd.Flags().StringVarP(&o.Repo, "repo", "r", "", "The name of the repository to filter on")
+	cmd.Flags().BoolVarP(&o.ExactHookMatch, "exact-hook-url-match", "", true, "Whether to exactly match the hook based on the URL")
+	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Don't use the endpoint from the cluster, use the provided endpoint")
+	cmd.Flags().IntVarP(&o.Days, "days", "", 7, "Reports webhooks with no deliveries in this number of days. Use 0 to disable")
+	cmd.Flags().IntVarP(&o.Deliveries, "deliveries", "", 20, "The number of recent deliveries to check")
+	cmd.Flags().BoolVarP(&o.Redeliver, "redeliver", "", false, "Redelivers the most recent delivery if it failed")
+	cmd.Flags().BoolVarP(&o.Ping, "ping", "", false, "Pings webhooks which have no recent deliveries")
+	cmd.Flags().StringVarP(&o.Format, "format", "", FormatTable, fmt.Sprintf("The output format. Possible values: %s", strings.Join(Formats, ", ")))
+	cmd.Flags().IntVarP(&o.Workers, "workers", "", 8, "The number of repositories to verify in parallel")
+	cmd.Flags().Float64VarP(&o.RateLimit, "rate-limit", "", 0, "The maximum number of requests per second to each git server. If not specified a default for the kind of git provider is used")
+	cmd.Flags().IntVarP(&o.MaxRetries, "max-retries", "", 5, "The maximum number of times to retry a request if the git provider rate limits it")
+
+	o.ScmClientFactory.AddFlags(cmd)
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+// Run verifies the webhooks
+func (o *Options) Run() error {
+	if stringhelpers.StringArrayIndex(Formats, o.Format) < 0 {
+		return options.InvalidOption("format", o.Format, Formats)
+	}
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+
+	srList, err := o.JXClient.JenkinsV1().SourceRepositories(o.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find any SourceRepositories in namespace %s", o.Namespace)
+	}
+	var repositories []*v1.SourceRepository
+	for i := range srList.Items {
+		sr := &srList.Items[i]
+		if o.MatchesRepository(sr) {
+			repositories = append(repositories, sr)
+		}
+	}
+
+	o.Verified = make([]*Result, len(repositories))
+	update.RunWorkers(o.Workers, len(repositories), func(i int) {
+		sr := repositories[i]
+		result := &Result{Repository: scm.Join(sr.Spec.Org, sr.Spec.Repo)}
+		err := o.verifyRepository(sr, result)
+		o.Verified[i] = result
+		if err != nil {
+			// lets leave the annotation unchanged as we could not determine the health of the webhook
+			log.Logger().Warn(err.Error())
+			result.Status = StatusError
+			result.Problems = append(result.Problems, err.Error())
+			return
+		}
+		if len(result.Problems) > 0 {
+			result.Status = StatusUnhealthy
+		} else {
+			result.Status = StatusHealthy
+		}
+		o.annotate(sr, result)
+	})
+	return o.report()
+}
+
+func (o *Options) verifyRepository(sr *v1.SourceRepository, result *Result) error {
+	server, err := o.GitServerFor(&sr.Spec)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	fullName := result.Repository
+	hooks, err := server.ListHooks(ctx, fullName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list webhooks of repository %s", fullName)
+	}
+
+	var hook *scm.Hook
+	for _, h := range hooks {
+		if o.MatchesWebhookURL(h, o.Endpoint, server.Kind) {
+			hook = h
+			break
+		}
+	}
+	if hook == nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("no webhook registered for %s", o.Endpoint))
+		return nil
+	}
+	result.HookID = hook.ID
+	if !hook.Active {
+		result.Problems = append(result.Problems, "webhook is not active")
+	}
+	var missing []string
+	for _, e := range RequiredEvents[server.Kind] {
+		if stringhelpers.StringArrayIndex(hook.Events, e) < 0 {
+			missing = append(missing, e)
+		}
+	}
+	if len(missing) > 0 {
+		result.Problems = append(result.Problems, "missing event subscriptions: "+strings.Join(missing, ", "))
+	}
+
+	provider := NewDeliveryProvider(server)
+	if provider == nil {
+		log.Logger().Debugf("cannot check the deliveries of repository %s as git provider %s does not expose them", fullName, server.Kind)
+		return nil
+	}
+
+	contentType, err := provider.ContentType(ctx, fullName, hook)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the webhook of repository %s", fullName)
+	}
+	if contentType != "" && contentType != "json" {
+		result.Problems = append(result.Problems, fmt.Sprintf("webhook content type is %s rather than json", contentType))
+	}
+
+	deliveries, err := provider.Deliveries(ctx, fullName, hook, o.Deliveries)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the webhook deliveries of repository %s", fullName)
+	}
+	result.Deliveries = len(deliveries)
+	for _, d := range deliveries {
+		if d.Failed() {
+			result.FailedDeliveries++
+		}
+	}
+	var last *Delivery
+	if len(deliveries) > 0 {
+		last = deliveries[0]
+		result.LastDelivery = &last.DeliveredAt
+		result.LastStatusCode = last.StatusCode
+		if last.Failed() {
+			result.Problems = append(result.Problems, fmt.Sprintf("last delivery failed with status %d", last.StatusCode))
+		}
+	}
+	stale := false
+	if o.Days > 0 {
+		since := time.Now().Add(-time.Duration(o.Days) * 24 * time.Hour)
+		if last == nil || last.DeliveredAt.Before(since) {
+			stale = true
+			result.Problems = append(result.Problems, fmt.Sprintf("no deliveries in the last %d days", o.Days))
+		}
+	}
+
+	if o.Redeliver && last != nil && last.Failed() {
+		err = provider.Redeliver(ctx, fullName, hook, last)
+		if err != nil {
+			return errors.Wrapf(err, "failed to redeliver delivery %s of repository %s", last.ID, fullName)
+		}
+		result.Redelivered = true
+		log.Logger().Infof("redelivered delivery %s of repository %s", info(last.ID), info(fullName))
+	}
+	if o.Ping && stale {
+		err = provider.Ping(ctx, fullName, hook)
+		if err != nil {
+			return errors.Wrapf(err, "failed to ping the webhook of repository %s", fullName)
+		}
+		result.Pinged = true
+		log.Logger().Infof("pinged the webhook of repository %s", info(fullName))
+	}
+	return nil
+}
+
+// annotate updates the webhook annotation on the repository with the result
+func (o *Options) annotate(sr *v1.SourceRepository, result *Result) {
+	if sr.Annotations == nil {
+		sr.Annotations = map[string]string{}
+	}
+	if result.Status == StatusHealthy {
+		sr.Annotations[update.WebHookAnnotation] = "true"
+		delete(sr.Annotations, update.WebHookErrorAnnotation)
+	} else {
+		sr.Annotations[update.WebHookAnnotation] = "failed"
+		sr.Annotations[update.WebHookErrorAnnotation] = strings.Join(result.Problems, "; ")
+	}
+	_, err := o.JXClient.JenkinsV1().SourceRepositories(o.Namespace).Update(context.TODO(), sr, metav1.UpdateOptions{})
+	if err != nil {
+		log.Logger().Warnf("failed to annotate SourceRepository %s with webhook status: %s", sr.Name, err.Error())
+	}
+}
+
+// report displays the results returning an error if any webhooks are not healthy
+func (o *Options) report() error {
+	unhealthy := 0
+	for _, r := range o.Verified {
+		if r.Status != StatusHealthy {
+			unhealthy++
+		}
+	}
+
+	if o.Format == FormatJSON {
+		data, err := json.MarshalIndent(o.Verified, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal results to JSON")
+		}
+		_, err = fmt.Fprintln(o.Out, string(data))
+		if err != nil {
+			return errors.Wrapf(err, "failed to write results")
+		}
+	} else {
+		t := table.CreateTable(o.Out)
+		t.AddRow("REPOSITORY", "STATUS", "LAST DELIVERY", "FAILED", "PROBLEMS")
+		for _, r := range o.Verified {
+			last := ""
+			if r.LastDelivery != nil {
+				last = r.LastDelivery.Format(time.RFC3339) + " " + strconv.Itoa(r.LastStatusCode)
+			}
+			failed := fmt.Sprintf("%d/%d", r.FailedDeliveries, r.Deliveries)
+			t.AddRow(r.Repository, r.Status, last, failed, strings.Join(r.Problems, "; "))
+		}
+		t.Render()
+	}
+
+	if unhealthy > 0 {
+		return errors.Errorf("%d of %d webhooks are not healthy", unhealthy, len(o.Verified))
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package verify_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/verify"
+	fakejx "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers/testjx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const endpoint = "https://hook-jx.jx.example.com/hook"
+
+type fakeHook struct {
+	ContentType     string
+	Events          []string
+	Deliveries      []map[string]interface{}
+	DeliveriesError bool
+}
+
+func TestWebhookVerify(t *testing.T) {
+	ns := "jx"
+	owner := "myorg"
+	now := time.Now().UTC()
+	recent := now.Add(-time.Hour).Format(time.RFC3339)
+	old := now.Add(-30 * 24 * time.Hour).Format(time.RFC3339)
+	allEvents := []string{"push", "pull_request", "issue_comment"}
+
+	repos := map[string]*fakeHook{
+		"healthy": {
+			ContentType: "json",
+			Events:      allEvents,
+			Deliveries:  []map[string]interface{}{{"id": 2, "event": "push", "status_code": 200, "delivered_at": recent}},
+		},
+		"failing": {
+			ContentType: "json",
+			Events:      allEvents,
+			Deliveries: []map[string]interface{}{
+				{"id": 4, "event": "push", "status_code": 500, "delivered_at": recent},
+				{"id": 3, "event": "push", "status_code": 200, "delivered_at": old},
+			},
+		},
+		"recovered": {
+			ContentType: "json",
+			Events:      allEvents,
+			Deliveries: []map[string]interface{}{
+				{"id": 7, "event": "push", "status_code": 200, "delivered_at": recent},
+				{"id": 6, "event": "push", "status_code": 502, "delivered_at": recent},
+			},
+		},
+		"broken": {
+			ContentType:     "json",
+			Events:          allEvents,
+			DeliveriesError: true,
+		},
+		"stale": {
+			ContentType: "form",
+			Events:      []string{"push"},
+			Deliveries:  []map[string]interface{}{{"id": 5, "event": "push", "status_code": 200, "delivered_at": old}},
+		},
+		"missing": nil,
+	}
+
+	lock := sync.Mutex{}
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/"+owner+"/")
+		parts := strings.Split(path, "/")
+		hook, ok := repos[parts[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost {
+			lock.Lock()
+			posts = append(posts, path)
+			lock.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var result interface{}
+		switch {
+		case len(parts) == 2:
+			hooks := []interface{}{}
+			if hook != nil {
+				hooks = append(hooks, githubHook(hook))
+			}
+			result = hooks
+		case len(parts) == 3:
+			result = githubHook(hook)
+		case len(parts) == 4 && parts[3] == "deliveries":
+			if hook.DeliveriesError {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			result = hook.Deliveries
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	kubeClient := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+	devEnv := jxenv.CreateDefaultDevEnvironment(ns)
+	devEnv.Namespace = ns
+	objects := []runtime.Object{devEnv}
+	for r := range repos {
+		sr := testjx.CreateSourceRepository(ns, owner, r, "github", server.URL)
+		if r == "broken" {
+			sr.Annotations = map[string]string{update.WebHookAnnotation: "true"}
+		}
+		objects = append(objects, sr)
+	}
+	jxClient := fakejx.NewSimpleClientset(objects...)
+
+	_, o := verify.NewCmdWebHookVerify()
+	o.Namespace = ns
+	o.KubeClient = kubeClient
+	o.JXClient = jxClient
+	o.Endpoint = endpoint
+	o.ScmClientFactory.GitToken = "dummytoken"
+	o.Format = verify.FormatJSON
+	o.Redeliver = true
+	o.Ping = true
+	buf := &bytes.Buffer{}
+	o.Out = buf
+
+	err := o.Run()
+	require.Error(t, err, "should fail as some webhooks are not healthy")
+
+	var results []*verify.Result
+	err = json.Unmarshal(buf.Bytes(), &results)
+	require.NoError(t, err, "failed to parse JSON output %s", buf.String())
+	require.Len(t, results, len(repos))
+
+	statuses := map[string]*verify.Result{}
+	for _, r := range results {
+		statuses[strings.TrimPrefix(r.Repository, owner+"/")] = r
+	}
+
+	r := statuses["healthy"]
+	require.NotNil(t, r)
+	assert.Equal(t, verify.StatusHealthy, r.Status, "problems %v", r.Problems)
+	assert.Empty(t, r.Problems)
+
+	r = statuses["failing"]
+	require.NotNil(t, r)
+	assert.Equal(t, verify.StatusUnhealthy, r.Status)
+	assert.Equal(t, 1, r.FailedDeliveries)
+	assert.Equal(t, 500, r.LastStatusCode)
+	assert.True(t, r.Redelivered, "should have redelivered the failed delivery")
+	assert.False(t, r.Pinged)
+
+	r = statuses["recovered"]
+	require.NotNil(t, r)
+	assert.Equal(t, verify.StatusHealthy, r.Status, "should be healthy as the latest delivery succeeded: %v", r.Problems)
+	assert.Equal(t, 1, r.FailedDeliveries)
+	assert.False(t, r.Redelivered)
+
+	r = statuses["broken"]
+	require.NotNil(t, r)
+	assert.Equal(t, verify.StatusError, r.Status)
+
+	r = statuses["stale"]
+	require.NotNil(t, r)
+	assert.Equal(t, verify.StatusUnhealthy, r.Status)
+	assert.Contains(t, r.Problems, "webhook content type is form rather than json")
+	assert.Contains(t, r.Problems, "missing event subscriptions: pull_request, issue_comment")
+	assert.Contains(t, r.Problems, "no deliveries in the last 7 days")
+	assert.True(t, r.Pinged, "should have pinged the stale webhook")
+
+	r = statuses["missing"]
+	require.NotNil(t, r)
+	assert.Equal(t, verify.StatusUnhealthy, r.Status)
+	assert.Equal(t, []string{"no webhook registered for " + endpoint}, r.Problems)
+
+	assert.ElementsMatch(t, []string{"failing/hooks/1/deliveries/4/attempts", "stale/hooks/1/pings"}, posts)
+
+	srList, err := jxClient.JenkinsV1().SourceRepositories(ns).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list SourceRepositories")
+	for i := range srList.Items {
+		sr := &srList.Items[i]
+		expected := "failed"
+		if sr.Spec.Repo == "broken" {
+			assert.Equal(t, "true", sr.Annotations[update.WebHookAnnotation], "should not change the webhook annotation on %s when the deliveries cannot be listed", sr.Name)
+			assert.Empty(t, sr.Annotations[update.WebHookErrorAnnotation], "error annotation on %s", sr.Name)
+			continue
+		}
+		if sr.Spec.Repo == "healthy" || sr.Spec.Repo == "recovered" {
+			expected = "true"
+			assert.Empty(t, sr.Annotations[update.WebHookErrorAnnotation], "error annotation on %s", sr.Name)
+		} else {
+			assert.NotEmpty(t, sr.Annotations[update.WebHookErrorAnnotation], "error annotation on %s", sr.Name)
+		}
+		assert.Equal(t, expected, sr.Annotations[update.WebHookAnnotation], "webhook annotation on %s", sr.Name)
+	}
+}
+
+func githubHook(hook *fakeHook) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     1,
+		"name":   "web",
+		"active": true,
+		"events": hook.Events,
+		"config": map[string]interface{}{
+			"url":          endpoint,
+			"content_type": hook.ContentType,
+		},
+		"url": fmt.Sprintf("%s/hooks/1", endpoint),
+	}
+}
@@ -2,7 +2,9 @@ package webhook
 
 import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/delete"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/rotate"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/update"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/webhook/verify"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -28,5 +30,7 @@ func NewCmdWebhook() *cobra.Command {
 	}
 	command.AddCommand(helper.RetryOnErrorCommand(cobras.SplitCommand(update.NewCmdWebHookVerify()), helper.RegexRetryFunction(webHookUpdateRetriableErrors)))
 	command.AddCommand(helper.RetryOnErrorCommand(cobras.SplitCommand(delete.NewCmdWebHookDelete()), helper.RegexRetryFunction(webHookUpdateRetriableErrors)))
+	command.AddCommand(helper.RetryOnErrorCommand(cobras.SplitCommand(rotate.NewCmdWebHookRotateHMAC()), helper.RegexRetryFunction(webHookUpdateRetriableErrors)))
+	command.AddCommand(cobras.SplitCommand(verify.NewCmdWebHookVerify()))
 	return command
 }
@@ -0,0 +1,369 @@
+package yset
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/utils"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+)
+
+const (
+	// TypeString forces the value to be a string
+	TypeString = "string"
+
+	// TypeInt the value must be an integer
+	TypeInt = "int"
+
+	// TypeBool the value must be a boolean
+	TypeBool = "bool"
+
+	// TypeFloat the value must be a floating point number
+	TypeFloat = "float"
+
+	// TypeNull sets a null value
+	TypeNull = "null"
+
+	// TypeYAML parses the value as YAML so that maps and lists can be set
+	TypeYAML = "yaml"
+)
+
+// Types the supported value types
+var Types = []string{TypeString, TypeInt, TypeBool, TypeFloat, TypeNull, TypeYAML}
+
+// BatchFile a file of operations applied to each file
+type BatchFile struct {
+	Operations []Operation `json:"operations,omitempty"`
+}
+
+// Operation a modification of a YAML file at a path expression.
+//
+// If none of Delete, Append or MergeFile are specified the value is set at the path
+type Operation struct {
+	// Path the path expression such as 'spec.template.spec.containers[name=app].image' or 'items[0].name'
+	Path string `json:"path,omitempty"`
+
+	// Value the value to set or append
+	Value string `json:"value,omitempty"`
+
+	// Type the optional type of the value. If blank the type is inferred from the value
+	Type string `json:"type,omitempty"`
+
+	// Delete removes the value at the path
+	Delete bool `json:"delete,omitempty"`
+
+	// Append appends the value to the list at the path
+	Append bool `json:"append,omitempty"`
+
+	// MergeFile the YAML file to merge into the map at the path or the root if there is no path
+	MergeFile string `json:"mergeFile,omitempty"`
+}
+
+// Validate validates the operation
+func (o *Operation) Validate() error {
+	count := 0
+	for _, b := range []bool{o.Delete, o.Append, o.MergeFile != ""} {
+		if b {
+			count++
+		}
+	}
+	if count > 1 {
+		return errors.Errorf("only one of delete, append or merge file can be specified for path %s", o.Path)
+	}
+	if o.Path == "" && o.MergeFile == "" {
+		return errors.Errorf("missing path")
+	}
+	if o.Type != "" && stringhelpers.StringArrayIndex(Types, o.Type) < 0 {
+		return errors.Errorf("invalid type %s for path %s. Supported values are: %s", o.Type, o.Path, strings.Join(Types, ", "))
+	}
+	if o.Delete || o.MergeFile != "" {
+		return nil
+	}
+	if o.Value == "" && o.Type != TypeNull && o.Type != TypeString {
+		return errors.Errorf("missing value for path %s", o.Path)
+	}
+	_, err := o.valueNode()
+	return err
+}
+
+// Apply applies the operation to the node
+func (o *Operation) Apply(node *yaml.RNode) error {
+	parts, err := ParsePath(o.Path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case o.Delete:
+		return deletePath(node, parts)
+	case o.MergeFile != "":
+		return o.merge(node, parts)
+	case o.Append:
+		return o.append(node, parts)
+	default:
+		return o.set(node, parts)
+	}
+}
+
+// ParsePath splits a path expression into the parts used by kyaml.
+//
+// Dots separate fields, '[name=value]' matches a list element by a field or '[=value]' by value, '[0]' selects
+// a list element by index and '[-]' the last element. Keys containing dots can be escaped as '[a.b.c]' or 'a\.b\.c'
+func ParsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	buff := strings.Builder{}
+	depth := 0
+	for i, r := range path {
+		switch r {
+		case '[':
+			if depth == 0 && i > 0 && path[i-1] != '.' {
+				buff.WriteRune('.')
+			}
+			depth++
+		case ']':
+			depth--
+			if depth < 0 {
+				return nil, errors.Errorf("unbalanced ']' in path %s", path)
+			}
+		}
+		buff.WriteRune(r)
+	}
+	if depth != 0 {
+		return nil, errors.Errorf("unbalanced '[' in path %s", path)
+	}
+
+	var answer []string
+	for _, p := range utils.SmarterPathSplitter(buff.String(), ".") {
+		if p == "" {
+			return nil, errors.Errorf("empty path element in path %s", path)
+		}
+		if yaml.IsListIndex(p) {
+			idx := strings.TrimSuffix(strings.TrimPrefix(p, "["), "]")
+			if idx == "-" || yaml.IsIdxNumber(idx) {
+				p = idx
+			}
+		}
+		answer = append(answer, p)
+	}
+	return answer, nil
+}
+
+func (o *Operation) set(node *yaml.RNode, parts []string) error {
+	value, err := o.valueNode()
+	if err != nil {
+		return err
+	}
+	parentPath, last := parts[:len(parts)-1], parts[len(parts)-1]
+	if !isElementPart(last) {
+		parent, err := node.Pipe(yaml.PathGetter{Path: parentPath, Create: yaml.MappingNode})
+		if err != nil {
+			return errors.Wrapf(err, "failed to find path %s", o.Path)
+		}
+		if parent == nil {
+			return errors.Errorf("could not find path %s", strings.Join(parentPath, "."))
+		}
+		err = parent.PipeE(yaml.FieldSetter{Name: last, Value: value})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set path %s=%s", o.Path, o.Value)
+		}
+		return nil
+	}
+
+	parent, err := node.Pipe(yaml.PathGetter{Path: parentPath, Create: yaml.SequenceNode})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find list for path %s", o.Path)
+	}
+	if parent == nil {
+		return errors.Errorf("could not find path %s", strings.Join(parentPath, "."))
+	}
+	if parent.YNode().Kind != yaml.SequenceNode {
+		return errors.Errorf("path %s is not a list", strings.Join(parentPath, "."))
+	}
+	idx, err := elementIndex(parent, last)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find element for path %s", o.Path)
+	}
+	if idx < 0 {
+		if yaml.IsIdxNumber(last) || last == "-" {
+			return errors.Errorf("no list element at index %s for path %s", last, o.Path)
+		}
+		parent.YNode().Content = append(parent.YNode().Content, value.YNode())
+		return nil
+	}
+	parent.YNode().Content[idx] = value.YNode()
+	return nil
+}
+
+func (o *Operation) append(node *yaml.RNode, parts []string) error {
+	value, err := o.valueNode()
+	if err != nil {
+		return err
+	}
+	list, err := node.Pipe(yaml.PathGetter{Path: parts, Create: yaml.SequenceNode})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find list for path %s", o.Path)
+	}
+	if list == nil {
+		return errors.Errorf("could not find path %s", o.Path)
+	}
+	if list.YNode().Kind != yaml.SequenceNode {
+		return errors.Errorf("cannot append to path %s as it is not a list", o.Path)
+	}
+	list.YNode().Content = append(list.YNode().Content, value.YNode())
+	return nil
+}
+
+func (o *Operation) merge(node *yaml.RNode, parts []string) error {
+	src, err := yaml.ReadFile(o.MergeFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load merge file %s", o.MergeFile)
+	}
+	dest := node
+	if len(parts) > 0 {
+		dest, err = node.Pipe(yaml.PathGetter{Path: parts, Create: yaml.MappingNode})
+		if err != nil {
+			return errors.Wrapf(err, "failed to find path %s", o.Path)
+		}
+		if dest == nil {
+			return errors.Errorf("could not find path %s", o.Path)
+		}
+	}
+	if dest.YNode().Kind != yaml.MappingNode {
+		return errors.Errorf("cannot merge file %s into path %s as it is not a map", o.MergeFile, o.Path)
+	}
+	result, err := merge2.Merge(src, dest, yaml.MergeOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to merge file %s into path %s", o.MergeFile, o.Path)
+	}
+	if result != nil && result.YNode() != dest.YNode() {
+		*dest.YNode() = *result.YNode()
+	}
+	return nil
+}
+
+func deletePath(node *yaml.RNode, parts []string) error {
+	parentPath, last := parts[:len(parts)-1], parts[len(parts)-1]
+	parent, err := node.Pipe(yaml.PathGetter{Path: parentPath})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find path %s", strings.Join(parentPath, "."))
+	}
+	if parent == nil {
+		return nil
+	}
+	if !isElementPart(last) {
+		if parent.YNode().Kind != yaml.MappingNode {
+			return nil
+		}
+		_, err = parent.Pipe(yaml.Clear(last))
+		return err
+	}
+	if parent.YNode().Kind != yaml.SequenceNode {
+		return nil
+	}
+	idx, err := elementIndex(parent, last)
+	if err != nil || idx < 0 {
+		return err
+	}
+	content := parent.YNode().Content
+	parent.YNode().Content = append(content[:idx], content[idx+1:]...)
+	return nil
+}
+
+// isElementPart returns true if the path part selects a list element
+func isElementPart(part string) bool {
+	return part == "-" || yaml.IsIdxNumber(part) || yaml.IsListIndex(part)
+}
+
+// elementIndex returns the index of the list element matching the path part or -1 if there is no match
+func elementIndex(list *yaml.RNode, part string) (int, error) {
+	content := list.YNode().Content
+	if part == "-" {
+		return len(content) - 1, nil
+	}
+	if yaml.IsIdxNumber(part) {
+		idx, _ := strconv.Atoi(part)
+		if idx >= len(content) {
+			return -1, nil
+		}
+		return idx, nil
+	}
+	name, value, err := yaml.SplitIndexNameValue(part)
+	if err != nil {
+		return -1, err
+	}
+	for i, n := range content {
+		if name == "" {
+			if n.Kind == yaml.ScalarNode && n.Value == value {
+				return i, nil
+			}
+			continue
+		}
+		v, err := yaml.NewRNode(n).Pipe(yaml.Get(name))
+		if err == nil && v != nil && yaml.GetValue(v) == value {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// valueNode returns the value converted to a node of the type
+func (o *Operation) valueNode() (*yaml.RNode, error) {
+	v := o.Value
+	tag := ""
+	switch o.Type {
+	case "":
+		return yaml.NewScalarRNode(v), nil
+	case TypeString:
+		return yaml.NewStringRNode(v), nil
+	case TypeInt:
+		_, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("value %s for path %s is not an int", v, o.Path)
+		}
+		tag = yaml.NodeTagInt
+	case TypeBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Errorf("value %s for path %s is not a bool", v, o.Path)
+		}
+		v = strconv.FormatBool(b)
+		tag = yaml.NodeTagBool
+	case TypeFloat:
+		_, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.Errorf("value %s for path %s is not a float", v, o.Path)
+		}
+		tag = yaml.NodeTagFloat
+	case TypeNull:
+		v = "null"
+		tag = yaml.NodeTagNull
+	case TypeYAML:
+		n, err := yaml.Parse(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse YAML value for path %s", o.Path)
+		}
+		clearFlowStyle(n.YNode())
+		return n, nil
+	default:
+		return nil, errors.Errorf("unsupported type %s", o.Type)
+	}
+	answer := yaml.NewRNode(&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v})
+
+	// lets make sure null values are kept rather than clearing the field
+	answer.ShouldKeep = o.Type == TypeNull
+	return answer, nil
+}
+
+// clearFlowStyle converts any flow style maps and lists to block style so they match the rest of the file
+func clearFlowStyle(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+		n.Style &^= yaml.FlowStyle
+	}
+	for _, c := range n.Content {
+		clearFlowStyle(c)
+	}
+}
@@ -2,12 +2,14 @@ package yset
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -17,6 +19,25 @@ import (
 var (
 	annotateLong = templates.LongDesc(`
 		Modifies one or more yaml files using a path expression while preserving comments
+
+		Path expressions are separated by dots. List elements can be matched by a field such as 'containers[name=app]',
+		by value such as 'args[=--verbose]' or by index such as 'containers[0]' with '[-]' for the last element.
+
+		A batch file of operations can be applied to many files in one run. Any merge files are relative to the batch file. e.g.
+
+		    operations:
+		    - path: spec.template.spec.containers[name=app].image
+		      value: myimage:1.2.3
+		    - path: spec.replicas
+		      value: "2"
+		      type: int
+		    - path: metadata.annotations.[example.com/old]
+		      delete: true
+		    - path: spec.template.spec.containers[name=app].args
+		      value: --verbose
+		      append: true
+		    - path: metadata.labels
+		      mergeFile: labels.yaml
 `)
 
 	annotateExample = templates.Examples(`
@@ -27,16 +48,34 @@ var (
 		%[1]s yset --path foo.bar --value abc --file foo.yaml
 
 		# sets the foo.bar=abc in the file foo.yaml and bar.yaml
-		%[1]s --path foo.bar --value abc --file bar.yaml --file foo.yaml
+		%[1]s yset --path foo.bar --value abc --file bar.yaml --file foo.yaml
+
+		# sets the image of the container called app
+		%[1]s yset --path 'spec.template.spec.containers[name=app].image' --value myimage:1.2.3 deployment.yaml
+
+		# sets an integer value
+		%[1]s yset --path spec.replicas --value 3 --type int deployment.yaml
+
+		# deletes a key
+		%[1]s yset --path metadata.annotations.foo --delete deployment.yaml
+
+		# appends a value to a list
+		%[1]s yset --path 'spec.template.spec.containers[0].args' --value=--verbose --append deployment.yaml
+
+		# merges a YAML file into the labels
+		%[1]s yset --path metadata.labels --merge-file labels.yaml deployment.yaml
+
+		# applies a batch file of operations to many files
+		%[1]s yset --batch-file ops.yaml *.yaml
 	`)
 )
 
-// AnnotateOptions the options for the command
+// Options the options for the command
 type Options struct {
-	Files []string
-	Path  string
-	Value string
-	Args  []string
+	Operation
+	Files     []string
+	BatchFile string
+	Args      []string
 }
 
 // NewCmdUpdate creates a command object for the command
@@ -54,12 +93,18 @@ func NewCmdYSet() (*cobra.Command, *Options) {
 			helper.CheckErr(err)
 		},
 	}
-	cmd.Flags().StringVarP(&o.Path, "path", "p", "", "the path expression to modify (separated by dots)")
+	cmd.Flags().StringVarP(&o.Path, "path", "p", "", "the path expression to modify (separated by dots). List elements can be selected via [name=value], [=value] or [index]")
 	cmd.Flags().StringVarP(&o.Value, "value", "v", "", "the value to modify")
+	cmd.Flags().StringVarP(&o.Type, "type", "", "", "the type of the value. If not specified it is inferred from the value. Supported values: "+strings.Join(Types, ", "))
+	cmd.Flags().BoolVarP(&o.Delete, "delete", "", false, "deletes the value at the path")
+	cmd.Flags().BoolVarP(&o.Append, "append", "", false, "appends the value to the list at the path")
+	cmd.Flags().StringVarP(&o.MergeFile, "merge-file", "", "", "a YAML file to merge into the map at the path or into the root if no path is specified")
+	cmd.Flags().StringVarP(&o.BatchFile, "batch-file", "", "", "a YAML file containing a list of operations to apply to each file")
 	cmd.Flags().StringArrayVarP(&o.Files, "file", "f", nil, "the file(s) to process")
 	return cmd, o
 }
 
+// Run runs the command
 func (o *Options) Run() error {
 	if len(o.Files) == 0 {
 		if len(o.Args) == 0 {
@@ -67,11 +112,10 @@ func (o *Options) Run() error {
 		}
 		o.Files = o.Args
 	}
-	if o.Path == "" {
-		return options.MissingOption("path")
-	}
-	if o.Value == "" {
-		return options.MissingOption("value")
+
+	operations, err := o.Operations()
+	if err != nil {
+		return err
 	}
 
 	log.Logger().Debugf("loading files %v", o.Files)
@@ -82,24 +126,10 @@ func (o *Options) Run() error {
 			return errors.Wrapf(err, "failed to load file %s", fileName)
 		}
 
-		v := o.Value
-		vn := yaml.NewScalarRNode(v)
-
-		paths := strings.Split(o.Path, ".")
-		lastIdx := len(paths) - 1
-		k := paths[lastIdx]
-		if lastIdx > 0 {
-			paths = paths[0:lastIdx]
-			_, err = node.Pipe(
-				yaml.PathGetter{Path: paths, Create: yaml.MappingNode},
-				yaml.FieldSetter{Name: k, Value: vn})
+		for i := range operations {
+			err = operations[i].Apply(node)
 			if err != nil {
-				return errors.Wrapf(err, "failed to modify node %s set path %s=%s", strings.Join(paths, "."), k, v)
-			}
-		} else {
-			_, err = node.Pipe(yaml.FieldSetter{Name: k, Value: vn})
-			if err != nil {
-				return errors.Wrapf(err, "failed to set path %s=%s", k, v)
+				return errors.Wrapf(err, "failed to modify file %s", fileName)
 			}
 		}
 
@@ -110,3 +140,43 @@ func (o *Options) Run() error {
 	}
 	return nil
 }
+
+// Operations returns the validated operations from the batch file and the command line options
+func (o *Options) Operations() ([]Operation, error) {
+	var answer []Operation
+	if o.BatchFile != "" {
+		batch := &BatchFile{}
+		err := yamls.LoadFile(o.BatchFile, batch)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load batch file %s", o.BatchFile)
+		}
+		// merge files in the batch file are relative to the batch file
+		dir := filepath.Dir(o.BatchFile)
+		for i := range batch.Operations {
+			op := &batch.Operations[i]
+			if op.MergeFile != "" && !filepath.IsAbs(op.MergeFile) {
+				op.MergeFile = filepath.Join(dir, op.MergeFile)
+			}
+		}
+		answer = append(answer, batch.Operations...)
+	}
+	if o.Path != "" || o.MergeFile != "" || o.BatchFile == "" {
+		if o.Path == "" && o.MergeFile == "" {
+			return nil, options.MissingOption("path")
+		}
+		if o.Value == "" && !o.Delete && o.MergeFile == "" && o.Type != TypeNull && o.Type != TypeString {
+			return nil, options.MissingOption("value")
+		}
+		answer = append(answer, o.Operation)
+	}
+	if len(answer) == 0 {
+		return nil, errors.Errorf("no operations found in batch file %s", o.BatchFile)
+	}
+	for i := range answer {
+		err := answer[i].Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid operation %d", i+1)
+		}
+	}
+	return answer, nil
+}
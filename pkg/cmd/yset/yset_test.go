@@ -8,6 +8,7 @@ import (
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/yset"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,9 +17,14 @@ var generateTestOutput = false
 
 func TestYSet(t *testing.T) {
 	testCases := []struct {
-		dir   string
-		path  string
-		value string
+		dir       string
+		path      string
+		value     string
+		valueType string
+		delete    bool
+		append    bool
+		mergeFile string
+		batchFile string
 	}{
 		{
 			dir:   "image_tag",
@@ -35,6 +41,37 @@ func TestYSet(t *testing.T) {
 			path:  "myTopLevel",
 			value: "beer wine",
 		},
+		{
+			dir:   "container_image",
+			path:  "spec.template.spec.containers[name=app].image",
+			value: "myapp:2.0.0",
+		},
+		{
+			dir:       "typed_value",
+			path:      "spec.replicas",
+			value:     "3",
+			valueType: "int",
+		},
+		{
+			dir:    "delete",
+			path:   "metadata.annotations.[example.com/old]",
+			delete: true,
+		},
+		{
+			dir:    "append",
+			path:   "spec.template.spec.containers[1].args",
+			value:  "--verbose",
+			append: true,
+		},
+		{
+			dir:       "merge_file",
+			path:      "metadata.labels",
+			mergeFile: filepath.Join("testdata", "merge_file", "merge.yaml"),
+		},
+		{
+			dir:       "batch",
+			batchFile: filepath.Join("testdata", "batch", "batch.yaml"),
+		},
 	}
 
 	tmpDir := t.TempDir()
@@ -56,6 +93,11 @@ func TestYSet(t *testing.T) {
 		o.Files = []string{outFile}
 		o.Path = tc.path
 		o.Value = tc.value
+		o.Type = tc.valueType
+		o.Delete = tc.delete
+		o.Append = tc.append
+		o.MergeFile = tc.mergeFile
+		o.BatchFile = tc.batchFile
 
 		err = o.Run()
 		require.NoError(t, err, "failed to run for test %s", name)
@@ -71,3 +113,48 @@ func TestYSet(t *testing.T) {
 		_ = testhelpers.AssertEqualFileText(t, expectedFile, outFile)
 	}
 }
+
+func TestYSetInvalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcFile := filepath.Join("testdata", "container_image", "source.yaml")
+	outFile := filepath.Join(tmpDir, "source.yaml")
+	err := files.CopyFile(srcFile, outFile)
+	require.NoError(t, err, "failed to copy %s to %s", srcFile, outFile)
+
+	_, o := yset.NewCmdYSet()
+	o.Files = []string{outFile}
+	o.Path = "spec.replicas"
+	o.Value = "three"
+	o.Type = "int"
+	require.Error(t, o.Run(), "should fail with an invalid int")
+
+	o.Type = ""
+	o.Path = "spec.template.spec.containers[5].image"
+	require.Error(t, o.Run(), "should fail with a missing index")
+
+	o.Path = "spec.template.spec.containers[name=app.image"
+	require.Error(t, o.Run(), "should fail with an unbalanced path")
+
+	o.Path = "spec.replicas"
+	o.Delete = true
+	o.Append = true
+	require.Error(t, o.Run(), "should fail with multiple operations")
+}
+
+func TestParsePath(t *testing.T) {
+	testCases := map[string][]string{
+		"a.b.c": {"a", "b", "c"},
+		"spec.template.spec.containers[name=app].image": {"spec", "template", "spec", "containers", "[name=app]", "image"},
+		"spec.containers.[name=app].image":              {"spec", "containers", "[name=app]", "image"},
+		"items[0].name":                                 {"items", "0", "name"},
+		"items[-]":                                      {"items", "-"},
+		"args[=--verbose]":                              {"args", "[=--verbose]"},
+		"metadata.annotations.[example.com/foo]":        {"metadata", "annotations", "example.com/foo"},
+		`metadata.labels.app\.kubernetes\.io/name`:      {"metadata", "labels", "app.kubernetes.io/name"},
+	}
+	for path, expected := range testCases {
+		actual, err := yset.ParsePath(path)
+		require.NoError(t, err, "failed to parse path %s", path)
+		assert.Equal(t, expected, actual, "for path %s", path)
+	}
+}
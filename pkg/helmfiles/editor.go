@@ -2,7 +2,6 @@ package helmfiles
 
 import (
 	"path/filepath"
-	"sort"
 
 	"github.com/helmfile/helmfile/pkg/state"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
@@ -76,22 +75,7 @@ func (e *Editor) AddChart(opts *ChartDetails) error {
 	rootPath := e.helmfiles[0].Filepath
 	root := e.getOrCreateState(rootPath)[0]
 	// Assumes the root helmfile has only one document
-	found := false
-	for _, f := range root.Helmfiles {
-		if f.Path == rel {
-			found = true
-			break
-		}
-	}
-	if !found {
-		root.Helmfiles = append(root.Helmfiles, state.SubHelmfileSpec{
-			Path: rel,
-		})
-		sort.Slice(root.Helmfiles, func(i, j int) bool {
-			h1 := root.Helmfiles[i]
-			h2 := root.Helmfiles[j]
-			return h1.Path < h2.Path
-		})
+	if AddNestedHelmfile(root, rel) {
 		e.modified[rootPath] = true
 	}
 
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
@@ -144,14 +145,28 @@ func AddRepository(helmStates []*state.HelmState, prefix, repositoryURL string,
 
 // LoadHelmfile loads helmfile from a path
 func LoadHelmfile(path string) ([]*state.HelmState, error) {
-	var helmStates []*state.HelmState
-
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	dec := yaml.NewDecoder(file)
+	helmStates, err := ParseHelmfile(file)
+	if err != nil {
+		return nil, err
+	}
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return helmStates, nil
+}
+
+// ParseHelmfile parses the documents of a helmfile
+func ParseHelmfile(r io.Reader) ([]*state.HelmState, error) {
+	var helmStates []*state.HelmState
+
+	dec := yaml.NewDecoder(r)
 	for {
 		helmState := state.HelmState{}
 		if err := dec.Decode(&helmState); err != nil {
@@ -166,12 +181,38 @@ func LoadHelmfile(path string) ([]*state.HelmState, error) {
 		}
 		helmStates = append(helmStates, &helmState)
 	}
-	err = file.Close()
-	if err != nil {
-		return nil, err
+	return helmStates, nil
+}
+
+// AddNestedHelmfile adds the relative path of a nested helmfile to the first document of the root helmfile
+// returning true if it was added
+func AddNestedHelmfile(root *state.HelmState, rel string) bool {
+	for _, f := range root.Helmfiles {
+		if f.Path == rel {
+			return false
+		}
 	}
+	root.Helmfiles = append(root.Helmfiles, state.SubHelmfileSpec{
+		Path: rel,
+	})
+	sort.Slice(root.Helmfiles, func(i, j int) bool {
+		return root.Helmfiles[i].Path < root.Helmfiles[j].Path
+	})
+	return true
+}
 
-	return helmStates, nil
+// RemoveNestedHelmfile removes the relative path of a nested helmfile from the root helmfile
+// returning true if it was removed
+func RemoveNestedHelmfile(root *state.HelmState, rel string) bool {
+	removed := false
+	for i := 0; i < len(root.Helmfiles); i++ {
+		if root.Helmfiles[i].Path == rel {
+			root.Helmfiles = append(root.Helmfiles[:i], root.Helmfiles[i+1:]...)
+			i--
+			removed = true
+		}
+	}
+	return removed
 }
 
 // SaveHelmfile saves helmfile to a path, overwriting if file exists
@@ -0,0 +1,151 @@
+package ingresses
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Gateways the listeners of the Gateway API and Istio Gateways used to find out if the routes which reference them
+// are exposed over TLS
+type Gateways struct {
+	gateways      map[string][]*listener
+	istioGateways map[string][]*listener
+}
+
+// listener a Gateway API listener or Istio server
+type listener struct {
+	name  string
+	hosts []string
+	tls   bool
+}
+
+// FindGateways finds the Gateway API and Istio Gateways in the YAML files in the directory tree
+func FindGateways(dir string) (*Gateways, error) {
+	answer := &Gateways{
+		gateways:      map[string][]*listener{},
+		istioGateways: map[string][]*listener{},
+	}
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return answer, nil
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+			return nil
+		}
+		node, err := yaml.ReadFile(path)
+		if err != nil {
+			// lets ignore files which are not a single resource
+			return nil
+		}
+		key := kyamls.GetNamespace(node, path) + "/" + kyamls.GetName(node, path)
+		switch {
+		case GatewayHandler.Matches(node, path):
+			answer.gateways[key] = listeners(node, []string{"spec", "listeners"}, []string{"name"}, "hostname", []string{"protocol"})
+		case IstioGatewayHandler.Matches(node, path):
+			answer.istioGateways[key] = listeners(node, []string{"spec", "servers"}, []string{"port", "name"}, "hosts", []string{"port", "protocol"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find gateways in dir %s", dir)
+	}
+	return answer, nil
+}
+
+// GatewayTLS returns true if the Gateway API Gateway exposes the host over TLS. If the section name is specified
+// only the listener of that name is checked
+func (g *Gateways) GatewayTLS(ns, name, sectionName, host string) bool {
+	if g == nil {
+		return false
+	}
+	for _, l := range g.gateways[ns+"/"+name] {
+		if sectionName != "" && l.name != sectionName {
+			continue
+		}
+		if l.tls && l.matches(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// IstioGatewayTLS returns true if the Istio Gateway exposes the host over TLS
+func (g *Gateways) IstioGatewayTLS(ns, name, host string) bool {
+	if g == nil {
+		return false
+	}
+	for _, l := range g.istioGateways[ns+"/"+name] {
+		if l.tls && l.matches(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the listener has no hosts or one of its hosts matches the host
+func (l *listener) matches(host string) bool {
+	if len(l.hosts) == 0 {
+		return true
+	}
+	for _, h := range l.hosts {
+		// lets remove the namespace prefix of Istio hosts
+		h = h[strings.LastIndex(h, "/")+1:]
+		switch {
+		case h == "*" || h == host:
+			return true
+		case strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// listeners returns the listeners or servers of the gateway
+func listeners(node *yaml.RNode, listenersPath, namePath []string, hostField string, protocolPath []string) []*listener {
+	list, err := node.Pipe(yaml.Lookup(listenersPath...))
+	if err != nil || list == nil {
+		return nil
+	}
+	elements, err := list.Elements()
+	if err != nil {
+		return nil
+	}
+	var answer []*listener
+	for _, e := range elements {
+		l := &listener{}
+		n, _ := e.Pipe(yaml.Lookup(namePath...))
+		if n != nil {
+			l.name = n.YNode().Value
+		}
+		hosts, _ := e.Pipe(yaml.Lookup(hostField))
+		if hosts != nil {
+			if hosts.YNode().Kind == yaml.SequenceNode {
+				values, _ := hosts.Elements()
+				for _, v := range values {
+					l.hosts = append(l.hosts, v.YNode().Value)
+				}
+			} else if hosts.YNode().Value != "" {
+				l.hosts = append(l.hosts, hosts.YNode().Value)
+			}
+		}
+		protocol, _ := e.Pipe(yaml.Lookup(protocolPath...))
+		if protocol != nil {
+			l.tls = protocol.YNode().Value == "HTTPS" || protocol.YNode().Value == "TLS"
+		}
+		answer = append(answer, l)
+	}
+	return answer
+}
@@ -0,0 +1,406 @@
+package ingresses
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/services"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	nv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	sigyaml "sigs.k8s.io/yaml"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	// IngressHandler handles Ingress resources
+	IngressHandler = Handler{
+		Name:   "Ingress",
+		Kinds:  kindFilters("networking.k8s.io/v1/Ingress", "networking.k8s.io/v1beta1/Ingress", "extensions/v1beta1/Ingress"),
+		Update: updateIngress,
+		URL: func(data []byte, path string, gateways *Gateways) (string, error) {
+			ing := &nv1.Ingress{}
+			err := sigyaml.Unmarshal(data, ing)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to unmarshal YAML as Ingress in file %s", path)
+			}
+			return services.IngressURL(ing), nil
+		},
+	}
+
+	// HTTPRouteHandler handles Gateway API HTTPRoute resources
+	HTTPRouteHandler = Handler{
+		Name:  "HTTPRoute",
+		Kinds: kindFilters("gateway.networking.k8s.io/HTTPRoute"),
+		Update: func(data []byte, path string, r *Replacer) ([]byte, bool, error) {
+			return updateNode(data, path, func(node *yaml.RNode) (bool, error) {
+				return replaceHosts(node, path, r, "spec", "hostnames")
+			})
+		},
+		URL: func(data []byte, path string, gateways *Gateways) (string, error) {
+			return nodeURL(data, path, func(node *yaml.RNode) string {
+				return httpRouteURL(node, gateways)
+			})
+		},
+	}
+
+	// GatewayHandler handles Gateway API Gateway resources
+	GatewayHandler = Handler{
+		Name:  "Gateway",
+		Kinds: kindFilters("gateway.networking.k8s.io/Gateway"),
+		Update: func(data []byte, path string, r *Replacer) ([]byte, bool, error) {
+			return updateNode(data, path, func(node *yaml.RNode) (bool, error) {
+				return updateServers(node, path, r, []string{"spec", "listeners"}, []string{"hostname"}, []string{"protocol"}, []string{"port"})
+			})
+		},
+		URL: func(data []byte, path string, gateways *Gateways) (string, error) {
+			return nodeURL(data, path, func(node *yaml.RNode) string {
+				return serverURL(node, []string{"spec", "listeners"}, "hostname", []string{"protocol"})
+			})
+		},
+	}
+
+	// VirtualServiceHandler handles Istio VirtualService resources
+	VirtualServiceHandler = Handler{
+		Name:  "VirtualService",
+		Kinds: kindFilters("networking.istio.io/VirtualService"),
+		Update: func(data []byte, path string, r *Replacer) ([]byte, bool, error) {
+			return updateNode(data, path, func(node *yaml.RNode) (bool, error) {
+				return replaceHosts(node, path, r, "spec", "hosts")
+			})
+		},
+		URL: func(data []byte, path string, gateways *Gateways) (string, error) {
+			return nodeURL(data, path, func(node *yaml.RNode) string {
+				return virtualServiceURL(node, gateways)
+			})
+		},
+	}
+
+	// IstioGatewayHandler handles Istio Gateway resources
+	IstioGatewayHandler = Handler{
+		Name:  "Istio Gateway",
+		Kinds: kindFilters("networking.istio.io/Gateway"),
+		Update: func(data []byte, path string, r *Replacer) ([]byte, bool, error) {
+			return updateNode(data, path, func(node *yaml.RNode) (bool, error) {
+				return updateServers(node, path, r, []string{"spec", "servers"}, []string{"hosts"}, []string{"port", "protocol"}, []string{"port", "number"})
+			})
+		},
+		URL: func(data []byte, path string, gateways *Gateways) (string, error) {
+			return nodeURL(data, path, func(node *yaml.RNode) string {
+				return serverURL(node, []string{"spec", "servers"}, "hosts", []string{"port", "protocol"})
+			})
+		},
+	}
+
+	// RouteHandler handles OpenShift Route resources
+	RouteHandler = Handler{
+		Name:  "Route",
+		Kinds: kindFilters("route.openshift.io/Route"),
+		Update: func(data []byte, path string, r *Replacer) ([]byte, bool, error) {
+			return updateNode(data, path, func(node *yaml.RNode) (bool, error) {
+				modified, err := replaceHostField(node, path, r, "spec", "host")
+				if err != nil || !modified || r.TLS {
+					return modified, err
+				}
+				return true, disableTLS(node, path, "spec", "tls")
+			})
+		},
+		URL: func(data []byte, path string, gateways *Gateways) (string, error) {
+			return nodeURL(data, path, func(node *yaml.RNode) string {
+				host, _ := node.GetString("spec.host")
+				scheme := "http"
+				tls, _ := node.Pipe(yaml.Lookup("spec", "tls"))
+				if tls != nil {
+					scheme = "https"
+				}
+				u := hostURL(scheme, host)
+				if u != "" {
+					p, _ := node.GetString("spec.path")
+					u += p
+				}
+				return u
+			})
+		},
+	}
+)
+
+// updateIngress replaces the domain of the rules and TLS hosts of an Ingress
+func updateIngress(data []byte, path string, r *Replacer) ([]byte, bool, error) {
+	ing := &nv1.Ingress{}
+	err := sigyaml.Unmarshal(data, ing)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to unmarshal YAML as Ingress in file %s", path)
+	}
+
+	modified := false
+	s := &ing.Spec
+	for i, rule := range s.Rules {
+		currentHost := rule.Host
+		host := r.ModifyHost(currentHost)
+		if host != "" && host != currentHost {
+			modified = true
+			s.Rules[i].Host = host
+			log.Logger().Infof("ingress at %s updated to %s", info(path), info(host))
+		} else {
+			log.Logger().Infof("ingress at %s does not match domain as is %s", info(path), info(currentHost))
+		}
+	}
+	for i, tls := range s.TLS {
+		hosts := tls.Hosts
+		for j, currentHost := range hosts {
+			host := r.ModifyHost(currentHost)
+			if host != "" && host != currentHost {
+				modified = true
+				if !r.TLS {
+					log.Logger().Infof("ingress at %s disabling TLS", info(path))
+					s.TLS = nil
+					break
+				}
+				log.Logger().Infof("ingress at %s updated to %s", info(path), info(host))
+				hosts[j] = host
+				s.TLS[i].Hosts = hosts
+			} else {
+				log.Logger().Infof("ingress at %s does not match domain as is %s", info(path), info(currentHost))
+			}
+		}
+	}
+	if !modified {
+		return nil, false, nil
+	}
+	data, err = sigyaml.Marshal(ing)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to marshal ingress onject to yaml")
+	}
+	return data, true, nil
+}
+
+// updateServers replaces the domain of the hosts of the listeners of a Gateway API or Istio Gateway, switching
+// any modified HTTPS listeners to HTTP if TLS is disabled
+func updateServers(node *yaml.RNode, path string, r *Replacer, listenersPath, hostPath, protocolPath, portPath []string) (bool, error) {
+	listeners, err := node.Pipe(yaml.Lookup(listenersPath...))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find %s", strings.Join(listenersPath, "."))
+	}
+	if listeners == nil {
+		return false, nil
+	}
+	elements, err := listeners.Elements()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the elements of %s", strings.Join(listenersPath, "."))
+	}
+	modified := false
+	for _, l := range elements {
+		var changed bool
+		hosts, err := l.Pipe(yaml.Lookup(hostPath...))
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to find %s", strings.Join(hostPath, "."))
+		}
+		if hosts == nil {
+			continue
+		}
+		if hosts.YNode().Kind == yaml.SequenceNode {
+			changed, err = replaceGatewayHosts(hosts, path, r)
+			if err != nil {
+				return false, err
+			}
+		} else {
+			changed = replaceHost(hosts.YNode(), path, r)
+		}
+		if !changed {
+			continue
+		}
+		modified = true
+		if r.TLS {
+			continue
+		}
+		err = disableListenerTLS(l, path, protocolPath, portPath)
+		if err != nil {
+			return false, err
+		}
+	}
+	return modified, nil
+}
+
+// replaceGatewayHosts replaces the domain of the Istio Gateway hosts which may be prefixed with a namespace
+func replaceGatewayHosts(hosts *yaml.RNode, path string, r *Replacer) (bool, error) {
+	elements, err := hosts.Elements()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the elements of hosts")
+	}
+	modified := false
+	for _, e := range elements {
+		n := e.YNode()
+		prefix := ""
+		idx := strings.LastIndex(n.Value, "/")
+		if idx >= 0 {
+			prefix = n.Value[0 : idx+1]
+		}
+		host := &yaml.Node{Kind: n.Kind, Value: strings.TrimPrefix(n.Value, prefix)}
+		if replaceHost(host, path, r) {
+			n.Value = prefix + host.Value
+			modified = true
+		}
+	}
+	return modified, nil
+}
+
+// disableListenerTLS switches a HTTPS listener to HTTP removing its TLS configuration
+func disableListenerTLS(l *yaml.RNode, path string, protocolPath, portPath []string) error {
+	protocol, err := l.Pipe(yaml.Lookup(protocolPath...))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find %s", strings.Join(protocolPath, "."))
+	}
+	if protocol != nil && protocol.YNode().Value == "HTTPS" {
+		protocol.YNode().Value = "HTTP"
+		port, err := l.Pipe(yaml.Lookup(portPath...))
+		if err != nil {
+			return errors.Wrapf(err, "failed to find %s", strings.Join(portPath, "."))
+		}
+		if port != nil && port.YNode().Value == "443" {
+			port.YNode().Value = "80"
+		}
+	}
+	return disableTLS(l, path, "tls")
+}
+
+// disableTLS removes the TLS configuration at the given field path
+func disableTLS(node *yaml.RNode, path string, fields ...string) error {
+	parent := node
+	if len(fields) > 1 {
+		var err error
+		parent, err = node.Pipe(yaml.Lookup(fields[0 : len(fields)-1]...))
+		if err != nil {
+			return errors.Wrapf(err, "failed to find %s", strings.Join(fields, "."))
+		}
+		if parent == nil {
+			return nil
+		}
+	}
+	removed, err := parent.Pipe(yaml.Clear(fields[len(fields)-1]))
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove %s", strings.Join(fields, "."))
+	}
+	if removed != nil {
+		log.Logger().Infof("resource at %s disabling TLS", info(path))
+	}
+	return nil
+}
+
+// serverURL returns the URL of the first listener with a host
+func serverURL(node *yaml.RNode, listenersPath []string, hostField string, protocolPath []string) string {
+	listeners, err := node.Pipe(yaml.Lookup(listenersPath...))
+	if err != nil || listeners == nil {
+		return ""
+	}
+	elements, err := listeners.Elements()
+	if err != nil {
+		return ""
+	}
+	for _, l := range elements {
+		var host string
+		hosts, _ := l.Pipe(yaml.Lookup(hostField))
+		if hosts == nil {
+			continue
+		}
+		if hosts.YNode().Kind == yaml.SequenceNode {
+			values, _ := hosts.Elements()
+			for _, v := range values {
+				h := v.YNode().Value
+				h = h[strings.LastIndex(h, "/")+1:]
+				if h != "*" && strings.Contains(h, ".") && !strings.HasPrefix(h, "*") {
+					host = h
+					break
+				}
+			}
+		} else {
+			host = hosts.YNode().Value
+			if strings.HasPrefix(host, "*") {
+				host = ""
+			}
+		}
+		if host == "" {
+			continue
+		}
+		scheme := "http"
+		protocol, _ := l.Pipe(yaml.Lookup(protocolPath...))
+		if protocol != nil && protocol.YNode().Value == "HTTPS" {
+			scheme = "https"
+		}
+		return hostURL(scheme, host)
+	}
+	return ""
+}
+
+// httpRouteURL returns the URL of the first host of the HTTPRoute using https if a parent Gateway exposes it over TLS
+func httpRouteURL(node *yaml.RNode, gateways *Gateways) string {
+	host := firstHost(node, "spec", "hostnames")
+	if host == "" {
+		return ""
+	}
+	scheme := "http"
+	ns := node.GetNamespace()
+	parentRefs, _ := node.Pipe(yaml.Lookup("spec", "parentRefs"))
+	if parentRefs != nil {
+		elements, _ := parentRefs.Elements()
+		for _, ref := range elements {
+			kind, _ := ref.GetString("kind")
+			if kind != "" && kind != "Gateway" {
+				continue
+			}
+			name, _ := ref.GetString("name")
+			gatewayNS, _ := ref.GetString("namespace")
+			if gatewayNS == "" {
+				gatewayNS = ns
+			}
+			sectionName, _ := ref.GetString("sectionName")
+			if gateways.GatewayTLS(gatewayNS, name, sectionName, host) {
+				scheme = "https"
+				break
+			}
+		}
+	}
+	return hostURL(scheme, host)
+}
+
+// virtualServiceURL returns the URL of the first host of the VirtualService using https if it has TLS routes or
+// one of its Istio Gateways exposes it over TLS
+func virtualServiceURL(node *yaml.RNode, gateways *Gateways) string {
+	host := firstHost(node, "spec", "hosts")
+	if host == "" {
+		return ""
+	}
+	tls, _ := node.Pipe(yaml.Lookup("spec", "tls"))
+	if tls != nil && len(tls.Content()) > 0 {
+		return hostURL("https", host)
+	}
+	ns := node.GetNamespace()
+	refs, _ := node.Pipe(yaml.Lookup("spec", "gateways"))
+	if refs != nil {
+		elements, _ := refs.Elements()
+		for _, ref := range elements {
+			name := ref.YNode().Value
+			if name == "mesh" {
+				continue
+			}
+			gatewayNS := ns
+			i := strings.Index(name, "/")
+			if i >= 0 {
+				gatewayNS = name[:i]
+				name = name[i+1:]
+			}
+			if gateways.IstioGatewayTLS(gatewayNS, name, host) {
+				return hostURL("https", host)
+			}
+		}
+	}
+	return hostURL("http", host)
+}
+
+func hostURL(scheme, host string) string {
+	if host == "" {
+		return ""
+	}
+	return scheme + "://" + host
+}
@@ -0,0 +1,176 @@
+package ingresses
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Replacer replaces the domain of the hosts exposed by a resource
+type Replacer struct {
+	// ReplaceDomain the domain suffix of the hosts to replace
+	ReplaceDomain string
+
+	// Domain the new domain
+	Domain string
+
+	// TLS whether TLS is enabled for the new domain
+	TLS bool
+}
+
+// ModifyHost modifies the host name if it matches the replace domain otherwise returns an empty string
+func (r *Replacer) ModifyHost(host string) string {
+	if strings.HasSuffix(host, r.ReplaceDomain) {
+		return strings.TrimSuffix(host, r.ReplaceDomain) + r.Domain
+	}
+	return ""
+}
+
+// Handler updates the domain and discovers the URL of a kind of resource which exposes services
+type Handler struct {
+	// Name the name of the kind of resource for logging
+	Name string
+
+	// Kinds the kind filters of the resources handled
+	Kinds []kyamls.KindFilter
+
+	// Update replaces the domain in the resource returning the new YAML if it was modified
+	Update func(data []byte, path string, r *Replacer) ([]byte, bool, error)
+
+	// URL returns the URL exposed by the resource or an empty string. The gateways are used to find out if the
+	// routes which reference them are exposed over TLS
+	URL func(data []byte, path string, gateways *Gateways) (string, error)
+}
+
+// Matches returns true if the handler handles the given resource
+func (h *Handler) Matches(node *yaml.RNode, path string) bool {
+	for i := range h.Kinds {
+		if h.Kinds[i].Matches(node, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultHandlers the handlers for Ingress, Gateway API, Istio and OpenShift Route resources
+var DefaultHandlers = []Handler{
+	IngressHandler,
+	HTTPRouteHandler,
+	GatewayHandler,
+	VirtualServiceHandler,
+	IstioGatewayHandler,
+	RouteHandler,
+}
+
+// FindHandler finds the handler for the given resource or nil if there is none
+func FindHandler(handlers []Handler, node *yaml.RNode, path string) *Handler {
+	for i := range handlers {
+		if handlers[i].Matches(node, path) {
+			return &handlers[i]
+		}
+	}
+	return nil
+}
+
+// kindFilters parses the kind filters in the form 'apiVersion/kind'
+func kindFilters(texts ...string) []kyamls.KindFilter {
+	var answer []kyamls.KindFilter
+	for _, text := range texts {
+		answer = append(answer, kyamls.ParseKindFilter(text))
+	}
+	return answer
+}
+
+// updateNode parses the YAML and applies the function to it returning the modified YAML
+func updateNode(data []byte, path string, fn func(node *yaml.RNode) (bool, error)) ([]byte, bool, error) {
+	node, err := yaml.Parse(string(data))
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to parse YAML in file %s", path)
+	}
+	modified, err := fn(node)
+	if err != nil || !modified {
+		return nil, modified, err
+	}
+	text, err := node.String()
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to marshal YAML for file %s", path)
+	}
+	return []byte(text), true, nil
+}
+
+// nodeURL parses the YAML and returns the URL from the function
+func nodeURL(data []byte, path string, fn func(node *yaml.RNode) string) (string, error) {
+	node, err := yaml.Parse(string(data))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse YAML in file %s", path)
+	}
+	return fn(node), nil
+}
+
+// replaceHosts replaces the domain of the string values in the sequence at the given field path
+func replaceHosts(node *yaml.RNode, path string, r *Replacer, fields ...string) (bool, error) {
+	modified := false
+	seq, err := node.Pipe(yaml.Lookup(fields...))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find %s", strings.Join(fields, "."))
+	}
+	if seq == nil {
+		return false, nil
+	}
+	elements, err := seq.Elements()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the elements of %s", strings.Join(fields, "."))
+	}
+	for _, e := range elements {
+		if replaceHost(e.YNode(), path, r) {
+			modified = true
+		}
+	}
+	return modified, nil
+}
+
+// replaceHostField replaces the domain of the string value at the given field path
+func replaceHostField(node *yaml.RNode, path string, r *Replacer, fields ...string) (bool, error) {
+	value, err := node.Pipe(yaml.Lookup(fields...))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find %s", strings.Join(fields, "."))
+	}
+	if value == nil {
+		return false, nil
+	}
+	return replaceHost(value.YNode(), path, r), nil
+}
+
+// replaceHost replaces the domain of a host scalar
+func replaceHost(n *yaml.Node, path string, r *Replacer) bool {
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+	currentHost := n.Value
+	host := r.ModifyHost(currentHost)
+	if host == "" || host == currentHost {
+		log.Logger().Debugf("resource at %s does not match domain as is %s", path, currentHost)
+		return false
+	}
+	n.Value = host
+	log.Logger().Infof("resource at %s updated to %s", info(path), info(host))
+	return true
+}
+
+// firstHost returns the first host in the sequence at the given field path
+func firstHost(node *yaml.RNode, fields ...string) string {
+	values, err := node.GetSlice(strings.Join(fields, "."))
+	if err != nil {
+		return ""
+	}
+	for _, v := range values {
+		host, ok := v.(string)
+		if ok && host != "" && host != "*" && strings.Contains(host, ".") {
+			return host
+		}
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package ingresses_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/ingresses"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestHandlerURLs(t *testing.T) {
+	testCases := []struct {
+		dir      string
+		file     string
+		expected string
+	}{
+		{dir: "tls", file: "modify-ingress.yaml", expected: "https://godemo48.my.domain.com"},
+		{dir: "tls", file: "httproute.yaml", expected: "https://godemo48.my.domain.com"},
+		{dir: "tls", file: "gateway.yaml", expected: ""},
+		{dir: "tls", file: "virtualservice.yaml", expected: "https://godemo48.my.domain.com"},
+		{dir: "tls", file: "istio-gateway.yaml", expected: "https://godemo48.my.domain.com"},
+		{dir: "tls", file: "route.yaml", expected: "https://godemo48.my.domain.com/"},
+		{dir: "notls", file: "httproute.yaml", expected: "http://godemo48.my.domain.com"},
+		{dir: "notls", file: "virtualservice.yaml", expected: "http://godemo48.my.domain.com"},
+		{dir: "notls", file: "istio-gateway.yaml", expected: "http://godemo48.my.domain.com"},
+		{dir: "notls", file: "route.yaml", expected: "http://godemo48.my.domain.com/"},
+		{dir: "tls", file: "service.yaml", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		dir := filepath.Join("..", "cmd", "ingress", "testdata", tc.dir, "expected")
+		gateways, err := ingresses.FindGateways(dir)
+		require.NoError(t, err, "failed to find gateways in %s", dir)
+
+		path := filepath.Join(dir, "namespaces", "myapps", tc.file)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err, "failed to read file %s", path)
+		node, err := yaml.Parse(string(data))
+		require.NoError(t, err, "failed to parse file %s", path)
+
+		h := ingresses.FindHandler(ingresses.DefaultHandlers, node, path)
+		if tc.file == "service.yaml" {
+			assert.Nil(t, h, "should not find a handler for %s", path)
+			continue
+		}
+		require.NotNil(t, h, "no handler for %s", path)
+
+		u, err := h.URL(data, path, gateways)
+		require.NoError(t, err, "failed to find URL for %s", path)
+		assert.Equal(t, tc.expected, u, "URL for %s", path)
+	}
+}
+
+func TestHandlerURLsWithoutGateways(t *testing.T) {
+	for _, file := range []string{"httproute.yaml", "virtualservice.yaml"} {
+		path := filepath.Join("..", "cmd", "ingress", "testdata", "tls", "expected", "namespaces", "myapps", file)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err, "failed to read file %s", path)
+		node, err := yaml.Parse(string(data))
+		require.NoError(t, err, "failed to parse file %s", path)
+
+		h := ingresses.FindHandler(ingresses.DefaultHandlers, node, path)
+		require.NotNil(t, h, "no handler for %s", path)
+
+		u, err := h.URL(data, path, nil)
+		require.NoError(t, err, "failed to find URL for %s", path)
+		assert.Equal(t, "http://godemo48.my.domain.com", u, "URL for %s without its gateway", path)
+	}
+}
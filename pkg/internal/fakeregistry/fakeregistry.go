@@ -0,0 +1,261 @@
+package fakeregistry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+const (
+	chartConfigMediaType  = "application/vnd.cncf.helm.config.v1+json"
+	chartContentMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+// Registry a simple in memory OCI distribution registry for use in tests
+type Registry struct {
+	lock      sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]manifest
+	tags      map[string]map[string]bool
+	uploads   map[string]bool
+}
+
+type manifest struct {
+	mediaType string
+	data      []byte
+}
+
+// NewServer creates a new in memory OCI registry HTTP server. The caller should Close() it
+func NewServer() (*httptest.Server, *Registry) {
+	r := &Registry{
+		blobs:     map[string][]byte{},
+		manifests: map[string]manifest{},
+		tags:      map[string]map[string]bool{},
+		uploads:   map[string]bool{},
+	}
+	return httptest.NewServer(r), r
+}
+
+// Host returns the host and port of the server for use in OCI references
+func Host(server *httptest.Server) string {
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// Tags returns the sorted tags for the given repository
+func (r *Registry) Tags(repository string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var answer []string
+	for t := range r.tags[repository] {
+		answer = append(answer, t)
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+// ServeHTTP implements the OCI distribution API
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	path := req.URL.Path
+	if path == "/v2/" || path == "/v2" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	path = strings.TrimPrefix(path, "/v2/")
+
+	switch {
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		tags := []string{}
+		for t := range r.tags[repo] {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+
+	case strings.Contains(path, "/blobs/uploads/"):
+		r.serveUpload(w, req, path)
+
+	case strings.Contains(path, "/blobs/"):
+		idx := strings.LastIndex(path, "/blobs/")
+		d := path[idx+len("/blobs/"):]
+		data, ok := r.blobs[d]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+		w.Header().Set("Docker-Content-Digest", d)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+
+	case strings.Contains(path, "/manifests/"):
+		idx := strings.LastIndex(path, "/manifests/")
+		repo := path[:idx]
+		ref := path[idx+len("/manifests/"):]
+		r.serveManifest(w, req, repo, ref)
+
+	default:
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN")
+	}
+}
+
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, path string) {
+	idx := strings.Index(path, "/blobs/uploads/")
+	repo := path[:idx]
+	id := path[idx+len("/blobs/uploads/"):]
+	d := req.URL.Query().Get("digest")
+
+	switch req.Method {
+	case http.MethodPost:
+		if d != "" {
+			r.storeBlob(w, req, repo, d)
+			return
+		}
+		id = uuid.New().String()
+		r.uploads[id] = true
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+
+	case http.MethodPut:
+		if !r.uploads[id] {
+			writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN")
+			return
+		}
+		delete(r.uploads, id)
+		r.storeBlob(w, req, repo, d)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
+
+func (r *Registry) storeBlob(w http.ResponseWriter, req *http.Request, repo, d string) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID")
+		return
+	}
+	if digestOf(data) != d {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID")
+		return
+	}
+	r.blobs[d] = data
+	w.Header().Set("Location", "/v2/"+repo+"/blobs/"+d)
+	w.Header().Set("Docker-Content-Digest", d)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (r *Registry) serveManifest(w http.ResponseWriter, req *http.Request, repo, ref string) {
+	switch req.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID")
+			return
+		}
+		d := digestOf(data)
+		m := manifest{mediaType: req.Header.Get("Content-Type"), data: data}
+		r.manifests[repo+"@"+d] = m
+		if !strings.HasPrefix(ref, "sha256:") {
+			r.manifests[repo+":"+ref] = m
+			if r.tags[repo] == nil {
+				r.tags[repo] = map[string]bool{}
+			}
+			r.tags[repo][ref] = true
+		}
+		w.Header().Set("Location", "/v2/"+repo+"/manifests/"+d)
+		w.Header().Set("Docker-Content-Digest", d)
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodGet, http.MethodHead:
+		key := repo + ":" + ref
+		if strings.HasPrefix(ref, "sha256:") {
+			key = repo + "@" + ref
+		}
+		m, ok := r.manifests[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(m.data)))
+		w.Header().Set("Docker-Content-Digest", digestOf(m.data))
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(m.data)
+		}
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": strings.ToLower(code)}},
+	})
+}
+
+// PushChart pushes a fake helm chart manifest for the given repository reference and version
+// such as 'localhost:1234/charts/myapp' so it can be signed and verified in tests
+func PushChart(reference, version string) error {
+	repo, err := remote.NewRepository(reference)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse reference %s", reference)
+	}
+	repo.PlainHTTP = true
+
+	ctx := context.Background()
+	configDesc, err := oras.PushBytes(ctx, repo, chartConfigMediaType, []byte(`{"name":"`+path.Base(reference)+`","version":"`+version+`"}`))
+	if err != nil {
+		return errors.Wrap(err, "failed to push chart config")
+	}
+	layerDesc, err := oras.PushBytes(ctx, repo, chartContentMediaType, []byte(reference+":"+version))
+	if err != nil {
+		return errors.Wrap(err, "failed to push chart content")
+	}
+	data, err := json.Marshal(&ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal chart manifest")
+	}
+	_, err = oras.TagBytes(ctx, repo, ocispec.MediaTypeImageManifest, data, version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to push chart manifest %s:%s", reference, version)
+	}
+	return nil
+}
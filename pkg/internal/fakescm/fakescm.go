@@ -0,0 +1,47 @@
+package fakescm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+)
+
+// NewSerialClient creates a fake git provider client whose webhook requests are serialized so that it can be
+// used by parallel workers as the fake driver is not safe for concurrent use
+func NewSerialClient() (*scm.Client, *fake.Data) {
+	client, data := fake.NewDefault()
+	client.Repositories = &serialRepositoryService{RepositoryService: client.Repositories}
+	return client, data
+}
+
+// serialRepositoryService serializes the webhook requests to the repository service
+type serialRepositoryService struct {
+	scm.RepositoryService
+	lock sync.Mutex
+}
+
+func (s *serialRepositoryService) ListHooks(ctx context.Context, repo string, opts *scm.ListOptions) ([]*scm.Hook, *scm.Response, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.RepositoryService.ListHooks(ctx, repo, opts)
+}
+
+func (s *serialRepositoryService) CreateHook(ctx context.Context, repo string, input *scm.HookInput) (*scm.Hook, *scm.Response, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.RepositoryService.CreateHook(ctx, repo, input)
+}
+
+func (s *serialRepositoryService) UpdateHook(ctx context.Context, repo string, input *scm.HookInput) (*scm.Hook, *scm.Response, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.RepositoryService.UpdateHook(ctx, repo, input)
+}
+
+func (s *serialRepositoryService) DeleteHook(ctx context.Context, repo, id string) (*scm.Response, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.RepositoryService.DeleteHook(ctx, repo, id)
+}
@@ -0,0 +1,231 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MigrationsDir the directory in the version stream containing the declarative migrations
+	MigrationsDir = "migrations"
+
+	// RecordFileName the name of the file recording the applied migrations
+	RecordFileName = "migrations.yaml"
+)
+
+// RecordFile the path of the file relative to the git repository recording the applied migrations
+var RecordFile = filepath.Join(".jx", "gitops", RecordFileName)
+
+// Migration a numbered, idempotent upgrade step of a GitOps repository which is only applied once
+type Migration struct {
+	// ID the unique numbered ID of the migration such as '0001-tekton-chart'. Migrations are applied in ID order
+	ID string `json:"id"`
+
+	// Description the optional description of the migration
+	Description string `json:"description,omitempty"`
+
+	// Steps the declarative steps applied to each helmfile
+	Steps []Step `json:"steps,omitempty"`
+
+	// Repository the optional function applied once to the git repository
+	Repository func(ctx *Context) error `json:"-"`
+
+	// Helmfile the optional function applied to each helmfile
+	Helmfile func(ctx *Context, helmState *state.HelmState) error `json:"-"`
+}
+
+// Context the context of applying a migration
+type Context struct {
+	// Dir the root directory of the git repository
+	Dir string
+
+	// HelmfileDir the directory containing the helmfile being migrated
+	HelmfileDir string
+
+	// VersionStreamPath the path to the version stream relative to the helmfile being migrated
+	VersionStreamPath string
+}
+
+// Record the record of the migrations applied to a git repository
+type Record struct {
+	Applied []AppliedMigration `json:"applied,omitempty"`
+}
+
+// AppliedMigration a migration applied to a git repository
+type AppliedMigration struct {
+	ID        string `json:"id"`
+	AppliedAt string `json:"appliedAt,omitempty"`
+}
+
+// HasApplied returns true if the migration has been applied
+func (r *Record) HasApplied(id string) bool {
+	for i := range r.Applied {
+		if r.Applied[i].ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner applies the pending migrations to a git repository
+type Runner struct {
+	Dir     string
+	Record  Record
+	All     []*Migration
+	Pending []*Migration
+}
+
+// NewRunner creates a runner for the given git repository, version stream and registered Go migrations
+func NewRunner(dir, versionStreamDir string, registered []*Migration) (*Runner, error) {
+	all := append([]*Migration{}, registered...)
+	if versionStreamDir != "" {
+		declared, err := LoadMigrations(filepath.Join(versionStreamDir, MigrationsDir))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, declared...)
+	}
+
+	ids := map[string]bool{}
+	for _, m := range all {
+		if m.ID == "" {
+			return nil, errors.Errorf("migration has no id: %s", m.Description)
+		}
+		if ids[m.ID] {
+			return nil, errors.Errorf("duplicate migration id %s", m.ID)
+		}
+		ids[m.ID] = true
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+
+	r := &Runner{
+		Dir: dir,
+		All: all,
+	}
+	path := filepath.Join(dir, RecordFile)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if exists {
+		err = yamls.LoadFile(path, &r.Record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load migration record %s", path)
+		}
+	}
+	for _, m := range all {
+		if !r.Record.HasApplied(m.ID) {
+			r.Pending = append(r.Pending, m)
+		}
+	}
+	return r, nil
+}
+
+// LoadMigrations loads the declarative migrations from the *.yaml files in the given directory.
+// If a migration has no id the file name without the extension is used
+func LoadMigrations(dir string) ([]*Migration, error) {
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+	}
+	if !exists {
+		return nil, nil
+	}
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find migrations in %s", dir)
+	}
+	sort.Strings(fileNames)
+
+	var answer []*Migration
+	for _, path := range fileNames {
+		m := &Migration{}
+		err = yamls.LoadFile(path, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load migration %s", path)
+		}
+		if m.ID == "" {
+			m.ID = strings.TrimSuffix(filepath.Base(path), ".yaml")
+		}
+		for i := range m.Steps {
+			err = m.Steps[i].Validate()
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid step %d of migration %s", i+1, path)
+			}
+		}
+		answer = append(answer, m)
+	}
+	return answer, nil
+}
+
+// ApplyRepository applies the pending migrations to the git repository. This should be called before any helmfiles are migrated
+func (r *Runner) ApplyRepository() error {
+	ctx := &Context{Dir: r.Dir}
+	for _, m := range r.Pending {
+		if m.Repository == nil {
+			continue
+		}
+		err := m.Repository(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply migration %s", m.ID)
+		}
+	}
+	return nil
+}
+
+// ApplyHelmfile applies the pending migrations to the helm state of the helmfile in the given directory
+func (r *Runner) ApplyHelmfile(helmfileDir, versionStreamPath string, helmState *state.HelmState) error {
+	ctx := &Context{
+		Dir:               r.Dir,
+		HelmfileDir:       helmfileDir,
+		VersionStreamPath: versionStreamPath,
+	}
+	for _, m := range r.Pending {
+		for i := range m.Steps {
+			err := m.Steps[i].Apply(ctx, helmState)
+			if err != nil {
+				return errors.Wrapf(err, "failed to apply step %d of migration %s", i+1, m.ID)
+			}
+		}
+		if m.Helmfile != nil {
+			err := m.Helmfile(ctx, helmState)
+			if err != nil {
+				return errors.Wrapf(err, "failed to apply migration %s", m.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// Complete records the pending migrations as applied so they are not applied again
+func (r *Runner) Complete() error {
+	if len(r.Pending) == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, m := range r.Pending {
+		r.Record.Applied = append(r.Record.Applied, AppliedMigration{ID: m.ID, AppliedAt: now})
+	}
+	r.Pending = nil
+
+	path := filepath.Join(r.Dir, RecordFile)
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", path)
+	}
+	err = yamls.SaveFile(&r.Record, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save migration record %s", path)
+	}
+	return nil
+}
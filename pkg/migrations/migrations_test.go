@@ -0,0 +1,100 @@
+package migrations_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/migrations"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := files.CopyDirOverwrite(filepath.Join("testdata", "repo"), tmpDir)
+	require.NoError(t, err, "failed to copy test repository")
+	versionStreamDir := filepath.Join("testdata", "versionStream")
+
+	repositoryCount := 0
+	registered := []*migrations.Migration{
+		{
+			ID: "0001-count",
+			Repository: func(_ *migrations.Context) error {
+				repositoryCount++
+				return nil
+			},
+		},
+		{
+			ID: "0002-add-chart",
+			Helmfile: func(_ *migrations.Context, helmState *state.HelmState) error {
+				if migrations.FindRelease(helmState, "myrepo/added") == nil {
+					helmState.Releases = append(helmState.Releases, state.ReleaseSpec{Chart: "myrepo/added"})
+				}
+				return nil
+			},
+		},
+	}
+
+	r, err := migrations.NewRunner(tmpDir, versionStreamDir, registered)
+	require.NoError(t, err, "failed to create runner")
+	require.Len(t, r.Pending, 3)
+	assert.Equal(t, "0001-count", r.Pending[0].ID)
+	assert.Equal(t, "0002-add-chart", r.Pending[1].ID)
+	assert.Equal(t, "0100-rename-foo", r.Pending[2].ID)
+
+	helmfileDir := filepath.Join(tmpDir, "helmfiles", "jx")
+	helmfilePath := filepath.Join(helmfileDir, "helmfile.yaml")
+	helmStates, err := helmfiles.LoadHelmfile(helmfilePath)
+	require.NoError(t, err, "failed to load helmfile")
+	helmState := helmStates[0]
+
+	require.NoError(t, r.ApplyRepository())
+	require.NoError(t, r.ApplyHelmfile(helmfileDir, "../../versionStream", helmState))
+
+	// applying again should be idempotent
+	require.NoError(t, r.ApplyHelmfile(helmfileDir, "../../versionStream", helmState))
+	require.NoError(t, r.Complete())
+	assert.Equal(t, 1, repositoryCount)
+
+	require.Len(t, helmState.Releases, 3)
+	bar := helmState.Releases[0]
+	assert.Equal(t, "newrepo/bar", bar.Chart)
+	assert.Equal(t, "bar", bar.Namespace)
+	assert.Equal(t, []interface{}{"../../versionStream/charts/newrepo/bar/values.yaml.gotmpl", "values/bar.yaml"}, bar.Values)
+	assert.Equal(t, "myrepo/other", helmState.Releases[1].Chart)
+	assert.Equal(t, "myrepo/added", helmState.Releases[2].Chart)
+
+	require.Len(t, helmState.Repositories, 2)
+	assert.Equal(t, "https://example.com/new", helmState.Repositories[0].URL)
+	assert.Equal(t, "newrepo", helmState.Repositories[1].Name)
+
+	assert.FileExists(t, filepath.Join(helmfileDir, "values", "bar.yaml"))
+	assert.NoFileExists(t, filepath.Join(helmfileDir, "values", "foo.yaml"))
+
+	// lets verify the migrations are only applied once
+	assert.FileExists(t, filepath.Join(tmpDir, migrations.RecordFile))
+	r, err = migrations.NewRunner(tmpDir, versionStreamDir, registered)
+	require.NoError(t, err, "failed to create runner")
+	assert.Empty(t, r.Pending)
+	assert.Len(t, r.Record.Applied, 3)
+
+	require.NoError(t, r.ApplyRepository())
+	assert.Equal(t, 1, repositoryCount)
+}
+
+func TestMigrationsInvalidStep(t *testing.T) {
+	s := migrations.Step{
+		RenameChart:  &migrations.RenameChart{From: "a/b", To: "c/d"},
+		SetNamespace: &migrations.SetNamespace{Chart: "c/d", Namespace: "foo"},
+	}
+	require.Error(t, s.Validate(), "should fail with multiple operations")
+
+	s = migrations.Step{RenameChart: &migrations.RenameChart{From: "a/b"}}
+	require.Error(t, s.Validate(), "should fail without a to chart")
+
+	_, err := migrations.NewRunner(t.TempDir(), "", []*migrations.Migration{{ID: "0001"}, {ID: "0001"}})
+	require.Error(t, err, "should fail with duplicate ids")
+}
@@ -0,0 +1,242 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// versionStreamPrefix the prefix of paths in steps which refer to the version stream
+const versionStreamPrefix = "versionStream/"
+
+// Step a declarative migration step. Only one of the fields should be specified
+type Step struct {
+	// RenameChart renames a chart such as 'jenkins-x/tekton' to 'cdf/tekton-pipeline'
+	RenameChart *RenameChart `json:"renameChart,omitempty"`
+
+	// ReplaceRepositoryURL replaces the URL of a chart repository
+	ReplaceRepositoryURL *ReplaceRepositoryURL `json:"replaceRepositoryURL,omitempty"`
+
+	// MoveValuesFile moves a values file referenced by releases
+	MoveValuesFile *MoveValuesFile `json:"moveValuesFile,omitempty"`
+
+	// SetNamespace sets the namespace of the releases of a chart
+	SetNamespace *SetNamespace `json:"setNamespace,omitempty"`
+}
+
+// RenameChart renames a chart
+type RenameChart struct {
+	// From the chart name to replace such as 'jenkins-x/tekton'
+	From string `json:"from"`
+
+	// To the new chart name such as 'cdf/tekton-pipeline'
+	To string `json:"to"`
+
+	// RepositoryURL the optional URL of the chart repository of the new chart which is added if it is missing
+	RepositoryURL string `json:"repositoryURL,omitempty"`
+
+	// Namespace the optional namespace of the renamed releases
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ReplaceRepositoryURL replaces the URL of a chart repository
+type ReplaceRepositoryURL struct {
+	// Name the optional name of the repository to replace. If blank any repository with the URL is replaced
+	Name string `json:"name,omitempty"`
+
+	// From the URL to replace
+	From string `json:"from"`
+
+	// To the new URL
+	To string `json:"to"`
+}
+
+// MoveValuesFile moves a values file. Paths starting with 'versionStream/' refer to the version stream,
+// otherwise they are relative to the helmfile
+type MoveValuesFile struct {
+	// Chart the optional chart whose releases are updated. If blank all releases are updated
+	Chart string `json:"chart,omitempty"`
+
+	// From the old path of the values file
+	From string `json:"from"`
+
+	// To the new path of the values file
+	To string `json:"to"`
+}
+
+// SetNamespace sets the namespace of the releases of a chart
+type SetNamespace struct {
+	// Chart the name of the chart such as 'cdf/tekton-pipeline'
+	Chart string `json:"chart"`
+
+	// Namespace the namespace to use
+	Namespace string `json:"namespace"`
+}
+
+// Validate validates the step
+func (s *Step) Validate() error {
+	count := 0
+	if s.RenameChart != nil {
+		count++
+		if s.RenameChart.From == "" || s.RenameChart.To == "" {
+			return errors.Errorf("renameChart requires from and to")
+		}
+	}
+	if s.ReplaceRepositoryURL != nil {
+		count++
+		if s.ReplaceRepositoryURL.From == "" || s.ReplaceRepositoryURL.To == "" {
+			return errors.Errorf("replaceRepositoryURL requires from and to")
+		}
+	}
+	if s.MoveValuesFile != nil {
+		count++
+		if s.MoveValuesFile.From == "" || s.MoveValuesFile.To == "" {
+			return errors.Errorf("moveValuesFile requires from and to")
+		}
+	}
+	if s.SetNamespace != nil {
+		count++
+		if s.SetNamespace.Chart == "" || s.SetNamespace.Namespace == "" {
+			return errors.Errorf("setNamespace requires chart and namespace")
+		}
+	}
+	if count != 1 {
+		return errors.Errorf("a step must specify exactly one of renameChart, replaceRepositoryURL, moveValuesFile or setNamespace")
+	}
+	return nil
+}
+
+// Apply applies the step to the helm state
+func (s *Step) Apply(ctx *Context, helmState *state.HelmState) error {
+	switch {
+	case s.RenameChart != nil:
+		s.RenameChart.Apply(helmState)
+	case s.ReplaceRepositoryURL != nil:
+		s.ReplaceRepositoryURL.Apply(helmState)
+	case s.MoveValuesFile != nil:
+		return s.MoveValuesFile.Apply(ctx, helmState)
+	case s.SetNamespace != nil:
+		s.SetNamespace.Apply(helmState)
+	}
+	return nil
+}
+
+// Apply renames the chart in the helm state
+func (s *RenameChart) Apply(helmState *state.HelmState) {
+	found := false
+	for i := range helmState.Releases {
+		release := &helmState.Releases[i]
+		if release.Chart == s.From {
+			release.Chart = s.To
+			if s.Namespace != "" {
+				release.Namespace = s.Namespace
+			}
+			found = true
+		}
+	}
+	if found && s.RepositoryURL != "" {
+		prefix := strings.SplitN(s.To, "/", 2)[0]
+		EnsureRepository(helmState, prefix, s.RepositoryURL)
+	}
+}
+
+// Apply replaces the repository URL in the helm state
+func (s *ReplaceRepositoryURL) Apply(helmState *state.HelmState) {
+	from := strings.TrimSuffix(s.From, "/")
+	for i := range helmState.Repositories {
+		repo := &helmState.Repositories[i]
+		if s.Name != "" && repo.Name != s.Name {
+			continue
+		}
+		if strings.TrimSuffix(repo.URL, "/") == from {
+			repo.URL = s.To
+		}
+	}
+}
+
+// Apply updates the values file references in the helm state and moves any local file
+func (s *MoveValuesFile) Apply(ctx *Context, helmState *state.HelmState) error {
+	from := ctx.resolvePath(s.From)
+	to := ctx.resolvePath(s.To)
+	for i := range helmState.Releases {
+		release := &helmState.Releases[i]
+		if s.Chart != "" && release.Chart != s.Chart {
+			continue
+		}
+		for j := range release.Values {
+			text, ok := release.Values[j].(string)
+			if ok && text == from {
+				release.Values[j] = to
+			}
+		}
+	}
+
+	if strings.HasPrefix(s.From, versionStreamPrefix) || ctx.HelmfileDir == "" {
+		return nil
+	}
+	fromPath := filepath.Join(ctx.HelmfileDir, s.From)
+	exists, err := files.FileExists(fromPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", fromPath)
+	}
+	if !exists {
+		return nil
+	}
+	toPath := filepath.Join(ctx.HelmfileDir, s.To)
+	err = os.MkdirAll(filepath.Dir(toPath), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", toPath)
+	}
+	err = os.Rename(fromPath, toPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to move %s to %s", fromPath, toPath)
+	}
+	log.Logger().Infof("moved values file %s to %s", fromPath, toPath)
+	return nil
+}
+
+// Apply sets the namespace of the releases of the chart in the helm state
+func (s *SetNamespace) Apply(helmState *state.HelmState) {
+	for i := range helmState.Releases {
+		release := &helmState.Releases[i]
+		if release.Chart == s.Chart {
+			release.Namespace = s.Namespace
+		}
+	}
+}
+
+// resolvePath converts paths starting with 'versionStream/' to be relative to the helmfile
+func (c *Context) resolvePath(path string) string {
+	if c.VersionStreamPath != "" && strings.HasPrefix(path, versionStreamPrefix) {
+		return c.VersionStreamPath + "/" + strings.TrimPrefix(path, versionStreamPrefix)
+	}
+	return path
+}
+
+// EnsureRepository adds the repository to the helm state if there is no repository with the name
+func EnsureRepository(helmState *state.HelmState, name, url string) {
+	for i := range helmState.Repositories {
+		if helmState.Repositories[i].Name == name {
+			return
+		}
+	}
+	helmState.Repositories = append(helmState.Repositories, state.RepositorySpec{
+		Name: name,
+		URL:  url,
+	})
+}
+
+// FindRelease returns the first release of the chart or nil if there is none
+func FindRelease(helmState *state.HelmState, chart string) *state.ReleaseSpec {
+	for i := range helmState.Releases {
+		if helmState.Releases[i].Chart == chart {
+			return &helmState.Releases[i]
+		}
+	}
+	return nil
+}
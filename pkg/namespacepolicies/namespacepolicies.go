@@ -0,0 +1,341 @@
+package namespacepolicies
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// ResourcesDirName the directory inside the namespace directory which contains the generated policy resources
+	ResourcesDirName = "namespace-policy"
+
+	// ResourceName the name of the generated ResourceQuota and LimitRange
+	ResourceName = "namespace-policy"
+
+	// NetworkPolicyName the name of the generated default deny NetworkPolicy
+	NetworkPolicyName = "default-deny"
+)
+
+// DefaultPath the default path of the namespace policies file relative to the root of the git repository
+var DefaultPath = filepath.Join(".jx", "gitops", v1alpha1.NamespacePoliciesFileName)
+
+// Load loads the namespace policies from the given file. If the file does not exist there are no policies
+func Load(path string) (*v1alpha1.NamespacePolicies, error) {
+	config := &v1alpha1.NamespacePolicies{}
+	if path == "" {
+		return config, nil
+	}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return config, nil
+	}
+	err = yamls.LoadFile(path, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load NamespacePolicies file %s", path)
+	}
+	return config, nil
+}
+
+// Resolve merges the policies which match the namespace in order. Returns nil if no policy matches
+func Resolve(config *v1alpha1.NamespacePolicies, ns string) (*v1alpha1.NamespacePolicy, error) {
+	policies, err := config.Spec.PoliciesFor(ns)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	answer := &v1alpha1.NamespacePolicy{}
+	for _, p := range policies {
+		if answer.Name == "" {
+			answer.Name = p.Name
+		} else if p.Name != "" {
+			answer.Name += "," + p.Name
+		}
+		answer.Labels = mergeMaps(answer.Labels, p.Labels)
+		answer.Annotations = mergeMaps(answer.Annotations, p.Annotations)
+		if p.ResourceQuota != nil {
+			answer.ResourceQuota = p.ResourceQuota
+		}
+		if p.LimitRange != nil {
+			answer.LimitRange = p.LimitRange
+		}
+		if p.NetworkPolicy != nil {
+			if answer.NetworkPolicy == nil {
+				answer.NetworkPolicy = &v1alpha1.NamespaceNetworkPolicy{}
+			}
+			answer.NetworkPolicy.DenyIngress = answer.NetworkPolicy.DenyIngress || p.NetworkPolicy.DenyIngress
+			answer.NetworkPolicy.DenyEgress = answer.NetworkPolicy.DenyEgress || p.NetworkPolicy.DenyEgress
+		}
+	}
+	return answer, nil
+}
+
+// ApplyToNamespace adds the labels and annotations of the policy to the Namespace resource
+func ApplyToNamespace(namespace *corev1.Namespace, policy *v1alpha1.NamespacePolicy) {
+	if policy == nil {
+		return
+	}
+	namespace.Labels = mergeMaps(namespace.Labels, policy.Labels)
+	namespace.Annotations = mergeMaps(namespace.Annotations, policy.Annotations)
+}
+
+// ApplyToNode adds the labels and annotations of the policy to the Namespace resource node.
+// Returns true if the node was modified
+func ApplyToNode(node *yaml.RNode, policy *v1alpha1.NamespacePolicy) (bool, error) {
+	if policy == nil {
+		return false, nil
+	}
+	modified := false
+	labels := node.GetLabels()
+	for _, k := range slices.Sorted(maps.Keys(policy.Labels)) {
+		v := policy.Labels[k]
+		if labels[k] == v {
+			continue
+		}
+		err := node.PipeE(yaml.SetLabel(k, v))
+		if err != nil {
+			return modified, errors.Wrapf(err, "failed to set label %s", k)
+		}
+		modified = true
+	}
+	annotations := node.GetAnnotations()
+	for _, k := range slices.Sorted(maps.Keys(policy.Annotations)) {
+		v := policy.Annotations[k]
+		if annotations[k] == v {
+			continue
+		}
+		err := node.PipeE(yaml.SetAnnotation(k, v))
+		if err != nil {
+			return modified, errors.Wrapf(err, "failed to set annotation %s", k)
+		}
+		modified = true
+	}
+	return modified, nil
+}
+
+// SaveResources generates the ResourceQuota, LimitRange and NetworkPolicy of the policy into the
+// namespace-policy directory of the given namespace directory, removing any previously generated resources
+func SaveResources(namespaceDir, ns string, policy *v1alpha1.NamespacePolicy) error {
+	dir := filepath.Join(namespaceDir, ResourcesDirName)
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove dir %s", dir)
+	}
+	if policy == nil {
+		return nil
+	}
+
+	resources := map[string]interface{}{}
+	if policy.ResourceQuota != nil {
+		resources["resourcequota.yaml"] = &corev1.ResourceQuota{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ResourceQuota"},
+			ObjectMeta: metav1.ObjectMeta{Name: ResourceName, Namespace: ns},
+			Spec:       *policy.ResourceQuota,
+		}
+	}
+	if policy.LimitRange != nil {
+		resources["limitrange.yaml"] = &corev1.LimitRange{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "LimitRange"},
+			ObjectMeta: metav1.ObjectMeta{Name: ResourceName, Namespace: ns},
+			Spec:       *policy.LimitRange,
+		}
+	}
+	networkPolicy := defaultDenyNetworkPolicy(ns, policy)
+	if networkPolicy != nil {
+		resources["networkpolicy.yaml"] = networkPolicy
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	for name, resource := range resources {
+		path := filepath.Join(dir, name)
+		err = yamls.SaveFile(resource, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", path)
+		}
+	}
+	return nil
+}
+
+// defaultDenyNetworkPolicy returns the default deny NetworkPolicy of the policy or nil if it denies no traffic
+func defaultDenyNetworkPolicy(ns string, policy *v1alpha1.NamespacePolicy) *networkingv1.NetworkPolicy {
+	np := policy.NetworkPolicy
+	if np == nil || (!np.DenyIngress && !np.DenyEgress) {
+		return nil
+	}
+	networkPolicy := &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: NetworkPolicyName, Namespace: ns},
+	}
+	if np.DenyIngress {
+		networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+	}
+	if np.DenyEgress {
+		networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	}
+	return networkPolicy
+}
+
+// Verify returns a message for each namespace in the config root directory which is missing any of the
+// labels, annotations or resources of the policies which match it
+func Verify(config *v1alpha1.NamespacePolicies, configRootDir string) ([]string, error) {
+	clusterDir := filepath.Join(configRootDir, "cluster")
+	namespacesDir := filepath.Join(configRootDir, "namespaces")
+
+	nodes := map[string]*yaml.RNode{}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		nodes[kyamls.GetName(node, path)] = node
+		return false, nil
+	}
+	err := kyamls.ModifyFiles(clusterDir, modifyFn, kyamls.Filter{Kinds: []string{"Namespace"}})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the Namespace resources in dir %s", clusterDir)
+	}
+
+	var answer []string
+	for _, ns := range slices.Sorted(maps.Keys(nodes)) {
+		node := nodes[ns]
+		policy, err := Resolve(config, ns)
+		if err != nil {
+			return nil, err
+		}
+		if policy == nil {
+			if config.Spec.RequirePolicy {
+				answer = append(answer, fmt.Sprintf("namespace %s does not match any policy", ns))
+			}
+			continue
+		}
+
+		labels := node.GetLabels()
+		for _, k := range slices.Sorted(maps.Keys(policy.Labels)) {
+			if labels[k] != policy.Labels[k] {
+				answer = append(answer, fmt.Sprintf("namespace %s is missing label %s=%s", ns, k, policy.Labels[k]))
+			}
+		}
+		annotations := node.GetAnnotations()
+		for _, k := range slices.Sorted(maps.Keys(policy.Annotations)) {
+			if annotations[k] != policy.Annotations[k] {
+				answer = append(answer, fmt.Sprintf("namespace %s is missing annotation %s=%s", ns, k, policy.Annotations[k]))
+			}
+		}
+
+		resources := map[string]*yaml.RNode{}
+		resourceFn := func(node *yaml.RNode, path string) (bool, error) {
+			resources[kyamls.GetKind(node, path)+"/"+kyamls.GetName(node, path)] = node
+			return false, nil
+		}
+		dir := filepath.Join(namespacesDir, ns)
+		exists, err := files.DirExists(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check if dir exists %s", dir)
+		}
+		if exists {
+			err = kyamls.ModifyFiles(dir, resourceFn, kyamls.Filter{Kinds: []string{"ResourceQuota", "LimitRange", "NetworkPolicy"}})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to find the policy resources in dir %s", dir)
+			}
+		}
+
+		if policy.ResourceQuota != nil {
+			rq := &corev1.ResourceQuota{}
+			found, err := loadResource(resources, "ResourceQuota", ResourceName, rq)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				answer = append(answer, fmt.Sprintf("namespace %s is missing a ResourceQuota", ns))
+			} else if !equality.Semantic.DeepEqual(*policy.ResourceQuota, rq.Spec) {
+				answer = append(answer, fmt.Sprintf("namespace %s has a ResourceQuota %s which does not match the policy", ns, ResourceName))
+			}
+		}
+		if policy.LimitRange != nil {
+			lr := &corev1.LimitRange{}
+			found, err := loadResource(resources, "LimitRange", ResourceName, lr)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				answer = append(answer, fmt.Sprintf("namespace %s is missing a LimitRange", ns))
+			} else if !equality.Semantic.DeepEqual(*policy.LimitRange, lr.Spec) {
+				answer = append(answer, fmt.Sprintf("namespace %s has a LimitRange %s which does not match the policy", ns, ResourceName))
+			}
+		}
+		expected := defaultDenyNetworkPolicy(ns, policy)
+		if expected != nil {
+			networkPolicy := &networkingv1.NetworkPolicy{}
+			found, err := loadResource(resources, "NetworkPolicy", NetworkPolicyName, networkPolicy)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				answer = append(answer, fmt.Sprintf("namespace %s is missing a default deny NetworkPolicy", ns))
+			} else if !networkPolicySpecMatches(&expected.Spec, &networkPolicy.Spec) {
+				answer = append(answer, fmt.Sprintf("namespace %s has a NetworkPolicy %s which does not match the policy", ns, NetworkPolicyName))
+			}
+		}
+	}
+	return answer, nil
+}
+
+// loadResource loads the resource of the given kind and name into the object returning false if there is no such resource
+func loadResource(resources map[string]*yaml.RNode, kind, name string, object interface{}) (bool, error) {
+	node := resources[kind+"/"+name]
+	if node == nil {
+		return false, nil
+	}
+	text, err := node.String()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to marshal %s %s", kind, name)
+	}
+	err = sigsyaml.Unmarshal([]byte(text), object)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to unmarshal %s %s", kind, name)
+	}
+	return true, nil
+}
+
+// networkPolicySpecMatches returns true if the specs are the same ignoring the order of the policy types
+func networkPolicySpecMatches(expected, actual *networkingv1.NetworkPolicySpec) bool {
+	e := expected.DeepCopy()
+	a := actual.DeepCopy()
+	slices.Sort(e.PolicyTypes)
+	slices.Sort(a.PolicyTypes)
+	return equality.Semantic.DeepEqual(e, a)
+}
+
+func mergeMaps(m, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return m
+	}
+	if m == nil {
+		m = map[string]string{}
+	}
+	for k, v := range overrides {
+		m[k] = v
+	}
+	return m
+}
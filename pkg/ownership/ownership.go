@@ -0,0 +1,173 @@
+package ownership
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+)
+
+// FileName the name of the ownership index file
+const FileName = "ownership.yaml"
+
+// DefaultPath the path of the ownership index relative to the git repository. It is kept out of the
+// config-root directory so that it is never applied to the cluster
+var DefaultPath = filepath.Join(".jx", "gitops", FileName)
+
+// Resource a cluster scoped resource and the helm release which owns it
+type Resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Release    string `json:"release"`
+	Namespace  string `json:"namespace"`
+	Path       string `json:"path,omitempty"`
+}
+
+// Group returns the API group of the resource
+func (r *Resource) Group() string {
+	i := strings.LastIndex(r.APIVersion, "/")
+	if i < 0 {
+		return ""
+	}
+	return r.APIVersion[0:i]
+}
+
+// Key returns the unique key of the resource in the cluster
+func (r *Resource) Key() string {
+	return fmt.Sprintf("%s/%s/%s", r.Group(), r.Kind, r.Name)
+}
+
+// Owner returns the namespace and name of the owning release
+func (r *Resource) Owner() string {
+	return r.Namespace + "/" + r.Release
+}
+
+// Index the cluster scoped resources and their owning releases
+type Index struct {
+	Resources []Resource `json:"resources,omitempty"`
+}
+
+// RemoveMissing removes the resources whose files no longer exist in the given output directory
+// such as those of releases which have been removed
+func (i *Index) RemoveMissing(outputDir string) error {
+	var answer []Resource
+	for k := range i.Resources {
+		r := i.Resources[k]
+		if r.Path != "" {
+			exists, err := files.FileExists(filepath.Join(outputDir, filepath.FromSlash(r.Path)))
+			if err != nil {
+				return errors.Wrapf(err, "failed to check if file exists %s", r.Path)
+			}
+			if !exists {
+				continue
+			}
+		}
+		answer = append(answer, r)
+	}
+	i.Resources = answer
+	return nil
+}
+
+// Conflict a cluster scoped resource which is rendered by more than one release
+type Conflict struct {
+	Key    string
+	Owners []string
+}
+
+// String returns a description of the conflict
+func (c *Conflict) String() string {
+	return fmt.Sprintf("%s is rendered by releases %s", c.Key, strings.Join(c.Owners, ", "))
+}
+
+// ReplaceOwners replaces the resources owned by the given releases with the resources
+func (i *Index) ReplaceOwners(owners map[string]bool, resources []Resource) {
+	var answer []Resource
+	for k := range i.Resources {
+		r := i.Resources[k]
+		if !owners[r.Owner()] {
+			answer = append(answer, r)
+		}
+	}
+	answer = append(answer, resources...)
+	sort.SliceStable(answer, func(a, b int) bool {
+		r1 := answer[a]
+		r2 := answer[b]
+		if r1.Key() != r2.Key() {
+			return r1.Key() < r2.Key()
+		}
+		return r1.Owner() < r2.Owner()
+	})
+	i.Resources = answer
+}
+
+// Conflicts returns the resources which are owned by more than one release
+func (i *Index) Conflicts() []Conflict {
+	owners := map[string][]string{}
+	var keys []string
+	for k := range i.Resources {
+		r := &i.Resources[k]
+		key := r.Key()
+		list := owners[key]
+		if len(list) == 0 {
+			keys = append(keys, key)
+		}
+		owner := r.Owner()
+		found := false
+		for _, o := range list {
+			if o == owner {
+				found = true
+				break
+			}
+		}
+		if !found {
+			owners[key] = append(list, owner)
+		}
+	}
+	sort.Strings(keys)
+
+	var answer []Conflict
+	for _, key := range keys {
+		list := owners[key]
+		if len(list) > 1 {
+			sort.Strings(list)
+			answer = append(answer, Conflict{Key: key, Owners: list})
+		}
+	}
+	return answer
+}
+
+// LoadIndex loads the index from the ownership file returning an empty index if it does not exist
+func LoadIndex(path string) (*Index, error) {
+	index := &Index{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return index, nil
+	}
+	err = yamls.LoadFile(path, index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	return index, nil
+}
+
+// SaveIndex saves the index into the ownership file
+func SaveIndex(index *Index, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", path)
+	}
+	err = yamls.SaveFile(index, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
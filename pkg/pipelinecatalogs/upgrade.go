@@ -0,0 +1,146 @@
+package pipelinecatalogs
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/pkg/errors"
+)
+
+const tagRefPrefix = "refs/tags/"
+
+var commitSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Change a pipeline file changed between two versions of a pipeline catalog
+type Change struct {
+	// Status the git status letter such as A, M or D
+	Status string
+	Path   string
+}
+
+// IsCommitSHA returns true if the git reference is a full commit SHA
+func IsCommitSHA(ref string) bool {
+	return commitSHARegex.MatchString(ref)
+}
+
+// IsVersionTag returns true if the git reference looks like a semantic version tag such as 'v1.2.3'
+func IsVersionTag(ref string) bool {
+	_, err := semver.NewVersion(ref)
+	return err == nil
+}
+
+// IsPinned returns true if the pipeline catalog is pinned to a commit SHA or a version tag
+// rather than a branch which can change without the cluster git repository changing
+func IsPinned(repo *v1alpha1.PipelineCatalogSource) bool {
+	return IsCommitSHA(repo.GitRef) || IsVersionTag(repo.GitRef)
+}
+
+// RemoteTags returns the commit SHA of each tag in the remote git repository using 'git ls-remote'
+func RemoteTags(g gitclient.Interface, gitURL string) (map[string]string, error) {
+	text, err := g.Command("", "ls-remote", "--tags", gitURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the tags of %s", gitURL)
+	}
+	answer := map[string]string{}
+	peeled := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], tagRefPrefix) {
+			continue
+		}
+		tag := strings.TrimPrefix(fields[1], tagRefPrefix)
+
+		// annotated tags are followed by the commit they point to
+		if strings.HasSuffix(tag, "^{}") {
+			peeled[strings.TrimSuffix(tag, "^{}")] = fields[0]
+			continue
+		}
+		answer[tag] = fields[0]
+	}
+	for tag, sha := range peeled {
+		answer[tag] = sha
+	}
+	return answer, nil
+}
+
+// LatestTag returns the tag with the highest semantic version matching the optional constraint such as '^1.2'.
+// Pre-release versions are ignored unless the constraint includes a pre-release
+func LatestTag(tags map[string]string, constraint string) (string, error) {
+	var c *semver.Constraints
+	if constraint != "" {
+		var err error
+		c, err = semver.NewConstraint(constraint)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid version constraint %s", constraint)
+		}
+	}
+	answer := ""
+	var latest *semver.Version
+	for tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		if c != nil {
+			if !c.Check(v) {
+				continue
+			}
+		} else if v.Prerelease() != "" {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) || (v.Equal(latest) && tag < answer) {
+			latest = v
+			answer = tag
+		}
+	}
+	return answer, nil
+}
+
+// Changelog returns the pipeline files which changed between the two git references of the remote repository
+func Changelog(g gitclient.Interface, gitURL, fromRef, toRef string) ([]Change, error) {
+	dir, err := os.MkdirTemp("", "jx-pipeline-catalog-")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = g.Command(dir, "clone", "--quiet", "--filter=blob:none", "--no-checkout", gitURL, ".")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to clone %s", gitURL)
+	}
+	from, err := resolveRef(g, dir, fromRef)
+	if err != nil {
+		return nil, err
+	}
+	to, err := resolveRef(g, dir, toRef)
+	if err != nil {
+		return nil, err
+	}
+	text, err := g.Command(dir, "diff", "--no-renames", "--name-status", from, to, "--", "*.yaml", "*.yml")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to diff %s and %s of %s", fromRef, toRef, gitURL)
+	}
+	var answer []Change
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			answer = append(answer, Change{Status: fields[0], Path: fields[1]})
+		}
+	}
+	return answer, nil
+}
+
+// resolveRef resolves a commit SHA, tag or remote branch name to a commit in the cloned repository
+func resolveRef(g gitclient.Interface, dir, ref string) (string, error) {
+	for _, r := range []string{ref, "origin/" + ref} {
+		sha, err := g.Command(dir, "rev-parse", "--verify", "--quiet", r+"^{commit}")
+		if err == nil && sha != "" {
+			return strings.TrimSpace(sha), nil
+		}
+	}
+	return "", errors.Errorf("failed to find git reference %s", ref)
+}
@@ -12,17 +12,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// GetHelmBinary returns the path to the locally installed helm 3 extension
+// GetHelmBinary returns the path to the locally installed helm 3 extension.
+// If the version is empty or the default version then the version in the plugin lock file is used if there is one
 func GetHelmBinary(version string) (string, error) {
-	if version == "" {
-		version = HelmVersion
-	}
 	pluginBinDir, err := PluginBinDir()
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to find plugin home dir")
 	}
-	plugin := CreateHelmPlugin(version)
-	return extensions.EnsurePluginInstalled(plugin, pluginBinDir)
+	return ensurePluginInstalled(HelmPluginName, version, HelmVersion, pluginBinDir, CreateHelmPlugin)
 }
 
 // PluginBinDir returns the plugin dir
@@ -41,6 +38,21 @@ func PluginBinDirFunc(fn func(string) string) (string, error) {
 	return homedir.PluginBinDir("", ".jx")
 }
 
+// GitopsPluginBinDir returns the plugin dir used for the kpt, kubectl and kapp plugins
+func GitopsPluginBinDir() (string, error) {
+	return homedir.PluginBinDir(os.Getenv("JX_GITOPS_HOME"), ".jx-gitops")
+}
+
+// PluginBinDirFor returns the directory the plugin of the given name is installed into
+func PluginBinDirFor(name string) (string, error) {
+	switch name {
+	case KptPluginName, KubectlPluginName, KappPluginName:
+		return GitopsPluginBinDir()
+	default:
+		return PluginBinDir()
+	}
+}
+
 // CreateHelmPlugin creates the helm 3 plugin
 func CreateHelmPlugin(version string) jenkinsv1.Plugin {
 	binaries := extensions.CreateBinaries(func(p extensions.Platform) string {
@@ -64,15 +76,11 @@ func CreateHelmPlugin(version string) jenkinsv1.Plugin {
 
 // GetHelmfileBinary returns the path to the locally installed helmfile extension
 func GetHelmfileBinary(version string) (string, error) {
-	if version == "" {
-		version = HelmfileVersion
-	}
 	pluginBinDir, err := PluginBinDir()
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to find plugin home dir")
 	}
-	plugin := CreateHelmfilePlugin(version)
-	return extensions.EnsurePluginInstalled(plugin, pluginBinDir)
+	return ensurePluginInstalled(HelmfilePluginName, version, HelmfileVersion, pluginBinDir, CreateHelmfilePlugin)
 }
 
 // CreateHelmfilePlugin creates the helmfile plugin
@@ -119,15 +127,11 @@ func CreateKustomizePlugin(version string) jenkinsv1.Plugin {
 
 // GetKptBinary returns the path to the locally installed kpt 3 extension
 func GetKptBinary(version string) (string, error) {
-	if version == "" {
-		version = KptVersion
-	}
-	pluginBinDir, err := homedir.PluginBinDir(os.Getenv("JX_GITOPS_HOME"), ".jx-gitops")
+	pluginBinDir, err := GitopsPluginBinDir()
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to find plugin home dir")
 	}
-	plugin := CreateKptPlugin(version)
-	return extensions.EnsurePluginInstalled(plugin, pluginBinDir)
+	return ensurePluginInstalled(KptPluginName, version, KptVersion, pluginBinDir, CreateKptPlugin)
 }
 
 // CreateKptPlugin creates the kpt 3 plugin
@@ -153,15 +157,11 @@ func CreateKptPlugin(version string) jenkinsv1.Plugin {
 
 // GetKubectlBinary returns the path to the locally installed kpt 3 extension
 func GetKubectlBinary(version string) (string, error) {
-	if version == "" {
-		version = KubectlVersion
-	}
-	pluginBinDir, err := homedir.PluginBinDir(os.Getenv("JX_GITOPS_HOME"), ".jx-gitops")
+	pluginBinDir, err := GitopsPluginBinDir()
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to find plugin home dir")
 	}
-	plugin := CreateKubectlPlugin(version)
-	return extensions.EnsurePluginInstalled(plugin, pluginBinDir)
+	return ensurePluginInstalled(KubectlPluginName, version, KubectlVersion, pluginBinDir, CreateKubectlPlugin)
 }
 
 // CreateKubectlPlugin creates the kpt 3 plugin
@@ -187,15 +187,11 @@ func CreateKubectlPlugin(version string) jenkinsv1.Plugin {
 
 // GetKappBinary returns the path to the locally installed kpt 3 extension
 func GetKappBinary(version string) (string, error) {
-	if version == "" {
-		version = KappVersion
-	}
-	pluginBinDir, err := homedir.PluginBinDir(os.Getenv("JX_GITOPS_HOME"), ".jx-gitops")
+	pluginBinDir, err := GitopsPluginBinDir()
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to find plugin home dir")
 	}
-	plugin := CreateKappPlugin(version)
-	return extensions.EnsurePluginInstalled(plugin, pluginBinDir)
+	return ensurePluginInstalled(KappPluginName, version, KappVersion, pluginBinDir, CreateKappPlugin)
 }
 
 // CreateKappPlugin creates the kpt 3 plugin
@@ -0,0 +1,324 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// LockFileName the name of the plugin lock file
+	LockFileName = "plugins-lock.yaml"
+
+	// LockFileEnvVar the environment variable to specify the plugin lock file
+	LockFileEnvVar = "JX_GITOPS_PLUGIN_LOCK"
+)
+
+var (
+	// VersionStreamLockFile the location of the lock file in the version stream of a cluster git repository
+	VersionStreamLockFile = filepath.Join("versionStream", "plugins", LockFileName)
+
+	//go:embed plugins-lock.yaml
+	defaultLockFileData []byte
+
+	// verifiedBinaries caches the verified plugin binaries by path so they are only hashed once
+	verifiedBinaries sync.Map
+
+	// unlockedPlugins the plugins we have already warned have no checksum
+	unlockedPlugins sync.Map
+)
+
+// verifiedBinary the file details of a plugin binary when it was verified against its checksum
+type verifiedBinary struct {
+	checksum string
+	size     int64
+	modTime  time.Time
+}
+
+// LockFile the pinned versions and binary checksums of the plugins
+type LockFile struct {
+	Plugins []LockedPlugin `json:"plugins,omitempty"`
+}
+
+// LockedPlugin the pinned version and SHA-256 checksums of the binary of a plugin for each platform
+type LockedPlugin struct {
+	// Name the name of the plugin
+	Name string `json:"name"`
+
+	// Version the version of the plugin
+	Version string `json:"version"`
+
+	// Checksums the SHA-256 checksums of the binary indexed by 'os/arch' such as 'linux/amd64'
+	Checksums map[string]string `json:"checksums,omitempty"`
+}
+
+// Find finds the locked plugin for the given name
+func (l *LockFile) Find(name string) *LockedPlugin {
+	if l == nil {
+		return nil
+	}
+	for i := range l.Plugins {
+		if l.Plugins[i].Name == name {
+			return &l.Plugins[i]
+		}
+	}
+	return nil
+}
+
+// Version returns the locked version of the plugin or the default version
+func (l *LockFile) Version(name, defaultVersion string) string {
+	p := l.Find(name)
+	if p != nil && p.Version != "" {
+		return p.Version
+	}
+	return defaultVersion
+}
+
+// Checksum returns the checksum of the plugin binary for the version and platform or an empty string
+func (l *LockFile) Checksum(name, version, platform string) string {
+	p := l.Find(name)
+	if p == nil || p.Version != version {
+		return ""
+	}
+	return p.Checksums[platform]
+}
+
+// SetChecksum records the checksum of the plugin binary for the version and platform
+func (l *LockFile) SetChecksum(name, version, platform, checksum string) {
+	p := l.Find(name)
+	if p == nil {
+		l.Plugins = append(l.Plugins, LockedPlugin{Name: name})
+		p = &l.Plugins[len(l.Plugins)-1]
+	}
+	if p.Version != version {
+		p.Version = version
+		p.Checksums = nil
+	}
+	if p.Checksums == nil {
+		p.Checksums = map[string]string{}
+	}
+	p.Checksums[platform] = checksum
+	sort.Slice(l.Plugins, func(i, j int) bool {
+		return l.Plugins[i].Name < l.Plugins[j].Name
+	})
+}
+
+// CurrentPlatform returns the platform key of the current operating system and architecture
+func CurrentPlatform() string {
+	return strings.ToLower(runtime.GOOS) + "/" + strings.ToLower(runtime.GOARCH)
+}
+
+// LockFilePath returns the lock file specified via $JX_GITOPS_PLUGIN_LOCK or the version stream lock file if it
+// exists in the current directory. Returns an empty string if there is no lock file
+func LockFilePath() (string, error) {
+	path := os.Getenv(LockFileEnvVar)
+	if path != "" {
+		return path, nil
+	}
+	exists, err := files.FileExists(VersionStreamLockFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to check if file exists %s", VersionStreamLockFile)
+	}
+	if exists {
+		return VersionStreamLockFile, nil
+	}
+	return "", nil
+}
+
+// LoadLockFile loads the lock file at the given path returning an empty lock file if the path is empty or missing
+func LoadLockFile(path string) (*LockFile, error) {
+	lock := &LockFile{}
+	if path == "" {
+		return lock, nil
+	}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return lock, nil
+	}
+	err = yamls.LoadFile(path, lock)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load plugin lock file %s", path)
+	}
+	return lock, nil
+}
+
+// SaveLockFile saves the lock file
+func SaveLockFile(lock *LockFile, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", path)
+	}
+	err = yamls.SaveFile(lock, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save plugin lock file %s", path)
+	}
+	return nil
+}
+
+// EmbeddedLockFile returns the lock file of the checksums of the default plugin versions embedded in the binary.
+// Entries for any other version are ignored so that the default versions are never overridden by the embedded lock file
+func EmbeddedLockFile() (*LockFile, error) {
+	lock := &LockFile{}
+	err := yaml.Unmarshal(defaultLockFileData, lock)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the embedded plugin lock file")
+	}
+	var answer []LockedPlugin
+	for _, p := range lock.Plugins {
+		if DefaultVersions[p.Name] == p.Version {
+			answer = append(answer, p)
+		}
+	}
+	lock.Plugins = answer
+	return lock, nil
+}
+
+// DefaultLockFile loads the lock file from $JX_GITOPS_PLUGIN_LOCK or the version stream falling back to the
+// embedded lock file of the default plugin versions
+func DefaultLockFile() (*LockFile, error) {
+	path, err := LockFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return EmbeddedLockFile()
+	}
+	return LoadLockFile(path)
+}
+
+// FileChecksum returns the hex encoded SHA-256 checksum of the file
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open file %s", path)
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read file %s", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PluginPath returns the path of the installed binary of the plugin in the plugin bin dir
+func PluginPath(plugin *jenkinsv1.Plugin, pluginBinDir string) string {
+	return filepath.Join(pluginBinDir, fmt.Sprintf("%s-%s", plugin.Spec.Name, plugin.Spec.Version))
+}
+
+// VerifyPlugin verifies the checksum of the installed binary of the plugin against the lock file.
+// Returns false if there is no checksum for the plugin version and platform in the lock file
+func VerifyPlugin(plugin *jenkinsv1.Plugin, path string, lock *LockFile) (bool, error) {
+	expected := lock.Checksum(plugin.Spec.Name, plugin.Spec.Version, CurrentPlatform())
+	if expected == "" {
+		return false, nil
+	}
+	actual, err := FileChecksum(path)
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return false, errors.Errorf("checksum of plugin %s version %s at %s is %s but expected %s", plugin.Spec.Name, plugin.Spec.Version, path, actual, expected)
+	}
+	return true, nil
+}
+
+// InstallPlugin ensures the plugin is installed and its binary matches the checksum in the lock file.
+// A binary which does not match is removed so that it is not executed. A binary which has already been
+// verified is not hashed again unless it has been modified
+func InstallPlugin(plugin jenkinsv1.Plugin, pluginBinDir string, lock *LockFile) (string, error) {
+	path, err := extensions.EnsurePluginInstalled(plugin, pluginBinDir)
+	if err != nil {
+		return path, err
+	}
+	platform := CurrentPlatform()
+	expected := lock.Checksum(plugin.Spec.Name, plugin.Spec.Version, platform)
+	if expected == "" {
+		key := plugin.Spec.Name + " " + plugin.Spec.Version + " " + platform
+		if _, warned := unlockedPlugins.LoadOrStore(key, true); !warned {
+			log.Logger().Warnf("the binary of plugin %s version %s on %s cannot be verified as there is no checksum in the plugin lock file", plugin.Spec.Name, plugin.Spec.Version, platform)
+		}
+		return path, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to stat plugin binary %s", path)
+	}
+	if v, ok := verifiedBinaries.Load(path); ok {
+		cached := v.(verifiedBinary)
+		if cached.checksum == expected && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+			return path, nil
+		}
+	}
+
+	_, err = VerifyPlugin(&plugin, path, lock)
+	if err != nil {
+		verifiedBinaries.Delete(path)
+		removeErr := os.Remove(path)
+		if removeErr != nil {
+			log.Logger().Warnf("failed to remove plugin binary %s: %s", path, removeErr.Error())
+		}
+		return "", err
+	}
+	verifiedBinaries.Store(path, verifiedBinary{checksum: expected, size: stat.Size(), modTime: stat.ModTime()})
+	return path, nil
+}
+
+// ensurePluginInstalled installs the plugin using the default lock file to pin the version and verify the binary.
+// The default version is replaced by the locked version so that a version stream can pin different versions.
+// The binary of a default version must have a checksum in the lock file or the embedded lock file
+func ensurePluginInstalled(name, version, defaultVersion, pluginBinDir string, createFn func(string) jenkinsv1.Plugin) (string, error) {
+	lock, err := DefaultLockFile()
+	if err != nil {
+		return "", err
+	}
+	if version == "" || version == defaultVersion {
+		version = lock.Version(name, defaultVersion)
+	}
+	if version == defaultVersion {
+		platform := CurrentPlatform()
+		if lock.Checksum(name, version, platform) == "" {
+			lock, err = EmbeddedLockFile()
+			if err != nil {
+				return "", err
+			}
+		}
+		if lock.Checksum(name, version, platform) == "" {
+			return "", errors.Errorf("cannot install plugin %s version %s on %s as there is no checksum for it in the plugin lock file", name, version, platform)
+		}
+	}
+	return InstallPlugin(createFn(version), pluginBinDir, lock)
+}
+
+// LockedPlugins returns the default plugins using the versions pinned in the lock file
+func LockedPlugins(lock *LockFile) []jenkinsv1.Plugin {
+	return []jenkinsv1.Plugin{
+		CreateHelmPlugin(lock.Version(HelmPluginName, HelmVersion)),
+		CreateHelmfilePlugin(lock.Version(HelmfilePluginName, HelmfileVersion)),
+		CreateKptPlugin(lock.Version(KptPluginName, KptVersion)),
+		CreateKubectlPlugin(lock.Version(KubectlPluginName, KubectlVersion)),
+		CreateKappPlugin(lock.Version(KappPluginName, KappVersion)),
+		CreateKustomizePlugin(lock.Version(KustomizePluginName, KustomizeVersion)),
+	}
+}
@@ -0,0 +1,133 @@
+package plugins_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInstallPluginVerifiesChecksum(t *testing.T) {
+	name := "fakeplugin"
+	version := "1.2.3"
+	binary := []byte("#!/bin/sh\necho fake\n")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	archive := createTarGz(t, name, binary)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	plugin := jenkinsv1.Plugin{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: jenkinsv1.PluginSpec{
+			SubCommand: name,
+			Binaries: extensions.CreateBinaries(func(_ extensions.Platform) string {
+				return server.URL + "/" + name + ".tar.gz"
+			}),
+			Name:    name,
+			Version: version,
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		checksum    string
+		expectError bool
+	}{
+		{name: "matching", checksum: checksum},
+		{name: "unlocked"},
+		{name: "mismatch", checksum: "0000", expectError: true},
+	}
+	for _, tc := range testCases {
+		binDir := t.TempDir()
+		lock := &plugins.LockFile{}
+		if tc.checksum != "" {
+			lock.SetChecksum(name, version, plugins.CurrentPlatform(), tc.checksum)
+		}
+
+		path, err := plugins.InstallPlugin(plugin, binDir, lock)
+		expectedPath := filepath.Join(binDir, name+"-"+version)
+		if tc.expectError {
+			require.Error(t, err, "should fail to install for %s", tc.name)
+			assert.NoFileExists(t, expectedPath, "should have removed the binary for %s", tc.name)
+			continue
+		}
+		require.NoError(t, err, "failed to install for %s", tc.name)
+		assert.Equal(t, expectedPath, path, "path for %s", tc.name)
+		assert.FileExists(t, path, "binary for %s", tc.name)
+	}
+}
+
+func TestLockFileVersions(t *testing.T) {
+	lock, err := plugins.LoadLockFile(filepath.Join("testdata", plugins.LockFileName))
+	require.NoError(t, err, "failed to load lock file")
+
+	assert.Equal(t, "3.14.0", lock.Version(plugins.HelmPluginName, plugins.HelmVersion))
+	assert.Equal(t, plugins.KptVersion, lock.Version(plugins.KptPluginName, plugins.KptVersion))
+	assert.Equal(t, "abcd", lock.Checksum(plugins.HelmPluginName, "3.14.0", "linux/amd64"))
+	assert.Empty(t, lock.Checksum(plugins.HelmPluginName, plugins.HelmVersion, "linux/amd64"), "should not use checksums of other versions")
+
+	for _, p := range plugins.LockedPlugins(lock) {
+		if p.Name == plugins.HelmPluginName {
+			assert.Equal(t, "3.14.0", p.Spec.Version)
+		}
+	}
+}
+
+func TestEmbeddedLockFile(t *testing.T) {
+	lock, err := plugins.EmbeddedLockFile()
+	require.NoError(t, err, "failed to load the embedded lock file")
+
+	expected := map[string]string{
+		plugins.HelmPluginName:      plugins.HelmVersion,
+		plugins.HelmfilePluginName:  plugins.HelmfileVersion,
+		plugins.KptPluginName:       plugins.KptVersion,
+		plugins.KubectlPluginName:   plugins.KubectlVersion,
+		plugins.KappPluginName:      plugins.KappVersion,
+		plugins.KustomizePluginName: plugins.KustomizeVersion,
+	}
+	for name, version := range expected {
+		p := lock.Find(name)
+		require.NotNil(t, p, "embedded lock file should contain plugin %s", name)
+		assert.Equal(t, version, p.Version, "embedded lock file version of plugin %s", name)
+	}
+}
+
+func TestEmbeddedLockFileUsesDefaultVersions(t *testing.T) {
+	lock, err := plugins.EmbeddedLockFile()
+	require.NoError(t, err, "failed to load the embedded lock file")
+
+	for _, p := range plugins.LockedPlugins(lock) {
+		assert.Equal(t, plugins.DefaultVersions[p.Spec.Name], p.Spec.Version, "version of plugin %s", p.Spec.Name)
+	}
+}
+
+func createTarGz(t *testing.T, name string, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(data))})
+	require.NoError(t, err, "failed to write tar header")
+	_, err = tw.Write(data)
+	require.NoError(t, err, "failed to write tar data")
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
@@ -56,6 +56,16 @@ var (
 		CreateKustomizePlugin(KustomizeVersion),
 	}
 
+	// DefaultVersions the default version of each plugin. These are only overridden by an explicit lock file
+	DefaultVersions = map[string]string{
+		HelmPluginName:      HelmVersion,
+		HelmfilePluginName:  HelmfileVersion,
+		KptPluginName:       KptVersion,
+		KubectlPluginName:   KubectlVersion,
+		KappPluginName:      KappVersion,
+		KustomizePluginName: KustomizeVersion,
+	}
+
 	// HelmPlugins to install and upgrade
 	HelmPlugins = []HelmPlugin{
 		{"https://github.com/mumoshu/helm-x", "x"},
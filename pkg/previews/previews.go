@@ -0,0 +1,286 @@
+package previews
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig"
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/sourceconfigs"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/naming"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultNamespace the default template of the preview namespace
+	DefaultNamespace = "jx-{{ .Owner }}-{{ .Repository }}-pr-{{ .PullRequest }}"
+
+	// TTLAnnotation the annotation added to the preview resources with the time to live of the preview
+	TTLAnnotation = "preview.jenkins-x.io/ttl"
+
+	// PullRequestAnnotation the annotation added to the preview resources with the pull request
+	PullRequestAnnotation = "preview.jenkins-x.io/pull-request"
+
+	// MarkerFileName the file in the preview helmfile directory containing the pull request of the preview.
+	// Only directories containing it are overwritten or removed
+	MarkerFileName = ".preview"
+)
+
+var previewNamespaceRegex = regexp.MustCompile(`^jx-.+-pr-\d+$`)
+
+// IsPreviewNamespace returns true if the namespace is labelled as a preview environment or is named like a preview
+func IsPreviewNamespace(ns *corev1.Namespace) bool {
+	if ns.Labels[kube.LabelEnvironment] == "preview" {
+		return true
+	}
+	return strings.HasPrefix(ns.Name, "jx-preview-") || previewNamespaceRegex.MatchString(ns.Name)
+}
+
+// Preview the details of a preview environment for a pull request
+type Preview struct {
+	Owner       string
+	Repository  string
+	PullRequest int
+	Namespace   string
+}
+
+// NewPreview creates the preview for the pull request evaluating the namespace template
+func NewPreview(tmpl *v1alpha1.PreviewTemplate, owner, repository string, pullRequest int) (*Preview, error) {
+	p := &Preview{
+		Owner:       owner,
+		Repository:  repository,
+		PullRequest: pullRequest,
+	}
+	text := tmpl.Namespace
+	if text == "" {
+		text = DefaultNamespace
+	}
+	ns, err := p.evaluate("namespace", text)
+	if err != nil {
+		return nil, err
+	}
+	p.Namespace = naming.ToValidName(strings.TrimSpace(ns))
+	if p.Namespace == "" {
+		return nil, errors.Errorf("the namespace template %s evaluated to an empty namespace", text)
+	}
+	return p, nil
+}
+
+// PullRequestName returns the name of the pull request of the preview such as 'myorg/myapp#123'
+func (p *Preview) PullRequestName() string {
+	return fmt.Sprintf("%s/%s#%d", p.Owner, p.Repository, p.PullRequest)
+}
+
+// IsPreviewDir returns true if the directory contains the marker of this preview
+func (p *Preview) IsPreviewDir(dir string) (bool, error) {
+	path := filepath.Join(dir, MarkerFileName)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	return strings.TrimSpace(string(data)) == p.PullRequestName(), nil
+}
+
+// WriteMarker writes the marker of this preview into the directory
+func (p *Preview) WriteMarker(dir string) error {
+	path := filepath.Join(dir, MarkerFileName)
+	err := os.WriteFile(path, []byte(p.PullRequestName()+"\n"), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// HelmfilePath returns the path of the preview helmfile relative to the root helmfile
+func (p *Preview) HelmfilePath() string {
+	return filepath.Join("helmfiles", p.Namespace, "helmfile.yaml")
+}
+
+// Render renders the helmfile snippet of the template for the preview
+func (p *Preview) Render(tmpl *v1alpha1.PreviewTemplate) ([]*state.HelmState, error) {
+	if tmpl.Helmfile == "" {
+		return nil, errors.Errorf("the preview template has no helmfile")
+	}
+	if tmpl.TTL != "" {
+		_, err := time.ParseDuration(tmpl.TTL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse preview ttl %s", tmpl.TTL)
+		}
+	}
+	text, err := p.evaluate("helmfile", tmpl.Helmfile)
+	if err != nil {
+		return nil, err
+	}
+	helmStates, err := helmfiles.ParseHelmfile(strings.NewReader(text))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the preview helmfile")
+	}
+
+	annotations := map[string]interface{}{
+		PullRequestAnnotation: p.PullRequestName(),
+	}
+	if tmpl.TTL != "" {
+		annotations[TTLAnnotation] = tmpl.TTL
+	}
+	for _, hs := range helmStates {
+		hs.OverrideNamespace = p.Namespace
+		for i := range hs.Releases {
+			release := &hs.Releases[i]
+			release.Transformers = append(release.Transformers, map[string]interface{}{
+				"apiVersion": "builtin",
+				"kind":       "AnnotationsTransformer",
+				"metadata": map[string]interface{}{
+					"name": "preview-annotations",
+				},
+				"annotations": annotations,
+				"fieldSpecs": []interface{}{
+					map[string]interface{}{
+						"path":   "metadata/annotations",
+						"create": true,
+					},
+				},
+			})
+		}
+	}
+	return helmStates, nil
+}
+
+func (p *Preview) evaluate(name, text string) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Funcs(sprig.TxtFuncMap()).Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse preview %s template", name)
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, p)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate preview %s template", name)
+	}
+	return buf.String(), nil
+}
+
+// Options the common options for finding the preview of a pull request
+type Options struct {
+	Dir         string
+	Helmfile    string
+	Owner       string
+	Repository  string
+	PullRequest int
+}
+
+// AddFlags adds the CLI flags
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the root helmfile and source config")
+	cmd.Flags().StringVarP(&o.Helmfile, "helmfile", "", "helmfile.yaml", "the root helmfile relative to the directory")
+	cmd.Flags().StringVarP(&o.Owner, "owner", "o", "", "the owner of the repository. Defaults to $REPO_OWNER")
+	cmd.Flags().StringVarP(&o.Repository, "repo", "r", "", "the name of the repository. Defaults to $REPO_NAME")
+	cmd.Flags().IntVarP(&o.PullRequest, "pr", "", 0, "the pull request number. Defaults to $PULL_NUMBER")
+}
+
+// FindPreview finds the preview template of the repository and the preview of the pull request
+func (o *Options) FindPreview() (*v1alpha1.PreviewTemplate, *Preview, error) {
+	if o.Owner == "" {
+		o.Owner = os.Getenv("REPO_OWNER")
+	}
+	if o.Repository == "" {
+		o.Repository = os.Getenv("REPO_NAME")
+	}
+	if o.PullRequest <= 0 {
+		text := os.Getenv("PULL_NUMBER")
+		if text != "" {
+			var err error
+			o.PullRequest, err = strconv.Atoi(text)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to parse $PULL_NUMBER %s", text)
+			}
+		}
+	}
+	if o.Owner == "" {
+		return nil, nil, options.MissingOption("owner")
+	}
+	if o.Repository == "" {
+		return nil, nil, options.MissingOption("repo")
+	}
+	if o.PullRequest <= 0 {
+		return nil, nil, options.MissingOption("pr")
+	}
+
+	config, err := sourceconfigs.LoadSourceConfig(o.Dir, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load source config")
+	}
+	tmpl := sourceconfigs.FindPreviewTemplate(config, o.Owner, o.Repository)
+	if tmpl == nil {
+		return nil, nil, errors.Errorf("no preview template in the group of repository %s/%s in %s", o.Owner, o.Repository, sourceconfigs.SourceConfigFile)
+	}
+	p, err := NewPreview(tmpl, o.Owner, o.Repository, o.PullRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+	envNamespaces, err := o.EnvironmentNamespaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	if stringhelpers.StringArrayIndex(envNamespaces, p.Namespace) >= 0 {
+		return nil, nil, errors.Errorf("the preview namespace %s of %s is the namespace of an environment", p.Namespace, p.PullRequestName())
+	}
+	return tmpl, p, nil
+}
+
+// EnvironmentNamespaces returns the namespaces of the environments in the requirements which can never be previews
+func (o *Options) EnvironmentNamespaces() ([]string, error) {
+	answer := []string{jxcore.DefaultNamespace}
+	path := filepath.Join(o.Dir, jxcore.RequirementsConfigFileName)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check if file exists %s", path)
+	}
+	if !exists {
+		return answer, nil
+	}
+	requirements, _, err := jxcore.LoadRequirementsConfig(o.Dir, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load requirements in dir %s", o.Dir)
+	}
+	for i := range requirements.Spec.Environments {
+		env := &requirements.Spec.Environments[i]
+		ns := env.Namespace
+		if ns == "" {
+			ns = "jx-" + env.Key
+		}
+		answer = append(answer, ns)
+	}
+	return answer, nil
+}
+
+// LoadRootHelmfile loads the root helmfile
+func (o *Options) LoadRootHelmfile() (string, []*state.HelmState, error) {
+	path := filepath.Join(o.Dir, o.Helmfile)
+	helmStates, err := helmfiles.LoadHelmfile(path)
+	if err != nil {
+		return path, nil, errors.Wrapf(err, "failed to load helmfile %s", path)
+	}
+	return path, helmStates, nil
+}
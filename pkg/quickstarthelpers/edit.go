@@ -0,0 +1,200 @@
+package quickstarthelpers
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/apis/gitops/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+)
+
+// SaveQuickstarts validates the quickstarts configuration, removes any duplicate imports and saves it
+func SaveQuickstarts(qs *v1alpha1.Quickstarts, fileName string) error {
+	DeduplicateImports(&qs.Spec)
+	for i := range qs.Spec.Imports {
+		_, err := qs.Spec.Imports[i].Matcher()
+		if err != nil {
+			return errors.Wrapf(err, "invalid import of %s", qs.Spec.Imports[i].File)
+		}
+	}
+	if qs.APIVersion == "" {
+		qs.APIVersion = "project.jenkins-x.io/v1alpha1"
+	}
+	if qs.Kind == "" {
+		qs.Kind = "Quickstarts"
+	}
+	err := os.MkdirAll(filepath.Dir(fileName), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", fileName)
+	}
+	err = yamls.SaveFile(qs, fileName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", fileName)
+	}
+	return nil
+}
+
+// AddQuickstart adds the quickstart replacing any existing quickstart with the same ID and excludes the ID from any
+// import which matches it so that the quickstart is only resolved once.
+// Returns true if an existing quickstart was replaced and the files of the imports which were modified
+func AddQuickstart(spec *v1alpha1.QuickstartsSpec, q *v1alpha1.QuickstartSource, dir string) (bool, []string, error) {
+	id := QuickstartID(spec, q)
+	imports, err := ExcludeFromImports(spec, id, dir)
+	if err != nil {
+		return false, imports, errors.Wrapf(err, "failed to exclude quickstart %s from the imports", id)
+	}
+	for i := range spec.Quickstarts {
+		if QuickstartID(spec, &spec.Quickstarts[i]) == id {
+			spec.Quickstarts[i] = *q
+			return true, imports, nil
+		}
+	}
+	spec.Quickstarts = append(spec.Quickstarts, *q)
+	return false, imports, nil
+}
+
+// QuickstartID returns the ID of the quickstart defaulting the owner without modifying the quickstart or configuration
+func QuickstartID(spec *v1alpha1.QuickstartsSpec, q *v1alpha1.QuickstartSource) string {
+	defaults := v1alpha1.QuickstartsSpec{DefaultOwner: spec.DefaultOwner}
+	copied := *q
+	defaults.DefaultValues(&copied)
+	return copied.ID
+}
+
+// RemoveQuickstart removes the custom quickstart with the given ID. Returns true if it was removed
+func RemoveQuickstart(spec *v1alpha1.QuickstartsSpec, id string) bool {
+	for i := range spec.Quickstarts {
+		if QuickstartID(spec, &spec.Quickstarts[i]) == id {
+			spec.Quickstarts = append(spec.Quickstarts[:i], spec.Quickstarts[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AddImport adds the import merging its includes into any existing import of the same file with the same excludes
+func AddImport(spec *v1alpha1.QuickstartsSpec, imp *v1alpha1.QuickstartImport) {
+	spec.Imports = append(spec.Imports, *imp)
+	DeduplicateImports(spec)
+}
+
+// RemoveImport removes the imports of the given file. Returns true if any were removed
+func RemoveImport(spec *v1alpha1.QuickstartsSpec, file string) bool {
+	var imports []v1alpha1.QuickstartImport
+	for i := range spec.Imports {
+		if spec.Imports[i].File != file {
+			imports = append(imports, spec.Imports[i])
+		}
+	}
+	removed := len(imports) != len(spec.Imports)
+	spec.Imports = imports
+	return removed
+}
+
+// ExcludeFromImports excludes the quickstart ID from any import which matches it.
+// Returns the files of the imports which were modified
+func ExcludeFromImports(spec *v1alpha1.QuickstartsSpec, id, dir string) ([]string, error) {
+	var answer []string
+	for i := range spec.Imports {
+		imp := &spec.Imports[i]
+		m, err := imp.Matcher()
+		if err != nil {
+			return answer, errors.Wrapf(err, "invalid import of %s", imp.File)
+		}
+		quickstarts, err := spec.LoadImports(imp, m, dir)
+		if err != nil {
+			return answer, errors.Wrapf(err, "failed to load imports of %s", imp.File)
+		}
+		for j := range quickstarts {
+			if quickstarts[j].ID == id {
+				imp.Excludes = stringhelpers.EnsureStringArrayContains(imp.Excludes, "^"+regexp.QuoteMeta(id)+"$")
+				answer = append(answer, imp.File)
+				break
+			}
+		}
+	}
+	return answer, nil
+}
+
+// DeduplicateImports merges any imports of the same file which have the same excludes so that their includes are combined.
+// Imports of the same file with different excludes are kept separate so that a quickstart is only excluded by
+// the imports which exclude it
+func DeduplicateImports(spec *v1alpha1.QuickstartsSpec) {
+	var imports []v1alpha1.QuickstartImport
+	for i := range spec.Imports {
+		imp := spec.Imports[i]
+		idx := -1
+		for j := range imports {
+			if imports[j].File == imp.File && sameStrings(imports[j].Excludes, imp.Excludes) {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 {
+			imports = append(imports, imp)
+			continue
+		}
+		existing := &imports[idx]
+		if len(existing.Include) == 0 || len(imp.Include) == 0 {
+			// one of the imports includes everything
+			existing.Include = nil
+		} else {
+			for _, s := range imp.Include {
+				existing.Include = stringhelpers.EnsureStringArrayContains(existing.Include, s)
+			}
+		}
+	}
+	spec.Imports = imports
+}
+
+// sameStrings returns true if the slices contain the same values ignoring order and duplicates
+func sameStrings(a, b []string) bool {
+	for _, s := range a {
+		if stringhelpers.StringArrayIndex(b, s) < 0 {
+			return false
+		}
+	}
+	for _, s := range b {
+		if stringhelpers.StringArrayIndex(a, s) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveQuickstarts returns the custom quickstarts and the quickstarts matched by the imports with any missing values defaulted
+func ResolveQuickstarts(qs *v1alpha1.Quickstarts, dir string) ([]v1alpha1.QuickstartSource, error) {
+	spec := &qs.Spec
+	var answer []v1alpha1.QuickstartSource
+	defaults := v1alpha1.QuickstartsSpec{DefaultOwner: spec.DefaultOwner}
+	for i := range spec.Quickstarts {
+		q := spec.Quickstarts[i]
+		defaults.DefaultValues(&q)
+		answer = append(answer, q)
+	}
+	imported := map[string]bool{}
+	for i := range spec.Imports {
+		imp := &spec.Imports[i]
+		m, err := imp.Matcher()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid import of %s", imp.File)
+		}
+		quickstarts, err := spec.LoadImports(imp, m, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load imports of %s", imp.File)
+		}
+		// separate imports of the same file may match the same quickstart
+		for j := range quickstarts {
+			key := imp.File + "/" + quickstarts[j].ID
+			if !imported[key] {
+				imported[key] = true
+				answer = append(answer, quickstarts[j])
+			}
+		}
+	}
+	return answer, nil
+}
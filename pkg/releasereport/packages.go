@@ -0,0 +1,155 @@
+package releasereport
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/pipelinecatalogs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+)
+
+// kptfile the parts of a Kptfile we report on for both the kpt.dev/v1alpha1 and kpt.dev/v1 formats
+type kptfile struct {
+	Metadata struct {
+		Name string `json:"name,omitempty"`
+	} `json:"metadata,omitempty"`
+	Upstream struct {
+		Git kptGit `json:"git,omitempty"`
+	} `json:"upstream,omitempty"`
+	UpstreamLock struct {
+		Git kptGit `json:"git,omitempty"`
+	} `json:"upstreamLock,omitempty"`
+}
+
+type kptGit struct {
+	Repo      string `json:"repo,omitempty"`
+	Directory string `json:"directory,omitempty"`
+	Ref       string `json:"ref,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+}
+
+// FindPackages finds the kpt packages in the directory tree sorted by path
+func FindPackages(dir string) ([]*PackageInfo, error) {
+	var answer []*PackageInfo
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "Kptfile" {
+			return nil
+		}
+		p, err := LoadPackage(path)
+		if err != nil {
+			return err
+		}
+		p.Path, err = filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the relative path of %s", path)
+		}
+		answer = append(answer, p)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find Kptfiles in dir %s", dir)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Path < answer[j].Path
+	})
+	return answer, nil
+}
+
+// LoadPackage loads the package information from the Kptfile preferring the upstream lock if present
+func LoadPackage(path string) (*PackageInfo, error) {
+	kf := &kptfile{}
+	err := yamls.LoadFile(path, kf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load Kptfile %s", path)
+	}
+	upstream := &kf.Upstream.Git
+	lock := &kf.UpstreamLock.Git
+	answer := &PackageInfo{
+		Name:       kf.Metadata.Name,
+		Repository: upstream.Repo,
+		Directory:  upstream.Directory,
+		Ref:        upstream.Ref,
+		Commit:     upstream.Commit,
+	}
+	if lock.Repo != "" {
+		answer.Repository = lock.Repo
+	}
+	if lock.Commit != "" {
+		answer.Commit = lock.Commit
+	}
+	return answer, nil
+}
+
+// SetLatestTag sets the latest tag of the upstream git repository given its tags and commit SHAs.
+//
+// A package whose ref is a tag is behind if that tag has a lower version than the latest tag. A package whose ref
+// is a commit SHA is compared using the tag of that commit, if there is one. A package whose ref is a branch
+// follows the branch rather than the tags so it is marked as tracking a branch and is never reported as behind
+func (p *PackageInfo) SetLatestTag(tags map[string]string) error {
+	latest, err := pipelinecatalogs.LatestTag(tags, "")
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the latest tag of %s", p.Repository)
+	}
+	p.LatestTag = latest
+	p.Behind = false
+	p.Branch = false
+
+	var current string
+	switch {
+	case tags[p.Ref] != "":
+		current = p.Ref
+	case p.Ref == "" || pipelinecatalogs.IsCommitSHA(p.Ref):
+		sha := p.Commit
+		if sha == "" {
+			sha = p.Ref
+		}
+		current, err = latestTagOfCommit(tags, sha)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the tag of commit %s of %s", sha, p.Repository)
+		}
+	default:
+		// a version like ref which is not a known tag may have been deleted upstream so is not a branch
+		if _, err := semver.NewVersion(p.Ref); err != nil {
+			p.Branch = true
+		}
+		return nil
+	}
+	if latest == "" || current == "" || tags[current] == tags[latest] {
+		return nil
+	}
+	latestVersion, err := semver.NewVersion(latest)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the version of tag %s", latest)
+	}
+	v, err := semver.NewVersion(current)
+	if err == nil && v.LessThan(latestVersion) {
+		p.Behind = true
+	}
+	return nil
+}
+
+// latestTagOfCommit returns the latest tag of the commit SHA or a blank string if it is not tagged
+func latestTagOfCommit(tags map[string]string, sha string) (string, error) {
+	if sha == "" {
+		return "", nil
+	}
+	commitTags := map[string]string{}
+	for tag, s := range tags {
+		if s == sha {
+			commitTags[tag] = s
+		}
+	}
+	return pipelinecatalogs.LatestTag(commitTags, "")
+}
@@ -12,6 +12,9 @@ type NamespaceReleases struct {
 	Path      string         `json:"path,omitempty"`
 	Namespace string         `json:"namespace,omitempty"`
 	Releases  []*ReleaseInfo `json:"releases,omitempty"`
+
+	// Packages the kpt packages in the repository. These are stored in a separate entry without a namespace
+	Packages []*PackageInfo `json:"packages,omitempty"`
 }
 
 // ReleaseInfo information about the release
@@ -48,6 +51,28 @@ type IngressInfo struct {
 	URL  string `json:"url,omitempty"`
 }
 
+// PackageInfo information about a kpt package and its upstream git repository
+type PackageInfo struct {
+	// Name the name of the package
+	Name string `json:"name,omitempty"`
+	// Path the relative path of the directory containing the Kptfile
+	Path string `json:"path,omitempty"`
+	// Repository the upstream git repository URL
+	Repository string `json:"repository,omitempty"`
+	// Directory the directory in the upstream git repository
+	Directory string `json:"directory,omitempty"`
+	// Ref the upstream git reference such as a branch, tag or commit SHA
+	Ref string `json:"ref,omitempty"`
+	// Commit the upstream git commit SHA the package was last fetched from
+	Commit string `json:"commit,omitempty"`
+	// LatestTag the latest semantic version tag of the upstream git repository if known
+	LatestTag string `json:"latestTag,omitempty"`
+	// Behind whether the package is older than the latest tag of the upstream git repository
+	Behind bool `json:"behind,omitempty"`
+	// Branch whether the ref is a branch so that the package is not compared with the latest tag
+	Branch bool `json:"branch,omitempty"`
+}
+
 func (i *ReleaseInfo) String() string {
 	answer := fmt.Sprintf("%s version: %s", i.Name, i.Version)
 	if i.Home != "" {
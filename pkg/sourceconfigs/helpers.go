@@ -362,6 +362,22 @@ func FindSettings(config *v1alpha1.SourceConfig, owner, repoName string) *jxcore
 	return nil
 }
 
+// FindPreviewTemplate finds the preview template of the group containing the given owner and repository name
+func FindPreviewTemplate(config *v1alpha1.SourceConfig, owner, repoName string) *v1alpha1.PreviewTemplate {
+	for i := range config.Spec.Groups {
+		group := &config.Spec.Groups[i]
+		if group.Owner != owner || group.Preview == nil {
+			continue
+		}
+		for j := range group.Repositories {
+			if group.Repositories[j].Name == repoName {
+				return group.Preview
+			}
+		}
+	}
+	return nil
+}
+
 // RemoveRepository removes the repositories with the given name optionally matching on the owner
 func RemoveRepository(config *v1alpha1.SourceConfig, owner, repoName string) bool {
 	modified := false